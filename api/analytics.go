@@ -0,0 +1,201 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxAnalyticsMonths = 36
+	maxAnalyticsYears  = 5
+	// maxDailyAnalyticsDays keeps a series with a point per day to a year.
+	maxDailyAnalyticsDays = 366
+)
+
+// analyticsParams holds the query parameters shared by the analytics
+// endpoints. Dates default to the last 30 days in the user's timezone, and
+// totals are converted to the user's home currency.
+type analyticsParams struct {
+	userID   string
+	timezone string
+	currency string
+	from     string
+	to       string
+	// today is the start of the current day in timezone.
+	today time.Time
+}
+
+func (s *Server) analyticsParams(r *http.Request) (analyticsParams, error) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return analyticsParams{}, err
+	}
+
+	tz, currency, err := s.reportSettings(r, userID)
+	if err != nil {
+		return analyticsParams{}, err
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return analyticsParams{}, err
+	}
+	now := time.Now().In(loc)
+
+	q := r.URL.Query()
+	p := analyticsParams{
+		userID:   userID,
+		timezone: tz,
+		currency: currency,
+		from:     q.Get("from"),
+		to:       q.Get("to"),
+		today:    time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc),
+	}
+	return p, p.validate()
+}
+
+// validate checks the dates and keeps the range within
+// maxAnalyticsYears, so one request cannot scan or generate an unbounded
+// series.
+func (p analyticsParams) validate() error {
+	for _, date := range []string{p.from, p.to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("dates must be in YYYY-MM-DD format")
+		}
+	}
+	if p.from != "" && p.to != "" && p.from > p.to {
+		return fmt.Errorf("from must not be after to")
+	}
+	if start, end := p.span(); !end.Before(start.AddDate(maxAnalyticsYears, 0, 0)) {
+		return fmt.Errorf("the range can cover at most %d years", maxAnalyticsYears)
+	}
+	return nil
+}
+
+// limitDaily rejects a range too long for a point per day.
+func (p analyticsParams) limitDaily() error {
+	if start, end := p.span(); !end.Before(start.AddDate(0, 0, maxDailyAnalyticsDays)) {
+		return fmt.Errorf("a daily interval can cover at most %d days", maxDailyAnalyticsDays)
+	}
+	return nil
+}
+
+// span returns the first and last day of the range with the defaults the
+// queries use: to is today and from is 29 days before today.
+func (p analyticsParams) span() (time.Time, time.Time) {
+	start, end := p.today.AddDate(0, 0, -29), p.today
+	if p.from != "" {
+		start, _ = time.ParseInLocation("2006-01-02", p.from, p.today.Location())
+	}
+	if p.to != "" {
+		end, _ = time.ParseInLocation("2006-01-02", p.to, p.today.Location())
+	}
+	return start, end
+}
+
+func intParam(r *http.Request, name string, def, min, max int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+	return value, nil
+}
+
+func (s *Server) spendByCategory(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.analyticsParams(r)
+	if err != nil {
+		return err
+	}
+	months, err := intParam(r, "months", 6, 1, maxAnalyticsMonths)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.store.SpendByCategory(p.userID, p.timezone, p.currency, months)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) spendByPaymentMethod(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.analyticsParams(r)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.store.SpendByPaymentMethod(p.userID, p.timezone, p.currency, p.from, p.to)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) spendTrend(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.analyticsParams(r)
+	if err != nil {
+		return err
+	}
+
+	var unit string
+	switch interval := r.URL.Query().Get("interval"); interval {
+	case "", "daily":
+		unit = "day"
+		if err := p.limitDaily(); err != nil {
+			return err
+		}
+	case "weekly":
+		unit = "week"
+	default:
+		return fmt.Errorf("interval must be daily or weekly")
+	}
+
+	result, err := s.store.SpendTrend(p.userID, p.timezone, p.currency, unit, p.from, p.to)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) topDescriptions(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.analyticsParams(r)
+	if err != nil {
+		return err
+	}
+	limit, err := intParam(r, "limit", 10, 1, 100)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.store.TopDescriptions(p.userID, p.timezone, p.currency, p.from, p.to, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) monthOverMonth(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.analyticsParams(r)
+	if err != nil {
+		return err
+	}
+	months, err := intParam(r, "months", 6, 1, maxAnalyticsMonths)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.store.MonthOverMonth(p.userID, p.timezone, p.currency, months)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, result)
+}
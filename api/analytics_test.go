@@ -0,0 +1,35 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyticsParamsRange(t *testing.T) {
+	loc, err := time.LoadLocation("Africa/Kampala")
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Date(2026, 10, 19, 0, 0, 0, 0, loc)
+	params := func(from, to string) analyticsParams {
+		return analyticsParams{timezone: "Africa/Kampala", from: from, to: to, today: today}
+	}
+
+	start, end := params("", "").span()
+	assert.Equal(t, "2026-09-20", start.Format("2006-01-02"))
+	assert.Equal(t, "2026-10-19", end.Format("2006-01-02"))
+
+	assert.NoError(t, params("2021-10-20", "").validate())
+	assert.NoError(t, params("2020-01-01", "2024-12-31").validate())
+	assert.Error(t, params("2020-01-01", "2025-01-01").validate())
+	assert.Error(t, params("2021-10-19", "").validate())
+	assert.Error(t, params("2026-02-01", "2026-01-01").validate())
+	assert.Error(t, params("2026/01/01", "").validate())
+
+	assert.NoError(t, params("", "").limitDaily())
+	assert.NoError(t, params("2024-01-01", "2024-12-31").limitDaily())
+	assert.Error(t, params("2024-01-01", "2025-01-01").limitDaily())
+	assert.Error(t, params("2025-10-18", "").limitDaily())
+}
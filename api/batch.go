@@ -0,0 +1,160 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Ayikoandrew/server/rules"
+	"github.com/Ayikoandrew/server/types"
+)
+
+const (
+	defaultMaxBatchSize = 500
+	maxBatchBodySize    = 10 << 20
+)
+
+// batchExpenses applies a list of queued create, update and delete
+// operations. In atomic mode (the default) nothing is saved unless every
+// operation succeeds; in best_effort mode each operation stands alone.
+func (s *Server) batchExpenses(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+	req := new(types.BatchRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return err
+	}
+
+	switch req.Mode {
+	case "":
+		req.Mode = types.BatchAtomic
+	case types.BatchAtomic, types.BatchBestEffort:
+	default:
+		return fmt.Errorf("mode must be %s or %s", types.BatchAtomic, types.BatchBestEffort)
+	}
+	if len(req.Operations) == 0 {
+		return fmt.Errorf("at least one operation is required")
+	}
+	if len(req.Operations) > s.maxBatchSize {
+		return writeJSON(w, http.StatusRequestEntityTooLarge, Err{
+			Err: fmt.Sprintf("a batch can hold at most %d operations", s.maxBatchSize),
+		})
+	}
+
+	engine, err := s.ruleEngine(userID)
+	if err != nil {
+		return err
+	}
+
+	atomic := req.Mode == types.BatchAtomic
+	errs := make([]error, len(req.Operations))
+	var valid []int
+	for i := range req.Operations {
+		if errs[i] = validateBatchOp(&req.Operations[i], engine); errs[i] == nil {
+			valid = append(valid, i)
+		}
+	}
+
+	// An atomic batch with an invalid operation never reaches the database.
+	if len(valid) == len(req.Operations) || !atomic {
+		ops := make([]types.BatchOperation, len(valid))
+		for j, i := range valid {
+			ops[j] = req.Operations[i]
+		}
+
+		applied, err := s.store.ApplyExpenseBatch(userID, ops, atomic)
+		if err != nil {
+			return err
+		}
+		for j, i := range valid {
+			req.Operations[i] = ops[j]
+			errs[i] = applied[j]
+		}
+	}
+
+	resp := batchResponse(req.Mode, req.Operations, errs)
+	if atomic && resp.Failed > 0 {
+		return writeJSON(w, http.StatusBadRequest, resp)
+	}
+
+	for i, op := range req.Operations {
+		if errs[i] == nil && op.Op != types.BatchDelete {
+			s.checkBudgetAlerts(userID, *op.Expense)
+		}
+	}
+	return writeJSON(w, http.StatusOK, resp)
+}
+
+// validateBatchOp checks an operation the way the single-item endpoints
+// would, and applies the user's rules to expenses being created.
+func validateBatchOp(op *types.BatchOperation, engine *rules.Engine) error {
+	switch op.Op {
+	case types.BatchCreate:
+		if op.Expense == nil {
+			return fmt.Errorf("expense is required to create")
+		}
+		if err := validateExpense(op.Expense); err != nil {
+			return err
+		}
+		tags, err := normalizeTags(op.Expense.Tags)
+		if err != nil {
+			return err
+		}
+		op.Expense.Tags = tags
+		engine.Apply(op.Expense, false)
+	case types.BatchUpdate:
+		if op.ID == "" || op.Update == nil {
+			return fmt.Errorf("id and update are required to update")
+		}
+		return validateExpenseUpdate(op.Update)
+	case types.BatchDelete:
+		if op.ID == "" {
+			return fmt.Errorf("id is required to delete")
+		}
+	default:
+		return fmt.Errorf("op must be %s, %s or %s", types.BatchCreate, types.BatchUpdate, types.BatchDelete)
+	}
+	return nil
+}
+
+// batchResponse turns the per-operation errors into results. When an
+// atomic batch failed, operations without an error of their own were
+// rolled back with it.
+func batchResponse(mode types.BatchMode, ops []types.BatchOperation, errs []error) types.BatchResponse {
+	failed := false
+	for _, err := range errs {
+		failed = failed || err != nil
+	}
+	rolledBack := failed && mode == types.BatchAtomic
+
+	resp := types.BatchResponse{Mode: mode, Results: make([]types.BatchResult, len(ops))}
+	for i, op := range ops {
+		result := types.BatchResult{Index: i, Op: op.Op, ClientID: op.ClientID, ID: op.ID}
+		switch err := errs[i]; {
+		case errors.Is(err, sql.ErrNoRows):
+			result.Status, result.Error = http.StatusNotFound, "expense not found"
+		case err != nil:
+			result.Status, result.Error = http.StatusBadRequest, err.Error()
+		case rolledBack:
+			result.Status, result.Error = http.StatusFailedDependency, "not applied: another operation in the batch failed"
+		case op.Op == types.BatchCreate:
+			result.Status, result.ID, result.Expense = http.StatusCreated, op.Expense.ID, op.Expense
+		default:
+			result.Status, result.Expense = http.StatusOK, op.Expense
+		}
+
+		if result.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+		resp.Results[i] = result
+	}
+	return resp
+}
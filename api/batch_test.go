@@ -0,0 +1,74 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Ayikoandrew/server/rules"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBatchOp(t *testing.T) {
+	engine, err := rules.New([]types.CategorizationRule{{
+		ID:         "r1",
+		Active:     true,
+		Conditions: types.RuleConditions{DescriptionContains: "uber"},
+		Actions:    types.RuleActions{Category: "Transport"},
+	}})
+	require.NoError(t, err)
+
+	create := types.BatchOperation{Op: types.BatchCreate, Expense: &types.Expense{
+		Amount: 8000, Date: "2025-01-02", Description: "Uber home", Currency: "kes", Tags: []string{"Work"},
+	}}
+	require.NoError(t, validateBatchOp(&create, engine))
+	assert.Equal(t, "KES", create.Expense.Currency)
+	assert.Equal(t, []string{"work"}, create.Expense.Tags)
+	assert.Equal(t, "Transport", create.Expense.Category)
+
+	amount := -1.0
+	invalid := []types.BatchOperation{
+		{Op: types.BatchCreate},
+		{Op: types.BatchCreate, Expense: &types.Expense{Amount: 10, Date: "02/01/2025"}},
+		{Op: types.BatchUpdate, ID: "e1"},
+		{Op: types.BatchUpdate, ID: "e1", Update: &types.ExpenseUpdate{Amount: &amount}},
+		{Op: types.BatchDelete},
+		{Op: "upsert", ID: "e1"},
+	}
+	for _, op := range invalid {
+		assert.Error(t, validateBatchOp(&op, engine), op.Op)
+	}
+}
+
+func TestBatchResponse(t *testing.T) {
+	ops := []types.BatchOperation{
+		{Op: types.BatchCreate, ClientID: "c1", Expense: &types.Expense{ID: "e1"}},
+		{Op: types.BatchUpdate, ID: "e2"},
+		{Op: types.BatchDelete, ID: "e3"},
+	}
+	errs := []error{nil, sql.ErrNoRows, nil}
+
+	t.Run("Best Effort", func(t *testing.T) {
+		resp := batchResponse(types.BatchBestEffort, ops, errs)
+		assert.Equal(t, 2, resp.Succeeded)
+		assert.Equal(t, 1, resp.Failed)
+		assert.Equal(t, http.StatusCreated, resp.Results[0].Status)
+		assert.Equal(t, "e1", resp.Results[0].ID)
+		assert.Equal(t, "c1", resp.Results[0].ClientID)
+		assert.Equal(t, http.StatusNotFound, resp.Results[1].Status)
+		assert.Equal(t, http.StatusOK, resp.Results[2].Status)
+	})
+
+	t.Run("Atomic Rolls Back Everything", func(t *testing.T) {
+		resp := batchResponse(types.BatchAtomic, ops, []error{nil, errors.New("boom"), nil})
+		assert.Equal(t, 0, resp.Succeeded)
+		assert.Equal(t, 3, resp.Failed)
+		assert.Equal(t, http.StatusFailedDependency, resp.Results[0].Status)
+		assert.Nil(t, resp.Results[0].Expense)
+		assert.Equal(t, "boom", resp.Results[1].Error)
+		assert.Equal(t, http.StatusFailedDependency, resp.Results[2].Status)
+	})
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/budget"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const maxAlertRules = 10
+
+func (s *Server) getCategories(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	categories, err := s.store.GetCategories(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, categories)
+}
+
+// setCategoryBudget creates or updates the category named in the path with
+// the monthly budget in the body.
+func (s *Server) setCategoryBudget(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	category := new(types.Category)
+	if err := json.NewDecoder(r.Body).Decode(category); err != nil {
+		return err
+	}
+
+	category.Name = strings.TrimSpace(mux.Vars(r)["name"])
+	if category.Name == "" || len(category.Name) > 100 {
+		return fmt.Errorf("category name must be between 1 and 100 characters")
+	}
+	if category.Budget < 0 {
+		return fmt.Errorf("budget must not be negative")
+	}
+
+	if err := s.store.SetCategoryBudget(userID, *category); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, category)
+}
+
+func (s *Server) deleteCategory(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteCategory(userID, mux.Vars(r)["name"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "category not found"})
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "category deleted"})
+}
+
+func (s *Server) getBudgetAlertRules(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	rules, err := s.store.GetBudgetAlertRules(userID, mux.Vars(r)["name"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, rules)
+}
+
+// setBudgetAlertRules replaces the category's alert rules with the list in
+// the body. Channels default to the in-app list.
+func (s *Server) setBudgetAlertRules(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	var rules []types.BudgetAlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		return err
+	}
+	if len(rules) > maxAlertRules {
+		return fmt.Errorf("a category can have at most %d alert rules", maxAlertRules)
+	}
+
+	seen := make(map[float64]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		if rule.ThresholdPercent <= 0 || rule.ThresholdPercent > 1000 {
+			return fmt.Errorf("thresholdPercent must be between 0 and 1000")
+		}
+		if seen[rule.ThresholdPercent] {
+			return fmt.Errorf("duplicate threshold %g%%", rule.ThresholdPercent)
+		}
+		seen[rule.ThresholdPercent] = true
+
+		if len(rule.Channels) == 0 {
+			rule.Channels = []string{types.ChannelInApp}
+		}
+		for _, channel := range rule.Channels {
+			if !s.notifier.Has(channel) {
+				return fmt.Errorf("notification channel %q is not available", channel)
+			}
+		}
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := s.store.SetBudgetAlertRules(userID, name, rules); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "category not found"})
+		}
+		return err
+	}
+
+	saved, err := s.store.GetBudgetAlertRules(userID, name)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, saved)
+}
+
+// getBudgets reports spending against every category budget for a month
+// (YYYY-MM, default the current one in the user's timezone), converted to
+// the user's home currency.
+func (s *Server) getBudgets(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	tz, currency, err := s.reportSettings(r, userID)
+	if err != nil {
+		return err
+	}
+
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		loc, _ := time.LoadLocation(tz)
+		month = time.Now().In(loc).Format("2006-01")
+	}
+	from, to, _, err := budget.Period(month + "-01")
+	if err != nil {
+		return fmt.Errorf("month must be in YYYY-MM format")
+	}
+
+	statuses, err := s.store.GetBudgetStatuses(userID, currency, from, to)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, statuses)
+}
+
+// checkBudgetAlerts sends any alerts the expense pushed its category over,
+// for the month the expense falls in. A rule is marked as fired before the
+// notification goes out, so a failed delivery is logged rather than retried.
+func (s *Server) checkBudgetAlerts(userID string, expense types.Expense) {
+	if expense.Category == "" {
+		return
+	}
+
+	from, to, period, err := budget.Period(expense.Date)
+	if err != nil {
+		return
+	}
+
+	user, err := s.store.GetUser(userID)
+	if err != nil {
+		slog.Error("Failed to load user for budget alerts", "error", err, "user", userID)
+		return
+	}
+
+	status, err := s.store.GetBudgetStatus(userID, expense.Category, user.HomeCurrency, from, to)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("Failed to load budget status", "error", err, "category", expense.Category)
+		}
+		return
+	}
+
+	rules, err := s.store.GetBudgetAlertRules(userID, status.Category)
+	if err != nil {
+		slog.Error("Failed to load budget alert rules", "error", err, "category", status.Category)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, rule := range budget.Crossed(status.Spent, status.Budget, rules) {
+		fired, err := s.store.MarkBudgetAlertFired(rule.ID, period)
+		if err != nil {
+			slog.Error("Failed to record budget alert", "error", err, "rule", rule.ID)
+			continue
+		}
+		if !fired {
+			continue
+		}
+
+		note := budget.Message(rule, status.Spent, status.Budget, status.Currency, period)
+		note.UserID = userID
+		if err := s.notifier.Send(ctx, note, rule.Channels...); err != nil {
+			slog.Error("Failed to deliver budget alert", "error", err, "rule", rule.ID)
+		}
+	}
+}
@@ -1,21 +1,243 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Ayikoandrew/server/fx"
 	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
 )
 
+const maxTags = 20
+
 func (s *Server) uploadExpenses(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
 	expense := new(types.Expense)
 	if err := json.NewDecoder(r.Body).Decode(expense); err != nil {
 		return err
 	}
 
+	if err := validateExpense(expense); err != nil {
+		return err
+	}
+	if expense.Tags, err = normalizeTags(expense.Tags); err != nil {
+		return err
+	}
+
+	engine, err := s.ruleEngine(userID)
+	if err != nil {
+		return err
+	}
+	engine.Apply(expense, false)
+
+	expense.UserID = userID
+	if err := s.store.CreateExpense(expense); err != nil {
+		return err
+	}
+	s.checkBudgetAlerts(userID, *expense)
+
+	return writeJSON(w, http.StatusCreated, expense)
+}
+
+func (s *Server) updateExpense(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	update := new(types.ExpenseUpdate)
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		return err
+	}
+
+	if err := validateExpenseUpdate(update); err != nil {
+		return err
+	}
+
+	expense, err := s.store.UpdateExpense(userID, mux.Vars(r)["id"], *update)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "expense not found"})
+		}
+		return err
+	}
+	s.checkBudgetAlerts(userID, *expense)
+
 	return writeJSON(w, http.StatusOK, expense)
 }
 
 func (s *Server) retriveExpenses(w http.ResponseWriter, r *http.Request) error {
-	return writeJSON(w, http.StatusOK, "OK")
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		return err
+	}
+
+	expenses, err := s.store.GetExpenses(userID, filter)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, expenses)
+}
+
+// searchExpenses ranks expenses against the q parameter; the usual listing
+// filters narrow the results.
+func (s *Server) searchExpenses(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		return err
+	}
+	if filter.Query == "" {
+		return fmt.Errorf("q is required")
+	}
+
+	results, err := s.store.SearchExpenses(userID, filter)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) getTags(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	tags, err := s.store.GetTags(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, tags)
+}
+
+func validateExpense(expense *types.Expense) error {
+	if expense.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if _, err := time.Parse("2006-01-02", expense.Date); err != nil {
+		return fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+	if expense.Currency != "" {
+		currency, err := fx.NormalizeCurrency(expense.Currency)
+		if err != nil {
+			return err
+		}
+		expense.Currency = currency
+	}
+	return nil
+}
+
+// validateExpenseUpdate checks the fields being changed and normalizes the
+// currency and tags.
+func validateExpenseUpdate(update *types.ExpenseUpdate) error {
+	if update.Amount != nil && *update.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if update.Date != nil {
+		if _, err := time.Parse("2006-01-02", *update.Date); err != nil {
+			return fmt.Errorf("date must be in YYYY-MM-DD format")
+		}
+	}
+
+	if update.Currency != nil {
+		currency, err := fx.NormalizeCurrency(*update.Currency)
+		if err != nil {
+			return err
+		}
+		update.Currency = &currency
+	}
+
+	if update.Tags != nil {
+		tags, err := normalizeTags(*update.Tags)
+		if err != nil {
+			return err
+		}
+		update.Tags = &tags
+	}
+	return nil
+}
+
+// parseExpenseFilter reads the listing filters from the query string:
+// from, to, category, paymentMethod, minAmount, maxAmount, q for full-text
+// search and tag, which may be repeated.
+func parseExpenseFilter(r *http.Request) (types.ExpenseFilter, error) {
+	q := r.URL.Query()
+	filter := types.ExpenseFilter{
+		From:          q.Get("from"),
+		To:            q.Get("to"),
+		Category:      q.Get("category"),
+		PaymentMethod: q.Get("paymentMethod"),
+		Query:         strings.TrimSpace(q.Get("q")),
+	}
+
+	tags, err := normalizeTags(q["tag"])
+	if err != nil {
+		return filter, err
+	}
+	filter.Tags = tags
+
+	for _, date := range []string{filter.From, filter.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return filter, fmt.Errorf("dates must be in YYYY-MM-DD format")
+		}
+	}
+
+	for name, dest := range map[string]*float64{"minAmount": &filter.MinAmount, "maxAmount": &filter.MaxAmount} {
+		if raw := q.Get(name); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || value < 0 {
+				return filter, fmt.Errorf("%s must be a positive number", name)
+			}
+			*dest = value
+		}
+	}
+
+	return filter, nil
+}
+
+// normalizeTags lowercases and trims tags and drops duplicates. Commas are
+// rejected because storage joins tags with them.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("an expense can have at most %d tags", maxTags)
+	}
+
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > 50 || strings.Contains(tag, ",") {
+			return nil, fmt.Errorf("tags must be 1 to 50 characters without commas")
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
 }
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{" Travel", "travel", "Work Trip"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"travel", "work trip"}, tags)
+
+	_, err = normalizeTags([]string{"a,b"})
+	assert.Error(t, err)
+
+	_, err = normalizeTags([]string{"  "})
+	assert.Error(t, err)
+}
+
+func TestParseExpenseFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/expense?q=%22coffee+beans%22&tag=Food&tag=work&from=2025-01-01&minAmount=5", nil)
+	filter, err := parseExpenseFilter(r)
+	require.NoError(t, err)
+	assert.Equal(t, `"coffee beans"`, filter.Query)
+	assert.Equal(t, []string{"food", "work"}, filter.Tags)
+	assert.Equal(t, "2025-01-01", filter.From)
+	assert.Equal(t, 5.0, filter.MinAmount)
+
+	_, err = parseExpenseFilter(httptest.NewRequest("GET", "/expense?from=01-01-2025", nil))
+	assert.Error(t, err)
+}
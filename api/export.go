@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/export"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+// exportDeadline replaces the server's write timeout for exports, which can
+// take far longer than a normal request.
+const exportDeadline = 10 * time.Minute
+
+// exportExpenses streams the filtered expenses in the requested format. On
+// top of the listing filters it accepts currency, source (the funding
+// account) and any number of account=<category>=<account> mappings.
+func (s *Server) exportExpenses(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		return err
+	}
+
+	q := r.URL.Query()
+	opts := export.Options{
+		Currency:      strings.ToUpper(q.Get("currency")),
+		SourceAccount: q.Get("source"),
+		Accounts:      make(map[string]string),
+		From:          filter.From,
+		To:            filter.To,
+	}
+	for _, mapping := range q["account"] {
+		category, account, ok := strings.Cut(mapping, "=")
+		if !ok || category == "" || account == "" {
+			return fmt.Errorf("account mappings must look like <category>=<account>")
+		}
+		opts.Accounts[strings.ToLower(category)] = account
+	}
+
+	format := export.Format(strings.ToLower(mux.Vars(r)["format"]))
+	buf := bufio.NewWriter(w)
+	writer, err := export.NewWriter(format, buf, opts)
+	if err != nil {
+		return err
+	}
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(exportDeadline)); err != nil {
+		slog.Warn("Could not extend export write deadline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="expenses-%s.%s"`, time.Now().UTC().Format("20060102"), format.Extension()))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are gone by now, so failures can only be logged and the
+	// stream cut short.
+	if err := writer.Begin(); err != nil {
+		slog.Error("Export failed", "error", err, "userId", userID)
+		return nil
+	}
+
+	written := 0
+	err = s.store.StreamExpenses(userID, filter, func(e types.Expense) error {
+		if err := writer.Write(e); err != nil {
+			return err
+		}
+		written++
+		if written%500 == 0 {
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = writer.End()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		slog.Error("Export failed", "error", err, "userId", userID, "rows", written)
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/Ayikoandrew/server/security"
 )
 
 type apiFunc func(http.ResponseWriter, *http.Request) error
@@ -11,6 +14,12 @@ type Err struct {
 	Err string `json:"err"`
 }
 
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errForbidden    = errors.New("forbidden")
+	errNotFound     = errors.New("not found")
+)
+
 func writeJSON(w http.ResponseWriter, status int, v any) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -20,7 +29,26 @@ func writeJSON(w http.ResponseWriter, status int, v any) error {
 func makeHTTPHandlerFunc(f apiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			writeJSON(w, http.StatusBadRequest, Err{Err: err.Error()})
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, errUnauthorized):
+				status = http.StatusUnauthorized
+			case errors.Is(err, errForbidden):
+				status = http.StatusForbidden
+			case errors.Is(err, errNotFound):
+				status = http.StatusNotFound
+			}
+			writeJSON(w, status, Err{Err: err.Error()})
 		}
 	}
 }
+
+// currentUserID returns the authenticated user for routes wrapped in
+// security.ValidateAccessTokenMiddleware.
+func currentUserID(r *http.Request) (string, error) {
+	userID, ok := security.UserIDFromContext(r.Context())
+	if !ok {
+		return "", errUnauthorized
+	}
+	return userID, nil
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxRatesPerRequest = 5000
+	maxRatesFileSize   = 2 << 20
+)
+
+// createExchangeRates saves a JSON array of rates entered by an admin.
+func (s *Server) createExchangeRates(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	var rates []types.ExchangeRate
+	if err := json.NewDecoder(r.Body).Decode(&rates); err != nil {
+		return err
+	}
+	for i := range rates {
+		if err := fx.Validate(&rates[i]); err != nil {
+			return fmt.Errorf("rate %d: %w", i+1, err)
+		}
+		if rates[i].Source == "" {
+			rates[i].Source = "admin"
+		}
+	}
+	return s.saveExchangeRates(w, rates)
+}
+
+// uploadExchangeRates saves the rates in a CSV file sent as the "file" field
+// of a multipart form.
+func (s *Server) uploadExchangeRates(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRatesFileSize)
+	if err := r.ParseMultipartForm(maxRatesFileSize); err != nil {
+		return fmt.Errorf("invalid upload: %w", err)
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return fmt.Errorf("file is required")
+	}
+	defer file.Close()
+
+	rates, err := fx.ParseCSV(file)
+	if err != nil {
+		return err
+	}
+	for i := range rates {
+		rates[i].Source = "csv"
+	}
+	return s.saveExchangeRates(w, rates)
+}
+
+func (s *Server) saveExchangeRates(w http.ResponseWriter, rates []types.ExchangeRate) error {
+	if len(rates) == 0 {
+		return fmt.Errorf("at least one rate is required")
+	}
+	if len(rates) > maxRatesPerRequest {
+		return fmt.Errorf("at most %d rates can be saved at once", maxRatesPerRequest)
+	}
+
+	saved, err := s.store.UpsertExchangeRates(rates)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]int{"saved": saved})
+}
+
+func (s *Server) deleteExchangeRate(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	vars := mux.Vars(r)
+	rate := types.ExchangeRate{Base: vars["base"], Quote: vars["quote"], Date: vars["date"], Rate: 1}
+	if err := fx.Validate(&rate); err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteExchangeRate(rate.Base, rate.Quote, rate.Date); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("exchange rate %w", errNotFound)
+		}
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// getExchangeRates lists stored rates, optionally for one base or quote
+// currency.
+func (s *Server) getExchangeRates(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	var codes [2]string
+	for i, name := range []string{"base", "quote"} {
+		if raw := q.Get(name); raw != "" {
+			code, err := fx.NormalizeCurrency(raw)
+			if err != nil {
+				return err
+			}
+			codes[i] = code
+		}
+	}
+
+	rates, err := s.store.GetExchangeRates(codes[0], codes[1])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, rates)
+}
+
+// convertCurrency converts amount from one currency to another at the rate
+// in effect on date, which defaults to today.
+func (s *Server) convertCurrency(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	from, err := fx.NormalizeCurrency(q.Get("from"))
+	if err != nil {
+		return err
+	}
+	to, err := fx.NormalizeCurrency(q.Get("to"))
+	if err != nil {
+		return err
+	}
+
+	date := q.Get("date")
+	if date == "" {
+		date = time.Now().UTC().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+
+	amount := 1.0
+	if raw := q.Get("amount"); raw != "" {
+		if amount, err = strconv.ParseFloat(raw, 64); err != nil {
+			return fmt.Errorf("amount must be a number")
+		}
+	}
+
+	rate, err := s.store.ExchangeRate(from, to, date)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("exchange rate from %s to %s on %s %w", from, to, date, errNotFound)
+		}
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, types.Conversion{
+		From:      from,
+		To:        to,
+		Date:      date,
+		Rate:      rate,
+		Amount:    amount,
+		Converted: math.Round(amount*rate*100) / 100,
+	})
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/goals"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createGoal(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	goal := new(types.SavingsGoal)
+	if err := json.NewDecoder(r.Body).Decode(goal); err != nil {
+		return err
+	}
+	if err := validateGoal(goal); err != nil {
+		return err
+	}
+
+	goal.UserID = userID
+	goal.Saved = 0
+	if err := s.store.CreateGoal(goal); err != nil {
+		return err
+	}
+
+	today, err := s.userToday(r, userID)
+	if err != nil {
+		return err
+	}
+	progress, err := goals.Progress(*goal, today)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, progress)
+}
+
+// getGoals reports the progress of every goal.
+func (s *Server) getGoals(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	saved, err := s.store.GetGoals(userID)
+	if err != nil {
+		return err
+	}
+	today, err := s.userToday(r, userID)
+	if err != nil {
+		return err
+	}
+
+	report := make([]types.GoalProgress, 0, len(saved))
+	for _, goal := range saved {
+		progress, err := goals.Progress(goal, today)
+		if err != nil {
+			return err
+		}
+		report = append(report, progress)
+	}
+	return writeJSON(w, http.StatusOK, report)
+}
+
+// getGoal reports one goal's progress along with its contributions.
+func (s *Server) getGoal(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	goal, err := s.store.GetGoal(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("goal %w", errNotFound)
+		}
+		return err
+	}
+	return s.writeGoalDetail(w, r, http.StatusOK, userID, goal)
+}
+
+func (s *Server) updateGoal(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	update := new(types.GoalUpdate)
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		return err
+	}
+	if err := validateGoalUpdate(update); err != nil {
+		return err
+	}
+
+	goal, err := s.store.UpdateGoal(userID, mux.Vars(r)["id"], *update)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("goal %w", errNotFound)
+		}
+		return err
+	}
+	return s.writeGoalDetail(w, r, http.StatusOK, userID, goal)
+}
+
+func (s *Server) deleteGoal(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteGoal(userID, mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("goal %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "goal deleted"})
+}
+
+// contributeToGoal records a manual contribution, in the goal's currency,
+// and returns the goal's updated progress.
+func (s *Server) contributeToGoal(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	contribution := new(types.GoalContribution)
+	if err := json.NewDecoder(r.Body).Decode(contribution); err != nil {
+		return err
+	}
+	if contribution.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if contribution.Date == "" {
+		today, err := s.userToday(r, userID)
+		if err != nil {
+			return err
+		}
+		contribution.Date = today.Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", contribution.Date); err != nil {
+		return fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+
+	contribution.GoalID = mux.Vars(r)["id"]
+	if err := s.store.AddGoalContribution(userID, contribution); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("goal %w", errNotFound)
+		}
+		return err
+	}
+
+	goal, err := s.store.GetGoal(userID, contribution.GoalID)
+	if err != nil {
+		return err
+	}
+	return s.writeGoalDetail(w, r, http.StatusCreated, userID, goal)
+}
+
+func (s *Server) deleteGoalContribution(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	vars := mux.Vars(r)
+	if err := s.store.DeleteGoalContribution(userID, vars["id"], vars["contributionId"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("contribution %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "contribution deleted"})
+}
+
+func (s *Server) writeGoalDetail(w http.ResponseWriter, r *http.Request, status int, userID string, goal *types.SavingsGoal) error {
+	today, err := s.userToday(r, userID)
+	if err != nil {
+		return err
+	}
+	progress, err := goals.Progress(*goal, today)
+	if err != nil {
+		return err
+	}
+	contributions, err := s.store.GetGoalContributions(goal.ID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, status, types.GoalDetail{GoalProgress: progress, Contributions: contributions})
+}
+
+// userToday returns the current date in the user's timezone.
+func (s *Server) userToday(r *http.Request, userID string) (time.Time, error) {
+	tz, _, err := s.reportSettings(r, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func validateGoal(goal *types.SavingsGoal) error {
+	goal.Name = strings.TrimSpace(goal.Name)
+	if goal.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if goal.TargetAmount <= 0 {
+		return fmt.Errorf("targetAmount must be greater than zero")
+	}
+	if _, err := time.Parse("2006-01-02", goal.Deadline); err != nil {
+		return fmt.Errorf("deadline must be in YYYY-MM-DD format")
+	}
+	if goal.Currency != "" {
+		currency, err := fx.NormalizeCurrency(goal.Currency)
+		if err != nil {
+			return err
+		}
+		goal.Currency = currency
+	}
+	return validateGoalRule(goal.Rule)
+}
+
+func validateGoalUpdate(update *types.GoalUpdate) error {
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		update.Name = &name
+	}
+	if update.TargetAmount != nil && *update.TargetAmount <= 0 {
+		return fmt.Errorf("targetAmount must be greater than zero")
+	}
+	if update.Deadline != nil {
+		if _, err := time.Parse("2006-01-02", *update.Deadline); err != nil {
+			return fmt.Errorf("deadline must be in YYYY-MM-DD format")
+		}
+	}
+	return validateGoalRule(update.Rule)
+}
+
+func validateGoalRule(rule *types.GoalRule) error {
+	if rule == nil {
+		return nil
+	}
+	if rule.Percent < 0 || rule.Percent > 100 {
+		return fmt.Errorf("rule percent must be between 0 and 100")
+	}
+	if rule.IncomeKind != "" && !validIncomeKind(rule.IncomeKind) {
+		return fmt.Errorf("rule incomeKind must be salary, transfer_in or other")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// deleteExpense moves an expense to the trash, from where it can be
+// restored until it is purged.
+func (s *Server) deleteExpense(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteExpense(userID, mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("expense %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "expense moved to trash"})
+}
+
+func (s *Server) restoreExpense(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	expense, err := s.store.RestoreExpense(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("deleted expense %w", errNotFound)
+		}
+		return err
+	}
+	s.checkBudgetAlerts(userID, *expense)
+
+	return writeJSON(w, http.StatusOK, expense)
+}
+
+func (s *Server) getTrash(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	trash, err := s.store.GetDeletedExpenses(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, trash)
+}
+
+func (s *Server) getExpenseHistory(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	versions, err := s.store.GetExpenseHistory(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("expense %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, versions)
+}
+
+// purgeTrash permanently removes expenses whose time in the trash is up,
+// then deletes their receipt files.
+func (s *Server) purgeTrash() {
+	keys, err := s.store.PurgeDeletedExpenses()
+	if err != nil {
+		log.Printf("Trash purge failed: %v", err)
+		return
+	}
+	s.deleteBlobs(keys...)
+}
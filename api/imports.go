@@ -0,0 +1,179 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/importer"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const maxImportSize = 5 << 20
+
+// previewImport parses an uploaded statement and stores it as a pending batch.
+// The multipart form carries the file, its format (csv, ofx or qif), the CSV
+// column mapping as JSON and an optional duplicate window in days.
+func (s *Server) previewImport(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		return fmt.Errorf("invalid upload: %w", err)
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return fmt.Errorf("file is required")
+	}
+	defer file.Close()
+
+	format := types.ImportFormat(strings.ToLower(r.FormValue("format")))
+	if format == "" {
+		format = types.ImportFormat(strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), "."))
+	}
+
+	var mapping types.ColumnMapping
+	if raw := r.FormValue("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return fmt.Errorf("invalid column mapping: %w", err)
+		}
+	}
+
+	window := importer.DefaultWindowDays
+	if raw := r.FormValue("windowDays"); raw != "" {
+		window, err = strconv.Atoi(raw)
+		if err != nil || window < 0 || window > 31 {
+			return fmt.Errorf("windowDays must be between 0 and 31")
+		}
+	}
+
+	transactions, err := importer.Parse(format, file, mapping)
+	if err != nil {
+		return err
+	}
+
+	rows := importer.Rows(transactions)
+	if len(rows) == 0 {
+		return fmt.Errorf("no expenses found in statement")
+	}
+
+	from, to := rows[0].Date, rows[0].Date
+	for _, row := range rows {
+		if row.Date < from {
+			from = row.Date
+		}
+		if row.Date > to {
+			to = row.Date
+		}
+	}
+	existing, err := s.store.GetExpensesInRange(userID, shiftDate(from, -window), shiftDate(to, window))
+	if err != nil {
+		return err
+	}
+	importer.MarkDuplicates(rows, existing, window)
+
+	batch := &types.ImportBatch{
+		UserID:   userID,
+		Format:   format,
+		Filename: header.Filename,
+		Rows:     rows,
+	}
+	if err := s.store.CreateImportBatch(batch); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if row.Duplicate {
+			batch.SkippedCount++
+		}
+	}
+	return writeJSON(w, http.StatusCreated, batch)
+}
+
+func (s *Server) getImportBatches(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	batches, err := s.store.GetImportBatches(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, batches)
+}
+
+func (s *Server) getImportBatch(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	batch, err := s.store.GetImportBatch(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "import batch not found"})
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, batch)
+}
+
+func (s *Server) commitImport(w http.ResponseWriter, r *http.Request) error {
+	return s.changeImportBatch(w, r, func(userID, id string) error {
+		if err := s.store.CommitImportBatch(userID, id); err != nil {
+			return err
+		}
+		s.classifyImport(userID, id)
+		return nil
+	})
+}
+
+func (s *Server) rollbackImport(w http.ResponseWriter, r *http.Request) error {
+	return s.changeImportBatch(w, r, s.store.RollbackImportBatch)
+}
+
+func (s *Server) changeImportBatch(w http.ResponseWriter, r *http.Request, change func(userID, id string) error) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	id := mux.Vars(r)["id"]
+	if err := change(userID, id); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return writeJSON(w, http.StatusNotFound, Err{Err: "import batch not found"})
+		case errors.Is(err, database.ErrInvalidBatchState):
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return err
+	}
+
+	batch, err := s.store.GetImportBatch(userID, id)
+	if err != nil {
+		return err
+	}
+	batch.Rows = nil
+	return writeJSON(w, http.StatusOK, batch)
+}
+
+func shiftDate(date string, days int) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.AddDate(0, 0, days).Format("2006-01-02")
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createIncome(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	income := new(types.Income)
+	if err := json.NewDecoder(r.Body).Decode(income); err != nil {
+		return err
+	}
+	if err := validateIncome(income); err != nil {
+		return err
+	}
+
+	income.UserID = userID
+	if err := s.store.CreateIncome(income); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, income)
+}
+
+func (s *Server) getIncomes(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	q := r.URL.Query()
+	from, to := q.Get("from"), q.Get("to")
+	for _, date := range []string{from, to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("dates must be in YYYY-MM-DD format")
+		}
+	}
+
+	incomes, err := s.store.GetIncomes(userID, from, to)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, incomes)
+}
+
+func (s *Server) updateIncome(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	update := new(types.IncomeUpdate)
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		return err
+	}
+	if err := validateIncomeUpdate(update); err != nil {
+		return err
+	}
+
+	income, err := s.store.UpdateIncome(userID, mux.Vars(r)["id"], *update)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("income %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, income)
+}
+
+func (s *Server) deleteIncome(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteIncome(userID, mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("income %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "income deleted"})
+}
+
+func validIncomeKind(kind types.IncomeKind) bool {
+	switch kind {
+	case types.IncomeSalary, types.IncomeTransferIn, types.IncomeOther:
+		return true
+	}
+	return false
+}
+
+// validateIncome checks a new income entry, defaulting the kind to other
+// and normalizing the currency.
+func validateIncome(income *types.Income) error {
+	if income.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if _, err := time.Parse("2006-01-02", income.Date); err != nil {
+		return fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+	if income.Kind == "" {
+		income.Kind = types.IncomeOther
+	}
+	if !validIncomeKind(income.Kind) {
+		return fmt.Errorf("kind must be salary, transfer_in or other")
+	}
+	if income.Currency != "" {
+		currency, err := fx.NormalizeCurrency(income.Currency)
+		if err != nil {
+			return err
+		}
+		income.Currency = currency
+	}
+	return nil
+}
+
+func validateIncomeUpdate(update *types.IncomeUpdate) error {
+	if update.Amount != nil && *update.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if update.Date != nil {
+		if _, err := time.Parse("2006-01-02", *update.Date); err != nil {
+			return fmt.Errorf("date must be in YYYY-MM-DD format")
+		}
+	}
+	if update.Kind != nil && !validIncomeKind(*update.Kind) {
+		return fmt.Errorf("kind must be salary, transfer_in or other")
+	}
+	if update.Currency != nil {
+		currency, err := fx.NormalizeCurrency(*update.Currency)
+		if err != nil {
+			return err
+		}
+		update.Currency = &currency
+	}
+	return nil
+}
+
+// cashFlow reports income minus expenses per day, week or month with the
+// savings rate and a running balance. Without from it covers the last
+// twelve months.
+func (s *Server) cashFlow(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.analyticsParams(r)
+	if err != nil {
+		return err
+	}
+
+	interval := r.URL.Query().Get("interval")
+	var unit string
+	switch interval {
+	case "", "monthly":
+		interval, unit = "monthly", "month"
+	case "weekly":
+		unit = "week"
+	case "daily":
+		unit = "day"
+	default:
+		return fmt.Errorf("interval must be daily, weekly or monthly")
+	}
+
+	if p.from == "" {
+		p.from = p.today.AddDate(0, -11, 1-p.today.Day()).Format("2006-01-02")
+		if p.to != "" && p.from > p.to {
+			return fmt.Errorf("from is required when to is more than a year ago")
+		}
+	}
+	if unit == "day" {
+		if err := p.limitDaily(); err != nil {
+			return err
+		}
+	}
+
+	points, err := s.store.CashFlow(p.userID, p.timezone, p.currency, unit, p.from, p.to)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, cashFlowReport(p.currency, interval, points))
+}
+
+// cashFlowReport fills in each period's net and savings rate and adds the
+// totals for the whole range.
+func cashFlowReport(currency, interval string, points []types.CashFlowPoint) types.CashFlowReport {
+	report := types.CashFlowReport{Currency: currency, Interval: interval, Periods: points}
+	for i := range points {
+		p := &points[i]
+		p.Net = roundCents(p.Income - p.Expenses)
+		p.SavingsRate = savingsRate(p.Income, p.Net)
+		report.Income += p.Income
+		report.Expenses += p.Expenses
+	}
+	if len(points) > 0 {
+		report.OpeningBalance = roundCents(points[0].Balance - points[0].Net)
+	}
+	report.Income = roundCents(report.Income)
+	report.Expenses = roundCents(report.Expenses)
+	report.Net = roundCents(report.Income - report.Expenses)
+	report.SavingsRate = savingsRate(report.Income, report.Net)
+	return report
+}
+
+// savingsRate is net as a percentage of income, or nil without income.
+func savingsRate(income, net float64) *float64 {
+	if income <= 0 {
+		return nil
+	}
+	rate := math.Round(net/income*10000) / 100
+	return &rate
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
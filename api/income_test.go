@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateIncome(t *testing.T) {
+	income := types.Income{Amount: 2500000, Date: "2025-01-31", Currency: "ugx"}
+	require.NoError(t, validateIncome(&income))
+	assert.Equal(t, types.IncomeOther, income.Kind)
+	assert.Equal(t, "UGX", income.Currency)
+
+	invalid := []types.Income{
+		{Amount: 0, Date: "2025-01-31"},
+		{Amount: 100, Date: "31/01/2025"},
+		{Amount: 100, Date: "2025-01-31", Kind: "bonus"},
+		{Amount: 100, Date: "2025-01-31", Currency: "shillings"},
+	}
+	for _, i := range invalid {
+		assert.Error(t, validateIncome(&i))
+	}
+}
+
+func TestCashFlowReport(t *testing.T) {
+	report := cashFlowReport("UGX", "monthly", []types.CashFlowPoint{
+		{Period: "2025-01-01", Income: 1000, Expenses: 750, Balance: 1250},
+		{Period: "2025-02-01", Income: 0, Expenses: 100, Balance: 1150},
+		{Period: "2025-03-01", Income: 500, Expenses: 600, Balance: 1050},
+	})
+
+	assert.Equal(t, 1000.0, report.OpeningBalance)
+	assert.Equal(t, 1500.0, report.Income)
+	assert.Equal(t, 1450.0, report.Expenses)
+	assert.Equal(t, 50.0, report.Net)
+	require.NotNil(t, report.SavingsRate)
+	assert.Equal(t, 3.33, *report.SavingsRate)
+
+	require.NotNil(t, report.Periods[0].SavingsRate)
+	assert.Equal(t, 25.0, *report.Periods[0].SavingsRate)
+	assert.Nil(t, report.Periods[1].SavingsRate)
+	assert.Equal(t, -100.0, report.Periods[1].Net)
+	assert.Equal(t, -20.0, *report.Periods[2].SavingsRate)
+}
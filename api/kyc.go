@@ -0,0 +1,319 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/blob"
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxKYCImageSize      = 10 << 20
+	maxKYCDocumentNumber = 50
+	maxKYCReason         = 500
+	kycURLTTL            = 5 * time.Minute
+)
+
+// submitKYC uploads the images of an identity document for review. The
+// form carries documentType, an optional documentNumber and one file per
+// side the document needs, named after the side.
+func (s *Server) submitKYC(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	if s.blobs == nil {
+		return writeJSON(w, http.StatusServiceUnavailable, Err{Err: "document storage is not configured"})
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 2*maxKYCImageSize+1<<20)
+	if err := r.ParseMultipartForm(maxKYCImageSize); err != nil {
+		return writeJSON(w, http.StatusRequestEntityTooLarge, Err{Err: "each image must be smaller than 10MB"})
+	}
+
+	current, err := s.store.GetKYCTier(userID)
+	if err != nil {
+		return err
+	}
+	sub := &types.KYCSubmission{
+		UserID:         userID,
+		DocumentType:   types.KYCDocumentType(r.FormValue("documentType")),
+		DocumentNumber: strings.TrimSpace(r.FormValue("documentNumber")),
+	}
+	sides, err := validateKYCSubmission(sub, current)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for _, side := range sides {
+		doc, err := s.storeKYCImage(r, userID, side)
+		if err != nil {
+			s.deleteBlobs(keys...)
+			return err
+		}
+		keys = append(keys, doc.BlobKey)
+		sub.Documents = append(sub.Documents, *doc)
+	}
+
+	if err := s.store.CreateKYCSubmission(sub); err != nil {
+		s.deleteBlobs(keys...)
+		if errors.Is(err, database.ErrKYCPending) {
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return err
+	}
+
+	s.auditKYC(sub, "", "kyc_submitted")
+	s.notifyKYC(sub, "kyc_submitted", "Documents received",
+		"We received your documents and will review them shortly.")
+	return writeJSON(w, http.StatusCreated, sub)
+}
+
+// storeKYCImage reads the image for one side of a document from the form
+// and puts it in blob storage.
+func (s *Server) storeKYCImage(r *http.Request, userID, side string) (*types.KYCDocument, error) {
+	file, header, err := r.FormFile(side)
+	if err != nil {
+		return nil, fmt.Errorf("%s image is required", side)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxKYCImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxKYCImageSize {
+		return nil, fmt.Errorf("%s image must be smaller than 10MB", side)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := receiptTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%s image must be a JPEG, PNG, GIF or WebP image", side)
+	}
+
+	key, err := blob.NewKey("kyc/"+userID, ext)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.blobs.Put(r.Context(), key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		slog.Error("Failed to store KYC document", "error", err)
+		return nil, fmt.Errorf("failed to store %s image", side)
+	}
+
+	return &types.KYCDocument{
+		Side:        side,
+		BlobKey:     key,
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}, nil
+}
+
+// getKYCStatus shows the caller their tier and their submissions.
+func (s *Server) getKYCStatus(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	tier, err := s.store.GetKYCTier(userID)
+	if err != nil {
+		return err
+	}
+	submissions, err := s.store.GetKYCSubmissions(userID, "", 20)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, types.KYCStatusResponse{Tier: tier, Submissions: submissions})
+}
+
+// getKYCQueue lists submissions for admins, by default the ones awaiting
+// review, oldest first.
+func (s *Server) getKYCQueue(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	status := types.KYCStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = types.KYCPending
+	case types.KYCPending, types.KYCApproved, types.KYCRejected:
+	default:
+		return fmt.Errorf("status must be pending, approved or rejected")
+	}
+	limit, err := intParam(r, "limit", 50, 1, 500)
+	if err != nil {
+		return err
+	}
+
+	submissions, err := s.store.GetKYCSubmissions("", status, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, submissions)
+}
+
+// getKYCSubmission shows an admin a submission with short-lived links to
+// its images.
+func (s *Server) getKYCSubmission(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	sub, err := s.store.GetKYCSubmission(mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("KYC submission %w", errNotFound)
+		}
+		return err
+	}
+
+	if s.blobs != nil {
+		for i := range sub.Documents {
+			if sub.Documents[i].URL, err = s.blobs.SignedURL(r.Context(), sub.Documents[i].BlobKey, kycURLTTL); err != nil {
+				return err
+			}
+		}
+	}
+	return writeJSON(w, http.StatusOK, sub)
+}
+
+func (s *Server) approveKYC(w http.ResponseWriter, r *http.Request) error {
+	return s.reviewKYC(w, r, types.KYCApproved)
+}
+
+func (s *Server) rejectKYC(w http.ResponseWriter, r *http.Request) error {
+	return s.reviewKYC(w, r, types.KYCRejected)
+}
+
+// reviewKYC records an admin's decision on a pending submission, writes it
+// to the audit trail and tells the user. A rejection needs a reason, which
+// the user sees.
+func (s *Server) reviewKYC(w http.ResponseWriter, r *http.Request, status types.KYCStatus) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+	adminID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	var review types.KYCReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	review.Reason = strings.TrimSpace(review.Reason)
+	if status == types.KYCRejected && review.Reason == "" {
+		return fmt.Errorf("reason is required to reject a submission")
+	}
+	if len(review.Reason) > maxKYCReason {
+		return fmt.Errorf("reason must be at most %d characters", maxKYCReason)
+	}
+
+	sub, err := s.store.ReviewKYCSubmission(mux.Vars(r)["id"], adminID, status, review.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("KYC submission %w", errNotFound)
+		case errors.Is(err, database.ErrInvalidKYCState):
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return err
+	}
+
+	s.auditKYC(sub, adminID, "kyc_reviewed")
+	if status == types.KYCApproved {
+		s.notifyKYC(sub, "kyc_approved", "Verification approved",
+			fmt.Sprintf("Your %s was approved. Your account is now at tier %d.", documentName(sub.DocumentType), sub.Tier))
+	} else {
+		s.notifyKYC(sub, "kyc_rejected", "Verification rejected",
+			fmt.Sprintf("Your %s was rejected: %s", documentName(sub.DocumentType), sub.Reason))
+	}
+	return writeJSON(w, http.StatusOK, sub)
+}
+
+func (s *Server) auditKYC(sub *types.KYCSubmission, actorID, action string) {
+	event := &types.AuditEvent{
+		UserID:  sub.UserID,
+		ActorID: actorID,
+		Action:  action,
+		Outcome: string(sub.Status),
+		Details: map[string]any{
+			"submissionId": sub.ID,
+			"documentType": sub.DocumentType,
+			"tier":         sub.Tier,
+			"reason":       sub.Reason,
+		},
+	}
+	if err := s.store.RecordAuditEvent(event); err != nil {
+		slog.Error("Failed to audit KYC event", "error", err, "submission", sub.ID)
+	}
+}
+
+func (s *Server) notifyKYC(sub *types.KYCSubmission, kind, title, body string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	note := types.Notification{
+		UserID: sub.UserID,
+		Kind:   kind,
+		Title:  title,
+		Body:   body,
+		Data:   map[string]string{"submissionId": sub.ID},
+	}
+	if err := s.notifier.Send(ctx, note, types.ChannelInApp, types.ChannelPush); err != nil {
+		slog.Error("Failed to send KYC notification", "error", err, "submission", sub.ID)
+	}
+}
+
+// validateKYCSubmission checks a submission against the user's current
+// tier, sets the tier it is for and returns the document sides that must
+// be uploaded. Identity documents lead to the basic tier; proof of address
+// leads to the full tier once the identity is verified.
+func validateKYCSubmission(sub *types.KYCSubmission, current types.KYCTier) ([]string, error) {
+	var sides []string
+	switch sub.DocumentType {
+	case types.DocumentNationalID:
+		sub.Tier, sides = types.KYCTierBasic, []string{"front", "back"}
+	case types.DocumentPassport:
+		sub.Tier, sides = types.KYCTierBasic, []string{"front"}
+	case types.DocumentProofOfAddress:
+		sub.Tier, sides = types.KYCTierFull, []string{"front"}
+	default:
+		return nil, fmt.Errorf("documentType must be national_id, passport or proof_of_address")
+	}
+
+	if len(sub.DocumentNumber) > maxKYCDocumentNumber {
+		return nil, fmt.Errorf("documentNumber must be at most %d characters", maxKYCDocumentNumber)
+	}
+	if sub.Tier != types.KYCTierFull && sub.DocumentNumber == "" {
+		return nil, fmt.Errorf("documentNumber is required for an identity document")
+	}
+	if current >= sub.Tier {
+		return nil, fmt.Errorf("your account is already at tier %d", current)
+	}
+	if current < sub.Tier-1 {
+		return nil, fmt.Errorf("verify your identity before submitting %s", documentName(sub.DocumentType))
+	}
+	return sides, nil
+}
+
+func documentName(t types.KYCDocumentType) string {
+	return strings.ReplaceAll(string(t), "_", " ")
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateKYCSubmission(t *testing.T) {
+	sub := &types.KYCSubmission{DocumentType: types.DocumentNationalID, DocumentNumber: "CM123"}
+	sides, err := validateKYCSubmission(sub, types.KYCTierNone)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"front", "back"}, sides)
+	assert.Equal(t, types.KYCTierBasic, sub.Tier)
+
+	sub = &types.KYCSubmission{DocumentType: types.DocumentPassport, DocumentNumber: "A1"}
+	sides, err = validateKYCSubmission(sub, types.KYCTierNone)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"front"}, sides)
+
+	sub = &types.KYCSubmission{DocumentType: types.DocumentProofOfAddress}
+	sides, err = validateKYCSubmission(sub, types.KYCTierBasic)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"front"}, sides)
+	assert.Equal(t, types.KYCTierFull, sub.Tier)
+}
+
+func TestValidateKYCSubmissionRejects(t *testing.T) {
+	for name, tc := range map[string]struct {
+		sub     types.KYCSubmission
+		current types.KYCTier
+	}{
+		"unknown type":            {types.KYCSubmission{DocumentType: "selfie"}, types.KYCTierNone},
+		"missing number":          {types.KYCSubmission{DocumentType: types.DocumentPassport}, types.KYCTierNone},
+		"already verified":        {types.KYCSubmission{DocumentType: types.DocumentPassport, DocumentNumber: "A1"}, types.KYCTierBasic},
+		"address before identity": {types.KYCSubmission{DocumentType: types.DocumentProofOfAddress}, types.KYCTierNone},
+	} {
+		_, err := validateKYCSubmission(&tc.sub, tc.current)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,454 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/split"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+var ledgerRoleRank = map[types.LedgerRole]int{
+	types.LedgerRoleViewer: 1,
+	types.LedgerRoleEditor: 2,
+	types.LedgerRoleOwner:  3,
+}
+
+// authorizeLedger checks that the user belongs to the ledger in the path
+// with at least the given role, and returns the ledger ID and their role.
+// Non-members get a 404 so ledger IDs cannot be probed.
+func (s *Server) authorizeLedger(r *http.Request, userID string, min types.LedgerRole) (string, types.LedgerRole, error) {
+	ledgerID := mux.Vars(r)["id"]
+	role, err := s.store.GetLedgerRole(ledgerID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", fmt.Errorf("ledger %w", errNotFound)
+		}
+		return "", "", err
+	}
+	if ledgerRoleRank[role] < ledgerRoleRank[min] {
+		return "", "", fmt.Errorf("%w: requires the %s role", errForbidden, min)
+	}
+	return ledgerID, role, nil
+}
+
+func (s *Server) createLedger(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	ledger := new(types.Ledger)
+	if err := json.NewDecoder(r.Body).Decode(ledger); err != nil {
+		return err
+	}
+
+	ledger.Name = strings.TrimSpace(ledger.Name)
+	if ledger.Name == "" || len(ledger.Name) > 100 {
+		return fmt.Errorf("name must be between 1 and 100 characters")
+	}
+
+	ledger.CreatedBy = userID
+	if err := s.store.CreateLedger(ledger); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, ledger)
+}
+
+func (s *Server) getLedgers(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	ledgers, err := s.store.GetLedgers(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, ledgers)
+}
+
+func (s *Server) getLedgerMembers(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	members, err := s.store.GetLedgerMembers(ledgerID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, members)
+}
+
+// updateLedgerMember lets the owner make a member an editor or a viewer.
+func (s *Server) updateLedgerMember(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	var body struct {
+		Role types.LedgerRole `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return err
+	}
+	if body.Role != types.LedgerRoleEditor && body.Role != types.LedgerRoleViewer {
+		return fmt.Errorf("role must be editor or viewer")
+	}
+
+	memberID := mux.Vars(r)["userId"]
+	if memberID == userID {
+		return fmt.Errorf("the owner's role cannot be changed")
+	}
+
+	if err := s.store.SetLedgerMemberRole(ledgerID, memberID, body.Role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("member %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "member updated"})
+}
+
+// removeLedgerMember lets the owner remove a member, or a member leave. The
+// owner cannot leave their own ledger.
+func (s *Server) removeLedgerMember(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, role, err := s.authorizeLedger(r, userID, types.LedgerRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	memberID := mux.Vars(r)["userId"]
+	switch {
+	case memberID == userID && role == types.LedgerRoleOwner:
+		return fmt.Errorf("the owner cannot leave the ledger")
+	case memberID != userID && role != types.LedgerRoleOwner:
+		return fmt.Errorf("%w: only the owner can remove other members", errForbidden)
+	}
+
+	if err := s.store.RemoveLedgerMember(ledgerID, memberID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("member %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "member removed"})
+}
+
+func (s *Server) inviteToLedger(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleOwner)
+	if err != nil {
+		return err
+	}
+
+	inv := new(types.LedgerInvitation)
+	if err := json.NewDecoder(r.Body).Decode(inv); err != nil {
+		return err
+	}
+
+	address, err := mail.ParseAddress(inv.Email)
+	if err != nil {
+		return fmt.Errorf("invalid email address")
+	}
+	if inv.Role == "" {
+		inv.Role = types.LedgerRoleEditor
+	}
+	if inv.Role != types.LedgerRoleEditor && inv.Role != types.LedgerRoleViewer {
+		return fmt.Errorf("role must be editor or viewer")
+	}
+
+	inv.Email = address.Address
+	inv.LedgerID = ledgerID
+	inv.InvitedBy = userID
+	if err := s.store.CreateLedgerInvitation(inv); err != nil {
+		if errors.Is(err, database.ErrAlreadyInvited) {
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return err
+	}
+
+	s.notifyInvitee(inv)
+	return writeJSON(w, http.StatusCreated, inv)
+}
+
+// notifyInvitee tells an existing user about a new invitation. People without
+// an account see it once they sign up with the invited address.
+func (s *Server) notifyInvitee(inv *types.LedgerInvitation) {
+	invitee, err := s.store.GetUserByEmail(inv.Email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("Failed to look up invitee", "error", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	note := types.Notification{
+		UserID: invitee.ID,
+		Kind:   "ledger_invitation",
+		Title:  "You have been invited to a shared ledger",
+		Body:   fmt.Sprintf("You were invited to join as %s.", inv.Role),
+		Data:   map[string]string{"invitationId": inv.ID, "ledgerId": inv.LedgerID},
+	}
+	if err := s.notifier.Send(ctx, note); err != nil {
+		slog.Error("Failed to deliver invitation", "error", err, "invitation", inv.ID)
+	}
+}
+
+func (s *Server) getInvitations(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := s.store.GetUserInvitations(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, invitations)
+}
+
+func (s *Server) acceptInvitation(w http.ResponseWriter, r *http.Request) error {
+	return s.respondToInvitation(w, r, true)
+}
+
+func (s *Server) declineInvitation(w http.ResponseWriter, r *http.Request) error {
+	return s.respondToInvitation(w, r, false)
+}
+
+func (s *Server) respondToInvitation(w http.ResponseWriter, r *http.Request, accept bool) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.RespondToInvitation(userID, mux.Vars(r)["id"], accept); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("invitation %w", errNotFound)
+		}
+		return err
+	}
+
+	message := "invitation declined"
+	if accept {
+		message = "invitation accepted"
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
+// createLedgerExpense records a shared expense. The payer defaults to the
+// caller, and an equal split without participants is shared by all members.
+func (s *Server) createLedgerExpense(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	expense := new(types.LedgerExpense)
+	if err := json.NewDecoder(r.Body).Decode(expense); err != nil {
+		return err
+	}
+	if _, err := time.Parse("2006-01-02", expense.Date); err != nil {
+		return fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+
+	members, err := s.store.GetLedgerMembers(ledgerID)
+	if err != nil {
+		return err
+	}
+	isMember := make(map[string]bool, len(members))
+	for _, m := range members {
+		isMember[m.UserID] = true
+	}
+
+	if expense.PaidBy == "" {
+		expense.PaidBy = userID
+	}
+	if expense.SplitMethod == "" {
+		expense.SplitMethod = types.SplitEqual
+	}
+	if expense.SplitMethod == types.SplitEqual && len(expense.Splits) == 0 {
+		for _, m := range members {
+			expense.Splits = append(expense.Splits, types.Split{UserID: m.UserID})
+		}
+	}
+
+	if !isMember[expense.PaidBy] {
+		return fmt.Errorf("paidBy must be a member of the ledger")
+	}
+	for _, sp := range expense.Splits {
+		if !isMember[sp.UserID] {
+			return fmt.Errorf("user %s is not a member of the ledger", sp.UserID)
+		}
+	}
+
+	expense.Splits, err = split.Allocate(expense.Amount, expense.SplitMethod, expense.Splits)
+	if err != nil {
+		return err
+	}
+
+	expense.LedgerID = ledgerID
+	expense.CreatedBy = userID
+	if err := s.store.CreateLedgerExpense(expense); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, expense)
+}
+
+func (s *Server) getLedgerExpenses(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	expenses, err := s.store.GetLedgerExpenses(ledgerID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, expenses)
+}
+
+func (s *Server) deleteLedgerExpense(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteLedgerExpense(ledgerID, mux.Vars(r)["expenseId"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("expense %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "expense deleted"})
+}
+
+// createSettlement records a direct payment between two members, such as
+// one step of the settle-up plan.
+func (s *Server) createSettlement(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	settlement := new(types.Settlement)
+	if err := json.NewDecoder(r.Body).Decode(settlement); err != nil {
+		return err
+	}
+	if settlement.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if settlement.FromUser == "" {
+		settlement.FromUser = userID
+	}
+	if settlement.FromUser == settlement.ToUser {
+		return fmt.Errorf("a settlement needs two different members")
+	}
+	if settlement.Date == "" {
+		settlement.Date = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", settlement.Date); err != nil {
+		return fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+
+	for _, member := range []string{settlement.FromUser, settlement.ToUser} {
+		if _, err := s.store.GetLedgerRole(ledgerID, member); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("user %s is not a member of the ledger", member)
+			}
+			return err
+		}
+	}
+
+	settlement.LedgerID = ledgerID
+	settlement.CreatedBy = userID
+	if err := s.store.CreateSettlement(settlement); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, settlement)
+}
+
+func (s *Server) getSettlements(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	settlements, err := s.store.GetSettlements(ledgerID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, settlements)
+}
+
+// getLedgerBalances reports who owes whom, with the shortest list of
+// transfers that would settle the ledger.
+func (s *Server) getLedgerBalances(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	ledgerID, _, err := s.authorizeLedger(r, userID, types.LedgerRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	balances, err := s.store.GetLedgerBalances(ledgerID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, types.LedgerBalances{
+		Balances: balances,
+		SettleUp: split.SettleUp(balances),
+	})
+}
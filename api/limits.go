@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/limits"
+	"github.com/Ayikoandrew/server/types"
+)
+
+const maxLimitRules = 500
+
+// limitRejection is the body of a 403 for a transaction over its limits.
+type limitRejection struct {
+	Err        string                 `json:"err"`
+	Code       string                 `json:"code"`
+	Violations []types.LimitViolation `json:"violations"`
+}
+
+// rejectOverLimit answers a transaction the limits turned down.
+func rejectOverLimit(w http.ResponseWriter, limitErr *database.LimitError) error {
+	return writeJSON(w, http.StatusForbidden, limitRejection{
+		Err:        limitErr.Error(),
+		Code:       "limit_exceeded",
+		Violations: limitErr.Decision.Violations,
+	})
+}
+
+func (s *Server) getLimitRules(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	rules, err := s.store.GetLimitRules()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, rules)
+}
+
+// replaceLimitRules swaps the whole limit configuration for the rules in
+// the body. Each tier, action, metric and currency can appear only once.
+func (s *Server) replaceLimitRules(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+	adminID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	var rules []types.LimitRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		return err
+	}
+	if len(rules) > maxLimitRules {
+		return fmt.Errorf("at most %d rules can be configured", maxLimitRules)
+	}
+	seen := map[types.LimitRule]bool{}
+	for i := range rules {
+		if err := limits.ValidateRule(&rules[i]); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		key := types.LimitRule{Tier: rules[i].Tier, Action: rules[i].Action, Metric: rules[i].Metric, Currency: rules[i].Currency}
+		if seen[key] {
+			return fmt.Errorf("rule %d: duplicates an earlier rule", i+1)
+		}
+		seen[key] = true
+	}
+
+	if err := s.store.ReplaceLimitRules(rules); err != nil {
+		return err
+	}
+	err = s.store.RecordAuditEvent(&types.AuditEvent{
+		UserID:  adminID,
+		ActorID: adminID,
+		Action:  "limit_rules_replaced",
+		Outcome: "saved",
+		Details: map[string]any{"rules": rules},
+	})
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *Server) getAuditEvents(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+	limit, err := intParam(r, "limit", 100, 1, 1000)
+	if err != nil {
+		return err
+	}
+
+	q := r.URL.Query()
+	events, err := s.store.GetAuditEvents(q.Get("userId"), q.Get("action"), limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, events)
+}
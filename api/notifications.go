@@ -0,0 +1,37 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getNotifications(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	notifications, err := s.store.GetNotifications(userID, r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, notifications)
+}
+
+func (s *Server) markNotificationRead(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.MarkNotificationRead(userID, mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "notification not found"})
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "notification marked as read"})
+}
@@ -0,0 +1,395 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/payments"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxWebhookBody = 64 << 10
+
+	// openPaymentAge is how long a payment may wait for its callback before
+	// the maintenance job asks the provider about it.
+	openPaymentAge = 15 * time.Minute
+)
+
+var errPaymentsDisabled = errors.New("payments are not configured")
+
+// createTopUp asks the provider to collect money from the user's mobile
+// money number. The wallet is credited once the provider confirms it.
+func (s *Server) createTopUp(w http.ResponseWriter, r *http.Request) error {
+	return s.startPayment(w, r, types.PaymentTopUp)
+}
+
+// createWithdrawal pays money out of the user's wallet to a mobile money
+// number. The amount is held from the wallet straight away and returned if
+// the payout fails. It needs the transaction PIN and must be within the
+// user's limits.
+func (s *Server) createWithdrawal(w http.ResponseWriter, r *http.Request) error {
+	return s.startPayment(w, r, types.PaymentWithdrawal)
+}
+
+func (s *Server) startPayment(w http.ResponseWriter, r *http.Request, kind types.PaymentKind) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	if s.provider == nil {
+		return errPaymentsDisabled
+	}
+
+	req := new(types.PaymentRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return err
+	}
+
+	user, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := validatePaymentRequest(req, user); err != nil {
+		return err
+	}
+	if kind == types.PaymentWithdrawal {
+		if err := s.requirePIN(r, userID); err != nil {
+			return err
+		}
+	}
+
+	payment := &types.Payment{
+		UserID:   userID,
+		Kind:     kind,
+		Amount:   req.Amount,
+		Currency: req.Currency,
+		Phone:    req.Phone,
+		Provider: s.provider.Name(),
+	}
+	if err := s.store.CreatePayment(payment); err != nil {
+		var limitErr *database.LimitError
+		if errors.As(err, &limitErr) {
+			return rejectOverLimit(w, limitErr)
+		}
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	providerReq := payments.Request{
+		Reference:   payment.ID,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Phone:       payment.Phone,
+		Description: "Liora " + string(kind),
+	}
+	var result payments.Result
+	if kind == types.PaymentTopUp {
+		result, err = s.provider.Collect(ctx, providerReq)
+	} else {
+		result, err = s.provider.Payout(ctx, providerReq)
+	}
+	if err != nil {
+		if !errors.Is(err, payments.ErrRejected) {
+			// A timeout or network error says nothing about whether the
+			// provider took the payment. It stays pending, with any hold in
+			// place, until pollPayments asks the provider about it.
+			slog.Warn("Payment outcome unknown", "error", err, "payment", payment.ID)
+			return writeJSON(w, http.StatusAccepted, payment)
+		}
+		slog.Error("Payment provider rejected payment", "error", err, "payment", payment.ID)
+		failed, err := s.store.SettlePayment(payment.ID, types.PaymentFailed, "provider rejected the payment")
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, http.StatusBadGateway, failed)
+	}
+
+	// From here on the provider has the payment, so errors are only logged:
+	// failing the request would invite a retry that pays out twice. The
+	// callback or pollPayments stores the provider's reference and the
+	// outcome instead.
+	if err := s.store.MarkPaymentProcessing(payment.ID, result.ProviderRef); err != nil {
+		if !errors.Is(err, database.ErrInvalidPaymentState) {
+			slog.Error("Failed to store provider reference", "error", err, "payment", payment.ID)
+			return writeJSON(w, http.StatusAccepted, payment)
+		}
+		// The provider's callback settled the payment first.
+		settled, err := s.store.GetPaymentByID(payment.ID)
+		if err != nil {
+			slog.Error("Failed to load settled payment", "error", err, "payment", payment.ID)
+			return writeJSON(w, http.StatusAccepted, payment)
+		}
+		return writeJSON(w, http.StatusAccepted, settled)
+	}
+	payment.Status = types.PaymentProcessing
+	payment.ProviderRef = result.ProviderRef
+
+	if payments.Final(result.Status) {
+		settled, err := s.settle(*payment, result)
+		if err != nil {
+			slog.Error("Failed to settle payment", "error", err, "payment", payment.ID)
+			return writeJSON(w, http.StatusAccepted, payment)
+		}
+		payment = settled
+	}
+	return writeJSON(w, http.StatusAccepted, payment)
+}
+
+func (s *Server) getPayments(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	kind := types.PaymentKind(r.URL.Query().Get("kind"))
+	if kind != "" && kind != types.PaymentTopUp && kind != types.PaymentWithdrawal {
+		return fmt.Errorf("kind must be topup or withdrawal")
+	}
+	limit, err := intParam(r, "limit", 50, 1, 500)
+	if err != nil {
+		return err
+	}
+
+	list, err := s.store.GetPayments(userID, kind, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) getPayment(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	payment, err := s.store.GetPayment(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("payment %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, payment)
+}
+
+// paymentWebhook receives a provider's callback with a payment's outcome.
+// The body must carry a valid HMAC signature; repeated callbacks for a
+// settled payment are acknowledged without effect. Callbacks we fail to
+// process get a 500 so the provider sends them again.
+func (s *Server) paymentWebhook(w http.ResponseWriter, r *http.Request) error {
+	if s.provider == nil || mux.Vars(r)["provider"] != s.provider.Name() {
+		return fmt.Errorf("payment provider %w", errNotFound)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		return err
+	}
+	err = payments.VerifySignature(s.webhookSecret, r.Header.Get(payments.TimestampHeader),
+		r.Header.Get(payments.SignatureHeader), body, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnauthorized, err)
+	}
+
+	result, err := s.provider.ParseCallback(body)
+	if err != nil {
+		return err
+	}
+
+	payment, err := s.callbackPayment(result)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("payment %w", errNotFound)
+		}
+		return retryCallback(w, err)
+	}
+	if _, err := s.settle(*payment, result); err != nil {
+		if errors.Is(err, database.ErrInvalidPaymentState) {
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return retryCallback(w, err)
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+}
+
+// callbackPayment finds the payment a callback is about. A callback can
+// arrive before startPayment has stored the provider's reference, so it
+// falls back to our own reference and stores the provider's with it.
+func (s *Server) callbackPayment(result payments.Result) (*types.Payment, error) {
+	payment, err := s.store.GetPaymentByProviderRef(s.provider.Name(), result.ProviderRef)
+	if !errors.Is(err, sql.ErrNoRows) || result.Reference == "" {
+		return payment, err
+	}
+
+	payment, err = s.store.GetPaymentByID(result.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Provider != s.provider.Name() || payment.ProviderRef != "" {
+		return nil, sql.ErrNoRows
+	}
+	err = s.store.MarkPaymentProcessing(payment.ID, result.ProviderRef)
+	if err != nil && !errors.Is(err, database.ErrInvalidPaymentState) {
+		return nil, err
+	}
+	payment.ProviderRef = result.ProviderRef
+	return payment, nil
+}
+
+func retryCallback(w http.ResponseWriter, err error) error {
+	slog.Error("Failed to process payment callback", "error", err)
+	return writeJSON(w, http.StatusInternalServerError, Err{Err: "callback could not be processed"})
+}
+
+// settle applies a final outcome from the provider and tells the user. A
+// status that is not final, such as a callback saying the payment is still
+// processing, changes nothing.
+func (s *Server) settle(payment types.Payment, result payments.Result) (*types.Payment, error) {
+	if payment.Status == result.Status || !payments.Final(result.Status) {
+		return &payment, nil
+	}
+
+	settled, err := s.store.SettlePayment(payment.ID, result.Status, result.FailureReason)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyPaymentSettled(*settled)
+	return settled, nil
+}
+
+func (s *Server) notifyPaymentSettled(p types.Payment) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	action := "Top-up"
+	if p.Kind == types.PaymentWithdrawal {
+		action = "Withdrawal"
+	}
+	note := types.Notification{
+		UserID: p.UserID,
+		Kind:   "payment_" + string(p.Status),
+		Title:  fmt.Sprintf("%s %s", action, p.Status),
+		Body:   fmt.Sprintf("%s of %s %s.", action, ledger.Format(p.Amount, p.Currency), p.Status),
+		Data:   map[string]string{"paymentId": p.ID},
+	}
+	if p.Status == types.PaymentFailed && p.FailureReason != "" {
+		note.Body = fmt.Sprintf("%s of %s failed: %s.", action, ledger.Format(p.Amount, p.Currency), p.FailureReason)
+	}
+	if err := s.notifier.Send(ctx, note, types.ChannelInApp, types.ChannelPush); err != nil {
+		slog.Error("Failed to notify payment outcome", "error", err, "payment", p.ID)
+	}
+}
+
+// StartPaymentPolling runs pollPayments every interval, so a payment whose
+// callback was lost settles soon after openPaymentAge.
+func (s *Server) StartPaymentPolling(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			s.pollPayments()
+		}
+	}()
+}
+
+// pollPayments asks the provider about payments whose callback never
+// arrived. A payment the provider never stored a reference for is looked
+// up by our own reference, and only failed once the provider confirms it
+// has no record of it; until then any hold stays in place.
+func (s *Server) pollPayments() {
+	if s.provider == nil {
+		return
+	}
+
+	open, err := s.store.GetOpenPayments(openPaymentAge, 500)
+	if err != nil {
+		slog.Error("Failed to load open payments", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	for _, p := range open {
+		if p.Provider != s.provider.Name() {
+			continue
+		}
+
+		var result payments.Result
+		if p.ProviderRef != "" {
+			result, err = s.provider.Status(ctx, p.ProviderRef)
+		} else {
+			result, err = s.provider.StatusByReference(ctx, p.ID)
+			if errors.Is(err, payments.ErrUnknownPayment) {
+				result = payments.Result{Status: types.PaymentFailed, FailureReason: "not accepted by the provider"}
+				err = nil
+			}
+		}
+		if err != nil {
+			slog.Error("Failed to query payment status", "error", err, "payment", p.ID)
+			continue
+		}
+
+		if p.ProviderRef == "" && result.ProviderRef != "" {
+			err := s.store.MarkPaymentProcessing(p.ID, result.ProviderRef)
+			if err != nil && !errors.Is(err, database.ErrInvalidPaymentState) {
+				slog.Error("Failed to store provider reference", "error", err, "payment", p.ID)
+				continue
+			}
+		}
+		if !payments.Final(result.Status) {
+			continue
+		}
+		if _, err := s.settle(p, result); err != nil {
+			slog.Error("Failed to settle payment", "error", err, "payment", p.ID)
+		}
+	}
+}
+
+func validatePaymentRequest(req *types.PaymentRequest, user *types.User) error {
+	if req.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+
+	if req.Currency == "" {
+		req.Currency = user.HomeCurrency
+	}
+	if req.Currency == "" {
+		req.Currency = fx.DefaultCurrency
+	}
+	currency, err := fx.NormalizeCurrency(req.Currency)
+	if err != nil {
+		return err
+	}
+	req.Currency = currency
+
+	req.Phone = strings.TrimSpace(req.Phone)
+	if req.Phone == "" {
+		req.Phone = user.PhoneNumber
+	}
+	digits := strings.TrimPrefix(strings.NewReplacer(" ", "", "-", "").Replace(req.Phone), "+")
+	if len(digits) < 9 || len(digits) > 15 || strings.Trim(digits, "0123456789") != "" {
+		return fmt.Errorf("phone must be a valid mobile number")
+	}
+	req.Phone = digits
+	return nil
+}
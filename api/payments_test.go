@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/payments"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePaymentRequest(t *testing.T) {
+	user := &types.User{PhoneNumber: "+256 772-123456", HomeCurrency: "UGX"}
+
+	req := types.PaymentRequest{Amount: 50000}
+	require.NoError(t, validatePaymentRequest(&req, user))
+	assert.Equal(t, "UGX", req.Currency)
+	assert.Equal(t, "256772123456", req.Phone)
+
+	req = types.PaymentRequest{Amount: 1000, Currency: "kes", Phone: "254700000001"}
+	require.NoError(t, validatePaymentRequest(&req, user))
+	assert.Equal(t, "KES", req.Currency)
+	assert.Equal(t, "254700000001", req.Phone)
+
+	invalid := []types.PaymentRequest{
+		{Amount: 0},
+		{Amount: 100, Currency: "shillings"},
+		{Amount: 100, Phone: "12345"},
+		{Amount: 100, Phone: "2567721234ab"},
+	}
+	for _, r := range invalid {
+		assert.Error(t, validatePaymentRequest(&r, user))
+	}
+}
+
+func TestPaymentWebhookSignature(t *testing.T) {
+	secret := []byte("s3cret")
+	s := &Server{provider: payments.NewFake("", secret, 0), webhookSecret: secret}
+	router := mux.NewRouter()
+	router.Handle("/payments/webhook/{provider}", makeHTTPHandlerFunc(s.paymentWebhook))
+
+	body := `{"reference":"fake-1","status":"succeeded"}`
+	send := func(provider, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/payments/webhook/"+provider, strings.NewReader(body))
+		req.Header.Set(payments.TimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+		req.Header.Set(payments.SignatureHeader, signature)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("fake", "deadbeef"))
+	assert.Equal(t, http.StatusUnauthorized, send("fake", ""))
+	assert.Equal(t, http.StatusNotFound, send("other", "deadbeef"))
+}
+
+func TestSettleIgnoresNonFinalStatus(t *testing.T) {
+	// No store is needed: a status that is not final must not reach it.
+	s := &Server{}
+	payment := types.Payment{ID: "p1", Status: types.PaymentPending}
+
+	got, err := s.settle(payment, payments.Result{ProviderRef: "fake-1", Status: types.PaymentProcessing})
+	require.NoError(t, err)
+	assert.Equal(t, types.PaymentPending, got.Status)
+}
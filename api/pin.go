@@ -0,0 +1,138 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/middleware"
+	"github.com/Ayikoandrew/server/pin"
+	"github.com/Ayikoandrew/server/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PINHeader carries the transaction PIN on every request that moves money
+// out of a wallet.
+const PINHeader = "X-Transaction-PIN"
+
+func (s *Server) getPINStatus(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	status, err := s.store.GetPINStatus(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, status)
+}
+
+// setPIN sets the caller's first transaction PIN.
+func (s *Server) setPIN(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(types.PINRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return err
+	}
+	hash, err := pin.Hash(req.PIN)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.SetPIN(userID, hash, false); err != nil {
+		if errors.Is(err, database.ErrPINAlreadySet) {
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return err
+	}
+	return s.getPINStatus(w, r)
+}
+
+// changePIN replaces the caller's PIN after checking the current one,
+// which counts towards the lockout like any other attempt.
+func (s *Server) changePIN(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(types.PINRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return err
+	}
+	hash, err := pin.Hash(req.PIN)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.VerifyPIN(userID, req.CurrentPIN); err != nil {
+		return pinError(err)
+	}
+	if err := s.store.SetPIN(userID, hash, true); err != nil {
+		return err
+	}
+	return s.getPINStatus(w, r)
+}
+
+// resetPIN replaces a forgotten or locked PIN once the caller proves who
+// they are with their login password.
+func (s *Server) resetPIN(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(types.PINRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return err
+	}
+	hash, err := pin.Hash(req.PIN)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.CheckPassword(userID, req.Password); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: incorrect password", errForbidden)
+		}
+		return err
+	}
+	if err := s.store.SetPIN(userID, hash, true); err != nil {
+		return err
+	}
+	return s.getPINStatus(w, r)
+}
+
+// requirePIN checks the PIN sent in PINHeader before money leaves the
+// caller's wallet. When it fails nothing has happened yet, so the
+// Idempotency-Key is freed for a retry with the right PIN.
+func (s *Server) requirePIN(r *http.Request, userID string) error {
+	p := r.Header.Get(PINHeader)
+	if p == "" {
+		middleware.AllowRetry(r)
+		return fmt.Errorf("%w: %s header is required", errForbidden, PINHeader)
+	}
+	if err := s.store.VerifyPIN(userID, p); err != nil {
+		middleware.AllowRetry(r)
+		return pinError(err)
+	}
+	return nil
+}
+
+func pinError(err error) error {
+	switch {
+	case errors.Is(err, database.ErrPINNotSet),
+		errors.Is(err, database.ErrWrongPIN),
+		errors.Is(err, database.ErrPINLocked):
+		return fmt.Errorf("%w: %v", errForbidden, err)
+	}
+	return err
+}
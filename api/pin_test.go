@@ -0,0 +1,33 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePINHeader(t *testing.T) {
+	s := &Server{}
+	req := httptest.NewRequest("POST", "/transfers", nil)
+	assert.ErrorIs(t, s.requirePIN(req, "user-1"), errForbidden)
+}
+
+func TestPINError(t *testing.T) {
+	for _, err := range []error{
+		database.ErrPINNotSet,
+		database.ErrPINLocked,
+		fmt.Errorf("%w, 2 attempts left", database.ErrWrongPIN),
+	} {
+		mapped := pinError(err)
+		assert.ErrorIs(t, mapped, errForbidden)
+		assert.Contains(t, mapped.Error(), err.Error())
+	}
+
+	other := errors.New("connection reset")
+	assert.Equal(t, other, pinError(other))
+	assert.NoError(t, pinError(nil))
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/types"
+)
+
+func (s *Server) getProfile(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.store.GetUser(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "user not found"})
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) updateProfile(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	update := new(types.ProfileUpdate)
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
+		return err
+	}
+
+	if update.Timezone != nil {
+		if _, err := time.LoadLocation(*update.Timezone); err != nil || *update.Timezone == "" {
+			return fmt.Errorf("unknown timezone %q", *update.Timezone)
+		}
+	}
+
+	if update.HomeCurrency != nil {
+		currency, err := fx.NormalizeCurrency(*update.HomeCurrency)
+		if err != nil {
+			return err
+		}
+		update.HomeCurrency = &currency
+	}
+
+	if err := s.store.UpdateProfile(userID, *update); err != nil {
+		return err
+	}
+	return s.getProfile(w, r)
+}
+
+// requireAdmin fails with errForbidden unless the caller has the admin role.
+func (s *Server) requireAdmin(r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if user.Role != types.RoleAdmin {
+		return fmt.Errorf("%w: admin role required", errForbidden)
+	}
+	return nil
+}
+
+// reportSettings returns the IANA zone and currency to report in: the tz
+// and currency query parameters when given, otherwise the ones saved on the
+// user's profile.
+func (s *Server) reportSettings(r *http.Request, userID string) (timezone, currency string, err error) {
+	q := r.URL.Query()
+	timezone, currency = q.Get("tz"), q.Get("currency")
+	if timezone == "" || currency == "" {
+		user, err := s.store.GetUser(userID)
+		if err != nil {
+			return "", "", err
+		}
+		if timezone == "" {
+			timezone = user.Timezone
+		}
+		if currency == "" {
+			currency = user.HomeCurrency
+		}
+	}
+
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return "", "", fmt.Errorf("unknown timezone %q", timezone)
+	}
+
+	if currency == "" {
+		currency = fx.DefaultCurrency
+	}
+	if currency, err = fx.NormalizeCurrency(currency); err != nil {
+		return "", "", err
+	}
+	return timezone, currency, nil
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/Ayikoandrew/server/blob"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxReceiptSize = 10 << 20
+	receiptURLTTL  = 5 * time.Minute
+)
+
+// receiptTypes lists the accepted image types, keyed by the sniffed content
+// type, with the extension the blob is stored under.
+var receiptTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+func (s *Server) uploadReceipt(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	if s.blobs == nil {
+		return writeJSON(w, http.StatusServiceUnavailable, Err{Err: "receipt storage is not configured"})
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxReceiptSize+1<<20)
+	if err := r.ParseMultipartForm(maxReceiptSize); err != nil {
+		return writeJSON(w, http.StatusRequestEntityTooLarge, Err{Err: "receipt must be smaller than 10MB"})
+	}
+
+	file, header, err := r.FormFile("receipt")
+	if err != nil {
+		return fmt.Errorf("receipt file is required")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxReceiptSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxReceiptSize {
+		return writeJSON(w, http.StatusRequestEntityTooLarge, Err{Err: "receipt must be smaller than 10MB"})
+	}
+
+	// Trust the bytes, not the client's Content-Type header.
+	contentType := http.DetectContentType(data)
+	ext, ok := receiptTypes[contentType]
+	if !ok {
+		return writeJSON(w, http.StatusUnsupportedMediaType, Err{Err: "receipt must be a JPEG, PNG, GIF or WebP image"})
+	}
+
+	expenseID := mux.Vars(r)["id"]
+	key, err := blob.NewKey("receipts/"+userID+"/"+expenseID, ext)
+	if err != nil {
+		return err
+	}
+	if err := s.blobs.Put(r.Context(), key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		slog.Error("Failed to store receipt", "error", err)
+		return fmt.Errorf("failed to store receipt")
+	}
+
+	receipt := &types.Receipt{
+		ExpenseID:   expenseID,
+		UserID:      userID,
+		BlobKey:     key,
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	if err := s.store.CreateReceipt(receipt); err != nil {
+		s.deleteBlobs(key)
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "expense not found"})
+		}
+		return err
+	}
+
+	if receipt.URL, err = s.blobs.SignedURL(r.Context(), key, receiptURLTTL); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, receipt)
+}
+
+func (s *Server) getReceipts(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	receipts, err := s.store.GetReceipts(userID, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	if s.blobs != nil {
+		for i := range receipts {
+			if receipts[i].URL, err = s.blobs.SignedURL(r.Context(), receipts[i].BlobKey, receiptURLTTL); err != nil {
+				return err
+			}
+		}
+	}
+	return writeJSON(w, http.StatusOK, receipts)
+}
+
+// getReceiptURL hands out a fresh short-lived download link.
+func (s *Server) getReceiptURL(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	if s.blobs == nil {
+		return writeJSON(w, http.StatusServiceUnavailable, Err{Err: "receipt storage is not configured"})
+	}
+
+	receipt, err := s.store.GetReceipt(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "receipt not found"})
+		}
+		return err
+	}
+
+	if receipt.URL, err = s.blobs.SignedURL(r.Context(), receipt.BlobKey, receiptURLTTL); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, receipt)
+}
+
+func (s *Server) deleteReceipt(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	key, err := s.store.DeleteReceipt(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "receipt not found"})
+		}
+		return err
+	}
+
+	s.deleteBlobs(key)
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "receipt deleted"})
+}
+
+// deleteBlobs removes files whose database rows are already gone. Failures
+// are only logged: the row is the source of truth and an orphaned file is
+// unreachable without it.
+func (s *Server) deleteBlobs(keys ...string) {
+	if s.blobs == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := s.blobs.Delete(context.Background(), key); err != nil {
+			slog.Error("Failed to delete blob", "error", err, "key", key)
+		}
+	}
+}
+
+// serveBlob serves files for the local blob store. Access is granted by the
+// signature in the URL rather than by a session.
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request) error {
+	local, ok := s.blobs.(*blob.LocalStore)
+	if !ok {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	key := mux.Vars(r)["key"]
+	q := r.URL.Query()
+	if err := local.Verify(key, q.Get("expires"), q.Get("sig")); err != nil {
+		return writeJSON(w, http.StatusForbidden, Err{Err: err.Error()})
+	}
+
+	rc, err := local.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			http.NotFound(w, r)
+			return nil
+		}
+		return err
+	}
+	defer rc.Close()
+
+	if contentType := mime.TypeByExtension(filepath.Ext(key)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, rc); err != nil {
+		slog.Error("Failed to serve blob", "error", err, "key", key)
+	}
+	return nil
+}
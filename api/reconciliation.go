@@ -0,0 +1,272 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/payments"
+	"github.com/Ayikoandrew/server/reconcile"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const maxSettlementFileSize = 10 << 20
+
+// createReconciliation checks a provider's settlement report, sent as the
+// "file" field of a multipart form, against the payments made through
+// that provider between the form's from and to dates. Payments we still
+// have open that the report shows settled are settled from the report
+// unless autoResolve is "false"; every other discrepancy, and any stuck
+// payment that could not be settled, is left open for review.
+func (s *Server) createReconciliation(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+	adminID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSettlementFileSize)
+	if err := r.ParseMultipartForm(maxSettlementFileSize); err != nil {
+		return fmt.Errorf("invalid upload: %w", err)
+	}
+
+	run := types.ReconciliationRun{
+		Provider:  strings.TrimSpace(r.FormValue("provider")),
+		From:      r.FormValue("from"),
+		To:        r.FormValue("to"),
+		CreatedBy: adminID,
+	}
+	if run.Provider == "" && s.provider != nil {
+		run.Provider = s.provider.Name()
+	}
+	if err := validateReconciliationRun(&run); err != nil {
+		return err
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return fmt.Errorf("file is required")
+	}
+	defer file.Close()
+	run.Filename = header.Filename
+
+	rows, err := reconcile.ParseCSV(file)
+	if err != nil {
+		return err
+	}
+	run.RowCount = len(rows)
+
+	list, err := s.providerPayments(run.Provider, run.From, run.To, rows)
+	if err != nil {
+		return err
+	}
+	run.Items, run.Matched = reconcile.Match(rows, list)
+
+	if r.FormValue("autoResolve") != "false" {
+		s.autoResolve(run.Items, list)
+	}
+
+	if err := s.store.CreateReconciliation(&run); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, run)
+}
+
+// providerPayments returns the provider's payments in the period plus any
+// older ones the report mentions, such as payments created just before the
+// period that the provider settled within it.
+func (s *Server) providerPayments(provider, from, to string, rows []types.SettlementRow) ([]types.Payment, error) {
+	list, err := s.store.GetProviderPayments(provider, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, p := range list {
+		known[p.ProviderRef] = true
+	}
+	for _, row := range rows {
+		if known[row.Reference] {
+			continue
+		}
+		known[row.Reference] = true
+
+		p, err := s.store.GetPaymentByProviderRef(provider, row.Reference)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *p)
+	}
+	return list, nil
+}
+
+// autoResolve settles stuck payments with the outcome from the report.
+func (s *Server) autoResolve(items []types.ReconciliationItem, list []types.Payment) {
+	byID := map[string]types.Payment{}
+	for _, p := range list {
+		byID[p.ID] = p
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.Kind != types.DiscrepancyStuck {
+			continue
+		}
+
+		result := payments.Result{ProviderRef: item.ProviderRef, Status: item.ReportedStatus}
+		if result.Status == types.PaymentFailed {
+			result.FailureReason = "reported failed in settlement report"
+		}
+		if _, err := s.settle(byID[item.PaymentID], result); err != nil {
+			slog.Error("Failed to auto-resolve payment", "error", err, "payment", item.PaymentID)
+			item.Note = "auto-resolve failed: " + err.Error()
+			continue
+		}
+		item.Resolution = types.ResolutionAutoResolved
+		item.Note = "settled as " + string(result.Status) + " from settlement report"
+	}
+}
+
+func (s *Server) getReconciliations(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+	limit, err := intParam(r, "limit", 50, 1, 500)
+	if err != nil {
+		return err
+	}
+
+	runs, err := s.store.GetReconciliations(limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) getReconciliation(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	run, err := s.store.GetReconciliation(mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("reconciliation %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, run)
+}
+
+// getReviewQueue lists the discrepancies from every run that still need an
+// admin's decision.
+func (s *Server) getReviewQueue(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+	limit, err := intParam(r, "limit", 100, 1, 1000)
+	if err != nil {
+		return err
+	}
+
+	items, err := s.store.GetReviewQueue(limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, items)
+}
+
+// resolveReconciliationItem closes a review item. The settle actions apply
+// that outcome to the item's payment first, posting the ledger entry as a
+// provider callback would.
+func (s *Server) resolveReconciliationItem(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+	adminID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	var action types.ReconciliationAction
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		return err
+	}
+
+	item, err := s.store.GetReconciliationItem(mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("reconciliation item %w", errNotFound)
+		}
+		return err
+	}
+	if item.Resolution != types.ResolutionOpen {
+		return writeJSON(w, http.StatusConflict, Err{Err: database.ErrItemResolved.Error()})
+	}
+
+	resolution := types.ResolutionResolved
+	switch action.Action {
+	case "settle_succeeded", "settle_failed":
+		if item.PaymentID == "" {
+			return fmt.Errorf("item has no payment to settle")
+		}
+		result := payments.Result{Status: types.PaymentSucceeded}
+		if action.Action == "settle_failed" {
+			result = payments.Result{Status: types.PaymentFailed, FailureReason: "failed after reconciliation"}
+		}
+
+		payment, err := s.store.GetPaymentByID(item.PaymentID)
+		if err != nil {
+			return err
+		}
+		if _, err := s.settle(*payment, result); err != nil {
+			if errors.Is(err, database.ErrInvalidPaymentState) {
+				return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+			}
+			return ledgerError(w, err)
+		}
+	case "dismiss":
+		resolution = types.ResolutionDismissed
+	default:
+		return fmt.Errorf("action must be settle_succeeded, settle_failed or dismiss")
+	}
+
+	resolved, err := s.store.ResolveReconciliationItem(item.ID, resolution, strings.TrimSpace(action.Note), adminID)
+	if err != nil {
+		if errors.Is(err, database.ErrItemResolved) {
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, resolved)
+}
+
+func validateReconciliationRun(run *types.ReconciliationRun) error {
+	if run.Provider == "" {
+		return fmt.Errorf("provider is required")
+	}
+	if run.From == "" || run.To == "" {
+		return fmt.Errorf("from and to are required")
+	}
+	for _, date := range []string{run.From, run.To} {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("dates must be in YYYY-MM-DD format")
+		}
+	}
+	if run.From > run.To {
+		return fmt.Errorf("from must not be after to")
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Ayikoandrew/server/recurring"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createRecurringExpense(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	template := new(types.RecurringExpense)
+	if err := json.NewDecoder(r.Body).Decode(template); err != nil {
+		return err
+	}
+
+	if template.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	if template.Interval == 0 {
+		template.Interval = 1
+	}
+	switch template.Type {
+	case "", types.RecurringExpenseType:
+		template.Type = types.RecurringExpenseType
+		template.IncomeKind = ""
+	case types.RecurringIncomeType:
+		if template.IncomeKind == "" {
+			template.IncomeKind = types.IncomeOther
+		}
+		if !validIncomeKind(template.IncomeKind) {
+			return fmt.Errorf("incomeKind must be salary, transfer_in or other")
+		}
+	default:
+		return fmt.Errorf("type must be expense or income")
+	}
+	if _, err := recurring.FromExpense(*template); err != nil {
+		return err
+	}
+
+	template.UserID = userID
+	if err := s.store.CreateRecurringExpense(template); err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, template)
+}
+
+func (s *Server) getRecurringExpenses(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	templates, err := s.store.GetRecurringExpenses(userID)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, templates)
+}
+
+func (s *Server) deleteRecurringExpense(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeactivateRecurringExpense(userID, mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "recurring expense not found"})
+		}
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "recurring expense stopped"})
+}
+
+// updateRecurringOccurrence skips or modifies a single occurrence without
+// touching the rest of the schedule.
+func (s *Server) updateRecurringOccurrence(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	vars := mux.Vars(r)
+	if _, err := time.Parse(recurring.DateLayout, vars["date"]); err != nil {
+		return fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+
+	exception := new(types.RecurringException)
+	if err := json.NewDecoder(r.Body).Decode(exception); err != nil {
+		return err
+	}
+	if exception.Amount != nil && *exception.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+
+	exception.RecurringID = vars["id"]
+	exception.OccurrenceDate = vars["date"]
+	if err := s.store.SetRecurringException(userID, exception); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return writeJSON(w, http.StatusNotFound, Err{Err: "recurring expense not found"})
+		}
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, exception)
+}
+
+// generateRecurringExpenses creates every occurrence that has fallen due since
+// the last run. Templates remember how far they have been generated, so a run
+// after downtime catches up on missed occurrences, spreading a long backlog
+// over several runs.
+func (s *Server) generateRecurringExpenses(now time.Time) error {
+	templates, err := s.store.GetActiveRecurringExpenses()
+	if err != nil {
+		return err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, template := range templates {
+		exceptions, err := s.store.GetRecurringExceptions(template.ID)
+		if err != nil {
+			slog.Error("Failed to load recurring exceptions", "error", err, "recurringId", template.ID)
+			continue
+		}
+
+		expenses, through, finished, err := recurring.Expand(template, exceptions, today)
+		if err != nil {
+			slog.Error("Invalid recurring expense", "error", err, "recurringId", template.ID)
+			continue
+		}
+
+		var inserted int
+		if template.Type == types.RecurringIncomeType {
+			inserted, err = s.store.InsertRecurringIncome(template.ID, recurringIncome(template, expenses), through.Format(recurring.DateLayout), finished)
+		} else {
+			inserted, err = s.store.InsertRecurringOccurrences(template.ID, expenses, through.Format(recurring.DateLayout), finished)
+		}
+		if err != nil {
+			slog.Error("Failed to generate recurring expenses", "error", err, "recurringId", template.ID)
+			continue
+		}
+		if inserted > 0 {
+			slog.Info("Generated recurring expenses", "recurringId", template.ID, "count", inserted)
+		}
+	}
+	return nil
+}
+
+// recurringIncome turns the occurrences expanded from an income template
+// into income entries.
+func recurringIncome(template types.RecurringExpense, occurrences []types.Expense) []types.Income {
+	incomes := make([]types.Income, 0, len(occurrences))
+	for _, o := range occurrences {
+		incomes = append(incomes, types.Income{
+			UserID:      o.UserID,
+			Amount:      o.Amount,
+			Date:        o.Date,
+			Kind:        template.IncomeKind,
+			Source:      template.Source,
+			Description: o.Description,
+			RecurringID: template.ID,
+		})
+	}
+	return incomes
+}
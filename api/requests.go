@@ -0,0 +1,374 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxInvoiceItems = 100
+
+	// requestLifetime is how long a request without a due date stays
+	// payable.
+	requestLifetime = 30 * 24 * time.Hour
+)
+
+// reminderDays are how many days before the due date the payer is
+// reminded of a pending request.
+var reminderDays = []int{3, 1}
+
+// createMoneyRequest asks another user, found by phone number or email,
+// for money or sends them an invoice. A request with a due date expires at
+// the end of that day in the requester's timezone; one without expires
+// after requestLifetime.
+func (s *Server) createMoneyRequest(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	in := new(types.MoneyRequestInput)
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		return err
+	}
+
+	tz, _, err := s.reportSettings(r, userID)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(loc)
+	if err := validateMoneyRequest(in, now); err != nil {
+		return err
+	}
+
+	requester, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if in.Currency == "" {
+		in.Currency = requester.HomeCurrency
+	}
+	if in.Currency == "" {
+		in.Currency = fx.DefaultCurrency
+	}
+
+	payer, err := s.findRecipient(in.To)
+	if err != nil {
+		return err
+	}
+	if payer.ID == userID {
+		return fmt.Errorf("you cannot request money from yourself")
+	}
+
+	expiresAt, remindOn := requestSchedule(in.DueDate, now)
+	m := &types.MoneyRequest{
+		Kind:        in.Kind,
+		RequesterID: userID,
+		PayerID:     payer.ID,
+		Payer:       in.To,
+		Amount:      in.Amount,
+		Currency:    in.Currency,
+		Note:        in.Note,
+		Items:       in.Items,
+		DueDate:     in.DueDate,
+		ExpiresAt:   expiresAt.Format(time.RFC3339),
+		Direction:   "sent",
+	}
+	if err := s.store.CreateMoneyRequest(m, remindOn); err != nil {
+		return err
+	}
+
+	what := "requested"
+	if m.Kind == types.MoneyRequestInvoice {
+		what = "sent you an invoice for"
+	}
+	s.notifyMoneyRequest(types.Notification{
+		UserID: m.PayerID,
+		Kind:   "money_request_received",
+		Title:  "Payment requested",
+		Body:   fmt.Sprintf("%s %s %s %s.", requester.FirstName, requester.LastName, what, ledger.Format(m.Amount, m.Currency)),
+	}, m.ID)
+	return writeJSON(w, http.StatusCreated, m)
+}
+
+// requestSchedule returns when a request due on dueDate expires and the
+// dates its payer should be reminded on, given the current time in the
+// requester's timezone.
+func requestSchedule(dueDate string, now time.Time) (time.Time, []string) {
+	if dueDate == "" {
+		return now.Add(requestLifetime), nil
+	}
+
+	due, _ := time.ParseInLocation("2006-01-02", dueDate, now.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	remindOn := []string{}
+	for _, days := range reminderDays {
+		if date := due.AddDate(0, 0, -days); date.After(today) {
+			remindOn = append(remindOn, date.Format("2006-01-02"))
+		}
+	}
+	return due.AddDate(0, 0, 1), remindOn
+}
+
+func (s *Server) getMoneyRequests(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	q := r.URL.Query()
+	direction := q.Get("direction")
+	if direction != "" && direction != "sent" && direction != "received" {
+		return fmt.Errorf("direction must be sent or received")
+	}
+	status := types.MoneyRequestStatus(q.Get("status"))
+	switch status {
+	case "", types.MoneyRequestPending, types.MoneyRequestPaid, types.MoneyRequestDeclined, types.MoneyRequestExpired:
+	default:
+		return fmt.Errorf("status must be pending, paid, declined or expired")
+	}
+	limit, err := intParam(r, "limit", 50, 1, 500)
+	if err != nil {
+		return err
+	}
+
+	requests, err := s.store.GetMoneyRequests(userID, direction, status, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, requests)
+}
+
+func (s *Server) getMoneyRequest(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	m, err := s.store.GetMoneyRequest(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("request %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, m)
+}
+
+// approveMoneyRequest pays a pending request addressed to the caller with
+// a transfer from their wallet to the requester's. It needs the
+// transaction PIN and is held to the payer's transfer limits.
+func (s *Server) approveMoneyRequest(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	if err := s.requirePIN(r, userID); err != nil {
+		return err
+	}
+
+	m, transfer, err := s.store.PayMoneyRequest(userID, mux.Vars(r)["id"])
+	if err != nil {
+		return moneyRequestError(w, err)
+	}
+
+	payer, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	s.notifyTransferReceived(payer, *transfer)
+	return writeJSON(w, http.StatusOK, map[string]any{"request": m, "transfer": transfer})
+}
+
+func (s *Server) declineMoneyRequest(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	m, err := s.store.DeclineMoneyRequest(userID, mux.Vars(r)["id"])
+	if err != nil {
+		return moneyRequestError(w, err)
+	}
+
+	payer, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	s.notifyMoneyRequest(types.Notification{
+		UserID: m.RequesterID,
+		Kind:   "money_request_declined",
+		Title:  "Request declined",
+		Body:   fmt.Sprintf("%s %s declined your request for %s.", payer.FirstName, payer.LastName, ledger.Format(m.Amount, m.Currency)),
+	}, m.ID)
+	return writeJSON(w, http.StatusOK, m)
+}
+
+func moneyRequestError(w http.ResponseWriter, err error) error {
+	var limitErr *database.LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		return rejectOverLimit(w, limitErr)
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("request %w", errNotFound)
+	case errors.Is(err, database.ErrInvalidMoneyRequestState):
+		return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+	}
+	return ledgerError(w, err)
+}
+
+func (s *Server) notifyMoneyRequest(note types.Notification, requestID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	note.Data = map[string]string{"requestId": requestID}
+	if err := s.notifier.Send(ctx, note, types.ChannelInApp, types.ChannelPush); err != nil {
+		slog.Error("Failed to send request notification", "error", err, "request", requestID)
+	}
+}
+
+// processMoneyRequests is the maintenance job that expires unanswered
+// requests and sends the reminders that have come due.
+func (s *Server) processMoneyRequests() {
+	expired, err := s.store.ExpireMoneyRequests()
+	if err != nil {
+		slog.Error("Failed to expire requests", "error", err)
+	}
+	for _, m := range expired {
+		s.notifyMoneyRequest(types.Notification{
+			UserID: m.RequesterID,
+			Kind:   "money_request_expired",
+			Title:  "Request expired",
+			Body:   fmt.Sprintf("Your request to %s for %s expired unpaid.", m.Payer, ledger.Format(m.Amount, m.Currency)),
+		}, m.ID)
+	}
+
+	reminders, err := s.store.ClaimDueReminders()
+	if err != nil {
+		slog.Error("Failed to load request reminders", "error", err)
+		return
+	}
+	reminded := map[string]bool{}
+	for _, reminder := range reminders {
+		m := reminder.Request
+		if reminded[m.ID] {
+			continue
+		}
+		reminded[m.ID] = true
+
+		due := "today"
+		if reminder.DaysLeft == 1 {
+			due = "tomorrow"
+		} else if reminder.DaysLeft > 1 {
+			due = fmt.Sprintf("in %d days", reminder.DaysLeft)
+		}
+		s.notifyMoneyRequest(types.Notification{
+			UserID: m.PayerID,
+			Kind:   "money_request_reminder",
+			Title:  "Payment due soon",
+			Body:   fmt.Sprintf("A request for %s is due %s.", ledger.Format(m.Amount, m.Currency), due),
+		}, m.ID)
+	}
+}
+
+// validateMoneyRequest checks a request and fills in its kind, currency
+// and, for an invoice, its amount from the items. now is the current time
+// in the requester's timezone.
+func validateMoneyRequest(in *types.MoneyRequestInput, now time.Time) error {
+	in.To = strings.TrimSpace(in.To)
+	if in.To == "" {
+		return fmt.Errorf("to must be a phone number or email")
+	}
+	in.Note = strings.TrimSpace(in.Note)
+	if len(in.Note) > maxTransferNote {
+		return fmt.Errorf("note must be at most %d characters", maxTransferNote)
+	}
+	if in.Currency != "" {
+		currency, err := fx.NormalizeCurrency(in.Currency)
+		if err != nil {
+			return err
+		}
+		in.Currency = currency
+	}
+
+	switch in.Kind {
+	case "", types.MoneyRequestPlain:
+		in.Kind = types.MoneyRequestPlain
+		if len(in.Items) > 0 {
+			return fmt.Errorf("only invoices can have items")
+		}
+		if in.Amount <= 0 {
+			return fmt.Errorf("amount must be greater than zero")
+		}
+	case types.MoneyRequestInvoice:
+		total, err := invoiceTotal(in.Items)
+		if err != nil {
+			return err
+		}
+		if in.Amount != 0 && in.Amount != total {
+			return fmt.Errorf("amount must match the total of the items")
+		}
+		in.Amount = total
+		if in.DueDate == "" {
+			return fmt.Errorf("dueDate is required for an invoice")
+		}
+	default:
+		return fmt.Errorf("kind must be request or invoice")
+	}
+
+	if in.DueDate != "" {
+		due, err := time.Parse("2006-01-02", in.DueDate)
+		if err != nil {
+			return fmt.Errorf("dueDate must be in YYYY-MM-DD format")
+		}
+		if due.Format("2006-01-02") < now.Format("2006-01-02") {
+			return fmt.Errorf("dueDate must not be in the past")
+		}
+	}
+	return nil
+}
+
+func invoiceTotal(items []types.InvoiceItem) (int64, error) {
+	if len(items) == 0 {
+		return 0, fmt.Errorf("an invoice needs at least one item")
+	}
+	if len(items) > maxInvoiceItems {
+		return 0, fmt.Errorf("an invoice can have at most %d items", maxInvoiceItems)
+	}
+
+	var total int64
+	for i := range items {
+		item := &items[i]
+		item.Description = strings.TrimSpace(item.Description)
+		if item.Description == "" {
+			return 0, fmt.Errorf("item %d: description is required", i+1)
+		}
+		if item.Quantity <= 0 || item.UnitAmount <= 0 {
+			return 0, fmt.Errorf("item %d: quantity and unitAmount must be greater than zero", i+1)
+		}
+		if item.UnitAmount > (math.MaxInt64-total)/item.Quantity {
+			return 0, fmt.Errorf("invoice total is too large")
+		}
+		total += item.Quantity * item.UnitAmount
+	}
+	return total, nil
+}
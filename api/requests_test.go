@@ -0,0 +1,72 @@
+package api
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMoneyRequest(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	in := types.MoneyRequestInput{To: " jane@example.com ", Amount: 5000, Currency: "ugx"}
+	require.NoError(t, validateMoneyRequest(&in, now))
+	assert.Equal(t, types.MoneyRequestPlain, in.Kind)
+	assert.Equal(t, "jane@example.com", in.To)
+	assert.Equal(t, "UGX", in.Currency)
+
+	in = types.MoneyRequestInput{
+		Kind:    types.MoneyRequestInvoice,
+		To:      "jane@example.com",
+		DueDate: "2025-03-10",
+		Items: []types.InvoiceItem{
+			{Description: " Design ", Quantity: 2, UnitAmount: 15000},
+			{Description: "Hosting", Quantity: 1, UnitAmount: 2500},
+		},
+	}
+	require.NoError(t, validateMoneyRequest(&in, now))
+	assert.Equal(t, int64(32500), in.Amount)
+	assert.Equal(t, "Design", in.Items[0].Description)
+
+	item := types.InvoiceItem{Description: "Work", Quantity: 1, UnitAmount: 100}
+	invalid := []types.MoneyRequestInput{
+		{To: "", Amount: 100},
+		{To: "jane@example.com", Amount: 0},
+		{To: "jane@example.com", Amount: 100, Kind: "gift"},
+		{To: "jane@example.com", Amount: 100, Items: []types.InvoiceItem{item}},
+		{To: "jane@example.com", Amount: 100, DueDate: "2025-03-09"},
+		{To: "jane@example.com", Amount: 100, DueDate: "10/03/2025"},
+		{To: "jane@example.com", Kind: types.MoneyRequestInvoice, Items: []types.InvoiceItem{item}},
+		{To: "jane@example.com", Kind: types.MoneyRequestInvoice, DueDate: "2025-04-01"},
+		{To: "jane@example.com", Kind: types.MoneyRequestInvoice, DueDate: "2025-04-01", Amount: 99, Items: []types.InvoiceItem{item}},
+		{To: "jane@example.com", Kind: types.MoneyRequestInvoice, DueDate: "2025-04-01", Items: []types.InvoiceItem{{Description: "", Quantity: 1, UnitAmount: 1}}},
+		{To: "jane@example.com", Kind: types.MoneyRequestInvoice, DueDate: "2025-04-01", Items: []types.InvoiceItem{{Description: "Work", Quantity: 0, UnitAmount: 1}}},
+		{To: "jane@example.com", Kind: types.MoneyRequestInvoice, DueDate: "2025-04-01", Items: []types.InvoiceItem{
+			{Description: "Big", Quantity: 2, UnitAmount: math.MaxInt64 / 2},
+			{Description: "More", Quantity: 1, UnitAmount: 2},
+		}},
+	}
+	for _, r := range invalid {
+		assert.Error(t, validateMoneyRequest(&r, now), "%+v", r)
+	}
+}
+
+func TestRequestSchedule(t *testing.T) {
+	loc := time.FixedZone("EAT", 3*60*60)
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, loc)
+
+	expires, remindOn := requestSchedule("2025-03-20", now)
+	assert.Equal(t, time.Date(2025, 3, 21, 0, 0, 0, 0, loc), expires)
+	assert.Equal(t, []string{"2025-03-17", "2025-03-19"}, remindOn)
+
+	_, remindOn = requestSchedule("2025-03-12", now)
+	assert.Equal(t, []string{"2025-03-11"}, remindOn)
+
+	expires, remindOn = requestSchedule("", now)
+	assert.Equal(t, now.Add(requestLifetime), expires)
+	assert.Empty(t, remindOn)
+}
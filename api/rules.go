@@ -0,0 +1,213 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Ayikoandrew/server/rules"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) createRule(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	rule, err := decodeRule(r)
+	if err != nil {
+		return err
+	}
+
+	rule.UserID = userID
+	if err := s.store.CreateRule(rule); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *Server) getRules(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	list, err := s.store.GetRules(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) updateRule(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	rule, err := decodeRule(r)
+	if err != nil {
+		return err
+	}
+
+	rule.ID = mux.Vars(r)["id"]
+	rule.UserID = userID
+	if err := s.store.UpdateRule(rule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("rule %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, rule)
+}
+
+func (s *Server) deleteRule(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteRule(userID, mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("rule %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]string{"message": "rule deleted"})
+}
+
+// decodeRule reads and validates a rule. Rules are active unless the body
+// says otherwise.
+func decodeRule(r *http.Request) (*types.CategorizationRule, error) {
+	rule := &types.CategorizationRule{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil {
+		return nil, err
+	}
+
+	rule.Name = strings.TrimSpace(rule.Name)
+	if len(rule.Name) > 100 {
+		return nil, fmt.Errorf("name must be at most 100 characters")
+	}
+
+	tags, err := normalizeTags(rule.Actions.Tags)
+	if err != nil {
+		return nil, err
+	}
+	rule.Actions.Tags = tags
+
+	if err := rules.Validate(*rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *Server) ruleEngine(userID string) (*rules.Engine, error) {
+	list, err := s.store.GetRules(userID)
+	if err != nil {
+		return nil, err
+	}
+	return rules.New(list)
+}
+
+// previewRules shows what re-applying the rules to past expenses would
+// change without saving anything.
+func (s *Server) previewRules(w http.ResponseWriter, r *http.Request) error {
+	return s.reapplyRules(w, r, true)
+}
+
+func (s *Server) applyRules(w http.ResponseWriter, r *http.Request) error {
+	return s.reapplyRules(w, r, false)
+}
+
+// reapplyRules runs the rules over the expenses selected by the usual
+// listing filters. By default only missing categories and payees are filled
+// in; overwrite=true lets rules replace existing ones.
+func (s *Server) reapplyRules(w http.ResponseWriter, r *http.Request, dryRun bool) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parseExpenseFilter(r)
+	if err != nil {
+		return err
+	}
+	overwrite := r.URL.Query().Get("overwrite") == "true"
+
+	engine, err := s.ruleEngine(userID)
+	if err != nil {
+		return err
+	}
+
+	run := types.RuleRun{DryRun: dryRun, Changes: []types.RuleChange{}}
+	var changed []types.Expense
+	err = s.store.StreamExpenses(userID, filter, func(e types.Expense) error {
+		run.Scanned++
+		before := types.Classification{Category: e.Category, Payee: e.Payee, Tags: slices.Clone(e.Tags)}
+
+		applied := engine.Apply(&e, overwrite)
+		if len(applied) == 0 {
+			return nil
+		}
+
+		changed = append(changed, e)
+		run.Changes = append(run.Changes, types.RuleChange{
+			ExpenseID:   e.ID,
+			Date:        e.Date,
+			Description: e.Description,
+			Amount:      e.Amount,
+			Before:      before,
+			After:       types.Classification{Category: e.Category, Payee: e.Payee, Tags: e.Tags},
+			RuleIDs:     applied,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	run.Changed = len(changed)
+
+	if !dryRun && len(changed) > 0 {
+		if err := s.store.ApplyClassifications(userID, changed); err != nil {
+			return err
+		}
+	}
+	return writeJSON(w, http.StatusOK, run)
+}
+
+// classifyImport runs the rules over a freshly committed import. The import
+// itself has already succeeded, so failures are only logged.
+func (s *Server) classifyImport(userID, batchID string) {
+	engine, err := s.ruleEngine(userID)
+	if err != nil {
+		slog.Error("Failed to load rules", "error", err)
+		return
+	}
+
+	expenses, err := s.store.GetImportedExpenses(userID, batchID)
+	if err != nil {
+		slog.Error("Failed to load imported expenses", "error", err, "batch", batchID)
+		return
+	}
+
+	var changed []types.Expense
+	for _, e := range expenses {
+		if len(engine.Apply(&e, false)) > 0 {
+			changed = append(changed, e)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	if err := s.store.ApplyClassifications(userID, changed); err != nil {
+		slog.Error("Failed to classify imported expenses", "error", err, "batch", batchID)
+	}
+}
@@ -0,0 +1,364 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/recurring"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	scheduledBatch = 100
+
+	// maxScheduledAttempts is how often one occurrence is tried before the
+	// worker gives up on it. Retries wait firstRetryDelay, then four times
+	// as long after each further failure.
+	maxScheduledAttempts = 5
+	firstRetryDelay      = 15 * time.Minute
+
+	maxScheduleAhead = 366 * 24 * time.Hour
+)
+
+// createScheduledTransfer sets up a transfer the worker sends later, once
+// or on a schedule. It needs the transaction PIN now; balance and limits
+// are checked each time it runs.
+func (s *Server) createScheduledTransfer(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	in := new(types.ScheduledTransferInput)
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		return err
+	}
+	req := types.WalletTransferRequest{To: in.To, Amount: in.Amount, Currency: in.Currency, Note: in.Note}
+	if err := validateTransferRequest(&req); err != nil {
+		return err
+	}
+	if err := s.requirePIN(r, userID); err != nil {
+		return err
+	}
+
+	sender, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if req.Currency == "" {
+		req.Currency = sender.HomeCurrency
+	}
+	if req.Currency == "" {
+		req.Currency = fx.DefaultCurrency
+	}
+	timezone := sender.Timezone
+	if _, err := time.LoadLocation(timezone); timezone == "" || err != nil {
+		timezone = "UTC"
+	}
+
+	recipient, err := s.findRecipient(req.To)
+	if err != nil {
+		return err
+	}
+	if recipient.ID == userID {
+		return fmt.Errorf("you cannot send money to yourself")
+	}
+
+	t := &types.ScheduledTransfer{
+		SenderID:    userID,
+		RecipientID: recipient.ID,
+		Recipient:   req.To,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Note:        req.Note,
+		Frequency:   in.Frequency,
+		Interval:    in.Interval,
+		DayOfMonth:  in.DayOfMonth,
+		EndDate:     in.EndDate,
+		Count:       in.Count,
+		StartAt:     in.StartAt,
+		Timezone:    timezone,
+	}
+	if err := validateScheduledTransfer(t, time.Now()); err != nil {
+		return err
+	}
+	if err := s.store.CreateScheduledTransfer(t); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, t)
+}
+
+func (s *Server) getScheduledTransfers(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	status := types.ScheduledTransferStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", types.ScheduleActive, types.ScheduleCompleted, types.ScheduleCancelled, types.ScheduleFailed:
+	default:
+		return fmt.Errorf("status must be active, completed, cancelled or failed")
+	}
+	limit, err := intParam(r, "limit", 50, 1, 500)
+	if err != nil {
+		return err
+	}
+
+	list, err := s.store.GetScheduledTransfers(userID, status, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) getScheduledTransfer(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	t, err := s.store.GetScheduledTransfer(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("scheduled transfer %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, t)
+}
+
+// cancelScheduledTransfer stops a schedule so no further occurrences or
+// retries run. Transfers it already sent are unaffected.
+func (s *Server) cancelScheduledTransfer(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	t, err := s.store.CancelScheduledTransfer(userID, mux.Vars(r)["id"])
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("scheduled transfer %w", errNotFound)
+		case errors.Is(err, database.ErrInvalidScheduleState):
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, t)
+}
+
+// StartScheduledTransfers runs the worker that sends due scheduled
+// transfers and retries failed ones, checking every interval.
+func (s *Server) StartScheduledTransfers(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			s.runScheduledTransfers(time.Now())
+		}
+	}()
+}
+
+func (s *Server) runScheduledTransfers(now time.Time) {
+	due, err := s.store.GetDueScheduledTransfers(now, scheduledBatch)
+	if err != nil {
+		slog.Error("Failed to load due scheduled transfers", "error", err)
+		return
+	}
+	for _, t := range due {
+		s.runScheduledTransfer(t, now)
+	}
+}
+
+// runScheduledTransfer makes one attempt at a schedule's current
+// occurrence. Balance and limits are checked afresh; a failure is recorded
+// and retried with backoff. Errors that say nothing about the transfer
+// itself, such as a lost database connection, leave it due for the next
+// tick.
+func (s *Server) runScheduledTransfer(t types.ScheduledTransfer, now time.Time) {
+	occurrence, err := time.Parse(time.RFC3339, t.NextRunAt)
+	if err != nil {
+		slog.Error("Invalid scheduled transfer occurrence", "error", err, "schedule", t.ID)
+		return
+	}
+	next, _, err := nextOccurrence(t, occurrence)
+	if err != nil {
+		slog.Error("Invalid scheduled transfer schedule", "error", err, "schedule", t.ID)
+		return
+	}
+
+	sender, err := s.store.GetUser(t.SenderID)
+	if err != nil {
+		slog.Error("Failed to load scheduled transfer sender", "error", err, "schedule", t.ID)
+		return
+	}
+	transfer, err := s.store.ExecuteScheduledTransfer(t.ID, occurrence, next)
+	var limitErr *database.LimitError
+	switch {
+	case err == nil:
+		s.notifyTransferReceived(sender, *transfer)
+	case errors.Is(err, database.ErrInvalidScheduleState):
+		// Cancelled, or another worker got to this occurrence first.
+	case errors.As(err, &limitErr), errors.Is(err, ledger.ErrInsufficientFunds):
+		s.failScheduledTransfer(t, occurrence, next, err.Error(), now)
+	default:
+		// The schedule is left as it is, due again on the next tick.
+		slog.Error("Failed to run scheduled transfer", "error", err, "schedule", t.ID)
+	}
+}
+
+// failScheduledTransfer records a failed attempt and tells the sender
+// what happens next: a retry, moving on to the next occurrence, or the
+// schedule stopping. An occurrence is not retried past the next one.
+func (s *Server) failScheduledTransfer(t types.ScheduledTransfer, occurrence, next time.Time, reason string, now time.Time) {
+	retryAt := retryTime(t.Attempts+1, now)
+	if !next.IsZero() && !retryAt.Before(next) {
+		retryAt = time.Time{}
+	}
+
+	updated, err := s.store.FailScheduledRun(t.ID, occurrence, reason, retryAt, next)
+	if err != nil {
+		if !errors.Is(err, database.ErrInvalidScheduleState) {
+			slog.Error("Failed to record scheduled transfer failure", "error", err, "schedule", t.ID)
+		}
+		return
+	}
+	s.notifyScheduledFailure(*updated, reason, retryAt)
+}
+
+// retryTime is when the occurrence is tried again after the given failed
+// attempt, or zero once it has had all its attempts.
+func retryTime(attempt int, now time.Time) time.Time {
+	if attempt >= maxScheduledAttempts {
+		return time.Time{}
+	}
+	return now.Add(firstRetryDelay << (2 * (attempt - 1)))
+}
+
+func (s *Server) notifyScheduledFailure(t types.ScheduledTransfer, reason string, retryAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	body := fmt.Sprintf("Your scheduled transfer of %s to %s failed: %s.",
+		ledger.Format(t.Amount, t.Currency), t.Recipient, reason)
+	switch {
+	case !retryAt.IsZero():
+		body += " We will try again at " + retryAt.In(loc).Format("15:04 on 2 Jan") + "."
+	case t.Status == types.ScheduleActive:
+		if next, err := time.Parse(time.RFC3339, t.NextRunAt); err == nil {
+			body += " We skipped this payment; the next one is due on " + next.In(loc).Format("2 Jan 2006") + "."
+		}
+	default:
+		body += " The scheduled transfer has been stopped."
+	}
+
+	note := types.Notification{
+		UserID: t.SenderID,
+		Kind:   "scheduled_transfer_failed",
+		Title:  "Scheduled transfer failed",
+		Body:   body,
+		Data:   map[string]string{"scheduledTransferId": t.ID},
+	}
+	if err := s.notifier.Send(ctx, note, types.ChannelInApp, types.ChannelPush); err != nil {
+		slog.Error("Failed to notify scheduled transfer failure", "error", err, "schedule", t.ID)
+	}
+}
+
+// validateScheduledTransfer checks the timing of a new scheduled transfer
+// and sets its StartAt in UTC and NextRunAt to its first occurrence.
+func validateScheduledTransfer(t *types.ScheduledTransfer, now time.Time) error {
+	start, err := time.Parse(time.RFC3339, t.StartAt)
+	if err != nil {
+		return fmt.Errorf("startAt must be a time such as 2026-11-01T09:00:00+03:00")
+	}
+	if !start.After(now) {
+		return fmt.Errorf("startAt must be in the future")
+	}
+	if start.Sub(now) > maxScheduleAhead {
+		return fmt.Errorf("startAt must be within a year")
+	}
+	t.StartAt = start.UTC().Format(time.RFC3339)
+
+	if t.Frequency == "" && (t.Interval != 0 || t.DayOfMonth != 0 || t.EndDate != "" || t.Count != 0) {
+		return fmt.Errorf("interval, dayOfMonth, endDate and count need a frequency")
+	}
+
+	first, ok, err := nextOccurrence(*t, start.Add(-time.Second))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("the schedule has no occurrences")
+	}
+	t.NextRunAt = first.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// nextOccurrence returns the first run of t after the given time, or false
+// when the schedule has no more runs. Recurring transfers run on the dates
+// of their schedule in t's timezone, at the time of day they started.
+func nextOccurrence(t types.ScheduledTransfer, after time.Time) (time.Time, bool, error) {
+	start, err := time.Parse(time.RFC3339, t.StartAt)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if t.Frequency == "" {
+		return start, start.After(after), nil
+	}
+
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	start = start.In(loc)
+
+	var end time.Time
+	if t.EndDate != "" {
+		if end, err = time.ParseInLocation(recurring.DateLayout, t.EndDate, loc); err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid end date: %w", err)
+		}
+	}
+	schedule := recurring.Schedule{
+		Frequency:  t.Frequency,
+		Interval:   t.Interval,
+		DayOfMonth: t.DayOfMonth,
+		Start:      time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc),
+		End:        end,
+		Count:      t.Count,
+	}
+	if err := schedule.Validate(); err != nil {
+		return time.Time{}, false, err
+	}
+
+	// The schedule works in whole dates, so start from the day before to
+	// catch a run later on the same day.
+	a := after.In(loc)
+	cursor := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	for {
+		date, ok := schedule.Next(cursor)
+		if !ok {
+			return time.Time{}, false, nil
+		}
+		run := time.Date(date.Year(), date.Month(), date.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc)
+		if run.After(after) {
+			return run, true, nil
+		}
+		cursor = date
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateScheduledTransferOneOff(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	st := &types.ScheduledTransfer{StartAt: "2026-10-20T09:00:00+03:00", Timezone: "Africa/Kampala"}
+	require.NoError(t, validateScheduledTransfer(st, now))
+	assert.Equal(t, "2026-10-20T06:00:00Z", st.StartAt)
+	assert.Equal(t, "2026-10-20T06:00:00Z", st.NextRunAt)
+
+	_, ok, err := nextOccurrence(*st, time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestValidateScheduledTransferRejects(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	for name, st := range map[string]types.ScheduledTransfer{
+		"bad time":          {StartAt: "tomorrow"},
+		"past":              {StartAt: "2026-10-19T11:59:00Z"},
+		"too far":           {StartAt: "2028-01-01T00:00:00Z"},
+		"count no schedule": {StartAt: "2026-11-01T09:00:00Z", Count: 3},
+		"bad frequency":     {StartAt: "2026-11-01T09:00:00Z", Frequency: "hourly", Timezone: "UTC"},
+		"ends before start": {StartAt: "2026-11-01T09:00:00Z", Frequency: types.FrequencyMonthly, EndDate: "2026-10-01", Timezone: "UTC"},
+	} {
+		assert.Error(t, validateScheduledTransfer(&st, now), name)
+	}
+}
+
+func TestNextOccurrenceMonthlyRent(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	st := &types.ScheduledTransfer{
+		StartAt:    "2026-10-20T08:00:00+03:00",
+		Timezone:   "Africa/Kampala",
+		Frequency:  types.FrequencyMonthly,
+		DayOfMonth: 1,
+		Count:      3,
+	}
+	require.NoError(t, validateScheduledTransfer(st, now))
+	assert.Equal(t, "2026-11-01T05:00:00Z", st.NextRunAt)
+
+	var runs []string
+	at, _ := time.Parse(time.RFC3339, st.NextRunAt)
+	for {
+		runs = append(runs, at.UTC().Format(time.RFC3339))
+		next, ok, err := nextOccurrence(*st, at)
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		at = next
+	}
+	assert.Equal(t, []string{"2026-11-01T05:00:00Z", "2026-12-01T05:00:00Z", "2027-01-01T05:00:00Z"}, runs)
+}
+
+func TestNextOccurrenceSameDay(t *testing.T) {
+	st := types.ScheduledTransfer{StartAt: "2026-10-20T18:30:00Z", Timezone: "UTC", Frequency: types.FrequencyDaily}
+	next, ok, err := nextOccurrence(st, time.Date(2026, 10, 21, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 10, 21, 18, 30, 0, 0, time.UTC), next.UTC())
+}
+
+func TestRetryTime(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, now.Add(15*time.Minute), retryTime(1, now))
+	assert.Equal(t, now.Add(time.Hour), retryTime(2, now))
+	assert.Equal(t, now.Add(16*time.Hour), retryTime(4, now))
+	assert.True(t, retryTime(maxScheduledAttempts, now).IsZero())
+}
@@ -15,9 +15,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Ayikoandrew/server/blob"
 	"github.com/Ayikoandrew/server/database"
 	api "github.com/Ayikoandrew/server/functions"
 	"github.com/Ayikoandrew/server/middleware"
+	"github.com/Ayikoandrew/server/notify"
+	"github.com/Ayikoandrew/server/payments"
 	"github.com/Ayikoandrew/server/security"
 	"github.com/Ayikoandrew/server/types"
 	"github.com/Ayikoandrew/server/utils"
@@ -29,17 +32,80 @@ import (
 type Server struct {
 	listenAddr string
 	store      database.DBHandler
+	blobs      blob.Store
+	notifier   *notify.Notifier
+	// maxBatchSize caps the operations in one /expense/batch request.
+	maxBatchSize int
+	idempotency  middleware.IdempotencyStore
+	// provider moves money in and out of wallets; webhookSecret signs its
+	// callbacks.
+	provider      payments.Provider
+	webhookSecret []byte
 }
 
-func NewServer(listenAddr string, store database.DBHandler) *Server {
-	return &Server{
+type Option func(*Server)
+
+// WithBlobStore sets where receipts and other uploads are kept.
+func WithBlobStore(blobs blob.Store) Option {
+	return func(s *Server) {
+		s.blobs = blobs
+	}
+}
+
+// WithNotifier sets how alerts reach users. Without it only the in-app
+// notification list is used.
+func WithNotifier(notifier *notify.Notifier) Option {
+	return func(s *Server) {
+		s.notifier = notifier
+	}
+}
+
+// WithIdempotencyStore enables Idempotency-Key handling on POST and PATCH
+// routes, keeping responses in store.
+func WithIdempotencyStore(store middleware.IdempotencyStore) Option {
+	return func(s *Server) {
+		s.idempotency = store
+	}
+}
+
+// WithPaymentProvider enables wallet top-ups and withdrawals through
+// provider, whose callbacks must be signed with webhookSecret.
+func WithPaymentProvider(provider payments.Provider, webhookSecret []byte) Option {
+	return func(s *Server) {
+		s.provider = provider
+		s.webhookSecret = webhookSecret
+	}
+}
+
+// WithMaxBatchSize sets how many operations one batch request may hold.
+func WithMaxBatchSize(n int) Option {
+	return func(s *Server) {
+		s.maxBatchSize = n
+	}
+}
+
+func NewServer(listenAddr string, store database.DBHandler, opts ...Option) *Server {
+	s := &Server{
 		listenAddr: listenAddr,
 		store:      store,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.notifier == nil {
+		s.notifier = notify.New(notify.NewInAppChannel(store))
+	}
+	if s.maxBatchSize <= 0 {
+		s.maxBatchSize = defaultMaxBatchSize
+	}
+	return s
 }
 
 func (s *Server) Run() {
 	router := mux.NewRouter()
+	// Session endpoints are left out: replaying them would hand back tokens
+	// that have since been replaced.
+	idempotent := middleware.Idempotency(s.idempotency)
 
 	// registry := prometheus.NewRegistry()
 	// promMiddleware := security.New(registry, nil)
@@ -51,7 +117,7 @@ func (s *Server) Run() {
 	// router.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{})).Methods(http.MethodGet)
 
 	router.Handle("/signup",
-		middleware.RateLimitMiddlewareTokenBucket(makeHTTPHandlerFunc(s.createAccount))).Methods(http.MethodPost)
+		middleware.RateLimitMiddlewareTokenBucket(idempotent(makeHTTPHandlerFunc(s.createAccount)))).Methods(http.MethodPost)
 	router.Handle("/login",
 		middleware.RateLimitMiddlewareTokenBucket(makeHTTPHandlerFunc(s.loginAccount))).Methods(http.MethodPost)
 	router.Handle("/health",
@@ -59,8 +125,144 @@ func (s *Server) Run() {
 	router.Handle("/logout", middleware.RateLimitMiddlewareTokenBucket(makeHTTPHandlerFunc(s.logoutHandler))).Methods(http.MethodPost)
 
 	router.Handle("/auth/refresh", makeHTTPHandlerFunc(s.refreshTokenHandler)).Methods(http.MethodPost)
-	router.Handle("/expense", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.uploadExpenses))).Methods(http.MethodGet)
+	router.Handle("/expense", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.uploadExpenses)))).Methods(http.MethodPost)
+	router.Handle("/expense", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.retriveExpenses))).Methods(http.MethodGet)
 	router.Handle("/", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.retriveExpenses))).Methods(http.MethodGet)
+	router.Handle("/expense/batch", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.batchExpenses)))).Methods(http.MethodPost)
+	router.Handle("/expense/search", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.searchExpenses))).Methods(http.MethodGet)
+	router.Handle("/expense/trash", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getTrash))).Methods(http.MethodGet)
+	router.Handle("/expense/{id}", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.updateExpense)))).Methods(http.MethodPatch)
+	router.Handle("/expense/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteExpense))).Methods(http.MethodDelete)
+	router.Handle("/expense/{id}/restore", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.restoreExpense)))).Methods(http.MethodPost)
+	router.Handle("/expense/{id}/history", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getExpenseHistory))).Methods(http.MethodGet)
+	router.Handle("/expense/{id}/receipts", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.uploadReceipt)))).Methods(http.MethodPost)
+	router.Handle("/expense/{id}/receipts", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getReceipts))).Methods(http.MethodGet)
+	router.Handle("/receipts/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getReceiptURL))).Methods(http.MethodGet)
+	router.Handle("/receipts/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteReceipt))).Methods(http.MethodDelete)
+	router.Handle("/blobs/{key:.+}", makeHTTPHandlerFunc(s.serveBlob)).Methods(http.MethodGet)
+	router.Handle("/expense/export/{format}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.exportExpenses))).Methods(http.MethodGet)
+
+	router.Handle("/profile", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getProfile))).Methods(http.MethodGet)
+	router.Handle("/profile", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.updateProfile)))).Methods(http.MethodPatch)
+	router.Handle("/profile/pin", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getPINStatus))).Methods(http.MethodGet)
+	router.Handle("/profile/pin", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.setPIN))).Methods(http.MethodPost)
+	router.Handle("/profile/pin", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.changePIN))).Methods(http.MethodPut)
+	router.Handle("/profile/pin/reset", middleware.RateLimitMiddlewareTokenBucket(
+		security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.resetPIN)))).Methods(http.MethodPost)
+	router.Handle("/kyc", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getKYCStatus))).Methods(http.MethodGet)
+	router.Handle("/kyc/submissions", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.submitKYC)))).Methods(http.MethodPost)
+	router.Handle("/admin/kyc/submissions", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getKYCQueue))).Methods(http.MethodGet)
+	router.Handle("/admin/kyc/submissions/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getKYCSubmission))).Methods(http.MethodGet)
+	router.Handle("/admin/kyc/submissions/{id}/approve", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.approveKYC)))).Methods(http.MethodPost)
+	router.Handle("/admin/kyc/submissions/{id}/reject", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.rejectKYC)))).Methods(http.MethodPost)
+
+	router.Handle("/rules", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createRule)))).Methods(http.MethodPost)
+	router.Handle("/rules", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getRules))).Methods(http.MethodGet)
+	router.Handle("/rules/preview", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.previewRules))).Methods(http.MethodGet)
+	router.Handle("/rules/apply", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.applyRules)))).Methods(http.MethodPost)
+	router.Handle("/rules/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.updateRule))).Methods(http.MethodPut)
+	router.Handle("/rules/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteRule))).Methods(http.MethodDelete)
+
+	router.Handle("/tags", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getTags))).Methods(http.MethodGet)
+	router.Handle("/budgets", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getBudgets))).Methods(http.MethodGet)
+	router.Handle("/categories", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getCategories))).Methods(http.MethodGet)
+	router.Handle("/categories/{name}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.setCategoryBudget))).Methods(http.MethodPut)
+	router.Handle("/categories/{name}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteCategory))).Methods(http.MethodDelete)
+	router.Handle("/categories/{name}/alerts", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getBudgetAlertRules))).Methods(http.MethodGet)
+	router.Handle("/categories/{name}/alerts", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.setBudgetAlertRules))).Methods(http.MethodPut)
+
+	router.Handle("/notifications", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getNotifications))).Methods(http.MethodGet)
+	router.Handle("/notifications/{id}/read", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.markNotificationRead)))).Methods(http.MethodPost)
+
+	router.Handle("/exchange-rates", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getExchangeRates))).Methods(http.MethodGet)
+	router.Handle("/exchange-rates/convert", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.convertCurrency))).Methods(http.MethodGet)
+	router.Handle("/admin/exchange-rates", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createExchangeRates)))).Methods(http.MethodPost)
+	router.Handle("/admin/exchange-rates/upload", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.uploadExchangeRates)))).Methods(http.MethodPost)
+	router.Handle("/admin/exchange-rates/{base}/{quote}/{date}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteExchangeRate))).Methods(http.MethodDelete)
+
+	router.Handle("/wallet", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getWallets))).Methods(http.MethodGet)
+	router.Handle("/wallet/{currency}/statement", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getWalletStatement))).Methods(http.MethodGet)
+	router.Handle("/statements", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getStatements))).Methods(http.MethodGet)
+	router.Handle("/statements", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createStatement)))).Methods(http.MethodPost)
+	router.Handle("/statements/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getStatement))).Methods(http.MethodGet)
+	router.Handle("/statements/{id}/{format}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.downloadStatement))).Methods(http.MethodGet)
+	router.Handle("/wallet/topups", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createTopUp)))).Methods(http.MethodPost)
+	router.Handle("/wallet/withdrawals", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createWithdrawal)))).Methods(http.MethodPost)
+	router.Handle("/wallet/payments", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getPayments))).Methods(http.MethodGet)
+	router.Handle("/wallet/payments/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getPayment))).Methods(http.MethodGet)
+	router.Handle("/payments/webhook/{provider}", makeHTTPHandlerFunc(s.paymentWebhook)).Methods(http.MethodPost)
+
+	router.Handle("/transfers", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createTransfer)))).Methods(http.MethodPost)
+	router.Handle("/transfers", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getTransfers))).Methods(http.MethodGet)
+	router.Handle("/transfers/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getTransfer))).Methods(http.MethodGet)
+	router.Handle("/transfers/{id}/reverse", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.reverseTransfer)))).Methods(http.MethodPost)
+	router.Handle("/scheduled-transfers", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createScheduledTransfer)))).Methods(http.MethodPost)
+	router.Handle("/scheduled-transfers", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getScheduledTransfers))).Methods(http.MethodGet)
+	router.Handle("/scheduled-transfers/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getScheduledTransfer))).Methods(http.MethodGet)
+	router.Handle("/scheduled-transfers/{id}/cancel", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.cancelScheduledTransfer)))).Methods(http.MethodPost)
+	router.Handle("/money-requests", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createMoneyRequest)))).Methods(http.MethodPost)
+	router.Handle("/money-requests", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getMoneyRequests))).Methods(http.MethodGet)
+	router.Handle("/money-requests/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getMoneyRequest))).Methods(http.MethodGet)
+	router.Handle("/money-requests/{id}/approve", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.approveMoneyRequest)))).Methods(http.MethodPost)
+	router.Handle("/money-requests/{id}/decline", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.declineMoneyRequest)))).Methods(http.MethodPost)
+	router.Handle("/admin/ledger/accounts", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getSystemAccounts))).Methods(http.MethodGet)
+	router.Handle("/admin/ledger/entries", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.postJournalEntry)))).Methods(http.MethodPost)
+	router.Handle("/admin/ledger/check", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.checkLedger))).Methods(http.MethodGet)
+	router.Handle("/admin/limits", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getLimitRules))).Methods(http.MethodGet)
+	router.Handle("/admin/limits", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.replaceLimitRules))).Methods(http.MethodPut)
+	router.Handle("/admin/audit", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getAuditEvents))).Methods(http.MethodGet)
+	router.Handle("/admin/reconciliations", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createReconciliation)))).Methods(http.MethodPost)
+	router.Handle("/admin/reconciliations", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getReconciliations))).Methods(http.MethodGet)
+	router.Handle("/admin/reconciliations/review", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getReviewQueue))).Methods(http.MethodGet)
+	router.Handle("/admin/reconciliations/items/{id}/resolve", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.resolveReconciliationItem)))).Methods(http.MethodPost)
+	router.Handle("/admin/reconciliations/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getReconciliation))).Methods(http.MethodGet)
+
+	router.Handle("/analytics/categories", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.spendByCategory))).Methods(http.MethodGet)
+	router.Handle("/analytics/payment-methods", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.spendByPaymentMethod))).Methods(http.MethodGet)
+	router.Handle("/analytics/trend", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.spendTrend))).Methods(http.MethodGet)
+	router.Handle("/analytics/top", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.topDescriptions))).Methods(http.MethodGet)
+	router.Handle("/analytics/month-over-month", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.monthOverMonth))).Methods(http.MethodGet)
+	router.Handle("/analytics/cash-flow", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.cashFlow))).Methods(http.MethodGet)
+
+	router.Handle("/income", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createIncome)))).Methods(http.MethodPost)
+	router.Handle("/income", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getIncomes))).Methods(http.MethodGet)
+	router.Handle("/income/{id}", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.updateIncome)))).Methods(http.MethodPatch)
+	router.Handle("/income/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteIncome))).Methods(http.MethodDelete)
+
+	router.Handle("/goals", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createGoal)))).Methods(http.MethodPost)
+	router.Handle("/goals", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getGoals))).Methods(http.MethodGet)
+	router.Handle("/goals/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getGoal))).Methods(http.MethodGet)
+	router.Handle("/goals/{id}", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.updateGoal)))).Methods(http.MethodPatch)
+	router.Handle("/goals/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteGoal))).Methods(http.MethodDelete)
+	router.Handle("/goals/{id}/contributions", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.contributeToGoal)))).Methods(http.MethodPost)
+	router.Handle("/goals/{id}/contributions/{contributionId}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteGoalContribution))).Methods(http.MethodDelete)
+
+	router.Handle("/ledgers", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createLedger)))).Methods(http.MethodPost)
+	router.Handle("/ledgers", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getLedgers))).Methods(http.MethodGet)
+	router.Handle("/ledgers/{id}/members", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getLedgerMembers))).Methods(http.MethodGet)
+	router.Handle("/ledgers/{id}/members/{userId}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.updateLedgerMember))).Methods(http.MethodPut)
+	router.Handle("/ledgers/{id}/members/{userId}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.removeLedgerMember))).Methods(http.MethodDelete)
+	router.Handle("/ledgers/{id}/invitations", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.inviteToLedger)))).Methods(http.MethodPost)
+	router.Handle("/ledgers/{id}/expenses", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createLedgerExpense)))).Methods(http.MethodPost)
+	router.Handle("/ledgers/{id}/expenses", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getLedgerExpenses))).Methods(http.MethodGet)
+	router.Handle("/ledgers/{id}/expenses/{expenseId}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteLedgerExpense))).Methods(http.MethodDelete)
+	router.Handle("/ledgers/{id}/settlements", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createSettlement)))).Methods(http.MethodPost)
+	router.Handle("/ledgers/{id}/settlements", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getSettlements))).Methods(http.MethodGet)
+	router.Handle("/ledgers/{id}/balances", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getLedgerBalances))).Methods(http.MethodGet)
+	router.Handle("/invitations", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getInvitations))).Methods(http.MethodGet)
+	router.Handle("/invitations/{id}/accept", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.acceptInvitation)))).Methods(http.MethodPost)
+	router.Handle("/invitations/{id}/decline", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.declineInvitation)))).Methods(http.MethodPost)
+
+	router.Handle("/recurring", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.createRecurringExpense)))).Methods(http.MethodPost)
+	router.Handle("/recurring", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getRecurringExpenses))).Methods(http.MethodGet)
+	router.Handle("/recurring/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.deleteRecurringExpense))).Methods(http.MethodDelete)
+	router.Handle("/recurring/{id}/occurrences/{date}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.updateRecurringOccurrence))).Methods(http.MethodPut)
+
+	router.Handle("/import", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.previewImport)))).Methods(http.MethodPost)
+	router.Handle("/import", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getImportBatches))).Methods(http.MethodGet)
+	router.Handle("/import/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.getImportBatch))).Methods(http.MethodGet)
+	router.Handle("/import/{id}/commit", security.ValidateAccessTokenMiddleware(idempotent(makeHTTPHandlerFunc(s.commitImport)))).Methods(http.MethodPost)
+	router.Handle("/import/{id}", security.ValidateAccessTokenMiddleware(makeHTTPHandlerFunc(s.rollbackImport))).Methods(http.MethodDelete)
 
 	serve := &http.Server{
 		Addr:         s.listenAddr,
@@ -302,14 +504,34 @@ func (s *Server) validateAccessToken(tokenString string, accessToken string) (*t
 	return claims, nil
 }
 
+// StartTokenCleanup runs the periodic maintenance jobs: expired session
+// cleanup, recurring expense generation, purging the expense trash,
+// closing out stale transfers, checking the wallet ledger and producing
+// month-end statements. The first run happens immediately so anything
+// missed while the server was down is caught up on start.
 func (s *Server) StartTokenCleanup(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 
 	go func() {
+		s.runMaintenance()
 		for range ticker.C {
-			if err := s.store.CleanupExpiredTokens(); err != nil {
-				log.Printf("Token cleanup failed: %v", err)
-			}
+			s.runMaintenance()
 		}
 	}()
 }
+
+func (s *Server) runMaintenance() {
+	if err := s.store.CleanupExpiredTokens(); err != nil {
+		log.Printf("Token cleanup failed: %v", err)
+	}
+
+	if err := s.generateRecurringExpenses(time.Now()); err != nil {
+		log.Printf("Recurring expense generation failed: %v", err)
+	}
+
+	s.purgeTrash()
+	s.failStaleTransfers()
+	s.processMoneyRequests()
+	s.checkLedgerInvariants()
+	s.produceStatements(time.Now())
+}
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/statement"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	statementBatch = 200
+	// statementLookback is how many months back a statement can be asked
+	// for.
+	statementLookback = 24
+
+	// lastMonthEnd is how long after midnight UTC the month ends in the
+	// westernmost timezone, UTC-12. The month-end job waits for it so
+	// every user's month is over.
+	lastMonthEnd = 12 * time.Hour
+)
+
+func (s *Server) getStatements(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	currency := r.URL.Query().Get("currency")
+	if currency != "" {
+		if currency, err = fx.NormalizeCurrency(currency); err != nil {
+			return err
+		}
+	}
+	limit, err := intParam(r, "limit", 24, 1, 500)
+	if err != nil {
+		return err
+	}
+
+	list, err := s.store.GetStatements(userID, currency, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, list)
+}
+
+// createStatement produces the statement of one of the user's wallets for
+// a month that has ended within the last statementLookback months, such as
+// one from before statements existed. A statement that was already
+// produced is returned as it is.
+func (s *Server) createStatement(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(types.StatementRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return err
+	}
+	currency, err := fx.NormalizeCurrency(req.Currency)
+	if err != nil {
+		return err
+	}
+	if err := validateStatementPeriod(req.Period, time.Now()); err != nil {
+		return err
+	}
+
+	if existing, err := s.store.GetStatementByPeriod(userID, currency, req.Period); err == nil {
+		return writeJSON(w, http.StatusOK, existing)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	user, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	wallets, err := s.store.GetWallets(userID)
+	if err != nil {
+		return err
+	}
+	var account *types.LedgerAccount
+	for i := range wallets {
+		if wallets[i].Currency == currency {
+			account = &wallets[i]
+		}
+	}
+	if account == nil {
+		return fmt.Errorf("%s wallet %w", currency, errNotFound)
+	}
+
+	st, err := s.produceStatement(user, *account, req.Period, time.Now())
+	if errors.Is(err, database.ErrStatementExists) {
+		if st, err = s.store.GetStatementByPeriod(userID, currency, req.Period); err != nil {
+			return err
+		}
+		return writeJSON(w, http.StatusOK, st)
+	}
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, st)
+}
+
+func (s *Server) getStatement(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	st, err := s.store.GetStatement(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("statement %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, st)
+}
+
+// downloadStatement serves the stored PDF or CSV of a statement exactly as
+// it was produced.
+func (s *Server) downloadStatement(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	vars := mux.Vars(r)
+	format := vars["format"]
+	contentType := map[string]string{"pdf": "application/pdf", "csv": "text/csv; charset=utf-8"}[format]
+	if contentType == "" {
+		return fmt.Errorf("format must be pdf or csv")
+	}
+
+	st, err := s.store.GetStatement(userID, vars["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("statement %w", errNotFound)
+		}
+		return err
+	}
+	data, err := s.store.GetStatementFile(userID, st.ID, format)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="statement-%s-%s.%s"`, st.Currency, st.Period, format))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	return err
+}
+
+// produceStatement renders and stores the statement of a wallet for period
+// in the holder's timezone. The month must have ended.
+func (s *Server) produceStatement(user *types.User, account types.LedgerAccount, period string, now time.Time) (*types.Statement, error) {
+	timezone := user.Timezone
+	loc, err := time.LoadLocation(timezone)
+	if timezone == "" || err != nil {
+		timezone, loc = "UTC", time.UTC
+	}
+	from, to, err := statement.Month(period, loc)
+	if err != nil {
+		return nil, err
+	}
+	if to.After(now) {
+		return nil, fmt.Errorf("statements are only produced for months that have ended")
+	}
+
+	opening, lines, err := s.store.GetStatementPostings(account.ID, from, to, timezone)
+	if err != nil {
+		return nil, err
+	}
+	st := types.Statement{
+		UserID:         user.ID,
+		AccountID:      account.ID,
+		Currency:       account.Currency,
+		Period:         period,
+		Timezone:       timezone,
+		PeriodStart:    from.Format(time.DateOnly),
+		PeriodEnd:      to.AddDate(0, 0, -1).Format(time.DateOnly),
+		OpeningBalance: opening,
+	}
+	if err := statement.Summarize(&st, lines); err != nil {
+		return nil, err
+	}
+
+	doc := statement.Document{
+		Statement: st,
+		Holder:    user.FirstName + " " + user.LastName,
+		Email:     user.Email,
+		Lines:     lines,
+		Generated: now,
+	}
+	var pdf, csv bytes.Buffer
+	if err := statement.WritePDF(&pdf, doc); err != nil {
+		return nil, err
+	}
+	if err := statement.WriteCSV(&csv, doc); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(pdf.Bytes())
+	st.Checksum = hex.EncodeToString(sum[:])
+
+	if err := s.store.CreateStatement(&st, pdf.Bytes(), csv.Bytes()); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// validateStatementPeriod accepts a month that has ended somewhere by now
+// and is at most statementLookback months old. Whether it has ended in the
+// holder's timezone is left to produceStatement.
+func validateStatementPeriod(period string, now time.Time) error {
+	if _, _, err := statement.Month(period, time.UTC); err != nil {
+		return err
+	}
+	// UTC+14 is the first timezone to finish a month.
+	latest := statement.PreviousMonth(now.UTC().Add(14 * time.Hour))
+	earliest := now.UTC().AddDate(0, -statementLookback, 0).Format(statement.PeriodLayout)
+	if period > latest {
+		return fmt.Errorf("statements are only produced for months that have ended")
+	}
+	if period < earliest {
+		return fmt.Errorf("statements go back at most %d months", statementLookback)
+	}
+	return nil
+}
+
+// produceStatements is the month-end job. Once the last month has ended
+// everywhere it produces the statement of every wallet that does not have
+// one yet, and tells the holder it is ready. Wallets are walked in pages,
+// so ones that fail are retried on the next run without holding up the
+// rest.
+func (s *Server) produceStatements(now time.Time) {
+	period := statement.PreviousMonth(now.UTC().Add(-lastMonthEnd))
+	_, end, err := statement.Month(period, time.UTC)
+	if err != nil {
+		slog.Error("Failed to work out statement period", "error", err)
+		return
+	}
+
+	users := map[string]*types.User{}
+	produced, cursor := 0, ""
+	for {
+		due, err := s.store.GetWalletsDueStatement(period, end.Add(lastMonthEnd), cursor, statementBatch)
+		if err != nil {
+			slog.Error("Failed to load wallets due a statement", "error", err)
+			return
+		}
+
+		made := 0
+		for _, account := range due {
+			cursor = account.ID
+			user, ok := users[account.UserID]
+			if !ok {
+				if user, err = s.store.GetUser(account.UserID); err != nil {
+					slog.Error("Failed to load statement holder", "error", err, "account", account.ID)
+					continue
+				}
+				users[account.UserID] = user
+			}
+
+			st, err := s.produceStatement(user, account, period, now)
+			if errors.Is(err, database.ErrStatementExists) {
+				continue
+			}
+			if err != nil {
+				slog.Error("Failed to produce statement", "error", err, "account", account.ID, "period", period)
+				continue
+			}
+			made++
+			s.notifyStatementReady(*st)
+		}
+
+		produced += made
+		if len(due) < statementBatch {
+			break
+		}
+	}
+	if produced > 0 {
+		slog.Info("Produced monthly statements", "count", produced, "period", period)
+	}
+}
+
+func (s *Server) notifyStatementReady(st types.Statement) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	month := st.Period
+	if t, err := time.Parse(statement.PeriodLayout, st.Period); err == nil {
+		month = t.Format("January 2006")
+	}
+	note := types.Notification{
+		UserID: st.UserID,
+		Kind:   "statement_ready",
+		Title:  "Your statement is ready",
+		Body: fmt.Sprintf("Your %s statement for %s is ready. Closing balance: %s.",
+			st.Currency, month, ledger.Format(st.ClosingBalance, st.Currency)),
+		Data: map[string]string{"statementId": st.ID},
+	}
+	if err := s.notifier.Send(ctx, note, types.ChannelInApp, types.ChannelPush); err != nil {
+		slog.Error("Failed to notify statement", "error", err, "statement", st.ID)
+	}
+}
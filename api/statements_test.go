@@ -0,0 +1,21 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStatementPeriod(t *testing.T) {
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	assert.NoError(t, validateStatementPeriod("2026-09", now))
+	assert.NoError(t, validateStatementPeriod("2024-10", now))
+	assert.Error(t, validateStatementPeriod("2024-09", now))
+	assert.Error(t, validateStatementPeriod("2026-10", now))
+	assert.Error(t, validateStatementPeriod("2026-13", now))
+
+	// September has ended in UTC+14 before it has in UTC.
+	assert.NoError(t, validateStatementPeriod("2026-09", time.Date(2026, 9, 30, 11, 0, 0, 0, time.UTC)))
+	assert.Error(t, validateStatementPeriod("2026-09", time.Date(2026, 9, 30, 9, 0, 0, 0, time.UTC)))
+}
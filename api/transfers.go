@@ -0,0 +1,259 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxTransferNote = 280
+
+	// staleTransferAge is how long a transfer may stay pending before the
+	// maintenance job gives up on it.
+	staleTransferAge = 10 * time.Minute
+)
+
+// createTransfer sends money from the caller's wallet to another user's,
+// found by phone number or email, once the transaction PIN checks out. The
+// transfer is recorded as pending first; if it is over the sender's limits
+// it is marked failed and turned down with a 403, and if the money cannot
+// move it is marked failed and returned with a 409.
+func (s *Server) createTransfer(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	req := new(types.WalletTransferRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return err
+	}
+	if err := validateTransferRequest(req); err != nil {
+		return err
+	}
+	if err := s.requirePIN(r, userID); err != nil {
+		return err
+	}
+
+	sender, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if req.Currency == "" {
+		req.Currency = sender.HomeCurrency
+	}
+	if req.Currency == "" {
+		req.Currency = fx.DefaultCurrency
+	}
+
+	recipient, err := s.findRecipient(req.To)
+	if err != nil {
+		return err
+	}
+	if recipient.ID == userID {
+		return fmt.Errorf("you cannot send money to yourself")
+	}
+
+	transfer := &types.WalletTransfer{
+		SenderID:    userID,
+		RecipientID: recipient.ID,
+		Recipient:   req.To,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Note:        req.Note,
+	}
+	if err := s.store.CreateTransfer(transfer); err != nil {
+		return err
+	}
+
+	completed, err := s.store.CompleteTransfer(transfer.ID)
+	var limitErr *database.LimitError
+	if errors.As(err, &limitErr) {
+		if err := s.store.FailTransfer(transfer.ID, limitErr.Error()); err != nil {
+			slog.Error("Failed to mark transfer failed", "error", err, "transfer", transfer.ID)
+		}
+		return rejectOverLimit(w, limitErr)
+	}
+	if err != nil {
+		reason := "transfer could not be completed"
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			reason = err.Error()
+		} else {
+			slog.Error("Failed to post transfer", "error", err, "transfer", transfer.ID)
+		}
+		if err := s.store.FailTransfer(transfer.ID, reason); err != nil {
+			slog.Error("Failed to mark transfer failed", "error", err, "transfer", transfer.ID)
+		}
+		transfer.Status = types.TransferFailed
+		transfer.FailureReason = reason
+		transfer.Direction = "sent"
+		return writeJSON(w, http.StatusConflict, transfer)
+	}
+	completed.Direction = "sent"
+
+	s.notifyTransferReceived(sender, *completed)
+	return writeJSON(w, http.StatusCreated, completed)
+}
+
+// findRecipient resolves the phone number or email a transfer is addressed
+// to.
+func (s *Server) findRecipient(to string) (*types.User, error) {
+	var (
+		user *types.User
+		err  error
+	)
+	if strings.Contains(to, "@") {
+		user, err = s.store.GetUserByEmail(to)
+	} else {
+		user, err = s.store.GetUserByPhone(to)
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("recipient %w", errNotFound)
+	case errors.Is(err, database.ErrAmbiguousPhone):
+		return nil, fmt.Errorf("%w; send to their email instead", err)
+	}
+	return user, err
+}
+
+func (s *Server) getTransfers(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	direction := r.URL.Query().Get("direction")
+	if direction != "" && direction != "sent" && direction != "received" {
+		return fmt.Errorf("direction must be sent or received")
+	}
+	limit, err := intParam(r, "limit", 50, 1, 500)
+	if err != nil {
+		return err
+	}
+
+	transfers, err := s.store.GetTransfers(userID, direction, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, transfers)
+}
+
+func (s *Server) getTransfer(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	transfer, err := s.store.GetTransfer(userID, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("transfer %w", errNotFound)
+		}
+		return err
+	}
+	return writeJSON(w, http.StatusOK, transfer)
+}
+
+// reverseTransfer sends a completed transfer back to its sender. The
+// recipient can do this themselves, for example after being paid by
+// mistake, with their transaction PIN and within their limits; admins can
+// reverse any transfer.
+func (s *Server) reverseTransfer(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+	asAdmin := s.requireAdmin(r) == nil
+	if !asAdmin {
+		if err := s.requirePIN(r, userID); err != nil {
+			return err
+		}
+	}
+
+	transfer, err := s.store.ReverseTransfer(userID, mux.Vars(r)["id"], asAdmin)
+	if err != nil {
+		var limitErr *database.LimitError
+		switch {
+		case errors.As(err, &limitErr):
+			return rejectOverLimit(w, limitErr)
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("transfer %w", errNotFound)
+		case errors.Is(err, database.ErrInvalidTransferState):
+			return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+		}
+		return ledgerError(w, err)
+	}
+	transfer.Direction = "received"
+	if transfer.SenderID == userID {
+		transfer.Direction = "sent"
+	}
+	return writeJSON(w, http.StatusOK, transfer)
+}
+
+func validateTransferRequest(req *types.WalletTransferRequest) error {
+	req.To = strings.TrimSpace(req.To)
+	if req.To == "" {
+		return fmt.Errorf("to must be a phone number or email")
+	}
+	if req.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if len(req.Note) > maxTransferNote {
+		return fmt.Errorf("note must be at most %d characters", maxTransferNote)
+	}
+	if req.Currency != "" {
+		currency, err := fx.NormalizeCurrency(req.Currency)
+		if err != nil {
+			return err
+		}
+		req.Currency = currency
+	}
+	return nil
+}
+
+func (s *Server) notifyTransferReceived(sender *types.User, t types.WalletTransfer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	body := fmt.Sprintf("%s %s sent you %s.", sender.FirstName, sender.LastName, ledger.Format(t.Amount, t.Currency))
+	if t.Note != "" {
+		body += " Note: " + t.Note
+	}
+	note := types.Notification{
+		UserID: t.RecipientID,
+		Kind:   "transfer_received",
+		Title:  "Money received",
+		Body:   body,
+		Data:   map[string]string{"transferId": t.ID},
+	}
+	if err := s.notifier.Send(ctx, note, types.ChannelInApp, types.ChannelPush); err != nil {
+		slog.Error("Failed to notify transfer recipient", "error", err, "transfer", t.ID)
+	}
+}
+
+// failStaleTransfers is the maintenance job that closes out transfers
+// interrupted before their money moved.
+func (s *Server) failStaleTransfers() {
+	n, err := s.store.FailStaleTransfers(staleTransferAge)
+	if err != nil {
+		slog.Error("Failed to close stale transfers", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("Failed stale transfers", "count", n)
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTransferRequest(t *testing.T) {
+	req := types.WalletTransferRequest{To: " +256 772 000111 ", Amount: 5000, Currency: "ugx", Note: " lunch "}
+	require.NoError(t, validateTransferRequest(&req))
+	assert.Equal(t, "+256 772 000111", req.To)
+	assert.Equal(t, "UGX", req.Currency)
+	assert.Equal(t, "lunch", req.Note)
+
+	invalid := []types.WalletTransferRequest{
+		{To: "", Amount: 5000},
+		{To: "jane@example.com", Amount: 0},
+		{To: "jane@example.com", Amount: -10},
+		{To: "jane@example.com", Amount: 10, Currency: "shillings"},
+		{To: "jane@example.com", Amount: 10, Note: strings.Repeat("x", maxTransferNote+1)},
+	}
+	for _, r := range invalid {
+		assert.Error(t, validateTransferRequest(&r))
+	}
+}
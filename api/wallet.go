@@ -0,0 +1,147 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/types"
+	"github.com/gorilla/mux"
+)
+
+// getWallets lists the user's wallet balances, opening a wallet in their
+// home currency if they have none yet.
+func (s *Server) getWallets(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.store.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	currency := user.HomeCurrency
+	if currency == "" {
+		currency = fx.DefaultCurrency
+	}
+	if _, err := s.store.WalletAccount(userID, currency); err != nil {
+		return err
+	}
+
+	wallets, err := s.store.GetWallets(userID)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, wallets)
+}
+
+// getWalletStatement lists the latest postings to the user's wallet in one
+// currency.
+func (s *Server) getWalletStatement(w http.ResponseWriter, r *http.Request) error {
+	userID, err := currentUserID(r)
+	if err != nil {
+		return err
+	}
+
+	currency, err := fx.NormalizeCurrency(mux.Vars(r)["currency"])
+	if err != nil {
+		return err
+	}
+	limit, err := intParam(r, "limit", 50, 1, 500)
+	if err != nil {
+		return err
+	}
+
+	account, err := s.store.WalletAccount(userID, currency)
+	if err != nil {
+		return err
+	}
+	lines, err := s.store.GetAccountStatement(account.ID, limit)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]any{"account": account, "postings": lines})
+}
+
+// getSystemAccounts lists the fee, suspense and provider float accounts.
+func (s *Server) getSystemAccounts(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	accounts, err := s.store.GetWallets("")
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, accounts)
+}
+
+// postJournalEntry lets an admin record a manual adjustment between ledger
+// accounts.
+func (s *Server) postJournalEntry(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	entry := new(types.JournalEntry)
+	if err := json.NewDecoder(r.Body).Decode(entry); err != nil {
+		return err
+	}
+	for i := range entry.Postings {
+		currency, err := fx.NormalizeCurrency(entry.Postings[i].Currency)
+		if err != nil {
+			return err
+		}
+		entry.Postings[i].Currency = currency
+	}
+
+	if err := s.store.PostJournalEntry(entry); err != nil {
+		return ledgerError(w, err)
+	}
+	return writeJSON(w, http.StatusCreated, entry)
+}
+
+// checkLedger runs the ledger invariant checks on demand.
+func (s *Server) checkLedger(w http.ResponseWriter, r *http.Request) error {
+	if err := s.requireAdmin(r); err != nil {
+		return err
+	}
+
+	violations, err := s.store.CheckLedgerInvariants()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, map[string]any{"ok": len(violations) == 0, "violations": violations})
+}
+
+// ledgerError reports a failed posting: conflicts with the ledger's state
+// are 409s and unknown accounts 404s.
+func ledgerError(w http.ResponseWriter, err error) error {
+	switch {
+	case errors.Is(err, ledger.ErrInsufficientFunds), errors.Is(err, ledger.ErrDuplicateEntry):
+		return writeJSON(w, http.StatusConflict, Err{Err: err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("ledger account %w", errNotFound)
+	}
+	return err
+}
+
+// checkLedgerInvariants is the maintenance job that reports ledger
+// corruption.
+func (s *Server) checkLedgerInvariants() {
+	violations, err := s.store.CheckLedgerInvariants()
+	if err != nil {
+		log.Printf("Ledger invariant check failed: %v", err)
+		return
+	}
+	for _, v := range violations {
+		log.Printf("Ledger invariant %s violated: account=%s entry=%s currency=%s: %s",
+			v.Check, v.AccountID, v.EntryID, v.Currency, v.Detail)
+	}
+}
@@ -0,0 +1,95 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("blob not found")
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+)
+
+// Store is where uploaded files such as receipts live. Keys are slash
+// separated paths chosen by the caller.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that allows anyone holding it to download the
+	// blob until the expiry passes.
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// NewFromEnv builds the store selected by BLOB_BACKEND ("local", the default,
+// or "s3").
+func NewFromEnv() (Store, error) {
+	switch backend := os.Getenv("BLOB_BACKEND"); backend {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "./data/blobs"
+		}
+		return NewLocalStore(dir, os.Getenv("BLOB_BASE_URL"), signingKey())
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: os.Getenv("S3_VIRTUAL_HOST") != "true",
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", backend)
+	}
+}
+
+func signingKey() []byte {
+	if key := os.Getenv("BLOB_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+
+	slog.Warn("BLOB_SIGNING_KEY is not set, signed URLs will not survive a restart")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("failed to generate blob signing key: %v", err))
+	}
+	return key
+}
+
+// NewKey returns a random key under prefix, keeping the file extension.
+func NewKey(prefix, extension string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + "/" + hex.EncodeToString(b) + extension, nil
+}
+
+func sign(secret []byte, key string, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verify(secret []byte, key, expires, signature string, now time.Time) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > exp {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sign(secret, key, exp)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
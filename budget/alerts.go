@@ -0,0 +1,62 @@
+package budget
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Period returns the first and last day of the budget month containing
+// date, and the key used to remember which alerts already fired in it.
+func Period(date string) (start, end, key string, err error) {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid date %q", date)
+	}
+	first := time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1)
+	return first.Format("2006-01-02"), last.Format("2006-01-02"), first.Format("2006-01"), nil
+}
+
+// Crossed returns the rules whose threshold has been reached, lowest first.
+// A category without a positive budget never triggers alerts.
+func Crossed(spent, budget float64, rules []types.BudgetAlertRule) []types.BudgetAlertRule {
+	if budget <= 0 {
+		return nil
+	}
+
+	var crossed []types.BudgetAlertRule
+	for _, rule := range rules {
+		if spent >= budget*rule.ThresholdPercent/100 {
+			crossed = append(crossed, rule)
+		}
+	}
+	sort.Slice(crossed, func(i, j int) bool {
+		return crossed[i].ThresholdPercent < crossed[j].ThresholdPercent
+	})
+	return crossed
+}
+
+// Message builds the notification for a crossed threshold. Amounts are in
+// currency, the user's home currency.
+func Message(rule types.BudgetAlertRule, spent, budget float64, currency, period string) types.Notification {
+	title := fmt.Sprintf("%s budget at %.0f%%", rule.Category, rule.ThresholdPercent)
+	if rule.ThresholdPercent >= 100 {
+		title = fmt.Sprintf("%s budget exceeded", rule.Category)
+	}
+
+	return types.Notification{
+		Kind:  "budget_alert",
+		Title: title,
+		Body: fmt.Sprintf("You have spent %.2f %s of your %.2f %s %s budget for %s (%.0f%%).",
+			spent, currency, budget, currency, rule.Category, period, spent/budget*100),
+		Data: map[string]string{
+			"category":  rule.Category,
+			"period":    period,
+			"currency":  currency,
+			"threshold": fmt.Sprintf("%g", rule.ThresholdPercent),
+		},
+	}
+}
@@ -0,0 +1,48 @@
+package budget
+
+import (
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriod(t *testing.T) {
+	start, end, key, err := Period("2024-02-14")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-02-01", start)
+	assert.Equal(t, "2024-02-29", end)
+	assert.Equal(t, "2024-02", key)
+
+	_, _, _, err = Period("14/02/2024")
+	assert.Error(t, err)
+}
+
+func TestCrossed(t *testing.T) {
+	rules := []types.BudgetAlertRule{
+		{ID: "r100", ThresholdPercent: 100},
+		{ID: "r80", ThresholdPercent: 80},
+	}
+
+	assert.Empty(t, Crossed(799, 1000, rules))
+
+	crossed := Crossed(800, 1000, rules)
+	require.Len(t, crossed, 1)
+	assert.Equal(t, "r80", crossed[0].ID)
+
+	crossed = Crossed(1200, 1000, rules)
+	require.Len(t, crossed, 2)
+	assert.Equal(t, "r80", crossed[0].ID)
+	assert.Equal(t, "r100", crossed[1].ID)
+
+	assert.Empty(t, Crossed(500, 0, rules))
+}
+
+func TestMessage(t *testing.T) {
+	n := Message(types.BudgetAlertRule{Category: "Food", ThresholdPercent: 100}, 1100, 1000, "KES", "2025-01")
+	assert.Equal(t, "Food budget exceeded", n.Title)
+	assert.Equal(t, "budget_alert", n.Kind)
+	assert.Contains(t, n.Body, "110%")
+	assert.Contains(t, n.Body, "1100.00 KES of your 1000.00 KES")
+}
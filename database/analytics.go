@@ -0,0 +1,270 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// analyticsSchema adds the user's timezone and an index over live expenses
+// covering every column convertedExpenses reads, so the dashboard
+// aggregates can be answered from the index alone.
+const analyticsSchema = `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+	DROP INDEX IF EXISTS idx_expenses_analytics;
+	CREATE INDEX IF NOT EXISTS idx_expenses_analytics_live
+		ON expenses (user_id, expense_date)
+		INCLUDE (id, amount, currency, category, payment_method, description)
+		WHERE deleted_at IS NULL;
+	`
+
+// rangeBounds resolves an optional from/to pair ($3, $4) against the user's
+// local date in timezone $2, defaulting to the last 30 days.
+const rangeBounds = `bounds AS (
+		SELECT COALESCE(NULLIF($3, '')::date, (NOW() AT TIME ZONE $2)::date - 29) AS start_date,
+		COALESCE(NULLIF($4, '')::date, (NOW() AT TIME ZONE $2)::date) AS end_date
+	)`
+
+// convertedExpenses selects user $1's expenses within the bounds, adding
+// home_amount converted into the currency held by the given parameter. It is
+// NULL, and unconverted is true, when no rate is known for the date.
+func convertedExpenses(currencyParam int) string {
+	return fmt.Sprintf(`converted AS (
+		SELECT e.id, e.expense_date, e.amount, e.currency, e.category, e.payment_method, e.description,
+		e.amount * fx.rate AS home_amount, fx.rate IS NULL AS unconverted
+		FROM expenses e
+		CROSS JOIN bounds b
+		CROSS JOIN LATERAL (SELECT fx_rate(e.currency, $%d, e.expense_date) AS rate) fx
+		WHERE e.user_id = $1 AND e.deleted_at IS NULL
+			AND e.expense_date BETWEEN b.start_date AND b.end_date
+	)`, currencyParam)
+}
+
+// Grouped reports first total per group and original currency, then fold
+// the currencies together.
+const (
+	currencyTotals = `c.currency, SUM(c.amount) AS original, SUM(c.home_amount) AS total,
+		COUNT(*) AS n, COUNT(*) FILTER (WHERE c.unconverted) AS unconverted`
+	spendTotals = `ROUND(COALESCE(SUM(total), 0), 2), SUM(n)::int, SUM(unconverted)::int,
+		json_object_agg(currency, original)`
+)
+
+func decodeOriginal(raw []byte) (map[string]float64, error) {
+	original := map[string]float64{}
+	if len(raw) == 0 {
+		return original, nil
+	}
+	return original, json.Unmarshal(raw, &original)
+}
+
+// SpendByCategory totals spending per category for each of the last months
+// calendar months in the user's timezone, including the current one.
+func (s *Storage) SpendByCategory(userID, timezone, currency string, months int) ([]types.CategorySpend, error) {
+	query := `WITH bounds AS (
+		SELECT (date_trunc('month', NOW() AT TIME ZONE $2) - make_interval(months => $3::int - 1))::date AS start_date,
+		(NOW() AT TIME ZONE $2)::date AS end_date
+	),
+	` + convertedExpenses(4) + `,
+	per_currency AS (
+		SELECT to_char(date_trunc('month', c.expense_date::timestamp), 'YYYY-MM') AS month,
+		COALESCE(NULLIF(c.category, ''), 'Uncategorized') AS category,
+		` + currencyTotals + `
+		FROM converted c
+		GROUP BY 1, 2, c.currency
+	)
+	SELECT month, category, ` + spendTotals + `
+	FROM per_currency
+	GROUP BY 1, 2
+	ORDER BY 1, 3 DESC`
+
+	rows, err := s.db.Query(query, userID, timezone, months, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category spend: %w", err)
+	}
+	defer rows.Close()
+
+	result := []types.CategorySpend{}
+	for rows.Next() {
+		c := types.CategorySpend{Currency: currency}
+		var original []byte
+		if err := rows.Scan(&c.Month, &c.Category, &c.Total, &c.Count, &c.Unconverted, &original); err != nil {
+			return nil, err
+		}
+		if c.Original, err = decodeOriginal(original); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+func (s *Storage) SpendByPaymentMethod(userID, timezone, currency, from, to string) ([]types.PaymentMethodSpend, error) {
+	query := `WITH ` + rangeBounds + `,
+	` + convertedExpenses(5) + `,
+	per_currency AS (
+		SELECT COALESCE(NULLIF(c.payment_method, ''), 'Unspecified') AS payment_method,
+		` + currencyTotals + `
+		FROM converted c
+		GROUP BY 1, c.currency
+	)
+	SELECT payment_method, ` + spendTotals + `
+	FROM per_currency
+	GROUP BY 1
+	ORDER BY 2 DESC`
+
+	rows, err := s.db.Query(query, userID, timezone, from, to, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment method spend: %w", err)
+	}
+	defer rows.Close()
+
+	result := []types.PaymentMethodSpend{}
+	for rows.Next() {
+		p := types.PaymentMethodSpend{Currency: currency}
+		var original []byte
+		if err := rows.Scan(&p.PaymentMethod, &p.Total, &p.Count, &p.Unconverted, &original); err != nil {
+			return nil, err
+		}
+		if p.Original, err = decodeOriginal(original); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// SpendTrend returns one point per day or week in the range, with empty
+// periods filled in as zero so charts do not have gaps. unit is "day" or
+// "week"; weeks start on Monday.
+func (s *Storage) SpendTrend(userID, timezone, currency, unit, from, to string) ([]types.TrendPoint, error) {
+	if unit != "day" && unit != "week" {
+		return nil, fmt.Errorf("unsupported trend unit %q", unit)
+	}
+
+	query := `WITH ` + rangeBounds + `,
+	` + convertedExpenses(6) + `,
+	periods AS (
+		SELECT generate_series(
+			date_trunc($5, b.start_date::timestamp),
+			date_trunc($5, b.end_date::timestamp),
+			('1 ' || $5)::interval
+		)::date AS period
+		FROM bounds b
+	)
+	SELECT p.period::text, ROUND(COALESCE(SUM(c.home_amount), 0), 2), COUNT(c.id),
+	COUNT(*) FILTER (WHERE c.unconverted)
+	FROM periods p
+	LEFT JOIN converted c ON date_trunc($5, c.expense_date::timestamp)::date = p.period
+	GROUP BY p.period
+	ORDER BY p.period`
+
+	rows, err := s.db.Query(query, userID, timezone, from, to, unit, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spend trend: %w", err)
+	}
+	defer rows.Close()
+
+	result := []types.TrendPoint{}
+	for rows.Next() {
+		p := types.TrendPoint{Currency: currency}
+		if err := rows.Scan(&p.Period, &p.Total, &p.Count, &p.Unconverted); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// TopDescriptions ranks merchants or descriptions by total spend. Case and
+// surrounding whitespace are ignored when grouping.
+func (s *Storage) TopDescriptions(userID, timezone, currency, from, to string, limit int) ([]types.TopDescription, error) {
+	query := `WITH ` + rangeBounds + `,
+	` + convertedExpenses(6) + `,
+	per_currency AS (
+		SELECT LOWER(TRIM(c.description)) AS key, MIN(TRIM(c.description)) AS label,
+		` + currencyTotals + `
+		FROM converted c
+		WHERE TRIM(c.description) <> ''
+		GROUP BY 1, c.currency
+	)
+	SELECT MIN(label), ` + spendTotals + `
+	FROM per_currency
+	GROUP BY key
+	ORDER BY 2 DESC
+	LIMIT $5`
+
+	rows, err := s.db.Query(query, userID, timezone, from, to, limit, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top descriptions: %w", err)
+	}
+	defer rows.Close()
+
+	result := []types.TopDescription{}
+	for rows.Next() {
+		t := types.TopDescription{Currency: currency}
+		var original []byte
+		if err := rows.Scan(&t.Description, &t.Total, &t.Count, &t.Unconverted, &original); err != nil {
+			return nil, err
+		}
+		if t.Original, err = decodeOriginal(original); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+// MonthOverMonth compares each of the last months calendar months with the
+// month before it.
+func (s *Storage) MonthOverMonth(userID, timezone, currency string, months int) ([]types.MonthChange, error) {
+	query := `WITH bounds AS (
+		SELECT (date_trunc('month', NOW() AT TIME ZONE $2) - make_interval(months => $3::int))::date AS start_date,
+		(NOW() AT TIME ZONE $2)::date AS end_date
+	),
+	` + convertedExpenses(4) + `,
+	months AS (
+		SELECT generate_series(b.start_date::timestamp, date_trunc('month', b.end_date::timestamp), interval '1 month')::date AS month
+		FROM bounds b
+	),
+	totals AS (
+		SELECT m.month, COALESCE(SUM(c.home_amount), 0) AS total
+		FROM months m
+		LEFT JOIN converted c ON c.expense_date >= m.month
+			AND c.expense_date < (m.month + interval '1 month')
+		GROUP BY m.month
+	)
+	SELECT to_char(month, 'YYYY-MM'), ROUND(total, 2), ROUND(COALESCE(LAG(total) OVER (ORDER BY month), 0), 2)
+	FROM totals
+	ORDER BY month`
+
+	rows, err := s.db.Query(query, userID, timezone, months, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query month over month: %w", err)
+	}
+	defer rows.Close()
+
+	result := []types.MonthChange{}
+	first := true
+	for rows.Next() {
+		m := types.MonthChange{Currency: currency}
+		if err := rows.Scan(&m.Month, &m.Total, &m.Previous); err != nil {
+			return nil, err
+		}
+		// The oldest month is only fetched to give the first reported
+		// month something to compare against.
+		if first {
+			first = false
+			continue
+		}
+
+		m.Change = m.Total - m.Previous
+		if m.Previous != 0 {
+			percent := m.Change / m.Previous * 100
+			m.ChangePercent = &percent
+		}
+		result = append(result, m)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// auditSchema is an append-only trail of security and compliance
+// decisions, such as limit checks.
+const auditSchema = `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		actor_id UUID,
+		action VARCHAR(50) NOT NULL,
+		outcome VARCHAR(50) NOT NULL,
+		details JSONB,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id),
+		FOREIGN KEY (actor_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_events_user ON audit_events (user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action, created_at);
+
+	CREATE OR REPLACE FUNCTION audit_events_immutable() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit events are immutable';
+	END
+	$$;
+
+	DROP TRIGGER IF EXISTS audit_events_immutable ON audit_events;
+	CREATE TRIGGER audit_events_immutable BEFORE UPDATE OR DELETE ON audit_events
+		FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
+	`
+
+func (s *Storage) RecordAuditEvent(e *types.AuditEvent) error {
+	return recordAuditEvent(s.db, e)
+}
+
+func recordAuditEvent(q queryer, e *types.AuditEvent) error {
+	var details string
+	if len(e.Details) > 0 {
+		raw, err := json.Marshal(e.Details)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit details: %w", err)
+		}
+		details = string(raw)
+	}
+
+	err := q.QueryRow(`INSERT INTO audit_events (user_id, actor_id, action, outcome, details)
+	VALUES ($1, NULLIF($2, '')::uuid, $3, $4, NULLIF($5, '')::jsonb)
+	RETURNING id, created_at::text`,
+		e.UserID, e.ActorID, e.Action, e.Outcome, details,
+	).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// GetAuditEvents lists audit events newest first, optionally only for one
+// user or action.
+func (s *Storage) GetAuditEvents(userID, action string, limit int) ([]types.AuditEvent, error) {
+	rows, err := s.db.Query(`SELECT id, user_id, COALESCE(actor_id::text, ''), action, outcome,
+		details, created_at::text
+	FROM audit_events
+	WHERE ($1 = '' OR user_id::text = $1) AND ($2 = '' OR action = $2)
+	ORDER BY created_at DESC
+	LIMIT $3`, userID, action, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []types.AuditEvent{}
+	for rows.Next() {
+		var (
+			e       types.AuditEvent
+			details []byte
+		)
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorID, &e.Action, &e.Outcome, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &e.Details); err != nil {
+				return nil, fmt.Errorf("failed to decode audit details: %w", err)
+			}
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// ApplyExpenseBatch runs the operations for userID in one transaction and
+// returns an error per operation, nil where it succeeded. Created and
+// updated expenses are written back to each operation's Expense.
+//
+// With atomic set, the first failure rolls everything back and later
+// operations are not attempted. Otherwise each operation runs under its own
+// savepoint so a failure only undoes that operation.
+func (s *Storage) ApplyExpenseBatch(userID string, ops []types.BatchOperation, atomic bool) ([]error, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	errs := make([]error, len(ops))
+	for i := range ops {
+		op := &ops[i]
+		if !atomic {
+			if _, err := tx.Exec(`SAVEPOINT batch_op`); err != nil {
+				return nil, fmt.Errorf("failed to create savepoint: %w", err)
+			}
+		}
+
+		switch op.Op {
+		case types.BatchCreate:
+			op.Expense.UserID = userID
+			errs[i] = createExpense(tx, op.Expense)
+		case types.BatchUpdate:
+			op.Expense, errs[i] = updateExpense(tx, userID, op.ID, *op.Update)
+		case types.BatchDelete:
+			errs[i] = deleteExpense(tx, userID, op.ID)
+		default:
+			errs[i] = fmt.Errorf("unknown operation %q", op.Op)
+		}
+
+		switch {
+		case errs[i] != nil && atomic:
+			return errs, nil
+		case errs[i] != nil:
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT batch_op`); err != nil {
+				return nil, fmt.Errorf("failed to roll back to savepoint: %w", err)
+			}
+		case !atomic:
+			if _, err := tx.Exec(`RELEASE SAVEPOINT batch_op`); err != nil {
+				return nil, fmt.Errorf("failed to release savepoint: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return errs, nil
+}
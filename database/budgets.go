@@ -0,0 +1,256 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const budgetSchema = `
+	CREATE TABLE IF NOT EXISTS categories (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		budget NUMERIC(14, 2) NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_user_name ON categories (user_id, LOWER(name));
+
+	CREATE TABLE IF NOT EXISTS budget_alert_rules (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		category_id UUID NOT NULL,
+		threshold_percent NUMERIC(6, 2) NOT NULL CHECK (threshold_percent > 0),
+		channels TEXT NOT NULL DEFAULT 'in_app',
+		UNIQUE (category_id, threshold_percent),
+		FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS budget_alerts_fired (
+		rule_id UUID NOT NULL,
+		period CHAR(7) NOT NULL,
+		fired_at TIMESTAMPTZ DEFAULT NOW (),
+		PRIMARY KEY (rule_id, period),
+		FOREIGN KEY (rule_id) REFERENCES budget_alert_rules (id) ON DELETE CASCADE
+	);
+	`
+
+func (s *Storage) GetCategories(userID string) ([]types.Category, error) {
+	rows, err := s.db.Query(`SELECT name, budget FROM categories WHERE user_id = $1 ORDER BY LOWER(name)`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []types.Category{}
+	for rows.Next() {
+		var c types.Category
+		if err := rows.Scan(&c.Name, &c.Budget); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// SetCategoryBudget creates the category or updates its monthly budget.
+// Names are matched case-insensitively, like the expense filters.
+func (s *Storage) SetCategoryBudget(userID string, category types.Category) error {
+	_, err := s.db.Exec(`INSERT INTO categories (user_id, name, budget) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, LOWER(name)) DO UPDATE SET name = EXCLUDED.name, budget = EXCLUDED.budget`,
+		userID, category.Name, category.Budget,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save category: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) DeleteCategory(userID, name string) error {
+	result, err := s.db.Exec(`DELETE FROM categories WHERE user_id = $1 AND LOWER(name) = LOWER($2)`, userID, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Storage) GetBudgetAlertRules(userID, category string) ([]types.BudgetAlertRule, error) {
+	rows, err := s.db.Query(`SELECT r.id, c.name, r.threshold_percent, r.channels
+	FROM budget_alert_rules r JOIN categories c ON c.id = r.category_id
+	WHERE c.user_id = $1 AND LOWER(c.name) = LOWER($2)
+	ORDER BY r.threshold_percent`, userID, category)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query budget alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []types.BudgetAlertRule{}
+	for rows.Next() {
+		var rule types.BudgetAlertRule
+		var channels string
+		if err := rows.Scan(&rule.ID, &rule.Category, &rule.ThresholdPercent, &channels); err != nil {
+			return nil, err
+		}
+		rule.Channels = strings.Split(channels, ",")
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SetBudgetAlertRules replaces a category's alert rules. Rules whose
+// threshold is unchanged keep their ID, so alerts that already fired this
+// period are not sent again.
+func (s *Storage) SetBudgetAlertRules(userID, category string, rules []types.BudgetAlertRule) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var categoryID string
+	err = tx.QueryRow(`SELECT id FROM categories WHERE user_id = $1 AND LOWER(name) = LOWER($2) FOR UPDATE`,
+		userID, category,
+	).Scan(&categoryID)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[float64]bool, len(rules))
+	for _, rule := range rules {
+		keep[rule.ThresholdPercent] = true
+		_, err := tx.Exec(`INSERT INTO budget_alert_rules (category_id, threshold_percent, channels)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (category_id, threshold_percent) DO UPDATE SET channels = EXCLUDED.channels`,
+			categoryID, rule.ThresholdPercent, strings.Join(rule.Channels, ","),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save budget alert rule: %w", err)
+		}
+	}
+
+	rows, err := tx.Query(`SELECT id, threshold_percent FROM budget_alert_rules WHERE category_id = $1`, categoryID)
+	if err != nil {
+		return fmt.Errorf("failed to query budget alert rules: %w", err)
+	}
+	var stale []string
+	for rows.Next() {
+		var id string
+		var threshold float64
+		if err := rows.Scan(&id, &threshold); err != nil {
+			rows.Close()
+			return err
+		}
+		if !keep[threshold] {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range stale {
+		if _, err := tx.Exec(`DELETE FROM budget_alert_rules WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("failed to delete budget alert rule: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// budgetStatuses totals spending per category between from and to
+// inclusive, converted to currency at each expense's date. An empty
+// category selects all of the user's categories.
+func (s *Storage) budgetStatuses(userID, category, currency, from, to string) ([]types.BudgetStatus, error) {
+	rows, err := s.db.Query(`WITH spent AS (
+		SELECT c.id, e.currency, SUM(e.amount) AS original, SUM(e.amount * fx.rate) AS total,
+		COUNT(*) FILTER (WHERE fx.rate IS NULL) AS unconverted
+		FROM categories c
+		JOIN expenses e ON e.user_id = c.user_id
+			AND LOWER(e.category) = LOWER(c.name)
+			AND e.expense_date BETWEEN $3 AND $4
+			AND e.deleted_at IS NULL
+		CROSS JOIN LATERAL (SELECT fx_rate(e.currency, $5, e.expense_date) AS rate) fx
+		WHERE c.user_id = $1
+		GROUP BY c.id, e.currency
+	)
+	SELECT c.name, c.budget, ROUND(COALESCE(SUM(s.total), 0), 2), COALESCE(SUM(s.unconverted), 0)::int,
+	COALESCE(json_object_agg(s.currency, s.original) FILTER (WHERE s.currency IS NOT NULL), '{}')
+	FROM categories c
+	LEFT JOIN spent s ON s.id = c.id
+	WHERE c.user_id = $1 AND ($2 = '' OR LOWER(c.name) = LOWER($2))
+	GROUP BY c.id
+	ORDER BY c.name`, userID, category, from, to, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query budget status: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := []types.BudgetStatus{}
+	for rows.Next() {
+		status := types.BudgetStatus{Currency: currency}
+		var original []byte
+		if err := rows.Scan(&status.Category, &status.Budget, &status.Spent, &status.Unconverted, &original); err != nil {
+			return nil, err
+		}
+		if status.Original, err = decodeOriginal(original); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// GetBudgetStatus returns a category's budget and what was spent in it
+// between from and to inclusive, in currency. It returns sql.ErrNoRows when
+// the user has no such category.
+func (s *Storage) GetBudgetStatus(userID, category, currency, from, to string) (*types.BudgetStatus, error) {
+	if category == "" {
+		return nil, sql.ErrNoRows
+	}
+	statuses, err := s.budgetStatuses(userID, category, currency, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &statuses[0], nil
+}
+
+// GetBudgetStatuses returns every budgeted category with what was spent in
+// it between from and to inclusive, in currency.
+func (s *Storage) GetBudgetStatuses(userID, currency, from, to string) ([]types.BudgetStatus, error) {
+	return s.budgetStatuses(userID, "", currency, from, to)
+}
+
+// MarkBudgetAlertFired records that a rule fired for a period (YYYY-MM). It
+// reports false when the rule had already fired, so concurrent edits cannot
+// send the same alert twice.
+func (s *Storage) MarkBudgetAlertFired(ruleID, period string) (bool, error) {
+	result, err := s.db.Exec(`INSERT INTO budget_alerts_fired (rule_id, period) VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`, ruleID, period)
+	if err != nil {
+		return false, fmt.Errorf("failed to record budget alert: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
@@ -1,6 +1,10 @@
 package database
 
-import "github.com/Ayikoandrew/server/types"
+import (
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
 
 type DBHandler interface {
 	Init() error
@@ -13,4 +17,175 @@ type DBHandler interface {
 	ValidateRefreshToken(string) (string, error)
 	CleanupExpiredTokens() error
 	RevokeToken(string) error
+	GetUser(userID string) (*types.User, error)
+	UpdateProfile(userID string, update types.ProfileUpdate) error
+	GetUserByEmail(email string) (*types.User, error)
+	GetUserByPhone(phone string) (*types.User, error)
+	CheckPassword(userID, password string) error
+	GetPINStatus(userID string) (*types.PINStatus, error)
+	SetPIN(userID string, hash []byte, replace bool) error
+	VerifyPIN(userID, pin string) error
+	GetKYCTier(userID string) (types.KYCTier, error)
+	CreateKYCSubmission(sub *types.KYCSubmission) error
+	GetKYCSubmissions(userID string, status types.KYCStatus, limit int) ([]types.KYCSubmission, error)
+	GetKYCSubmission(id string) (*types.KYCSubmission, error)
+	ReviewKYCSubmission(id, adminID string, status types.KYCStatus, reason string) (*types.KYCSubmission, error)
+
+	CreateExpense(expense *types.Expense) error
+	GetExpenses(userID string, filter types.ExpenseFilter) ([]types.Expense, error)
+	StreamExpenses(userID string, filter types.ExpenseFilter, fn func(types.Expense) error) error
+	UpdateExpense(userID, id string, update types.ExpenseUpdate) (*types.Expense, error)
+	DeleteExpense(userID, id string) error
+	RestoreExpense(userID, id string) (*types.Expense, error)
+	GetDeletedExpenses(userID string) ([]types.TrashedExpense, error)
+	GetExpenseHistory(userID, id string) ([]types.ExpenseVersion, error)
+	PurgeDeletedExpenses() ([]string, error)
+	ApplyExpenseBatch(userID string, ops []types.BatchOperation, atomic bool) ([]error, error)
+	GetExpensesInRange(userID, from, to string) ([]types.Expense, error)
+	SearchExpenses(userID string, filter types.ExpenseFilter) ([]types.ExpenseSearchResult, error)
+	GetTags(userID string) ([]types.TagCount, error)
+
+	CreateRule(rule *types.CategorizationRule) error
+	GetRules(userID string) ([]types.CategorizationRule, error)
+	UpdateRule(rule *types.CategorizationRule) error
+	DeleteRule(userID, id string) error
+	GetImportedExpenses(userID, batchID string) ([]types.Expense, error)
+	ApplyClassifications(userID string, expenses []types.Expense) error
+
+	CreateRecurringExpense(r *types.RecurringExpense) error
+	GetRecurringExpenses(userID string) ([]types.RecurringExpense, error)
+	GetActiveRecurringExpenses() ([]types.RecurringExpense, error)
+	DeactivateRecurringExpense(userID, id string) error
+	GetRecurringExceptions(recurringID string) (map[string]types.RecurringException, error)
+	SetRecurringException(userID string, ex *types.RecurringException) error
+	InsertRecurringOccurrences(recurringID string, expenses []types.Expense, generatedThrough string, finished bool) (int, error)
+	InsertRecurringIncome(recurringID string, incomes []types.Income, generatedThrough string, finished bool) (int, error)
+
+	CreateIncome(income *types.Income) error
+	GetIncomes(userID, from, to string) ([]types.Income, error)
+	UpdateIncome(userID, id string, update types.IncomeUpdate) (*types.Income, error)
+	DeleteIncome(userID, id string) error
+
+	CreateGoal(goal *types.SavingsGoal) error
+	GetGoals(userID string) ([]types.SavingsGoal, error)
+	GetGoal(userID, id string) (*types.SavingsGoal, error)
+	UpdateGoal(userID, id string, update types.GoalUpdate) (*types.SavingsGoal, error)
+	DeleteGoal(userID, id string) error
+	AddGoalContribution(userID string, c *types.GoalContribution) error
+	GetGoalContributions(goalID string) ([]types.GoalContribution, error)
+	DeleteGoalContribution(userID, goalID, id string) error
+
+	CreateImportBatch(batch *types.ImportBatch) error
+	GetImportBatches(userID string) ([]types.ImportBatch, error)
+	GetImportBatch(userID, id string) (*types.ImportBatch, error)
+	CommitImportBatch(userID, id string) error
+	RollbackImportBatch(userID, id string) error
+
+	CreateReceipt(receipt *types.Receipt) error
+	GetReceipts(userID, expenseID string) ([]types.Receipt, error)
+	GetReceipt(userID, id string) (*types.Receipt, error)
+	DeleteReceipt(userID, id string) (string, error)
+
+	GetCategories(userID string) ([]types.Category, error)
+	SetCategoryBudget(userID string, category types.Category) error
+	DeleteCategory(userID, name string) error
+	GetBudgetAlertRules(userID, category string) ([]types.BudgetAlertRule, error)
+	SetBudgetAlertRules(userID, category string, rules []types.BudgetAlertRule) error
+	GetBudgetStatus(userID, category, currency, from, to string) (*types.BudgetStatus, error)
+	GetBudgetStatuses(userID, currency, from, to string) ([]types.BudgetStatus, error)
+	MarkBudgetAlertFired(ruleID, period string) (bool, error)
+
+	CreateNotification(n *types.Notification) error
+	GetNotifications(userID string, unreadOnly bool) ([]types.Notification, error)
+	MarkNotificationRead(userID, id string) error
+
+	CreateLedger(ledger *types.Ledger) error
+	GetLedgers(userID string) ([]types.Ledger, error)
+	GetLedgerRole(ledgerID, userID string) (types.LedgerRole, error)
+	GetLedgerMembers(ledgerID string) ([]types.LedgerMember, error)
+	SetLedgerMemberRole(ledgerID, userID string, role types.LedgerRole) error
+	RemoveLedgerMember(ledgerID, userID string) error
+	CreateLedgerInvitation(inv *types.LedgerInvitation) error
+	GetUserInvitations(userID string) ([]types.LedgerInvitation, error)
+	RespondToInvitation(userID, invitationID string, accept bool) error
+	CreateLedgerExpense(e *types.LedgerExpense) error
+	GetLedgerExpenses(ledgerID string) ([]types.LedgerExpense, error)
+	DeleteLedgerExpense(ledgerID, id string) error
+	CreateSettlement(settlement *types.Settlement) error
+	GetSettlements(ledgerID string) ([]types.Settlement, error)
+	GetLedgerBalances(ledgerID string) ([]types.LedgerBalance, error)
+
+	SpendByCategory(userID, timezone, currency string, months int) ([]types.CategorySpend, error)
+	SpendByPaymentMethod(userID, timezone, currency, from, to string) ([]types.PaymentMethodSpend, error)
+	SpendTrend(userID, timezone, currency, unit, from, to string) ([]types.TrendPoint, error)
+	TopDescriptions(userID, timezone, currency, from, to string, limit int) ([]types.TopDescription, error)
+	MonthOverMonth(userID, timezone, currency string, months int) ([]types.MonthChange, error)
+	CashFlow(userID, timezone, currency, unit, from, to string) ([]types.CashFlowPoint, error)
+
+	UpsertExchangeRates(rates []types.ExchangeRate) (int, error)
+	GetExchangeRates(base, quote string) ([]types.ExchangeRate, error)
+	DeleteExchangeRate(base, quote, date string) error
+	ExchangeRate(from, to, date string) (float64, error)
+	GrantAdmins(emails []string) error
+
+	WalletAccount(userID, currency string) (*types.LedgerAccount, error)
+	SystemAccount(kind types.LedgerAccountKind, currency string) (*types.LedgerAccount, error)
+	GetWallets(userID string) ([]types.LedgerAccount, error)
+	GetAccountStatement(accountID string, limit int) ([]types.AccountStatementLine, error)
+	PostJournalEntry(entry *types.JournalEntry) error
+	CheckLedgerInvariants() ([]types.LedgerViolation, error)
+
+	CreateTransfer(t *types.WalletTransfer) error
+	CompleteTransfer(id string) (*types.WalletTransfer, error)
+	FailTransfer(id, reason string) error
+	ReverseTransfer(userID, id string, asAdmin bool) (*types.WalletTransfer, error)
+	GetTransfers(userID, direction string, limit int) ([]types.WalletTransfer, error)
+	GetTransfer(userID, id string) (*types.WalletTransfer, error)
+	FailStaleTransfers(age time.Duration) (int, error)
+
+	CreateMoneyRequest(m *types.MoneyRequest, remindOn []string) error
+	GetMoneyRequests(userID, direction string, status types.MoneyRequestStatus, limit int) ([]types.MoneyRequest, error)
+	GetMoneyRequest(userID, id string) (*types.MoneyRequest, error)
+	PayMoneyRequest(payerID, id string) (*types.MoneyRequest, *types.WalletTransfer, error)
+	DeclineMoneyRequest(payerID, id string) (*types.MoneyRequest, error)
+	ExpireMoneyRequests() ([]types.MoneyRequest, error)
+	ClaimDueReminders() ([]types.MoneyRequestReminder, error)
+
+	GetLimitRules() ([]types.LimitRule, error)
+	ReplaceLimitRules(rules []types.LimitRule) error
+	RecordAuditEvent(e *types.AuditEvent) error
+	GetAuditEvents(userID, action string, limit int) ([]types.AuditEvent, error)
+
+	CreatePayment(p *types.Payment) error
+	MarkPaymentProcessing(id, providerRef string) error
+	SettlePayment(id string, status types.PaymentStatus, reason string) (*types.Payment, error)
+	GetPayments(userID string, kind types.PaymentKind, limit int) ([]types.Payment, error)
+	GetPayment(userID, id string) (*types.Payment, error)
+	GetPaymentByID(id string) (*types.Payment, error)
+	GetPaymentByProviderRef(provider, providerRef string) (*types.Payment, error)
+	GetOpenPayments(age time.Duration, limit int) ([]types.Payment, error)
+
+	GetProviderPayments(provider, from, to string) ([]types.Payment, error)
+	CreateReconciliation(run *types.ReconciliationRun) error
+	GetReconciliations(limit int) ([]types.ReconciliationRun, error)
+	GetReconciliation(id string) (*types.ReconciliationRun, error)
+	GetReviewQueue(limit int) ([]types.ReconciliationItem, error)
+	GetReconciliationItem(id string) (*types.ReconciliationItem, error)
+	ResolveReconciliationItem(id string, resolution types.ReconciliationResolution, note, adminID string) (*types.ReconciliationItem, error)
+
+	GetStatementPostings(accountID string, from, to time.Time, timezone string) (int64, []types.AccountStatementLine, error)
+	CreateStatement(st *types.Statement, pdf, csv []byte) error
+	GetStatements(userID, currency string, limit int) ([]types.Statement, error)
+	GetStatement(userID, id string) (*types.Statement, error)
+	GetStatementByPeriod(userID, currency, period string) (*types.Statement, error)
+	GetStatementFile(userID, id, format string) ([]byte, error)
+	GetWalletsDueStatement(period string, openedBefore time.Time, afterID string, limit int) ([]types.LedgerAccount, error)
+
+	CreateScheduledTransfer(t *types.ScheduledTransfer) error
+	GetScheduledTransfers(userID string, status types.ScheduledTransferStatus, limit int) ([]types.ScheduledTransfer, error)
+	GetScheduledTransfer(userID, id string) (*types.ScheduledTransfer, error)
+	CancelScheduledTransfer(userID, id string) (*types.ScheduledTransfer, error)
+	GetDueScheduledTransfers(now time.Time, limit int) ([]types.ScheduledTransfer, error)
+	ExecuteScheduledTransfer(id string, occurrence, next time.Time) (*types.WalletTransfer, error)
+	FailScheduledRun(id string, occurrence time.Time, reason string, retryAt, next time.Time) (*types.ScheduledTransfer, error)
 }
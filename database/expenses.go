@@ -0,0 +1,248 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const expenseSchema = `
+	CREATE TABLE IF NOT EXISTS expenses (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		amount NUMERIC(14, 2) NOT NULL,
+		expense_date DATE NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		category VARCHAR(100) NOT NULL DEFAULT '',
+		payment_method VARCHAR(100) NOT NULL DEFAULT '',
+		recurring_id UUID,
+		occurrence_date DATE,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		updated_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_expenses_user_date ON expenses (user_id, expense_date);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_expenses_occurrence
+		ON expenses (recurring_id, occurrence_date) WHERE recurring_id IS NOT NULL;
+	`
+
+// expenseColumns selects an expense with its tags folded into one
+// comma-separated string; tags never contain commas.
+const expenseColumns = `e.id, e.user_id, e.amount, e.expense_date::text, e.description, e.category,
+	e.payment_method, COALESCE(e.recurring_id::text, ''), e.notes, e.payee, e.currency,
+	COALESCE((SELECT string_agg(t.tag, ',' ORDER BY t.tag) FROM expense_tags t WHERE t.expense_id = e.id), '')`
+
+func scanExpense(row interface{ Scan(...any) error }, extra ...any) (types.Expense, error) {
+	var e types.Expense
+	var tags string
+	err := row.Scan(append([]any{
+		&e.ID,
+		&e.UserID,
+		&e.Amount,
+		&e.Date,
+		&e.Description,
+		&e.Category,
+		&e.PaymentMethod,
+		&e.RecurringID,
+		&e.Notes,
+		&e.Payee,
+		&e.Currency,
+		&tags,
+	}, extra...)...)
+	if tags != "" {
+		e.Tags = strings.Split(tags, ",")
+	}
+	return e, err
+}
+
+func (s *Storage) CreateExpense(expense *types.Expense) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createExpense(tx, expense); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func createExpense(tx *sql.Tx, expense *types.Expense) error {
+	query := `INSERT INTO expenses
+	(user_id, amount, expense_date, description, category, payment_method, notes, payee, currency)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+		COALESCE(NULLIF($9, ''), (SELECT home_currency FROM users WHERE id = $1)))
+	RETURNING id, currency`
+
+	err := tx.QueryRow(query,
+		expense.UserID,
+		expense.Amount,
+		expense.Date,
+		expense.Description,
+		expense.Category,
+		expense.PaymentMethod,
+		expense.Notes,
+		expense.Payee,
+		expense.Currency,
+	).Scan(&expense.ID, &expense.Currency)
+	if err != nil {
+		slog.Error("Error inserting expense", "error", err)
+		return fmt.Errorf("failed to create expense: %w", err)
+	}
+
+	if err := setExpenseTags(tx, expense.UserID, expense.ID, expense.Tags); err != nil {
+		return err
+	}
+	return recordCreated(tx, types.SourceUser, expense.UserID, []types.Expense{*expense})
+}
+
+// UpdateExpense applies a partial edit, records what changed in the
+// expense's history and returns the updated expense.
+func (s *Storage) UpdateExpense(userID, id string, update types.ExpenseUpdate) (*types.Expense, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	expense, err := updateExpense(tx, userID, id, update)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return expense, nil
+}
+
+func updateExpense(tx *sql.Tx, userID, id string, update types.ExpenseUpdate) (*types.Expense, error) {
+	before, err := lockExpenses(tx, "e.id = $1 AND e.user_id = $2", id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(before) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	query := `UPDATE expenses SET
+		amount = COALESCE($3, amount),
+		expense_date = COALESCE($4::date, expense_date),
+		description = COALESCE($5, description),
+		category = COALESCE($6, category),
+		payment_method = COALESCE($7, payment_method),
+		notes = COALESCE($8, notes),
+		payee = COALESCE($9, payee),
+		currency = COALESCE($10, currency),
+		updated_at = NOW()
+	WHERE id = $1 AND user_id = $2`
+
+	_, err = tx.Exec(query,
+		id,
+		userID,
+		update.Amount,
+		update.Date,
+		update.Description,
+		update.Category,
+		update.PaymentMethod,
+		update.Notes,
+		update.Payee,
+		update.Currency,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update expense: %w", err)
+	}
+
+	if update.Tags != nil {
+		if _, err := tx.Exec(`DELETE FROM expense_tags WHERE expense_id = $1`, id); err != nil {
+			return nil, fmt.Errorf("failed to clear tags: %w", err)
+		}
+		if err := setExpenseTags(tx, userID, id, *update.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordUpdates(tx, types.SourceUser, userID, before); err != nil {
+		return nil, err
+	}
+
+	expense, err := scanExpense(tx.QueryRow(`SELECT `+expenseColumns+` FROM expenses e WHERE e.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &expense, nil
+}
+
+func (s *Storage) GetExpenses(userID string, filter types.ExpenseFilter) ([]types.Expense, error) {
+	where, args := expenseFilterClause(filter, []any{userID})
+	query := `SELECT ` + expenseColumns + ` FROM expenses e
+	WHERE e.user_id = $1 AND e.deleted_at IS NULL` + where + `
+	ORDER BY e.expense_date DESC, e.created_at DESC`
+	return s.queryExpenses(query, args...)
+}
+
+// GetExpensesInRange returns a user's expenses dated between from and to
+// inclusive, both given as YYYY-MM-DD.
+func (s *Storage) GetExpensesInRange(userID, from, to string) ([]types.Expense, error) {
+	query := `SELECT ` + expenseColumns + ` FROM expenses e
+	WHERE e.user_id = $1 AND e.deleted_at IS NULL AND e.expense_date BETWEEN $2 AND $3
+	ORDER BY e.expense_date`
+	return s.queryExpenses(query, userID, from, to)
+}
+
+// StreamExpenses calls fn for each matching expense in date order while the
+// rows are still being read, so large result sets are never held in memory.
+func (s *Storage) StreamExpenses(userID string, filter types.ExpenseFilter, fn func(types.Expense) error) error {
+	where, args := expenseFilterClause(filter, []any{userID})
+	query := `SELECT ` + expenseColumns + ` FROM expenses e
+	WHERE e.user_id = $1 AND e.deleted_at IS NULL` + where + `
+	ORDER BY e.expense_date, e.created_at`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query expenses: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanExpense(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Storage) queryExpenses(query string, args ...any) ([]types.Expense, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expenses: %w", err)
+	}
+	defer rows.Close()
+	return scanExpenses(rows)
+}
+
+func scanExpenses(rows *sql.Rows) ([]types.Expense, error) {
+	expenses := []types.Expense{}
+	for rows.Next() {
+		e, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
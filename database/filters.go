@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// expenseFilterClause renders the filter as extra WHERE conditions against
+// the expenses table aliased as e. New placeholders are numbered after the
+// arguments already in args.
+func expenseFilterClause(filter types.ExpenseFilter, args []any) (string, []any) {
+	var conditions []string
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.From != "" {
+		add("e.expense_date >= $%d", filter.From)
+	}
+	if filter.To != "" {
+		add("e.expense_date <= $%d", filter.To)
+	}
+	if filter.Category != "" {
+		add("LOWER(e.category) = LOWER($%d)", filter.Category)
+	}
+	if filter.PaymentMethod != "" {
+		add("LOWER(e.payment_method) = LOWER($%d)", filter.PaymentMethod)
+	}
+	if filter.MinAmount > 0 {
+		add("e.amount >= $%d", filter.MinAmount)
+	}
+	if filter.MaxAmount > 0 {
+		add("e.amount <= $%d", filter.MaxAmount)
+	}
+
+	for _, tag := range filter.Tags {
+		add("EXISTS (SELECT 1 FROM expense_tags t WHERE t.expense_id = e.id AND t.tag = $%d)", tag)
+	}
+	if filter.Query != "" {
+		add("e.search_vector @@ websearch_to_tsquery('english', $%d)", filter.Query)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// fxSchema stores exchange rates and adds currencies to users and expenses.
+//
+// fx_rate(src, dst, day) converts with the latest rate on or before day. A
+// pair can be stored either way round, and when there is no rate between
+// the two currencies directly it goes through one shared currency, so
+// uploading every currency against USD is enough. It returns NULL when no
+// rate is known.
+const fxSchema = `
+	CREATE TABLE IF NOT EXISTS exchange_rates (
+		base VARCHAR(3) NOT NULL,
+		quote VARCHAR(3) NOT NULL,
+		effective_date DATE NOT NULL,
+		rate NUMERIC(24, 10) NOT NULL CHECK (rate > 0),
+		source VARCHAR(100) NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		PRIMARY KEY (base, quote, effective_date)
+	);
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS home_currency VARCHAR(3) NOT NULL DEFAULT 'UGX';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+	ALTER TABLE expenses ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'UGX';
+
+	CREATE OR REPLACE FUNCTION fx_rate(src TEXT, dst TEXT, on_date DATE) RETURNS NUMERIC
+	LANGUAGE sql STABLE AS $$
+		WITH pairs AS (
+			SELECT DISTINCT ON (base, quote) base, quote, rate
+			FROM (
+				SELECT base, quote, rate, effective_date FROM exchange_rates
+				WHERE effective_date <= on_date AND (base = src OR quote = dst)
+				UNION ALL
+				SELECT quote, base, 1 / rate, effective_date FROM exchange_rates
+				WHERE effective_date <= on_date AND (quote = src OR base = dst)
+			) r
+			ORDER BY base, quote, effective_date DESC
+		)
+		SELECT CASE WHEN src = dst THEN 1 ELSE COALESCE(
+			(SELECT rate FROM pairs WHERE base = src AND quote = dst),
+			(SELECT a.rate * b.rate FROM pairs a JOIN pairs b ON b.base = a.quote
+			WHERE a.base = src AND b.quote = dst ORDER BY a.quote LIMIT 1)
+		) END
+	$$;
+	`
+
+// UpsertExchangeRates saves rates, replacing any already stored for the same
+// pair and date, and returns how many were saved.
+func (s *Storage) UpsertExchangeRates(rates []types.ExchangeRate) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, rate := range rates {
+		_, err := tx.Exec(`INSERT INTO exchange_rates (base, quote, effective_date, rate, source)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (base, quote, effective_date) DO UPDATE SET rate = EXCLUDED.rate, source = EXCLUDED.source`,
+			rate.Base, rate.Quote, rate.Date, rate.Rate, rate.Source,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to save exchange rate: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(rates), nil
+}
+
+// GetExchangeRates lists stored rates, newest first, optionally narrowed to
+// one base or quote currency.
+func (s *Storage) GetExchangeRates(base, quote string) ([]types.ExchangeRate, error) {
+	rows, err := s.db.Query(`SELECT base, quote, effective_date::text, rate, source FROM exchange_rates
+	WHERE ($1 = '' OR base = $1) AND ($2 = '' OR quote = $2)
+	ORDER BY effective_date DESC, base, quote
+	LIMIT 1000`, base, quote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := []types.ExchangeRate{}
+	for rows.Next() {
+		var r types.ExchangeRate
+		if err := rows.Scan(&r.Base, &r.Quote, &r.Date, &r.Rate, &r.Source); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+func (s *Storage) DeleteExchangeRate(base, quote, date string) error {
+	result, err := s.db.Exec(`DELETE FROM exchange_rates WHERE base = $1 AND quote = $2 AND effective_date = $3`,
+		base, quote, date)
+	if err != nil {
+		return fmt.Errorf("failed to delete exchange rate: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+// ExchangeRate returns the rate from one currency to another on a date, or
+// sql.ErrNoRows when none is known.
+func (s *Storage) ExchangeRate(from, to, date string) (float64, error) {
+	var rate sql.NullFloat64
+	if err := s.db.QueryRow(`SELECT fx_rate($1, $2, $3::date)`, from, to, date).Scan(&rate); err != nil {
+		return 0, err
+	}
+	if !rate.Valid {
+		return 0, sql.ErrNoRows
+	}
+	return rate.Float64, nil
+}
+
+// GrantAdmins gives the admin role to the users with the given emails.
+func (s *Storage) GrantAdmins(emails []string) error {
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		if _, err := s.db.Exec(`UPDATE users SET role = 'admin' WHERE LOWER(email) = LOWER($1)`, email); err != nil {
+			return fmt.Errorf("failed to grant admin role: %w", err)
+		}
+	}
+	return nil
+}
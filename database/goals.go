@@ -0,0 +1,244 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// goalSchema stores savings goals and the contributions made towards them.
+// Rule contributions are taken from income entries and disappear with the
+// income they came from.
+const goalSchema = `
+	CREATE TABLE IF NOT EXISTS savings_goals (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		target_amount NUMERIC(14, 2) NOT NULL CHECK (target_amount > 0),
+		currency VARCHAR(3) NOT NULL DEFAULT 'UGX',
+		deadline DATE NOT NULL,
+		rule_percent NUMERIC(5, 2) NOT NULL DEFAULT 0 CHECK (rule_percent BETWEEN 0 AND 100),
+		rule_income_kind VARCHAR(20) NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_savings_goals_user ON savings_goals (user_id);
+
+	CREATE TABLE IF NOT EXISTS goal_contributions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		goal_id UUID NOT NULL,
+		amount NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+		contribution_date DATE NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		source VARCHAR(10) NOT NULL DEFAULT 'manual',
+		income_id UUID,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (goal_id) REFERENCES savings_goals (id) ON DELETE CASCADE,
+		FOREIGN KEY (income_id) REFERENCES incomes (id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_goal_contributions_goal ON goal_contributions (goal_id, contribution_date);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_goal_contributions_income
+		ON goal_contributions (goal_id, income_id) WHERE income_id IS NOT NULL;
+	`
+
+// goalColumns selects a goal with the total contributed so far. The
+// creation time is reported as a date, in UTC.
+const goalColumns = `g.id, g.user_id, g.name, g.target_amount, g.currency, g.deadline::text,
+	g.rule_percent, g.rule_income_kind,
+	COALESCE((SELECT SUM(c.amount) FROM goal_contributions c WHERE c.goal_id = g.id), 0),
+	(g.created_at AT TIME ZONE 'UTC')::date::text`
+
+func scanGoal(row interface{ Scan(...any) error }) (types.SavingsGoal, error) {
+	var (
+		g    types.SavingsGoal
+		rule types.GoalRule
+	)
+	err := row.Scan(
+		&g.ID,
+		&g.UserID,
+		&g.Name,
+		&g.TargetAmount,
+		&g.Currency,
+		&g.Deadline,
+		&rule.Percent,
+		&rule.IncomeKind,
+		&g.Saved,
+		&g.CreatedAt,
+	)
+	if rule.Percent > 0 {
+		g.Rule = &rule
+	}
+	return g, err
+}
+
+// CreateGoal saves a goal, in the user's home currency unless one is given.
+func (s *Storage) CreateGoal(goal *types.SavingsGoal) error {
+	var rule types.GoalRule
+	if goal.Rule != nil {
+		rule = *goal.Rule
+	}
+
+	err := s.db.QueryRow(`INSERT INTO savings_goals
+	(user_id, name, target_amount, currency, deadline, rule_percent, rule_income_kind)
+	VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), (SELECT home_currency FROM users WHERE id = $1)), $5, $6, $7)
+	RETURNING id, currency, (created_at AT TIME ZONE 'UTC')::date::text`,
+		goal.UserID,
+		goal.Name,
+		goal.TargetAmount,
+		goal.Currency,
+		goal.Deadline,
+		rule.Percent,
+		rule.IncomeKind,
+	).Scan(&goal.ID, &goal.Currency, &goal.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create goal: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) GetGoals(userID string) ([]types.SavingsGoal, error) {
+	rows, err := s.db.Query(`SELECT `+goalColumns+` FROM savings_goals g
+	WHERE g.user_id = $1 ORDER BY g.deadline, g.created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []types.SavingsGoal{}
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, g)
+	}
+	return goals, rows.Err()
+}
+
+func (s *Storage) GetGoal(userID, id string) (*types.SavingsGoal, error) {
+	goal, err := scanGoal(s.db.QueryRow(`SELECT `+goalColumns+` FROM savings_goals g
+	WHERE g.id = $1 AND g.user_id = $2`, id, userID))
+	if err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// UpdateGoal applies a partial edit and returns the updated goal.
+func (s *Storage) UpdateGoal(userID, id string, update types.GoalUpdate) (*types.SavingsGoal, error) {
+	var percent *float64
+	var kind *types.IncomeKind
+	if update.Rule != nil {
+		percent, kind = &update.Rule.Percent, &update.Rule.IncomeKind
+	}
+
+	result, err := s.db.Exec(`UPDATE savings_goals SET
+		name = COALESCE($3, name),
+		target_amount = COALESCE($4, target_amount),
+		deadline = COALESCE($5::date, deadline),
+		rule_percent = COALESCE($6, rule_percent),
+		rule_income_kind = COALESCE($7, rule_income_kind)
+	WHERE id = $1 AND user_id = $2`,
+		id,
+		userID,
+		update.Name,
+		update.TargetAmount,
+		update.Deadline,
+		percent,
+		kind,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update goal: %w", err)
+	}
+	if err := expectOneRow(result); err != nil {
+		return nil, err
+	}
+	return s.GetGoal(userID, id)
+}
+
+func (s *Storage) DeleteGoal(userID, id string) error {
+	result, err := s.db.Exec(`DELETE FROM savings_goals WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete goal: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+// AddGoalContribution records a manual contribution to one of the user's
+// goals. It returns sql.ErrNoRows when the goal is not theirs.
+func (s *Storage) AddGoalContribution(userID string, c *types.GoalContribution) error {
+	err := s.db.QueryRow(`INSERT INTO goal_contributions (goal_id, amount, contribution_date, note, source)
+	SELECT id, $3, $4, $5, $6 FROM savings_goals WHERE id = $1 AND user_id = $2
+	RETURNING id`,
+		c.GoalID,
+		userID,
+		c.Amount,
+		c.Date,
+		c.Note,
+		types.ContributionManual,
+	).Scan(&c.ID)
+	if err != nil {
+		return err
+	}
+	c.Source = types.ContributionManual
+	return nil
+}
+
+func (s *Storage) GetGoalContributions(goalID string) ([]types.GoalContribution, error) {
+	rows, err := s.db.Query(`SELECT id, goal_id, amount, contribution_date::text, note, source,
+	COALESCE(income_id::text, '') FROM goal_contributions
+	WHERE goal_id = $1 ORDER BY contribution_date DESC, created_at DESC`, goalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goal contributions: %w", err)
+	}
+	defer rows.Close()
+
+	contributions := []types.GoalContribution{}
+	for rows.Next() {
+		var c types.GoalContribution
+		if err := rows.Scan(&c.ID, &c.GoalID, &c.Amount, &c.Date, &c.Note, &c.Source, &c.IncomeID); err != nil {
+			return nil, err
+		}
+		contributions = append(contributions, c)
+	}
+	return contributions, rows.Err()
+}
+
+func (s *Storage) DeleteGoalContribution(userID, goalID, id string) error {
+	result, err := s.db.Exec(`DELETE FROM goal_contributions c USING savings_goals g
+	WHERE c.id = $1 AND c.goal_id = $2 AND g.id = c.goal_id AND g.user_id = $3`, id, goalID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete goal contribution: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+// applyGoalRules sets aside part of a new income entry for every goal of
+// its owner with a matching rule. Goals that are already reached or past
+// their deadline are left alone, as are goals in a currency the income
+// cannot be converted to.
+func applyGoalRules(tx *sql.Tx, incomeID string) error {
+	_, err := tx.Exec(`INSERT INTO goal_contributions (goal_id, amount, contribution_date, source, income_id)
+	SELECT g.id, ROUND(i.amount * fx.rate * g.rule_percent / 100, 2), i.income_date, $2, i.id
+	FROM incomes i
+	JOIN savings_goals g ON g.user_id = i.user_id
+	CROSS JOIN LATERAL (SELECT fx_rate(i.currency, g.currency, i.income_date) AS rate) fx
+	WHERE i.id = $1
+		AND g.rule_percent > 0
+		AND (g.rule_income_kind = '' OR g.rule_income_kind = i.kind)
+		AND i.income_date <= g.deadline
+		AND fx.rate IS NOT NULL
+		AND ROUND(i.amount * fx.rate * g.rule_percent / 100, 2) > 0
+		AND COALESCE((SELECT SUM(c.amount) FROM goal_contributions c WHERE c.goal_id = g.id), 0) < g.target_amount
+	ON CONFLICT (goal_id, income_id) WHERE income_id IS NOT NULL DO NOTHING`,
+		incomeID, types.ContributionRule,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to apply goal rules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/history"
+	"github.com/Ayikoandrew/server/types"
+)
+
+// historySchema adds soft deletes to expenses and keeps every change made
+// to one. Deleted expenses stay in the trash for trashRetention and are
+// then purged, along with their history.
+const historySchema = `
+	ALTER TABLE expenses ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+	CREATE INDEX IF NOT EXISTS idx_expenses_deleted ON expenses (user_id, deleted_at)
+		WHERE deleted_at IS NOT NULL;
+
+	CREATE TABLE IF NOT EXISTS expense_versions (
+		expense_id UUID NOT NULL,
+		version INT NOT NULL,
+		action VARCHAR(20) NOT NULL,
+		source VARCHAR(20) NOT NULL,
+		changed_by UUID,
+		changes JSONB NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		PRIMARY KEY (expense_id, version),
+		FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE
+	);
+	`
+
+const trashRetention = `interval '30 days'`
+
+// versionEntry is one row for recordVersions; its JSON names match the
+// columns jsonb_to_recordset reads.
+type versionEntry struct {
+	ExpenseID string                       `json:"expense_id"`
+	Changes   map[string]types.FieldChange `json:"changes"`
+}
+
+// recordVersions appends a version to the history of each expense in
+// entries with a single statement. changedBy may be empty for changes no
+// user set off.
+func recordVersions(tx *sql.Tx, action types.ExpenseAction, source, changedBy string, entries []versionEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for i := range entries {
+		if entries[i].Changes == nil {
+			entries[i].Changes = map[string]types.FieldChange{}
+		}
+	}
+
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO expense_versions (expense_id, version, action, source, changed_by, changes)
+	SELECT v.expense_id,
+	COALESCE((SELECT MAX(h.version) FROM expense_versions h WHERE h.expense_id = v.expense_id), 0) + 1,
+	$2, $3, NULLIF($4, '')::uuid, v.changes
+	FROM jsonb_to_recordset($1::jsonb) AS v(expense_id UUID, changes JSONB)`,
+		string(raw), action, source, changedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record expense history: %w", err)
+	}
+	return nil
+}
+
+// recordCreated stores the first version of newly inserted expenses.
+func recordCreated(tx *sql.Tx, source, changedBy string, expenses []types.Expense) error {
+	entries := make([]versionEntry, len(expenses))
+	for i, e := range expenses {
+		entries[i] = versionEntry{ExpenseID: e.ID, Changes: history.Snapshot(e)}
+	}
+	return recordVersions(tx, types.ExpenseCreated, source, changedBy, entries)
+}
+
+// lockExpenses selects the live expenses matching where, a condition on
+// expenses e, and locks them until the transaction ends.
+func lockExpenses(tx *sql.Tx, where string, args ...any) ([]types.Expense, error) {
+	rows, err := tx.Query(`SELECT `+expenseColumns+` FROM expenses e
+	WHERE e.deleted_at IS NULL AND `+where+` FOR UPDATE OF e`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock expenses: %w", err)
+	}
+	defer rows.Close()
+	return scanExpenses(rows)
+}
+
+// recordUpdates compares each locked expense in before with its current
+// state and records a version for those the transaction changed.
+func recordUpdates(tx *sql.Tx, source, changedBy string, before []types.Expense) error {
+	var entries []versionEntry
+	for _, old := range before {
+		current, err := scanExpense(tx.QueryRow(`SELECT `+expenseColumns+` FROM expenses e WHERE e.id = $1`, old.ID))
+		if err != nil {
+			return err
+		}
+		if changes := history.Diff(old, current); len(changes) > 0 {
+			entries = append(entries, versionEntry{ExpenseID: old.ID, Changes: changes})
+		}
+	}
+	return recordVersions(tx, types.ExpenseUpdated, source, changedBy, entries)
+}
+
+// softDelete moves the live expenses matching where, a condition on
+// expenses e, to the trash and returns how many it moved.
+func softDelete(tx *sql.Tx, source, changedBy, where string, args ...any) (int, error) {
+	rows, err := tx.Query(`UPDATE expenses e SET deleted_at = NOW(), updated_at = NOW()
+	WHERE e.deleted_at IS NULL AND `+where+` RETURNING e.id`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expenses: %w", err)
+	}
+
+	var entries []versionEntry
+	for rows.Next() {
+		var entry versionEntry
+		if err := rows.Scan(&entry.ExpenseID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return len(entries), recordVersions(tx, types.ExpenseDeleted, source, changedBy, entries)
+}
+
+// DeleteExpense moves an expense to the trash. Its receipts are kept until
+// it is purged.
+func (s *Storage) DeleteExpense(userID, id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteExpense(tx, userID, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func deleteExpense(tx *sql.Tx, userID, id string) error {
+	deleted, err := softDelete(tx, types.SourceUser, userID, "e.id = $1 AND e.user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RestoreExpense takes an expense back out of the trash. It returns
+// sql.ErrNoRows when the user has no such deleted expense.
+func (s *Storage) RestoreExpense(userID, id string) (*types.Expense, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE expenses SET deleted_at = NULL, updated_at = NOW()
+	WHERE id = $1 AND user_id = $2 AND deleted_at > NOW() - `+trashRetention, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore expense: %w", err)
+	}
+	if err := expectOneRow(result); err != nil {
+		return nil, err
+	}
+
+	entry := versionEntry{ExpenseID: id}
+	if err := recordVersions(tx, types.ExpenseRestored, types.SourceUser, userID, []versionEntry{entry}); err != nil {
+		return nil, err
+	}
+
+	expense, err := scanExpense(tx.QueryRow(`SELECT `+expenseColumns+` FROM expenses e WHERE e.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &expense, nil
+}
+
+// GetDeletedExpenses lists the user's trash, most recently deleted first.
+func (s *Storage) GetDeletedExpenses(userID string) ([]types.TrashedExpense, error) {
+	rows, err := s.db.Query(`SELECT `+expenseColumns+`, e.deleted_at::text, (e.deleted_at + `+trashRetention+`)::text
+	FROM expenses e
+	WHERE e.user_id = $1 AND e.deleted_at > NOW() - `+trashRetention+`
+	ORDER BY e.deleted_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted expenses: %w", err)
+	}
+	defer rows.Close()
+
+	trash := []types.TrashedExpense{}
+	for rows.Next() {
+		var t types.TrashedExpense
+		t.Expense, err = scanExpense(rows, &t.DeletedAt, &t.PurgeAt)
+		if err != nil {
+			return nil, err
+		}
+		trash = append(trash, t)
+	}
+	return trash, rows.Err()
+}
+
+// GetExpenseHistory returns every version of an expense, oldest first,
+// including expenses in the trash. It returns sql.ErrNoRows when the user
+// has no such expense.
+func (s *Storage) GetExpenseHistory(userID, id string) ([]types.ExpenseVersion, error) {
+	var owner string
+	if err := s.db.QueryRow(`SELECT user_id FROM expenses WHERE id = $1 AND user_id = $2`, id, userID).Scan(&owner); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT version, action, source, COALESCE(changed_by::text, ''), changes, created_at::text
+	FROM expense_versions WHERE expense_id = $1 ORDER BY version`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense history: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []types.ExpenseVersion{}
+	for rows.Next() {
+		var v types.ExpenseVersion
+		var changes []byte
+		if err := rows.Scan(&v.Version, &v.Action, &v.Source, &v.ChangedBy, &changes, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &v.Changes); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// PurgeDeletedExpenses permanently removes expenses that have been in the
+// trash longer than the retention period, and returns the blob keys of
+// their receipts so the files can be deleted too.
+func (s *Storage) PurgeDeletedExpenses() ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`DELETE FROM receipts WHERE expense_id IN (
+		SELECT id FROM expenses WHERE deleted_at <= NOW() - ` + trashRetention + `
+	) RETURNING blob_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge receipts: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM expenses WHERE deleted_at <= NOW() - ` + trashRetention); err != nil {
+		return nil, fmt.Errorf("failed to purge expenses: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyStore keeps idempotency records in Redis, where they expire on
+// their own.
+type IdempotencyStore struct {
+	client *redis.Client
+}
+
+func NewIdempotencyStore() *IdempotencyStore {
+	return &IdempotencyStore{client: getRedisClient()}
+}
+
+func (s *IdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (bool, *types.IdempotencyRecord, error) {
+	pending, err := json.Marshal(types.IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return false, nil, err
+	}
+
+	// The existing record can expire between SETNX and GET, so try again
+	// once before giving up.
+	for attempt := 0; attempt < 2; attempt++ {
+		reserved, err := s.client.SetNX(ctx, key, pending, ttl).Result()
+		if err != nil || reserved {
+			return reserved, nil, err
+		}
+
+		raw, err := s.client.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return false, nil, err
+		}
+
+		record := new(types.IdempotencyRecord)
+		if err := json.Unmarshal(raw, record); err != nil {
+			return false, nil, err
+		}
+		return false, record, nil
+	}
+	return false, nil, errors.New("idempotency key changed while reserving it")
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, record *types.IdempotencyRecord, ttl time.Duration) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, key, raw, ttl).Err()
+}
+
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var ErrInvalidBatchState = errors.New("import batch is not in a valid state for this operation")
+
+const importSchema = `
+	CREATE TABLE IF NOT EXISTS import_batches (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		format VARCHAR(10) NOT NULL,
+		filename TEXT NOT NULL DEFAULT '',
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		row_count INTEGER NOT NULL DEFAULT 0,
+		imported_count INTEGER NOT NULL DEFAULT 0,
+		skipped_count INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		committed_at TIMESTAMPTZ,
+		rolled_back_at TIMESTAMPTZ,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_import_batches_user ON import_batches (user_id);
+
+	CREATE TABLE IF NOT EXISTS import_rows (
+		batch_id UUID NOT NULL,
+		line INTEGER NOT NULL,
+		expense_date DATE NOT NULL,
+		amount NUMERIC(14, 2) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		duplicate BOOLEAN NOT NULL DEFAULT FALSE,
+		duplicate_of UUID,
+		PRIMARY KEY (batch_id, line),
+		FOREIGN KEY (batch_id) REFERENCES import_batches (id) ON DELETE CASCADE
+	);
+
+	ALTER TABLE expenses ADD COLUMN IF NOT EXISTS import_batch_id UUID;
+
+	CREATE INDEX IF NOT EXISTS idx_expenses_import_batch ON expenses (import_batch_id)
+		WHERE import_batch_id IS NOT NULL;
+	`
+
+const importBatchColumns = `id, user_id, format, filename, status, row_count,
+	imported_count, skipped_count, created_at::text`
+
+func scanImportBatch(row interface{ Scan(...any) error }) (types.ImportBatch, error) {
+	var b types.ImportBatch
+	err := row.Scan(
+		&b.ID,
+		&b.UserID,
+		&b.Format,
+		&b.Filename,
+		&b.Status,
+		&b.RowCount,
+		&b.ImportedCount,
+		&b.SkippedCount,
+		&b.CreatedAt,
+	)
+	return b, err
+}
+
+// CreateImportBatch stores a parsed statement as a pending batch so the user
+// can review the preview before committing it.
+func (s *Storage) CreateImportBatch(batch *types.ImportBatch) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO import_batches (user_id, format, filename, row_count)
+	VALUES ($1, $2, $3, $4) RETURNING id, status, created_at::text`,
+		batch.UserID,
+		batch.Format,
+		batch.Filename,
+		len(batch.Rows),
+	).Scan(&batch.ID, &batch.Status, &batch.CreatedAt)
+	if err != nil {
+		slog.Error("Error inserting import batch", "error", err)
+		return fmt.Errorf("failed to create import batch: %w", err)
+	}
+	batch.RowCount = len(batch.Rows)
+
+	for _, row := range batch.Rows {
+		_, err := tx.Exec(`INSERT INTO import_rows
+		(batch_id, line, expense_date, amount, description, duplicate, duplicate_of)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::uuid)`,
+			batch.ID,
+			row.Line,
+			row.Date,
+			row.Amount,
+			row.Description,
+			row.Duplicate,
+			row.DuplicateOf,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store import row: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) GetImportBatches(userID string) ([]types.ImportBatch, error) {
+	rows, err := s.db.Query(`SELECT `+importBatchColumns+` FROM import_batches
+	WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []types.ImportBatch{}
+	for rows.Next() {
+		b, err := scanImportBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+func (s *Storage) GetImportBatch(userID, id string) (*types.ImportBatch, error) {
+	batch, err := scanImportBatch(s.db.QueryRow(`SELECT `+importBatchColumns+` FROM import_batches
+	WHERE id = $1 AND user_id = $2`, id, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT line, expense_date::text, amount, description, duplicate,
+	COALESCE(duplicate_of::text, '') FROM import_rows WHERE batch_id = $1 ORDER BY line`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import rows: %w", err)
+	}
+	defer rows.Close()
+
+	batch.Rows = []types.ImportRow{}
+	for rows.Next() {
+		var row types.ImportRow
+		if err := rows.Scan(&row.Line, &row.Date, &row.Amount, &row.Description, &row.Duplicate, &row.DuplicateOf); err != nil {
+			return nil, err
+		}
+		batch.Rows = append(batch.Rows, row)
+	}
+	return &batch, rows.Err()
+}
+
+// CommitImportBatch turns the non-duplicate rows of a pending batch into
+// expenses tagged with the batch ID.
+func (s *Storage) CommitImportBatch(userID, id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status types.ImportStatus
+	err = tx.QueryRow(`SELECT status FROM import_batches WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		id, userID,
+	).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status != types.ImportStatusPending {
+		return ErrInvalidBatchState
+	}
+
+	result, err := tx.Exec(`INSERT INTO expenses
+	(user_id, amount, expense_date, description, import_batch_id, currency)
+	SELECT $2, r.amount, r.expense_date, r.description, r.batch_id, u.home_currency
+	FROM import_rows r JOIN users u ON u.id = $2
+	WHERE r.batch_id = $1 AND r.duplicate = FALSE`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to import expenses: %w", err)
+	}
+	imported, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+
+	created, err := lockExpenses(tx, "e.import_batch_id = $1", id)
+	if err != nil {
+		return err
+	}
+	if err := recordCreated(tx, types.SourceImport, userID, created); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE import_batches
+	SET status = $2, imported_count = $3, skipped_count = row_count - $3, committed_at = NOW()
+	WHERE id = $1`, id, types.ImportStatusCommitted, imported)
+	if err != nil {
+		return fmt.Errorf("failed to update import batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RollbackImportBatch moves every expense created by a batch to the trash.
+// A pending batch is simply discarded.
+func (s *Storage) RollbackImportBatch(userID, id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status types.ImportStatus
+	err = tx.QueryRow(`SELECT status FROM import_batches WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+		id, userID,
+	).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status == types.ImportStatusRolledBack {
+		return ErrInvalidBatchState
+	}
+
+	if _, err := softDelete(tx, types.SourceImport, userID, "e.import_batch_id = $1 AND e.user_id = $2", id, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE import_batches SET status = $2, rolled_back_at = NOW() WHERE id = $1`,
+		id, types.ImportStatusRolledBack)
+	if err != nil {
+		return fmt.Errorf("failed to update import batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
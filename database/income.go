@@ -0,0 +1,299 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// incomeSchema stores money received and lets recurring templates generate
+// income as well as expenses.
+const incomeSchema = `
+	CREATE TABLE IF NOT EXISTS incomes (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		amount NUMERIC(14, 2) NOT NULL CHECK (amount > 0),
+		income_date DATE NOT NULL,
+		kind VARCHAR(20) NOT NULL DEFAULT 'other',
+		source VARCHAR(255) NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		currency VARCHAR(3) NOT NULL DEFAULT 'UGX',
+		recurring_id UUID,
+		occurrence_date DATE,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		updated_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_incomes_user_date ON incomes (user_id, income_date);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_incomes_occurrence
+		ON incomes (recurring_id, occurrence_date) WHERE recurring_id IS NOT NULL;
+
+	ALTER TABLE recurring_expenses ADD COLUMN IF NOT EXISTS entry_type VARCHAR(10) NOT NULL DEFAULT 'expense';
+	ALTER TABLE recurring_expenses ADD COLUMN IF NOT EXISTS income_kind VARCHAR(20) NOT NULL DEFAULT '';
+	ALTER TABLE recurring_expenses ADD COLUMN IF NOT EXISTS source VARCHAR(255) NOT NULL DEFAULT '';
+	`
+
+const incomeColumns = `id, user_id, amount, income_date::text, kind, source, description, currency,
+	COALESCE(recurring_id::text, '')`
+
+func scanIncome(row interface{ Scan(...any) error }) (types.Income, error) {
+	var i types.Income
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Amount,
+		&i.Date,
+		&i.Kind,
+		&i.Source,
+		&i.Description,
+		&i.Currency,
+		&i.RecurringID,
+	)
+	return i, err
+}
+
+// CreateIncome saves an income entry, in the user's home currency unless
+// one is given, and applies the user's savings goal rules to it.
+func (s *Storage) CreateIncome(income *types.Income) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO incomes
+	(user_id, amount, income_date, kind, source, description, currency)
+	VALUES ($1, $2, $3, $4, $5, $6, COALESCE(NULLIF($7, ''), (SELECT home_currency FROM users WHERE id = $1)))
+	RETURNING id, currency`,
+		income.UserID,
+		income.Amount,
+		income.Date,
+		income.Kind,
+		income.Source,
+		income.Description,
+		income.Currency,
+	).Scan(&income.ID, &income.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to create income: %w", err)
+	}
+
+	if err := applyGoalRules(tx, income.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetIncomes lists a user's income between from and to inclusive, newest
+// first. Either bound may be empty.
+func (s *Storage) GetIncomes(userID, from, to string) ([]types.Income, error) {
+	rows, err := s.db.Query(`SELECT `+incomeColumns+` FROM incomes
+	WHERE user_id = $1
+		AND ($2 = '' OR income_date >= NULLIF($2, '')::date)
+		AND ($3 = '' OR income_date <= NULLIF($3, '')::date)
+	ORDER BY income_date DESC, created_at DESC`, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query income: %w", err)
+	}
+	defer rows.Close()
+
+	incomes := []types.Income{}
+	for rows.Next() {
+		i, err := scanIncome(rows)
+		if err != nil {
+			return nil, err
+		}
+		incomes = append(incomes, i)
+	}
+	return incomes, rows.Err()
+}
+
+// UpdateIncome applies a partial edit and returns the updated entry.
+func (s *Storage) UpdateIncome(userID, id string, update types.IncomeUpdate) (*types.Income, error) {
+	income, err := scanIncome(s.db.QueryRow(`UPDATE incomes SET
+		amount = COALESCE($3, amount),
+		income_date = COALESCE($4::date, income_date),
+		kind = COALESCE($5, kind),
+		source = COALESCE($6, source),
+		description = COALESCE($7, description),
+		currency = COALESCE($8, currency),
+		updated_at = NOW()
+	WHERE id = $1 AND user_id = $2
+	RETURNING `+incomeColumns,
+		id,
+		userID,
+		update.Amount,
+		update.Date,
+		update.Kind,
+		update.Source,
+		update.Description,
+		update.Currency,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &income, nil
+}
+
+func (s *Storage) DeleteIncome(userID, id string) error {
+	result, err := s.db.Exec(`DELETE FROM incomes WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete income: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+// InsertRecurringIncome stores income generated from a template and
+// advances the template's high-water mark, like InsertRecurringOccurrences
+// does for expenses. Savings goal rules apply to each new entry.
+func (s *Storage) InsertRecurringIncome(recurringID string, incomes []types.Income, generatedThrough string, finished bool) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO incomes
+	(user_id, amount, income_date, kind, source, description, recurring_id, occurrence_date, currency)
+	SELECT $1, $2, $3, $4, $5, $6, $7, $3, home_currency FROM users WHERE id = $1
+	ON CONFLICT (recurring_id, occurrence_date) WHERE recurring_id IS NOT NULL DO NOTHING
+	RETURNING id`
+
+	inserted := 0
+	for _, i := range incomes {
+		var id string
+		err := tx.QueryRow(query,
+			i.UserID,
+			i.Amount,
+			i.Date,
+			i.Kind,
+			i.Source,
+			i.Description,
+			recurringID,
+		).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert recurring income: %w", err)
+		}
+		if err := applyGoalRules(tx, id); err != nil {
+			return 0, err
+		}
+		inserted++
+	}
+
+	_, err = tx.Exec(
+		`UPDATE recurring_expenses SET last_generated = $2, active = NOT $3 WHERE id = $1`,
+		recurringID, generatedThrough, finished,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update recurring expense: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return inserted, nil
+}
+
+// applyIncomeException removes or edits an income occurrence that was
+// already generated.
+func applyIncomeException(tx *sql.Tx, ex *types.RecurringException) error {
+	var err error
+	if ex.Skip {
+		_, err = tx.Exec(`DELETE FROM incomes WHERE recurring_id = $1 AND occurrence_date = $2`,
+			ex.RecurringID, ex.OccurrenceDate)
+	} else {
+		_, err = tx.Exec(`UPDATE incomes
+		SET amount = COALESCE($3, amount), description = COALESCE($4, description), updated_at = NOW()
+		WHERE recurring_id = $1 AND occurrence_date = $2`,
+			ex.RecurringID, ex.OccurrenceDate, ex.Amount, ex.Description,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply recurring exception to income: %w", err)
+	}
+	return nil
+}
+
+// CashFlow reports income, spending and the running balance per day, week
+// or month between from and to, converted to currency. The balance starts
+// from the net of everything recorded before from.
+func (s *Storage) CashFlow(userID, timezone, currency, unit, from, to string) ([]types.CashFlowPoint, error) {
+	if unit != "day" && unit != "week" && unit != "month" {
+		return nil, fmt.Errorf("unsupported cash flow unit %q", unit)
+	}
+
+	query := `WITH ` + rangeBounds + `,
+	` + convertedExpenses(5) + `,
+	converted_income AS (
+		SELECT i.income_date, i.amount * fx.rate AS home_amount, fx.rate IS NULL AS unconverted
+		FROM incomes i
+		CROSS JOIN bounds b
+		CROSS JOIN LATERAL (SELECT fx_rate(i.currency, $5, i.income_date) AS rate) fx
+		WHERE i.user_id = $1 AND i.income_date BETWEEN b.start_date AND b.end_date
+	),
+	periods AS (
+		SELECT generate_series(
+			date_trunc($6, b.start_date::timestamp),
+			date_trunc($6, b.end_date::timestamp),
+			('1 ' || $6)::interval
+		)::date AS period
+		FROM bounds b
+	),
+	income AS (
+		SELECT date_trunc($6, income_date::timestamp)::date AS period,
+		SUM(home_amount) AS total, COUNT(*) FILTER (WHERE unconverted) AS unconverted
+		FROM converted_income
+		GROUP BY 1
+	),
+	spending AS (
+		SELECT date_trunc($6, expense_date::timestamp)::date AS period,
+		SUM(home_amount) AS total, COUNT(*) FILTER (WHERE unconverted) AS unconverted
+		FROM converted
+		GROUP BY 1
+	),
+	opening AS (
+		SELECT COALESCE((
+			SELECT SUM(i.amount * fx_rate(i.currency, $5, i.income_date))
+			FROM incomes i, bounds b
+			WHERE i.user_id = $1 AND i.income_date < b.start_date
+		), 0) - COALESCE((
+			SELECT SUM(e.amount * fx_rate(e.currency, $5, e.expense_date))
+			FROM expenses e, bounds b
+			WHERE e.user_id = $1 AND e.deleted_at IS NULL AND e.expense_date < b.start_date
+		), 0) AS balance
+	)
+	SELECT p.period::text, ROUND(COALESCE(i.total, 0), 2), ROUND(COALESCE(s.total, 0), 2),
+	(COALESCE(i.unconverted, 0) + COALESCE(s.unconverted, 0))::int,
+	ROUND(o.balance + SUM(COALESCE(i.total, 0) - COALESCE(s.total, 0)) OVER (ORDER BY p.period), 2)
+	FROM periods p
+	CROSS JOIN opening o
+	LEFT JOIN income i ON i.period = p.period
+	LEFT JOIN spending s ON s.period = p.period
+	ORDER BY p.period`
+
+	rows, err := s.db.Query(query, userID, timezone, from, to, currency, unit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cash flow: %w", err)
+	}
+	defer rows.Close()
+
+	points := []types.CashFlowPoint{}
+	for rows.Next() {
+		var p types.CashFlowPoint
+		if err := rows.Scan(&p.Period, &p.Income, &p.Expenses, &p.Unconverted, &p.Balance); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,365 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/types"
+)
+
+// journalSchema is the double-entry wallet ledger. Account balances are
+// cached on the account row and only change while that row is locked, in
+// the same transaction that inserts the postings, so a balance always
+// equals the sum of its committed postings. Journal entries and postings
+// can never be updated or deleted.
+const journalSchema = `
+	CREATE TABLE IF NOT EXISTS ledger_accounts (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID,
+		kind VARCHAR(20) NOT NULL,
+		currency VARCHAR(3) NOT NULL,
+		balance BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id),
+		CHECK ((kind = 'wallet') = (user_id IS NOT NULL)),
+		CHECK (kind <> 'wallet' OR balance >= 0)
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_accounts_wallet
+		ON ledger_accounts (user_id, currency) WHERE user_id IS NOT NULL;
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_accounts_system
+		ON ledger_accounts (kind, currency) WHERE user_id IS NULL;
+
+	CREATE TABLE IF NOT EXISTS journal_entries (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		kind VARCHAR(50) NOT NULL,
+		reference VARCHAR(255) NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT NOW ()
+	);
+
+	CREATE TABLE IF NOT EXISTS journal_postings (
+		id BIGSERIAL PRIMARY KEY,
+		entry_id UUID NOT NULL,
+		account_id UUID NOT NULL,
+		amount BIGINT NOT NULL CHECK (amount <> 0),
+		currency VARCHAR(3) NOT NULL,
+		balance_after BIGINT NOT NULL,
+		FOREIGN KEY (entry_id) REFERENCES journal_entries (id),
+		FOREIGN KEY (account_id) REFERENCES ledger_accounts (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_journal_postings_entry ON journal_postings (entry_id);
+	CREATE INDEX IF NOT EXISTS idx_journal_postings_account ON journal_postings (account_id, id);
+
+	CREATE OR REPLACE FUNCTION journal_immutable() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+	BEGIN
+		RAISE EXCEPTION 'journal % are immutable', TG_TABLE_NAME;
+	END
+	$$;
+
+	DROP TRIGGER IF EXISTS journal_entries_immutable ON journal_entries;
+	CREATE TRIGGER journal_entries_immutable BEFORE UPDATE OR DELETE ON journal_entries
+		FOR EACH ROW EXECUTE FUNCTION journal_immutable();
+
+	DROP TRIGGER IF EXISTS journal_postings_immutable ON journal_postings;
+	CREATE TRIGGER journal_postings_immutable BEFORE UPDATE OR DELETE ON journal_postings
+		FOR EACH ROW EXECUTE FUNCTION journal_immutable();
+	`
+
+const ledgerAccountColumns = `id, COALESCE(user_id::text, ''), kind, currency, balance, created_at::text`
+
+func scanLedgerAccount(row interface{ Scan(...any) error }) (types.LedgerAccount, error) {
+	var a types.LedgerAccount
+	err := row.Scan(&a.ID, &a.UserID, &a.Kind, &a.Currency, &a.Balance, &a.CreatedAt)
+	return a, err
+}
+
+// WalletAccount returns the user's wallet in currency, opening it with a
+// zero balance the first time it is asked for.
+func (s *Storage) WalletAccount(userID, currency string) (*types.LedgerAccount, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, err := openWallet(tx, userID, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return account, nil
+}
+
+func openWallet(tx *sql.Tx, userID, currency string) (*types.LedgerAccount, error) {
+	_, err := tx.Exec(`INSERT INTO ledger_accounts (user_id, kind, currency) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, currency) WHERE user_id IS NOT NULL DO NOTHING`,
+		userID, types.AccountWallet, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wallet: %w", err)
+	}
+
+	account, err := scanLedgerAccount(tx.QueryRow(`SELECT `+ledgerAccountColumns+` FROM ledger_accounts
+	WHERE user_id = $1 AND currency = $2`, userID, currency))
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// SystemAccount returns the system account of kind in currency, creating it
+// the first time it is asked for.
+func (s *Storage) SystemAccount(kind types.LedgerAccountKind, currency string) (*types.LedgerAccount, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	account, err := openSystemAccount(tx, kind, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return account, nil
+}
+
+func openSystemAccount(tx *sql.Tx, kind types.LedgerAccountKind, currency string) (*types.LedgerAccount, error) {
+	if kind == types.AccountWallet {
+		return nil, fmt.Errorf("wallets are not system accounts")
+	}
+
+	_, err := tx.Exec(`INSERT INTO ledger_accounts (kind, currency) VALUES ($1, $2)
+	ON CONFLICT (kind, currency) WHERE user_id IS NULL DO NOTHING`, kind, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open system account: %w", err)
+	}
+
+	account, err := scanLedgerAccount(tx.QueryRow(`SELECT `+ledgerAccountColumns+` FROM ledger_accounts
+	WHERE kind = $1 AND currency = $2 AND user_id IS NULL`, kind, currency))
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetWallets lists the user's wallets, or the system accounts when userID
+// is empty.
+func (s *Storage) GetWallets(userID string) ([]types.LedgerAccount, error) {
+	rows, err := s.db.Query(`SELECT `+ledgerAccountColumns+` FROM ledger_accounts
+	WHERE user_id IS NOT DISTINCT FROM NULLIF($1, '')::uuid ORDER BY kind, currency`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []types.LedgerAccount{}
+	for rows.Next() {
+		a, err := scanLedgerAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// GetAccountStatement returns the latest postings to an account, newest
+// first.
+func (s *Storage) GetAccountStatement(accountID string, limit int) ([]types.AccountStatementLine, error) {
+	rows, err := s.db.Query(`SELECT e.id, e.kind, e.reference, e.description, p.amount, p.balance_after,
+	e.created_at::text
+	FROM journal_postings p JOIN journal_entries e ON e.id = p.entry_id
+	WHERE p.account_id = $1
+	ORDER BY p.id DESC
+	LIMIT $2`, accountID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account statement: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []types.AccountStatementLine{}
+	for rows.Next() {
+		var l types.AccountStatementLine
+		if err := rows.Scan(&l.EntryID, &l.Kind, &l.Reference, &l.Description, &l.Amount, &l.BalanceAfter, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// PostJournalEntry records an entry and applies its postings to the
+// account balances atomically. It fails with ledger.ErrDuplicateEntry when
+// the reference was already used and ledger.ErrInsufficientFunds when a
+// wallet would go below zero.
+func (s *Storage) PostJournalEntry(entry *types.JournalEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := postEntry(tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// postEntry posts an entry within tx. The accounts are locked in a fixed
+// order so concurrent entries touching the same accounts queue up instead
+// of deadlocking.
+func postEntry(tx *sql.Tx, entry *types.JournalEntry) error {
+	if err := ledger.Validate(*entry); err != nil {
+		return err
+	}
+
+	accounts := map[string]*types.LedgerAccount{}
+	for _, id := range ledger.AccountOrder(*entry) {
+		account, err := scanLedgerAccount(tx.QueryRow(`SELECT `+ledgerAccountColumns+` FROM ledger_accounts
+		WHERE id = $1 FOR UPDATE`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("ledger account %s: %w", id, sql.ErrNoRows)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock ledger account: %w", err)
+		}
+		accounts[id] = &account
+	}
+
+	err := tx.QueryRow(`INSERT INTO journal_entries (kind, reference, description) VALUES ($1, $2, $3)
+	ON CONFLICT (reference) DO NOTHING
+	RETURNING id, created_at::text`,
+		entry.Kind, entry.Reference, entry.Description,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ledger.ErrDuplicateEntry
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	for i := range entry.Postings {
+		p := &entry.Postings[i]
+		account := accounts[p.AccountID]
+		if p.Currency != account.Currency {
+			return fmt.Errorf("%w: account %s is in %s", ledger.ErrCurrencyMismatch, account.ID, account.Currency)
+		}
+		account.Balance += p.Amount
+		p.BalanceAfter = account.Balance
+
+		_, err := tx.Exec(`INSERT INTO journal_postings (entry_id, account_id, amount, currency, balance_after)
+		VALUES ($1, $2, $3, $4, $5)`, entry.ID, p.AccountID, p.Amount, p.Currency, p.BalanceAfter)
+		if err != nil {
+			return fmt.Errorf("failed to create posting: %w", err)
+		}
+	}
+
+	for _, account := range accounts {
+		if account.Balance < 0 && !ledger.CanGoNegative(account.Kind) {
+			return ledger.ErrInsufficientFunds
+		}
+		_, err := tx.Exec(`UPDATE ledger_accounts SET balance = $2 WHERE id = $1`, account.ID, account.Balance)
+		if err != nil {
+			return fmt.Errorf("failed to update ledger balance: %w", err)
+		}
+	}
+	return nil
+}
+
+// moveMoney posts the simplest entry: amount minor units from one account
+// to another.
+func moveMoney(tx *sql.Tx, kind, reference, description, fromID, toID string, amount int64, currency string) (*types.JournalEntry, error) {
+	entry := &types.JournalEntry{
+		Kind:        kind,
+		Reference:   reference,
+		Description: description,
+		Postings: []types.Posting{
+			{AccountID: fromID, Amount: -amount, Currency: currency},
+			{AccountID: toID, Amount: amount, Currency: currency},
+		},
+	}
+	if err := postEntry(tx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ledgerChecks are the invariants of the ledger. Each query returns the
+// offending account, entry and currency with a description of the problem.
+var ledgerChecks = []struct {
+	name  string
+	query string
+}{
+	{"unbalanced_entry", `SELECT '', entry_id::text, currency, 'postings sum to ' || SUM(amount)
+		FROM journal_postings GROUP BY entry_id, currency HAVING SUM(amount) <> 0`},
+	{"single_posting_entry", `SELECT '', e.id::text, '', COUNT(p.id) || ' postings'
+		FROM journal_entries e LEFT JOIN journal_postings p ON p.entry_id = e.id
+		GROUP BY e.id HAVING COUNT(p.id) < 2`},
+	{"balance_mismatch", `SELECT a.id::text, '', a.currency,
+		'balance ' || a.balance || ' but postings sum to ' || COALESCE(SUM(p.amount), 0)
+		FROM ledger_accounts a LEFT JOIN journal_postings p ON p.account_id = a.id
+		GROUP BY a.id HAVING a.balance <> COALESCE(SUM(p.amount), 0)`},
+	{"running_balance_mismatch", `SELECT a.id::text, '', a.currency,
+		'balance ' || a.balance || ' but last posting left ' || last.balance_after
+		FROM ledger_accounts a
+		JOIN LATERAL (SELECT balance_after FROM journal_postings
+			WHERE account_id = a.id ORDER BY id DESC LIMIT 1) last ON TRUE
+		WHERE last.balance_after <> a.balance`},
+	{"currency_mismatch", `SELECT a.id::text, p.entry_id::text, p.currency,
+		'posting in ' || p.currency || ' to an account in ' || a.currency
+		FROM journal_postings p JOIN ledger_accounts a ON a.id = p.account_id
+		WHERE p.currency <> a.currency`},
+	{"negative_wallet", `SELECT id::text, '', currency, 'wallet balance is ' || balance
+		FROM ledger_accounts WHERE kind = 'wallet' AND balance < 0`},
+	{"currency_total", `SELECT '', '', currency, 'balances sum to ' || SUM(balance)
+		FROM ledger_accounts GROUP BY currency HAVING SUM(balance) <> 0`},
+}
+
+// CheckLedgerInvariants runs every ledger check against one snapshot of the
+// database and returns the violations found; none means the ledger is
+// sound.
+func (s *Storage) CheckLedgerInvariants() ([]types.LedgerViolation, error) {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	violations := []types.LedgerViolation{}
+	for _, check := range ledgerChecks {
+		rows, err := tx.Query(check.query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run ledger check %s: %w", check.name, err)
+		}
+		for rows.Next() {
+			v := types.LedgerViolation{Check: check.name}
+			if err := rows.Scan(&v.AccountID, &v.EntryID, &v.Currency, &v.Detail); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			violations = append(violations, v)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return violations, nil
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var (
+	ErrKYCPending      = errors.New("a KYC submission is already awaiting review")
+	ErrInvalidKYCState = errors.New("KYC submission is not in a valid state for this operation")
+)
+
+// kycSchema stores identity document submissions and their review. The
+// images themselves live in blob storage. A user can have only one
+// submission awaiting review at a time.
+const kycSchema = `
+	CREATE TABLE IF NOT EXISTS kyc_submissions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		tier INTEGER NOT NULL,
+		document_type VARCHAR(30) NOT NULL,
+		document_number VARCHAR(50) NOT NULL DEFAULT '',
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		reason TEXT NOT NULL DEFAULT '',
+		reviewed_by UUID,
+		reviewed_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id),
+		FOREIGN KEY (reviewed_by) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_kyc_submissions_user ON kyc_submissions (user_id, created_at);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_kyc_submissions_pending ON kyc_submissions (user_id)
+		WHERE status = 'pending';
+
+	CREATE TABLE IF NOT EXISTS kyc_documents (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		submission_id UUID NOT NULL,
+		side VARCHAR(20) NOT NULL,
+		blob_key TEXT NOT NULL,
+		filename TEXT NOT NULL DEFAULT '',
+		content_type VARCHAR(100) NOT NULL,
+		size BIGINT NOT NULL,
+		FOREIGN KEY (submission_id) REFERENCES kyc_submissions (id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_kyc_documents_submission ON kyc_documents (submission_id);
+	`
+
+const kycSubmissionColumns = `id, user_id, tier, document_type, document_number, status, reason,
+	COALESCE(reviewed_by::text, ''), COALESCE(reviewed_at::text, ''), created_at::text`
+
+func scanKYCSubmission(row interface{ Scan(...any) error }) (types.KYCSubmission, error) {
+	var sub types.KYCSubmission
+	err := row.Scan(
+		&sub.ID,
+		&sub.UserID,
+		&sub.Tier,
+		&sub.DocumentType,
+		&sub.DocumentNumber,
+		&sub.Status,
+		&sub.Reason,
+		&sub.ReviewedBy,
+		&sub.ReviewedAt,
+		&sub.CreatedAt,
+	)
+	return sub, err
+}
+
+// CreateKYCSubmission stores a pending submission with its documents. It
+// fails with ErrKYCPending when the user already has one awaiting review.
+func (s *Storage) CreateKYCSubmission(sub *types.KYCSubmission) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO kyc_submissions (user_id, tier, document_type, document_number)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+	RETURNING id, status, created_at::text`,
+		sub.UserID,
+		sub.Tier,
+		sub.DocumentType,
+		sub.DocumentNumber,
+	).Scan(&sub.ID, &sub.Status, &sub.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrKYCPending
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create KYC submission: %w", err)
+	}
+
+	for i := range sub.Documents {
+		doc := &sub.Documents[i]
+		err := tx.QueryRow(`INSERT INTO kyc_documents (submission_id, side, blob_key, filename, content_type, size)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			sub.ID,
+			doc.Side,
+			doc.BlobKey,
+			doc.Filename,
+			doc.ContentType,
+			doc.Size,
+		).Scan(&doc.ID)
+		if err != nil {
+			return fmt.Errorf("failed to store KYC document: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetKYCSubmissions lists a user's submissions newest first, or with an
+// empty userID every submission with the given status oldest first, which
+// is the review queue.
+func (s *Storage) GetKYCSubmissions(userID string, status types.KYCStatus, limit int) ([]types.KYCSubmission, error) {
+	order := "created_at"
+	if userID != "" {
+		order = "created_at DESC"
+	}
+	rows, err := s.db.Query(`SELECT `+kycSubmissionColumns+` FROM kyc_submissions
+	WHERE ($1 = '' OR user_id::text = $1) AND ($2 = '' OR status = $2)
+	ORDER BY `+order+`
+	LIMIT $3`, userID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query KYC submissions: %w", err)
+	}
+	defer rows.Close()
+
+	submissions := []types.KYCSubmission{}
+	for rows.Next() {
+		sub, err := scanKYCSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, sub)
+	}
+	return submissions, rows.Err()
+}
+
+// GetKYCSubmission returns a submission with its documents.
+func (s *Storage) GetKYCSubmission(id string) (*types.KYCSubmission, error) {
+	sub, err := scanKYCSubmission(s.db.QueryRow(`SELECT `+kycSubmissionColumns+` FROM kyc_submissions
+	WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT id, side, blob_key, filename, content_type, size FROM kyc_documents
+	WHERE submission_id = $1 ORDER BY side`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query KYC documents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc types.KYCDocument
+		if err := rows.Scan(&doc.ID, &doc.Side, &doc.BlobKey, &doc.Filename, &doc.ContentType, &doc.Size); err != nil {
+			return nil, err
+		}
+		sub.Documents = append(sub.Documents, doc)
+	}
+	return &sub, rows.Err()
+}
+
+// ReviewKYCSubmission approves or rejects a pending submission. Approving
+// raises the user to the submission's tier, which needs them to hold the
+// tier below it already; a user is never lowered by a review.
+func (s *Storage) ReviewKYCSubmission(id, adminID string, status types.KYCStatus, reason string) (*types.KYCSubmission, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	sub, err := scanKYCSubmission(tx.QueryRow(`SELECT `+kycSubmissionColumns+` FROM kyc_submissions
+	WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status != types.KYCPending {
+		return nil, ErrInvalidKYCState
+	}
+
+	if status == types.KYCApproved {
+		var tier types.KYCTier
+		err := tx.QueryRow(`SELECT kyc_tier FROM users WHERE id = $1 FOR UPDATE`, sub.UserID).Scan(&tier)
+		if err != nil {
+			return nil, err
+		}
+		if tier < sub.Tier-1 {
+			return nil, ErrInvalidKYCState
+		}
+		if _, err := tx.Exec(`UPDATE users SET kyc_tier = GREATEST(kyc_tier, $2) WHERE id = $1`, sub.UserID, sub.Tier); err != nil {
+			return nil, fmt.Errorf("failed to update KYC tier: %w", err)
+		}
+	}
+
+	sub, err = scanKYCSubmission(tx.QueryRow(`UPDATE kyc_submissions
+	SET status = $2, reason = $3, reviewed_by = $4, reviewed_at = NOW()
+	WHERE id = $1 RETURNING `+kycSubmissionColumns, id, status, reason, adminID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to review KYC submission: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &sub, nil
+}
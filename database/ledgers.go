@@ -0,0 +1,463 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var ErrAlreadyInvited = errors.New("user is already a member or has a pending invitation")
+
+const ledgerSchema = `
+	CREATE TABLE IF NOT EXISTS ledgers (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		name VARCHAR(100) NOT NULL,
+		created_by UUID NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (created_by) REFERENCES users (id)
+	);
+
+	CREATE TABLE IF NOT EXISTS ledger_members (
+		ledger_id UUID NOT NULL,
+		user_id UUID NOT NULL,
+		role VARCHAR(10) NOT NULL,
+		joined_at TIMESTAMPTZ DEFAULT NOW (),
+		PRIMARY KEY (ledger_id, user_id),
+		FOREIGN KEY (ledger_id) REFERENCES ledgers (id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ledger_members_user ON ledger_members (user_id);
+
+	CREATE TABLE IF NOT EXISTS ledger_invitations (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		ledger_id UUID NOT NULL,
+		email VARCHAR(255) NOT NULL,
+		role VARCHAR(10) NOT NULL,
+		invited_by UUID NOT NULL,
+		status VARCHAR(10) NOT NULL DEFAULT 'pending',
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		responded_at TIMESTAMPTZ,
+		FOREIGN KEY (ledger_id) REFERENCES ledgers (id) ON DELETE CASCADE,
+		FOREIGN KEY (invited_by) REFERENCES users (id)
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_invitations_pending
+		ON ledger_invitations (ledger_id, LOWER(email)) WHERE status = 'pending';
+
+	CREATE TABLE IF NOT EXISTS ledger_expenses (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		ledger_id UUID NOT NULL,
+		paid_by UUID NOT NULL,
+		amount NUMERIC(14, 2) NOT NULL,
+		expense_date DATE NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		category VARCHAR(100) NOT NULL DEFAULT '',
+		split_method VARCHAR(10) NOT NULL,
+		created_by UUID NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (ledger_id) REFERENCES ledgers (id) ON DELETE CASCADE,
+		FOREIGN KEY (paid_by) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ledger_expenses_ledger ON ledger_expenses (ledger_id, expense_date);
+
+	CREATE TABLE IF NOT EXISTS ledger_expense_splits (
+		expense_id UUID NOT NULL,
+		user_id UUID NOT NULL,
+		value NUMERIC(14, 4) NOT NULL DEFAULT 0,
+		amount NUMERIC(14, 2) NOT NULL,
+		PRIMARY KEY (expense_id, user_id),
+		FOREIGN KEY (expense_id) REFERENCES ledger_expenses (id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE TABLE IF NOT EXISTS ledger_settlements (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		ledger_id UUID NOT NULL,
+		from_user UUID NOT NULL,
+		to_user UUID NOT NULL,
+		amount NUMERIC(14, 2) NOT NULL,
+		settled_on DATE NOT NULL,
+		created_by UUID NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (ledger_id) REFERENCES ledgers (id) ON DELETE CASCADE,
+		FOREIGN KEY (from_user) REFERENCES users (id),
+		FOREIGN KEY (to_user) REFERENCES users (id)
+	);
+	`
+
+// CreateLedger creates a ledger with its creator as the owner.
+func (s *Storage) CreateLedger(ledger *types.Ledger) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO ledgers (name, created_by) VALUES ($1, $2)
+	RETURNING id, created_at::text`, ledger.Name, ledger.CreatedBy,
+	).Scan(&ledger.ID, &ledger.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create ledger: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO ledger_members (ledger_id, user_id, role) VALUES ($1, $2, $3)`,
+		ledger.ID, ledger.CreatedBy, types.LedgerRoleOwner)
+	if err != nil {
+		return fmt.Errorf("failed to add ledger owner: %w", err)
+	}
+	ledger.Role = types.LedgerRoleOwner
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetLedgers lists the ledgers the user belongs to, with their role in each.
+func (s *Storage) GetLedgers(userID string) ([]types.Ledger, error) {
+	rows, err := s.db.Query(`SELECT l.id, l.name, l.created_by, l.created_at::text, m.role
+	FROM ledgers l JOIN ledger_members m ON m.ledger_id = l.id
+	WHERE m.user_id = $1 ORDER BY l.created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledgers: %w", err)
+	}
+	defer rows.Close()
+
+	ledgers := []types.Ledger{}
+	for rows.Next() {
+		var l types.Ledger
+		if err := rows.Scan(&l.ID, &l.Name, &l.CreatedBy, &l.CreatedAt, &l.Role); err != nil {
+			return nil, err
+		}
+		ledgers = append(ledgers, l)
+	}
+	return ledgers, rows.Err()
+}
+
+// GetLedgerRole returns the user's role in a ledger, or sql.ErrNoRows when
+// they are not a member.
+func (s *Storage) GetLedgerRole(ledgerID, userID string) (types.LedgerRole, error) {
+	var role types.LedgerRole
+	err := s.db.QueryRow(`SELECT role FROM ledger_members WHERE ledger_id = $1 AND user_id = $2`,
+		ledgerID, userID,
+	).Scan(&role)
+	return role, err
+}
+
+func (s *Storage) GetLedgerMembers(ledgerID string) ([]types.LedgerMember, error) {
+	rows, err := s.db.Query(`SELECT m.user_id, COALESCE(u.firstName, ''), COALESCE(u.lastName, ''),
+	COALESCE(u.email, ''), m.role, m.joined_at::text
+	FROM ledger_members m JOIN users u ON u.id = m.user_id
+	WHERE m.ledger_id = $1 ORDER BY m.joined_at`, ledgerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []types.LedgerMember{}
+	for rows.Next() {
+		var m types.LedgerMember
+		if err := rows.Scan(&m.UserID, &m.FirstName, &m.LastName, &m.Email, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *Storage) SetLedgerMemberRole(ledgerID, userID string, role types.LedgerRole) error {
+	result, err := s.db.Exec(`UPDATE ledger_members SET role = $3 WHERE ledger_id = $1 AND user_id = $2`,
+		ledgerID, userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to update ledger member: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+func (s *Storage) RemoveLedgerMember(ledgerID, userID string) error {
+	result, err := s.db.Exec(`DELETE FROM ledger_members WHERE ledger_id = $1 AND user_id = $2`, ledgerID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove ledger member: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+// CreateLedgerInvitation invites an email address to a ledger. It returns
+// ErrAlreadyInvited when the address already belongs to a member or has an
+// invitation waiting.
+func (s *Storage) CreateLedgerInvitation(inv *types.LedgerInvitation) error {
+	err := s.db.QueryRow(`INSERT INTO ledger_invitations (ledger_id, email, role, invited_by)
+	SELECT $1, $2, $3, $4
+	WHERE NOT EXISTS (
+		SELECT 1 FROM ledger_members m JOIN users u ON u.id = m.user_id
+		WHERE m.ledger_id = $1 AND LOWER(u.email) = LOWER($2)
+	)
+	ON CONFLICT DO NOTHING
+	RETURNING id, status, created_at::text`,
+		inv.LedgerID, inv.Email, inv.Role, inv.InvitedBy,
+	).Scan(&inv.ID, &inv.Status, &inv.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAlreadyInvited
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return nil
+}
+
+// GetUserInvitations lists the pending invitations sent to the user's email.
+func (s *Storage) GetUserInvitations(userID string) ([]types.LedgerInvitation, error) {
+	rows, err := s.db.Query(`SELECT i.id, i.ledger_id, l.name, i.email, i.role, i.invited_by, i.status,
+	i.created_at::text
+	FROM ledger_invitations i
+	JOIN ledgers l ON l.id = i.ledger_id
+	JOIN users u ON LOWER(u.email) = LOWER(i.email)
+	WHERE u.id = $1 AND i.status = 'pending'
+	ORDER BY i.created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []types.LedgerInvitation{}
+	for rows.Next() {
+		var i types.LedgerInvitation
+		err := rows.Scan(&i.ID, &i.LedgerID, &i.LedgerName, &i.Email, &i.Role, &i.InvitedBy, &i.Status, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+	return invitations, rows.Err()
+}
+
+// RespondToInvitation accepts or declines a pending invitation addressed to
+// the user. Accepting adds them to the ledger with the invited role.
+func (s *Storage) RespondToInvitation(userID, invitationID string, accept bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var ledgerID string
+	var role types.LedgerRole
+	err = tx.QueryRow(`SELECT i.ledger_id, i.role FROM ledger_invitations i
+	JOIN users u ON LOWER(u.email) = LOWER(i.email)
+	WHERE i.id = $1 AND u.id = $2 AND i.status = 'pending'
+	FOR UPDATE OF i`, invitationID, userID,
+	).Scan(&ledgerID, &role)
+	if err != nil {
+		return err
+	}
+
+	status := types.InvitationDeclined
+	if accept {
+		status = types.InvitationAccepted
+		_, err := tx.Exec(`INSERT INTO ledger_members (ledger_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, ledgerID, userID, role)
+		if err != nil {
+			return fmt.Errorf("failed to join ledger: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE ledger_invitations SET status = $2, responded_at = NOW() WHERE id = $1`,
+		invitationID, status)
+	if err != nil {
+		return fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CreateLedgerExpense stores a shared expense with its already computed
+// splits.
+func (s *Storage) CreateLedgerExpense(e *types.LedgerExpense) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO ledger_expenses
+	(ledger_id, paid_by, amount, expense_date, description, category, split_method, created_by)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		e.LedgerID,
+		e.PaidBy,
+		e.Amount,
+		e.Date,
+		e.Description,
+		e.Category,
+		e.SplitMethod,
+		e.CreatedBy,
+	).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create ledger expense: %w", err)
+	}
+
+	for _, split := range e.Splits {
+		_, err := tx.Exec(`INSERT INTO ledger_expense_splits (expense_id, user_id, value, amount)
+		VALUES ($1, $2, $3, $4)`, e.ID, split.UserID, split.Value, split.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to store split: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) GetLedgerExpenses(ledgerID string) ([]types.LedgerExpense, error) {
+	rows, err := s.db.Query(`SELECT id, ledger_id, paid_by, amount, expense_date::text, description,
+	category, split_method, created_by
+	FROM ledger_expenses WHERE ledger_id = $1
+	ORDER BY expense_date DESC, created_at DESC`, ledgerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger expenses: %w", err)
+	}
+	defer rows.Close()
+
+	expenses := []types.LedgerExpense{}
+	index := map[string]int{}
+	for rows.Next() {
+		var e types.LedgerExpense
+		err := rows.Scan(&e.ID, &e.LedgerID, &e.PaidBy, &e.Amount, &e.Date, &e.Description,
+			&e.Category, &e.SplitMethod, &e.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		e.Splits = []types.Split{}
+		index[e.ID] = len(expenses)
+		expenses = append(expenses, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	splits, err := s.db.Query(`SELECT s.expense_id, s.user_id, s.value, s.amount
+	FROM ledger_expense_splits s JOIN ledger_expenses e ON e.id = s.expense_id
+	WHERE e.ledger_id = $1`, ledgerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query splits: %w", err)
+	}
+	defer splits.Close()
+
+	for splits.Next() {
+		var expenseID string
+		var split types.Split
+		if err := splits.Scan(&expenseID, &split.UserID, &split.Value, &split.Amount); err != nil {
+			return nil, err
+		}
+		if i, ok := index[expenseID]; ok {
+			expenses[i].Splits = append(expenses[i].Splits, split)
+		}
+	}
+	return expenses, splits.Err()
+}
+
+func (s *Storage) DeleteLedgerExpense(ledgerID, id string) error {
+	result, err := s.db.Exec(`DELETE FROM ledger_expenses WHERE id = $1 AND ledger_id = $2`, id, ledgerID)
+	if err != nil {
+		return fmt.Errorf("failed to delete ledger expense: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+func (s *Storage) CreateSettlement(settlement *types.Settlement) error {
+	err := s.db.QueryRow(`INSERT INTO ledger_settlements
+	(ledger_id, from_user, to_user, amount, settled_on, created_by)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		settlement.LedgerID,
+		settlement.FromUser,
+		settlement.ToUser,
+		settlement.Amount,
+		settlement.Date,
+		settlement.CreatedBy,
+	).Scan(&settlement.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record settlement: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) GetSettlements(ledgerID string) ([]types.Settlement, error) {
+	rows, err := s.db.Query(`SELECT id, ledger_id, from_user, to_user, amount, settled_on::text, created_by
+	FROM ledger_settlements WHERE ledger_id = $1
+	ORDER BY settled_on DESC, created_at DESC`, ledgerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settlements: %w", err)
+	}
+	defer rows.Close()
+
+	settlements := []types.Settlement{}
+	for rows.Next() {
+		var st types.Settlement
+		if err := rows.Scan(&st.ID, &st.LedgerID, &st.FromUser, &st.ToUser, &st.Amount, &st.Date, &st.CreatedBy); err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, st)
+	}
+	return settlements, rows.Err()
+}
+
+// GetLedgerBalances totals what each member paid and owes. Settlements count
+// as paid by the sender and owed by the receiver. Former members stay in the
+// list while they have activity in the ledger.
+func (s *Storage) GetLedgerBalances(ledgerID string) ([]types.LedgerBalance, error) {
+	query := `WITH activity AS (
+		SELECT paid_by AS user_id, amount AS paid, 0::numeric AS owed
+		FROM ledger_expenses WHERE ledger_id = $1
+		UNION ALL
+		SELECT s.user_id, 0, s.amount
+		FROM ledger_expense_splits s JOIN ledger_expenses e ON e.id = s.expense_id
+		WHERE e.ledger_id = $1
+		UNION ALL
+		SELECT from_user, amount, 0 FROM ledger_settlements WHERE ledger_id = $1
+		UNION ALL
+		SELECT to_user, 0, amount FROM ledger_settlements WHERE ledger_id = $1
+		UNION ALL
+		SELECT user_id, 0, 0 FROM ledger_members WHERE ledger_id = $1
+	)
+	SELECT a.user_id, COALESCE(u.firstName, ''), COALESCE(u.lastName, ''), SUM(a.paid), SUM(a.owed)
+	FROM activity a JOIN users u ON u.id = a.user_id
+	GROUP BY a.user_id, u.firstName, u.lastName
+	ORDER BY 2, 3`
+
+	rows, err := s.db.Query(query, ledgerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger balances: %w", err)
+	}
+	defer rows.Close()
+
+	balances := []types.LedgerBalance{}
+	for rows.Next() {
+		var b types.LedgerBalance
+		if err := rows.Scan(&b.UserID, &b.FirstName, &b.LastName, &b.Paid, &b.Owed); err != nil {
+			return nil, err
+		}
+		b.Net = math.Round((b.Paid-b.Owed)*100) / 100
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+func expectOneRow(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
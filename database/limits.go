@@ -0,0 +1,268 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/limits"
+	"github.com/Ayikoandrew/server/types"
+)
+
+// limitSchema holds the limit rules per KYC tier. The defaults are only
+// seeded into an empty table, so rules an admin changes stay changed.
+const limitSchema = `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_tier INTEGER NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS limit_rules (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		tier INTEGER NOT NULL,
+		action VARCHAR(20) NOT NULL DEFAULT '',
+		metric VARCHAR(30) NOT NULL,
+		currency VARCHAR(3) NOT NULL DEFAULT '',
+		value BIGINT NOT NULL CHECK (value >= 0),
+		updated_at TIMESTAMPTZ DEFAULT NOW (),
+		UNIQUE (tier, action, metric, currency)
+	);
+
+	INSERT INTO limit_rules (tier, action, metric, currency, value)
+	SELECT * FROM (VALUES
+		(0, 'transfer', 'single_amount', 'UGX', 500000),
+		(0, 'withdrawal', 'single_amount', 'UGX', 200000),
+		(0, '', 'daily_amount', 'UGX', 1000000),
+		(0, '', 'monthly_amount', 'UGX', 4000000),
+		(0, '', 'hourly_count', '', 5),
+		(0, 'transfer', 'new_recipient_amount', 'UGX', 200000),
+		(1, 'transfer', 'single_amount', 'UGX', 5000000),
+		(1, 'withdrawal', 'single_amount', 'UGX', 2000000),
+		(1, '', 'daily_amount', 'UGX', 10000000),
+		(1, '', 'monthly_amount', 'UGX', 40000000),
+		(1, '', 'hourly_count', '', 20),
+		(1, 'transfer', 'new_recipient_amount', 'UGX', 2000000),
+		(2, 'transfer', 'single_amount', 'UGX', 20000000),
+		(2, 'withdrawal', 'single_amount', 'UGX', 10000000),
+		(2, '', 'daily_amount', 'UGX', 50000000),
+		(2, '', 'monthly_amount', 'UGX', 200000000),
+		(2, '', 'hourly_count', '', 60),
+		(2, 'transfer', 'new_recipient_amount', 'UGX', 10000000)
+	) AS defaults (tier, action, metric, currency, value)
+	WHERE NOT EXISTS (SELECT 1 FROM limit_rules);
+	`
+
+const limitRuleColumns = `id, tier, action, metric, currency, value, updated_at::text`
+
+func scanLimitRule(row interface{ Scan(...any) error }) (types.LimitRule, error) {
+	var rule types.LimitRule
+	err := row.Scan(
+		&rule.ID,
+		&rule.Tier,
+		&rule.Action,
+		&rule.Metric,
+		&rule.Currency,
+		&rule.Value,
+		&rule.UpdatedAt,
+	)
+	return rule, err
+}
+
+func (s *Storage) GetKYCTier(userID string) (types.KYCTier, error) {
+	var tier types.KYCTier
+	if err := s.db.QueryRow(`SELECT kyc_tier FROM users WHERE id = $1`, userID).Scan(&tier); err != nil {
+		return 0, err
+	}
+	return tier, nil
+}
+
+func (s *Storage) GetLimitRules() ([]types.LimitRule, error) {
+	return getLimitRules(s.db)
+}
+
+// queryer is the read side shared by *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func getLimitRules(q queryer) ([]types.LimitRule, error) {
+	rows, err := q.Query(`SELECT ` + limitRuleColumns + ` FROM limit_rules
+	ORDER BY tier, action, metric, currency`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query limit rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []types.LimitRule{}
+	for rows.Next() {
+		rule, err := scanLimitRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// ReplaceLimitRules swaps the whole rule set for rules in one transaction.
+func (s *Storage) ReplaceLimitRules(rules []types.LimitRule) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM limit_rules`); err != nil {
+		return fmt.Errorf("failed to clear limit rules: %w", err)
+	}
+	for i := range rules {
+		rule := &rules[i]
+		err := tx.QueryRow(`INSERT INTO limit_rules (tier, action, metric, currency, value)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, updated_at::text`,
+			rule.Tier,
+			rule.Action,
+			rule.Metric,
+			rule.Currency,
+			rule.Value,
+		).Scan(&rule.ID, &rule.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to store limit rule: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// LimitError is returned by a posting the sender's limits turned down. The
+// rejection is on the audit trail; nothing else changed.
+type LimitError struct {
+	Decision types.LimitDecision
+}
+
+func (e *LimitError) Error() string {
+	return e.Decision.Violations[0].Message
+}
+
+// enforceLimits checks an outgoing transaction against the sender's limits
+// inside the transaction that posts it, and must run before that
+// transaction writes anything. It first locks the sender, so concurrent
+// postings by one user are checked one after another, each against usage
+// that includes the others. The decision goes on the audit trail in the
+// same transaction; on a rejection the transaction is committed with only
+// that and a *LimitError is returned. excludeTransferID leaves the pending
+// transfer being completed out of the usage.
+func enforceLimits(tx *sql.Tx, attempt types.LimitAttempt, excludeTransferID string) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtextextended('limits:' || $1, 0))`, attempt.UserID); err != nil {
+		return fmt.Errorf("failed to lock sender limits: %w", err)
+	}
+
+	var timezone string
+	err := tx.QueryRow(`SELECT kyc_tier, COALESCE(NULLIF(timezone, ''), 'UTC') FROM users WHERE id = $1`,
+		attempt.UserID).Scan(&attempt.Tier, &timezone)
+	if err != nil {
+		return fmt.Errorf("failed to load sender: %w", err)
+	}
+	rules, err := getLimitRules(tx)
+	if err != nil {
+		return err
+	}
+	usage, err := getLimitUsage(tx, attempt.UserID, attempt.Currency, attempt.RecipientID, timezone, excludeTransferID)
+	if err != nil {
+		return err
+	}
+	decision := limits.Evaluate(rules, attempt, usage)
+
+	outcome := "allowed"
+	if !decision.Allowed {
+		outcome = "rejected"
+	}
+	err = recordAuditEvent(tx, &types.AuditEvent{
+		UserID:  attempt.UserID,
+		Action:  "limit_check",
+		Outcome: outcome,
+		Details: map[string]any{
+			"attempt":    attempt,
+			"usage":      usage,
+			"violations": decision.Violations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if decision.Allowed {
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &LimitError{Decision: decision}
+}
+
+// getLimitUsage sums what the user has sent in currency since the start
+// of the current day and month in timezone, counts their transactions in
+// the last hour, and checks whether they have paid recipientID before.
+// Transfers they received and sent back count as sent when reversed.
+// Failed transfers and withdrawals are left out.
+func getLimitUsage(q queryer, userID, currency, recipientID, timezone, excludeTransferID string) (types.LimitUsage, error) {
+	rows, err := q.Query(`WITH bounds AS (
+		SELECT date_trunc('day', NOW() AT TIME ZONE $3) AT TIME ZONE $3 AS day_start,
+			date_trunc('month', NOW() AT TIME ZONE $3) AT TIME ZONE $3 AS month_start,
+			NOW() - INTERVAL '1 hour' AS hour_start
+	),
+	outgoing AS (
+		SELECT 'transfer' AS action, amount, currency, created_at FROM transfers
+		WHERE sender_id = $1 AND status <> $4 AND id::text <> $7
+		UNION ALL
+		SELECT 'transfer', amount, currency, reversed_at FROM transfers
+		WHERE recipient_id = $1 AND status = $8
+		UNION ALL
+		SELECT 'withdrawal', amount, currency, created_at FROM payments
+		WHERE user_id = $1 AND kind = $5 AND status <> $6
+	)
+	SELECT o.action,
+		COALESCE(SUM(o.amount) FILTER (WHERE o.currency = $2 AND o.created_at >= b.day_start), 0),
+		COALESCE(SUM(o.amount) FILTER (WHERE o.currency = $2 AND o.created_at >= b.month_start), 0),
+		COUNT(*) FILTER (WHERE o.created_at >= b.hour_start)
+	FROM outgoing o, bounds b
+	WHERE o.created_at >= LEAST(b.month_start, b.hour_start)
+	GROUP BY o.action`,
+		userID, currency, timezone,
+		types.TransferFailed, types.PaymentWithdrawal, types.PaymentFailed, excludeTransferID,
+		types.TransferReversed,
+	)
+	if err != nil {
+		return types.LimitUsage{}, fmt.Errorf("failed to query limit usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage types.LimitUsage
+	for rows.Next() {
+		var (
+			action string
+			window types.LimitWindow
+		)
+		if err := rows.Scan(&action, &window.Day, &window.Month, &window.LastHour); err != nil {
+			return types.LimitUsage{}, err
+		}
+		if types.LimitAction(action) == types.LimitTransfer {
+			usage.Transfers = window
+		} else {
+			usage.Withdrawals = window
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return types.LimitUsage{}, err
+	}
+
+	if recipientID != "" {
+		err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM transfers
+		WHERE sender_id = $1 AND recipient_id = $2 AND status = $3)`,
+			userID, recipientID, types.TransferCompleted,
+		).Scan(&usage.KnownRecipient)
+		if err != nil {
+			return types.LimitUsage{}, fmt.Errorf("failed to check recipient: %w", err)
+		}
+	}
+	return usage, nil
+}
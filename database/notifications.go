@@ -0,0 +1,94 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const notificationSchema = `
+	CREATE TABLE IF NOT EXISTS notifications (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		kind VARCHAR(50) NOT NULL,
+		title TEXT NOT NULL,
+		body TEXT NOT NULL DEFAULT '',
+		data JSONB,
+		read_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, created_at DESC);
+	`
+
+const maxNotifications = 100
+
+func (s *Storage) CreateNotification(n *types.Notification) error {
+	var data string
+	if len(n.Data) > 0 {
+		raw, err := json.Marshal(n.Data)
+		if err != nil {
+			return err
+		}
+		data = string(raw)
+	}
+
+	err := s.db.QueryRow(`INSERT INTO notifications (user_id, kind, title, body, data)
+	VALUES ($1, $2, $3, $4, NULLIF($5, '')::jsonb) RETURNING id, created_at::text`,
+		n.UserID, n.Kind, n.Title, n.Body, data,
+	).Scan(&n.ID, &n.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+	return nil
+}
+
+// GetNotifications returns the user's most recent in-app notifications,
+// newest first.
+func (s *Storage) GetNotifications(userID string, unreadOnly bool) ([]types.Notification, error) {
+	rows, err := s.db.Query(`SELECT id, user_id, kind, title, body, data, read_at IS NOT NULL, created_at::text
+	FROM notifications
+	WHERE user_id = $1 AND ($2 = FALSE OR read_at IS NULL)
+	ORDER BY created_at DESC
+	LIMIT $3`, userID, unreadOnly, maxNotifications)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []types.Notification{}
+	for rows.Next() {
+		var n types.Notification
+		var data []byte
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Title, &n.Body, &data, &n.Read, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &n.Data); err != nil {
+				return nil, err
+			}
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (s *Storage) MarkNotificationRead(userID, id string) error {
+	result, err := s.db.Exec(`UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+	WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update notification: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
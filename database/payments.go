@@ -0,0 +1,299 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ayikoandrew/server/payments"
+	"github.com/Ayikoandrew/server/types"
+)
+
+var ErrInvalidPaymentState = errors.New("payment is not in a valid state for this operation")
+
+// paymentSchema tracks top-ups and withdrawals through external providers.
+//
+// A top-up only touches the ledger once the provider confirms it, moving
+// the money from the provider float into the wallet. A withdrawal holds the
+// amount in the suspense account as soon as it is accepted, so it cannot
+// be spent twice while the payout is under way; the confirmed payout moves
+// it on to the provider float, and a failed one releases the hold back to
+// the wallet.
+const paymentSchema = `
+	CREATE TABLE IF NOT EXISTS payments (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		kind VARCHAR(20) NOT NULL,
+		amount BIGINT NOT NULL CHECK (amount > 0),
+		currency VARCHAR(3) NOT NULL,
+		phone VARCHAR(20) NOT NULL,
+		provider VARCHAR(50) NOT NULL,
+		provider_ref VARCHAR(255),
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		failure_reason TEXT NOT NULL DEFAULT '',
+		hold_entry_id UUID,
+		entry_id UUID,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		updated_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id),
+		FOREIGN KEY (hold_entry_id) REFERENCES journal_entries (id),
+		FOREIGN KEY (entry_id) REFERENCES journal_entries (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_payments_user ON payments (user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_payments_open ON payments (updated_at) WHERE status IN ('pending', 'processing');
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_provider_ref
+		ON payments (provider, provider_ref) WHERE provider_ref IS NOT NULL;
+	`
+
+const paymentColumns = `id, user_id, kind, amount, currency, phone, provider, COALESCE(provider_ref, ''),
+	status, failure_reason, created_at::text, updated_at::text`
+
+func scanPayment(row interface{ Scan(...any) error }) (types.Payment, error) {
+	var p types.Payment
+	err := row.Scan(
+		&p.ID,
+		&p.UserID,
+		&p.Kind,
+		&p.Amount,
+		&p.Currency,
+		&p.Phone,
+		&p.Provider,
+		&p.ProviderRef,
+		&p.Status,
+		&p.FailureReason,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	return p, err
+}
+
+// CreatePayment records a pending top-up or withdrawal. For a withdrawal
+// the amount is moved from the wallet into suspense in the same
+// transaction, failing with a *LimitError when it is over the user's
+// limits and with ledger.ErrInsufficientFunds when the wallet cannot cover
+// it.
+func (s *Storage) CreatePayment(p *types.Payment) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if p.Kind == types.PaymentWithdrawal {
+		err := enforceLimits(tx, types.LimitAttempt{
+			UserID:   p.UserID,
+			Action:   types.LimitWithdrawal,
+			Amount:   p.Amount,
+			Currency: p.Currency,
+		}, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRow(`INSERT INTO payments (user_id, kind, amount, currency, phone, provider)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, status, created_at::text, updated_at::text`,
+		p.UserID,
+		p.Kind,
+		p.Amount,
+		p.Currency,
+		p.Phone,
+		p.Provider,
+	).Scan(&p.ID, &p.Status, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	if p.Kind == types.PaymentWithdrawal {
+		wallet, err := openWallet(tx, p.UserID, p.Currency)
+		if err != nil {
+			return err
+		}
+		suspense, err := openSystemAccount(tx, types.AccountSuspense, p.Currency)
+		if err != nil {
+			return err
+		}
+		hold, err := moveMoney(tx, "withdrawal_hold", "withdrawal:"+p.ID+":hold",
+			"Withdrawal to "+p.Phone, wallet.ID, suspense.ID, p.Amount, p.Currency)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE payments SET hold_entry_id = $2 WHERE id = $1`, p.ID, hold.ID); err != nil {
+			return fmt.Errorf("failed to update payment: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// MarkPaymentProcessing records that the provider accepted a pending
+// payment under providerRef.
+func (s *Storage) MarkPaymentProcessing(id, providerRef string) error {
+	result, err := s.db.Exec(`UPDATE payments SET status = $2, provider_ref = $3, updated_at = NOW()
+	WHERE id = $1 AND status = $4`, id, types.PaymentProcessing, providerRef, types.PaymentPending)
+	if err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	if err := expectOneRow(result); err != nil {
+		return ErrInvalidPaymentState
+	}
+	return nil
+}
+
+// SettlePayment applies a provider's final outcome and posts the matching
+// ledger entry. Settling a payment again with the outcome it already has
+// does nothing, so duplicate callbacks are harmless; a conflicting outcome
+// fails with ErrInvalidPaymentState.
+func (s *Storage) SettlePayment(id string, status types.PaymentStatus, reason string) (*types.Payment, error) {
+	if !payments.Final(status) {
+		return nil, fmt.Errorf("payment can only be settled as succeeded or failed")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p, err := scanPayment(tx.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == status {
+		return &p, nil
+	}
+	if !payments.CanTransition(p.Status, status) {
+		return nil, ErrInvalidPaymentState
+	}
+
+	entry, err := settlePayment(tx, p, status)
+	if err != nil {
+		return nil, err
+	}
+	if status == types.PaymentSucceeded {
+		reason = ""
+	}
+
+	err = tx.QueryRow(`UPDATE payments SET status = $2, failure_reason = $3, entry_id = $4, updated_at = NOW()
+	WHERE id = $1 RETURNING status, failure_reason, updated_at::text`,
+		p.ID, status, reason, entry,
+	).Scan(&p.Status, &p.FailureReason, &p.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to settle payment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &p, nil
+}
+
+// settlePayment posts the ledger entry for a payment's outcome and returns
+// its ID, or nil when there is nothing to post.
+func settlePayment(tx *sql.Tx, p types.Payment, status types.PaymentStatus) (*string, error) {
+	if p.Kind == types.PaymentTopUp && status == types.PaymentFailed {
+		return nil, nil
+	}
+
+	wallet, err := openWallet(tx, p.UserID, p.Currency)
+	if err != nil {
+		return nil, err
+	}
+	float, err := openSystemAccount(tx, types.AccountProviderFloat, p.Currency)
+	if err != nil {
+		return nil, err
+	}
+	suspense, err := openSystemAccount(tx, types.AccountSuspense, p.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry *types.JournalEntry
+	switch {
+	case p.Kind == types.PaymentTopUp:
+		entry, err = moveMoney(tx, "topup", "topup:"+p.ID, "Top-up from "+p.Phone,
+			float.ID, wallet.ID, p.Amount, p.Currency)
+	case status == types.PaymentSucceeded:
+		entry, err = moveMoney(tx, "withdrawal", "withdrawal:"+p.ID, "Withdrawal to "+p.Phone,
+			suspense.ID, float.ID, p.Amount, p.Currency)
+	default:
+		entry, err = moveMoney(tx, "withdrawal_release", "withdrawal:"+p.ID+":release", "Failed withdrawal to "+p.Phone,
+			suspense.ID, wallet.ID, p.Amount, p.Currency)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry.ID, nil
+}
+
+func (s *Storage) GetPayments(userID string, kind types.PaymentKind, limit int) ([]types.Payment, error) {
+	rows, err := s.db.Query(`SELECT `+paymentColumns+` FROM payments
+	WHERE user_id = $1 AND ($2 = '' OR kind = $2)
+	ORDER BY created_at DESC
+	LIMIT $3`, userID, kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %w", err)
+	}
+	defer rows.Close()
+	return scanPayments(rows)
+}
+
+func (s *Storage) GetPayment(userID, id string) (*types.Payment, error) {
+	p, err := scanPayment(s.db.QueryRow(`SELECT `+paymentColumns+` FROM payments
+	WHERE id = $1 AND user_id = $2`, id, userID))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPaymentByID looks a payment up for any user, for admin tools.
+func (s *Storage) GetPaymentByID(id string) (*types.Payment, error) {
+	p, err := scanPayment(s.db.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *Storage) GetPaymentByProviderRef(provider, providerRef string) (*types.Payment, error) {
+	p, err := scanPayment(s.db.QueryRow(`SELECT `+paymentColumns+` FROM payments
+	WHERE provider = $1 AND provider_ref = $2`, provider, providerRef))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetOpenPayments lists payments that have not been settled and have not
+// changed for at least age, oldest first.
+func (s *Storage) GetOpenPayments(age time.Duration, limit int) ([]types.Payment, error) {
+	rows, err := s.db.Query(`SELECT `+paymentColumns+` FROM payments
+	WHERE status IN ($1, $2) AND updated_at < NOW() - $3 * INTERVAL '1 second'
+	ORDER BY updated_at
+	LIMIT $4`, types.PaymentPending, types.PaymentProcessing, age.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query open payments: %w", err)
+	}
+	defer rows.Close()
+	return scanPayments(rows)
+}
+
+func scanPayments(rows *sql.Rows) ([]types.Payment, error) {
+	list := []types.Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
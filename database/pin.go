@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/pin"
+	"github.com/Ayikoandrew/server/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrPINNotSet     = errors.New("transaction PIN has not been set")
+	ErrPINAlreadySet = errors.New("transaction PIN is already set")
+	ErrWrongPIN      = errors.New("incorrect transaction PIN")
+	ErrPINLocked     = errors.New("transaction PIN is locked after too many wrong attempts")
+)
+
+// pinSchema keeps each user's transaction PIN apart from their login
+// password, along with the run of wrong attempts that leads to a lockout.
+const pinSchema = `
+	CREATE TABLE IF NOT EXISTS transaction_pins (
+		user_id UUID PRIMARY KEY,
+		pin_hash BYTEA NOT NULL,
+		failed_attempts INTEGER NOT NULL DEFAULT 0,
+		locked_until TIMESTAMPTZ,
+		updated_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+	);
+	`
+
+func (s *Storage) GetPINStatus(userID string) (*types.PINStatus, error) {
+	status := &types.PINStatus{AttemptsLeft: pin.MaxAttempts}
+	err := s.db.QueryRow(`SELECT TRUE, $2 - failed_attempts,
+		CASE WHEN locked_until > NOW() THEN locked_until::text ELSE '' END
+	FROM transaction_pins WHERE user_id = $1`, userID, pin.MaxAttempts,
+	).Scan(&status.Set, &status.AttemptsLeft, &status.LockedUntil)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load PIN status: %w", err)
+	}
+	return status, nil
+}
+
+// SetPIN stores a hashed PIN. Without replace it fails with
+// ErrPINAlreadySet if the user has one; with replace it overwrites it and
+// clears any lockout.
+func (s *Storage) SetPIN(userID string, hash []byte, replace bool) error {
+	query := `INSERT INTO transaction_pins (user_id, pin_hash) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO NOTHING`
+	if replace {
+		query = `INSERT INTO transaction_pins (user_id, pin_hash) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET pin_hash = EXCLUDED.pin_hash,
+			failed_attempts = 0, locked_until = NULL, updated_at = NOW()`
+	}
+
+	result, err := s.db.Exec(query, userID, hash)
+	if err != nil {
+		return fmt.Errorf("failed to store PIN: %w", err)
+	}
+	if err := expectOneRow(result); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPINAlreadySet
+		}
+		return err
+	}
+	return nil
+}
+
+// VerifyPIN checks a user's PIN. A wrong one fails with ErrWrongPIN and
+// counts towards the lockout; once pin.MaxAttempts wrong PINs have been
+// entered in a row the PIN is locked for pin.LockoutDuration and every
+// attempt fails with ErrPINLocked until then. A correct PIN resets the
+// count.
+func (s *Storage) VerifyPIN(userID, p string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		hash     []byte
+		attempts int
+		locked   bool
+	)
+	err = tx.QueryRow(`SELECT pin_hash, failed_attempts, COALESCE(locked_until > NOW(), FALSE)
+	FROM transaction_pins WHERE user_id = $1 FOR UPDATE`, userID).Scan(&hash, &attempts, &locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPINNotSet
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load PIN: %w", err)
+	}
+	if locked {
+		return ErrPINLocked
+	}
+
+	if pin.Matches(hash, p) {
+		if attempts > 0 {
+			if _, err := tx.Exec(`UPDATE transaction_pins SET failed_attempts = 0, locked_until = NULL
+			WHERE user_id = $1`, userID); err != nil {
+				return fmt.Errorf("failed to reset PIN attempts: %w", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil
+	}
+
+	attempts++
+	var wrong error
+	if attempts >= pin.MaxAttempts {
+		_, err = tx.Exec(`UPDATE transaction_pins SET failed_attempts = 0,
+			locked_until = NOW() + $2 * INTERVAL '1 second'
+		WHERE user_id = $1`, userID, pin.LockoutDuration.Seconds())
+		wrong = ErrPINLocked
+	} else {
+		_, err = tx.Exec(`UPDATE transaction_pins SET failed_attempts = $2 WHERE user_id = $1`, userID, attempts)
+		wrong = fmt.Errorf("%w, %d attempts left", ErrWrongPIN, pin.MaxAttempts-attempts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record PIN attempt: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return wrong
+}
+
+// CheckPassword compares password with the user's login password. It
+// returns bcrypt.ErrMismatchedHashAndPassword when they differ.
+func (s *Storage) CheckPassword(userID, password string) error {
+	var hash []byte
+	if err := s.db.QueryRow(`SELECT passwordHash FROM users WHERE id = $1`, userID).Scan(&hash); err != nil {
+		return err
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password))
+}
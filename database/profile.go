@@ -0,0 +1,78 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+func (s *Storage) GetUser(userID string) (*types.User, error) {
+	return s.getUser(`id = $1`, userID)
+}
+
+// GetUserByEmail looks a user up by email address, ignoring case.
+func (s *Storage) GetUserByEmail(email string) (*types.User, error) {
+	return s.getUser(`LOWER(email) = LOWER($1)`, email)
+}
+
+var ErrAmbiguousPhone = errors.New("phone number belongs to more than one account")
+
+const phoneMatch = `regexp_replace(phoneNumber, '[^0-9]', '', 'g') = regexp_replace($1, '[^0-9]', '', 'g')
+	AND COALESCE(phoneNumber, '') <> ''`
+
+// GetUserByPhone finds a user by phone number, comparing digits only so
+// spaces, dashes and a leading + do not matter. A number shared by several
+// accounts matches none of them.
+func (s *Storage) GetUserByPhone(phone string) (*types.User, error) {
+	var matches int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE `+phoneMatch, phone).Scan(&matches); err != nil {
+		return nil, err
+	}
+	if matches > 1 {
+		return nil, ErrAmbiguousPhone
+	}
+	return s.getUser(phoneMatch, phone)
+}
+
+func (s *Storage) getUser(where string, arg any) (*types.User, error) {
+	query := `SELECT id, firstName, lastName, phoneNumber, email, timezone, home_currency, role, kyc_tier
+	FROM users WHERE ` + where
+
+	var user types.User
+	err := s.db.QueryRow(query, arg).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.PhoneNumber,
+		&user.Email,
+		&user.Timezone,
+		&user.HomeCurrency,
+		&user.Role,
+		&user.KYCTier,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *Storage) UpdateProfile(userID string, update types.ProfileUpdate) error {
+	result, err := s.db.Exec(`UPDATE users SET timezone = COALESCE($2, timezone),
+	home_currency = COALESCE($3, home_currency) WHERE id = $1`,
+		userID, update.Timezone, update.HomeCurrency,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
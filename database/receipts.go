@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const receiptSchema = `
+	CREATE TABLE IF NOT EXISTS receipts (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		expense_id UUID NOT NULL,
+		user_id UUID NOT NULL,
+		blob_key TEXT NOT NULL,
+		filename TEXT NOT NULL DEFAULT '',
+		content_type VARCHAR(100) NOT NULL,
+		size BIGINT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_receipts_expense ON receipts (expense_id);
+	`
+
+const receiptColumns = `id, expense_id, user_id, blob_key, filename, content_type, size, created_at::text`
+
+func scanReceipt(row interface{ Scan(...any) error }) (types.Receipt, error) {
+	var r types.Receipt
+	err := row.Scan(
+		&r.ID,
+		&r.ExpenseID,
+		&r.UserID,
+		&r.BlobKey,
+		&r.Filename,
+		&r.ContentType,
+		&r.Size,
+		&r.CreatedAt,
+	)
+	return r, err
+}
+
+// CreateReceipt records an uploaded receipt. It fails with sql.ErrNoRows if
+// the expense does not belong to the user.
+func (s *Storage) CreateReceipt(receipt *types.Receipt) error {
+	query := `INSERT INTO receipts (expense_id, user_id, blob_key, filename, content_type, size)
+	SELECT id, user_id, $3, $4, $5, $6 FROM expenses WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+	RETURNING id, created_at::text`
+
+	err := s.db.QueryRow(query,
+		receipt.ExpenseID,
+		receipt.UserID,
+		receipt.BlobKey,
+		receipt.Filename,
+		receipt.ContentType,
+		receipt.Size,
+	).Scan(&receipt.ID, &receipt.CreatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		slog.Error("Error inserting receipt", "error", err)
+		return fmt.Errorf("failed to create receipt: %w", err)
+	}
+	return err
+}
+
+func (s *Storage) GetReceipts(userID, expenseID string) ([]types.Receipt, error) {
+	rows, err := s.db.Query(`SELECT `+receiptColumns+` FROM receipts
+	WHERE expense_id = $1 AND user_id = $2 ORDER BY created_at`, expenseID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	receipts := []types.Receipt{}
+	for rows.Next() {
+		r, err := scanReceipt(rows)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, rows.Err()
+}
+
+func (s *Storage) GetReceipt(userID, id string) (*types.Receipt, error) {
+	r, err := scanReceipt(s.db.QueryRow(`SELECT `+receiptColumns+` FROM receipts
+	WHERE id = $1 AND user_id = $2`, id, userID))
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// DeleteReceipt removes the receipt row and returns its blob key so the
+// caller can delete the file.
+func (s *Storage) DeleteReceipt(userID, id string) (string, error) {
+	var key string
+	err := s.db.QueryRow(`DELETE FROM receipts WHERE id = $1 AND user_id = $2 RETURNING blob_key`,
+		id, userID,
+	).Scan(&key)
+	return key, err
+}
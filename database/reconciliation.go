@@ -0,0 +1,257 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var ErrItemResolved = errors.New("reconciliation item has already been resolved")
+
+// reconciliationSchema keeps each settlement report that was checked and
+// every discrepancy it turned up. Items still open form the review queue.
+const reconciliationSchema = `
+	CREATE TABLE IF NOT EXISTS reconciliation_runs (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		provider VARCHAR(50) NOT NULL,
+		filename TEXT NOT NULL DEFAULT '',
+		period_from DATE NOT NULL,
+		period_to DATE NOT NULL,
+		row_count INTEGER NOT NULL DEFAULT 0,
+		matched_count INTEGER NOT NULL DEFAULT 0,
+		created_by UUID NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (created_by) REFERENCES users (id)
+	);
+
+	CREATE TABLE IF NOT EXISTS reconciliation_items (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		run_id UUID NOT NULL,
+		kind VARCHAR(30) NOT NULL,
+		payment_id UUID,
+		provider_ref VARCHAR(255) NOT NULL DEFAULT '',
+		line INTEGER NOT NULL DEFAULT 0,
+		currency VARCHAR(3) NOT NULL DEFAULT '',
+		expected_amount BIGINT NOT NULL DEFAULT 0,
+		reported_amount BIGINT NOT NULL DEFAULT 0,
+		our_status VARCHAR(20) NOT NULL DEFAULT '',
+		reported_status VARCHAR(20) NOT NULL DEFAULT '',
+		resolution VARCHAR(20) NOT NULL DEFAULT 'open',
+		note TEXT NOT NULL DEFAULT '',
+		resolved_by UUID,
+		resolved_at TIMESTAMPTZ,
+		FOREIGN KEY (run_id) REFERENCES reconciliation_runs (id) ON DELETE CASCADE,
+		FOREIGN KEY (payment_id) REFERENCES payments (id),
+		FOREIGN KEY (resolved_by) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_reconciliation_items_run ON reconciliation_items (run_id);
+	CREATE INDEX IF NOT EXISTS idx_reconciliation_items_open ON reconciliation_items (id) WHERE resolution = 'open';
+	`
+
+const reconciliationRunColumns = `id, provider, filename, period_from::text, period_to::text,
+	row_count, matched_count, created_by, created_at::text`
+
+func scanReconciliationRun(row interface{ Scan(...any) error }) (types.ReconciliationRun, error) {
+	var run types.ReconciliationRun
+	err := row.Scan(
+		&run.ID,
+		&run.Provider,
+		&run.Filename,
+		&run.From,
+		&run.To,
+		&run.RowCount,
+		&run.Matched,
+		&run.CreatedBy,
+		&run.CreatedAt,
+	)
+	return run, err
+}
+
+const reconciliationItemColumns = `i.id, i.run_id, i.kind, COALESCE(i.payment_id::text, ''), i.provider_ref,
+	i.line, i.currency, i.expected_amount, i.reported_amount, i.our_status, i.reported_status, i.resolution,
+	i.note, COALESCE(i.resolved_by::text, ''), COALESCE(i.resolved_at::text, '')`
+
+func scanReconciliationItem(row interface{ Scan(...any) error }) (types.ReconciliationItem, error) {
+	var item types.ReconciliationItem
+	err := row.Scan(
+		&item.ID,
+		&item.RunID,
+		&item.Kind,
+		&item.PaymentID,
+		&item.ProviderRef,
+		&item.Line,
+		&item.Currency,
+		&item.ExpectedAmount,
+		&item.ReportedAmount,
+		&item.OurStatus,
+		&item.ReportedStatus,
+		&item.Resolution,
+		&item.Note,
+		&item.ResolvedBy,
+		&item.ResolvedAt,
+	)
+	return item, err
+}
+
+// GetProviderPayments lists the payments made through provider that were
+// created between from and to inclusive, both given as YYYY-MM-DD in UTC.
+func (s *Storage) GetProviderPayments(provider, from, to string) ([]types.Payment, error) {
+	rows, err := s.db.Query(`SELECT `+paymentColumns+` FROM payments
+	WHERE provider = $1 AND (created_at AT TIME ZONE 'UTC')::date BETWEEN $2 AND $3
+	ORDER BY created_at`, provider, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %w", err)
+	}
+	defer rows.Close()
+	return scanPayments(rows)
+}
+
+// CreateReconciliation stores a run together with its items and fills in
+// their IDs.
+func (s *Storage) CreateReconciliation(run *types.ReconciliationRun) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO reconciliation_runs
+	(provider, filename, period_from, period_to, row_count, matched_count, created_by)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at::text`,
+		run.Provider,
+		run.Filename,
+		run.From,
+		run.To,
+		run.RowCount,
+		run.Matched,
+		run.CreatedBy,
+	).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create reconciliation: %w", err)
+	}
+
+	for i := range run.Items {
+		item := &run.Items[i]
+		item.RunID = run.ID
+		err := tx.QueryRow(`INSERT INTO reconciliation_items
+		(run_id, kind, payment_id, provider_ref, line, currency, expected_amount, reported_amount,
+		our_status, reported_status, resolution, note, resolved_at)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+			CASE WHEN $11 = 'open' THEN NULL ELSE NOW() END)
+		RETURNING id, COALESCE(resolved_at::text, '')`,
+			item.RunID,
+			item.Kind,
+			item.PaymentID,
+			item.ProviderRef,
+			item.Line,
+			item.Currency,
+			item.ExpectedAmount,
+			item.ReportedAmount,
+			item.OurStatus,
+			item.ReportedStatus,
+			item.Resolution,
+			item.Note,
+		).Scan(&item.ID, &item.ResolvedAt)
+		if err != nil {
+			return fmt.Errorf("failed to store reconciliation item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) GetReconciliations(limit int) ([]types.ReconciliationRun, error) {
+	rows, err := s.db.Query(`SELECT `+reconciliationRunColumns+` FROM reconciliation_runs
+	ORDER BY created_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliations: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []types.ReconciliationRun{}
+	for rows.Next() {
+		run, err := scanReconciliationRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (s *Storage) GetReconciliation(id string) (*types.ReconciliationRun, error) {
+	run, err := scanReconciliationRun(s.db.QueryRow(`SELECT `+reconciliationRunColumns+` FROM reconciliation_runs
+	WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	run.Items, err = s.queryReconciliationItems(`SELECT `+reconciliationItemColumns+` FROM reconciliation_items i
+	WHERE i.run_id = $1 ORDER BY i.kind, i.line`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetReviewQueue lists the open items from every run, oldest first.
+func (s *Storage) GetReviewQueue(limit int) ([]types.ReconciliationItem, error) {
+	return s.queryReconciliationItems(`SELECT `+reconciliationItemColumns+` FROM reconciliation_items i
+	JOIN reconciliation_runs r ON r.id = i.run_id
+	WHERE i.resolution = $1
+	ORDER BY r.created_at, i.line
+	LIMIT $2`, types.ResolutionOpen, limit)
+}
+
+func (s *Storage) GetReconciliationItem(id string) (*types.ReconciliationItem, error) {
+	item, err := scanReconciliationItem(s.db.QueryRow(`SELECT `+reconciliationItemColumns+` FROM reconciliation_items i
+	WHERE i.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ResolveReconciliationItem closes an open item, recording who closed it
+// and why. It fails with ErrItemResolved when the item is already closed.
+func (s *Storage) ResolveReconciliationItem(id string, resolution types.ReconciliationResolution, note, adminID string) (*types.ReconciliationItem, error) {
+	item, err := scanReconciliationItem(s.db.QueryRow(`UPDATE reconciliation_items i
+	SET resolution = $2, note = $3, resolved_by = $4, resolved_at = NOW()
+	WHERE i.id = $1 AND i.resolution = $5
+	RETURNING `+reconciliationItemColumns, id, resolution, note, adminID, types.ResolutionOpen))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetReconciliationItem(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrItemResolved
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reconciliation item: %w", err)
+	}
+	return &item, nil
+}
+
+func (s *Storage) queryReconciliationItems(query string, args ...any) ([]types.ReconciliationItem, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reconciliation items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []types.ReconciliationItem{}
+	for rows.Next() {
+		item, err := scanReconciliationItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,300 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const recurringSchema = `
+	CREATE TABLE IF NOT EXISTS recurring_expenses (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		amount NUMERIC(14, 2) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		category VARCHAR(100) NOT NULL DEFAULT '',
+		payment_method VARCHAR(100) NOT NULL DEFAULT '',
+		frequency VARCHAR(10) NOT NULL,
+		interval_count INTEGER NOT NULL DEFAULT 1,
+		day_of_month INTEGER NOT NULL DEFAULT 0,
+		start_date DATE NOT NULL,
+		end_date DATE,
+		occurrence_count INTEGER NOT NULL DEFAULT 0,
+		last_generated DATE,
+		active BOOLEAN DEFAULT TRUE,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_recurring_expenses_user ON recurring_expenses (user_id);
+
+	CREATE TABLE IF NOT EXISTS recurring_exceptions (
+		recurring_id UUID NOT NULL,
+		occurrence_date DATE NOT NULL,
+		skip BOOLEAN NOT NULL DEFAULT FALSE,
+		amount NUMERIC(14, 2),
+		description TEXT,
+		PRIMARY KEY (recurring_id, occurrence_date),
+		FOREIGN KEY (recurring_id) REFERENCES recurring_expenses (id) ON DELETE CASCADE
+	);
+	`
+
+const recurringColumns = `id, user_id, entry_type, income_kind, source, amount, description, category, payment_method,
+	frequency, interval_count, day_of_month, start_date::text, COALESCE(end_date::text, ''),
+	occurrence_count, COALESCE(last_generated::text, ''), active`
+
+func scanRecurringExpense(row interface{ Scan(...any) error }) (types.RecurringExpense, error) {
+	var r types.RecurringExpense
+	err := row.Scan(
+		&r.ID,
+		&r.UserID,
+		&r.Type,
+		&r.IncomeKind,
+		&r.Source,
+		&r.Amount,
+		&r.Description,
+		&r.Category,
+		&r.PaymentMethod,
+		&r.Frequency,
+		&r.Interval,
+		&r.DayOfMonth,
+		&r.StartDate,
+		&r.EndDate,
+		&r.Count,
+		&r.LastGenerated,
+		&r.Active,
+	)
+	return r, err
+}
+
+func (s *Storage) CreateRecurringExpense(r *types.RecurringExpense) error {
+	query := `INSERT INTO recurring_expenses
+	(user_id, amount, description, category, payment_method, frequency,
+	interval_count, day_of_month, start_date, end_date, occurrence_count, entry_type, income_kind, source)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::date, $11, $12, $13, $14)
+	RETURNING id, active`
+
+	err := s.db.QueryRow(query,
+		r.UserID,
+		r.Amount,
+		r.Description,
+		r.Category,
+		r.PaymentMethod,
+		r.Frequency,
+		r.Interval,
+		r.DayOfMonth,
+		r.StartDate,
+		r.EndDate,
+		r.Count,
+		r.Type,
+		r.IncomeKind,
+		r.Source,
+	).Scan(&r.ID, &r.Active)
+	if err != nil {
+		slog.Error("Error inserting recurring expense", "error", err)
+		return fmt.Errorf("failed to create recurring expense: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) GetRecurringExpenses(userID string) ([]types.RecurringExpense, error) {
+	query := `SELECT ` + recurringColumns + ` FROM recurring_expenses
+	WHERE user_id = $1 ORDER BY created_at DESC`
+	return s.queryRecurringExpenses(query, userID)
+}
+
+func (s *Storage) GetActiveRecurringExpenses() ([]types.RecurringExpense, error) {
+	query := `SELECT ` + recurringColumns + ` FROM recurring_expenses
+	WHERE active = TRUE AND start_date <= CURRENT_DATE`
+	return s.queryRecurringExpenses(query)
+}
+
+func (s *Storage) queryRecurringExpenses(query string, args ...any) ([]types.RecurringExpense, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring expenses: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []types.RecurringExpense{}
+	for rows.Next() {
+		r, err := scanRecurringExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, r)
+	}
+	return templates, rows.Err()
+}
+
+func (s *Storage) DeactivateRecurringExpense(userID, id string) error {
+	result, err := s.db.Exec(
+		`UPDATE recurring_expenses SET active = FALSE WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate recurring expense: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Storage) GetRecurringExceptions(recurringID string) (map[string]types.RecurringException, error) {
+	query := `SELECT recurring_id, occurrence_date::text, skip, amount, description
+	FROM recurring_exceptions WHERE recurring_id = $1`
+
+	rows, err := s.db.Query(query, recurringID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring exceptions: %w", err)
+	}
+	defer rows.Close()
+
+	exceptions := make(map[string]types.RecurringException)
+	for rows.Next() {
+		var (
+			ex          types.RecurringException
+			amount      sql.NullFloat64
+			description sql.NullString
+		)
+		if err := rows.Scan(&ex.RecurringID, &ex.OccurrenceDate, &ex.Skip, &amount, &description); err != nil {
+			return nil, err
+		}
+		if amount.Valid {
+			ex.Amount = &amount.Float64
+		}
+		if description.Valid {
+			ex.Description = &description.String
+		}
+		exceptions[ex.OccurrenceDate] = ex
+	}
+	return exceptions, rows.Err()
+}
+
+// SetRecurringException records an override for one occurrence. If the
+// occurrence was already generated, the concrete expense is moved to the
+// trash or updated to match; a generated income entry is removed or
+// updated.
+func (s *Storage) SetRecurringException(userID string, ex *types.RecurringException) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var owner string
+	err = tx.QueryRow(
+		`SELECT user_id FROM recurring_expenses WHERE id = $1 AND user_id = $2`,
+		ex.RecurringID, userID,
+	).Scan(&owner)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO recurring_exceptions
+	(recurring_id, occurrence_date, skip, amount, description)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (recurring_id, occurrence_date)
+	DO UPDATE SET skip = EXCLUDED.skip, amount = EXCLUDED.amount, description = EXCLUDED.description`,
+		ex.RecurringID, ex.OccurrenceDate, ex.Skip, ex.Amount, ex.Description,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store recurring exception: %w", err)
+	}
+
+	const occurrence = "e.recurring_id = $1 AND e.occurrence_date = $2"
+	if ex.Skip {
+		if _, err := softDelete(tx, types.SourceRecurring, userID, occurrence, ex.RecurringID, ex.OccurrenceDate); err != nil {
+			return err
+		}
+	} else {
+		before, err := lockExpenses(tx, occurrence, ex.RecurringID, ex.OccurrenceDate)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE expenses
+		SET amount = COALESCE($3, amount), description = COALESCE($4, description), updated_at = NOW()
+		WHERE recurring_id = $1 AND occurrence_date = $2 AND deleted_at IS NULL`,
+			ex.RecurringID, ex.OccurrenceDate, ex.Amount, ex.Description,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to apply recurring exception: %w", err)
+		}
+		if err := recordUpdates(tx, types.SourceRecurring, userID, before); err != nil {
+			return err
+		}
+	}
+
+	if err := applyIncomeException(tx, ex); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// InsertRecurringOccurrences stores generated expenses and advances the
+// template's high-water mark in one transaction. Occurrences that already
+// exist are ignored, which makes re-running the generator safe.
+func (s *Storage) InsertRecurringOccurrences(recurringID string, expenses []types.Expense, generatedThrough string, finished bool) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO expenses
+	(user_id, amount, expense_date, description, category, payment_method, recurring_id, occurrence_date, currency)
+	SELECT $1, $2, $3, $4, $5, $6, $7, $3, home_currency FROM users WHERE id = $1
+	ON CONFLICT (recurring_id, occurrence_date) WHERE recurring_id IS NOT NULL DO NOTHING
+	RETURNING id, currency`
+
+	var inserted []types.Expense
+	for _, e := range expenses {
+		err := tx.QueryRow(query,
+			e.UserID,
+			e.Amount,
+			e.Date,
+			e.Description,
+			e.Category,
+			e.PaymentMethod,
+			recurringID,
+		).Scan(&e.ID, &e.Currency)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert recurring occurrence: %w", err)
+		}
+		e.RecurringID = recurringID
+		inserted = append(inserted, e)
+	}
+
+	if err := recordCreated(tx, types.SourceRecurring, "", inserted); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE recurring_expenses SET last_generated = $2, active = NOT $3 WHERE id = $1`,
+		recurringID, generatedThrough, finished,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update recurring expense: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(inserted), nil
+}
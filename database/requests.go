@@ -0,0 +1,344 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var ErrInvalidMoneyRequestState = errors.New("request is not in a valid state for this operation")
+
+// moneyRequestSchema tracks requests for money and invoices between users.
+// Paying one creates an ordinary transfer. Reminders are scheduled when the
+// request is created and marked sent as the maintenance job delivers them.
+const moneyRequestSchema = `
+	CREATE TABLE IF NOT EXISTS money_requests (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		kind VARCHAR(20) NOT NULL,
+		requester_id UUID NOT NULL,
+		payer_id UUID NOT NULL,
+		payer VARCHAR(255) NOT NULL,
+		amount BIGINT NOT NULL CHECK (amount > 0),
+		currency VARCHAR(3) NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		due_date DATE,
+		expires_at TIMESTAMPTZ NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		transfer_id UUID,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		responded_at TIMESTAMPTZ,
+		FOREIGN KEY (requester_id) REFERENCES users (id),
+		FOREIGN KEY (payer_id) REFERENCES users (id),
+		FOREIGN KEY (transfer_id) REFERENCES transfers (id),
+		CHECK (requester_id <> payer_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_money_requests_requester ON money_requests (requester_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_money_requests_payer ON money_requests (payer_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_money_requests_pending ON money_requests (expires_at) WHERE status = 'pending';
+
+	CREATE TABLE IF NOT EXISTS money_request_items (
+		request_id UUID NOT NULL,
+		position INTEGER NOT NULL,
+		description TEXT NOT NULL,
+		quantity BIGINT NOT NULL CHECK (quantity > 0),
+		unit_amount BIGINT NOT NULL CHECK (unit_amount > 0),
+		PRIMARY KEY (request_id, position),
+		FOREIGN KEY (request_id) REFERENCES money_requests (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS money_request_reminders (
+		request_id UUID NOT NULL,
+		remind_on DATE NOT NULL,
+		sent_at TIMESTAMPTZ,
+		PRIMARY KEY (request_id, remind_on),
+		FOREIGN KEY (request_id) REFERENCES money_requests (id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_money_request_reminders_due ON money_request_reminders (remind_on)
+		WHERE sent_at IS NULL;
+	`
+
+const moneyRequestColumns = `id, kind, requester_id, payer_id, payer, amount, currency, note,
+	COALESCE(due_date::text, ''), expires_at::text, status, COALESCE(transfer_id::text, ''),
+	created_at::text, COALESCE(responded_at::text, '')`
+
+func scanMoneyRequest(row interface{ Scan(...any) error }, extra ...any) (types.MoneyRequest, error) {
+	var m types.MoneyRequest
+	err := row.Scan(append([]any{
+		&m.ID,
+		&m.Kind,
+		&m.RequesterID,
+		&m.PayerID,
+		&m.Payer,
+		&m.Amount,
+		&m.Currency,
+		&m.Note,
+		&m.DueDate,
+		&m.ExpiresAt,
+		&m.Status,
+		&m.TransferID,
+		&m.CreatedAt,
+		&m.RespondedAt,
+	}, extra...)...)
+	return m, err
+}
+
+// CreateMoneyRequest stores a pending request with its invoice items and
+// schedules a reminder for each of the remindOn dates.
+func (s *Storage) CreateMoneyRequest(m *types.MoneyRequest, remindOn []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO money_requests
+	(kind, requester_id, payer_id, payer, amount, currency, note, due_date, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::date, $9)
+	RETURNING id, expires_at::text, status, created_at::text`,
+		m.Kind,
+		m.RequesterID,
+		m.PayerID,
+		m.Payer,
+		m.Amount,
+		m.Currency,
+		m.Note,
+		m.DueDate,
+		m.ExpiresAt,
+	).Scan(&m.ID, &m.ExpiresAt, &m.Status, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for i, item := range m.Items {
+		_, err := tx.Exec(`INSERT INTO money_request_items
+		(request_id, position, description, quantity, unit_amount)
+		VALUES ($1, $2, $3, $4, $5)`, m.ID, i, item.Description, item.Quantity, item.UnitAmount)
+		if err != nil {
+			return fmt.Errorf("failed to store invoice item: %w", err)
+		}
+	}
+
+	for _, date := range remindOn {
+		_, err := tx.Exec(`INSERT INTO money_request_reminders (request_id, remind_on)
+		VALUES ($1, $2) ON CONFLICT DO NOTHING`, m.ID, date)
+		if err != nil {
+			return fmt.Errorf("failed to schedule reminder: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetMoneyRequests lists requests the user sent, received or both, newest
+// first, optionally only those with the given status. Invoice items are
+// left out.
+func (s *Storage) GetMoneyRequests(userID, direction string, status types.MoneyRequestStatus, limit int) ([]types.MoneyRequest, error) {
+	rows, err := s.db.Query(`SELECT `+moneyRequestColumns+` FROM money_requests
+	WHERE (($2 <> 'received' AND requester_id = $1) OR ($2 <> 'sent' AND payer_id = $1))
+		AND ($3 = '' OR status = $3)
+	ORDER BY created_at DESC
+	LIMIT $4`, userID, direction, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []types.MoneyRequest{}
+	for rows.Next() {
+		m, err := scanMoneyRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, withRequestDirection(m, userID))
+	}
+	return requests, rows.Err()
+}
+
+// GetMoneyRequest returns a request the user sent or received, with its
+// invoice items.
+func (s *Storage) GetMoneyRequest(userID, id string) (*types.MoneyRequest, error) {
+	m, err := scanMoneyRequest(s.db.QueryRow(`SELECT `+moneyRequestColumns+` FROM money_requests
+	WHERE id = $1 AND (requester_id = $2 OR payer_id = $2)`, id, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT description, quantity, unit_amount FROM money_request_items
+	WHERE request_id = $1 ORDER BY position`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item types.InvoiceItem
+		if err := rows.Scan(&item.Description, &item.Quantity, &item.UnitAmount); err != nil {
+			return nil, err
+		}
+		m.Items = append(m.Items, item)
+	}
+	m = withRequestDirection(m, userID)
+	return &m, rows.Err()
+}
+
+// PayMoneyRequest pays a pending request addressed to payerID by
+// transferring its amount to the requester, all in one transaction. It
+// fails with ErrInvalidMoneyRequestState when the request is no longer
+// pending or has expired, with a *LimitError when it is over the payer's
+// transfer limits, and with ledger.ErrInsufficientFunds when the payer's
+// wallet cannot cover it; in each case nothing changes.
+func (s *Storage) PayMoneyRequest(payerID, id string) (*types.MoneyRequest, *types.WalletTransfer, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var expired bool
+	m, err := scanMoneyRequest(tx.QueryRow(`SELECT `+moneyRequestColumns+`, expires_at <= NOW()
+	FROM money_requests WHERE id = $1 AND payer_id = $2 FOR UPDATE`, id, payerID), &expired)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.Status != types.MoneyRequestPending || expired {
+		return nil, nil, ErrInvalidMoneyRequestState
+	}
+	err = enforceLimits(tx, types.LimitAttempt{
+		UserID:      m.PayerID,
+		Action:      types.LimitTransfer,
+		Amount:      m.Amount,
+		Currency:    m.Currency,
+		RecipientID: m.RequesterID,
+	}, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := types.WalletTransfer{
+		SenderID:    m.PayerID,
+		RecipientID: m.RequesterID,
+		Amount:      m.Amount,
+		Currency:    m.Currency,
+		Note:        m.Note,
+	}
+	err = tx.QueryRow(`INSERT INTO transfers (sender_id, recipient_id, recipient, amount, currency, note)
+	SELECT $1, $2, COALESCE(email, ''), $3, $4, $5 FROM users WHERE id = $2
+	RETURNING id, recipient`,
+		t.SenderID,
+		t.RecipientID,
+		t.Amount,
+		t.Currency,
+		t.Note,
+	).Scan(&t.ID, &t.Recipient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create transfer: %w", err)
+	}
+
+	entry, err := postTransfer(tx, "transfer", "transfer:"+t.ID, t.SenderID, t.RecipientID, t.Amount, t.Currency, t.Note)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.QueryRow(`UPDATE transfers SET status = $2, entry_id = $3, completed_at = NOW()
+	WHERE id = $1 RETURNING status, created_at::text, completed_at::text`,
+		t.ID, types.TransferCompleted, entry.ID,
+	).Scan(&t.Status, &t.CreatedAt, &t.CompletedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to complete transfer: %w", err)
+	}
+
+	m, err = scanMoneyRequest(tx.QueryRow(`UPDATE money_requests
+	SET status = $2, transfer_id = $3, responded_at = NOW()
+	WHERE id = $1 RETURNING `+moneyRequestColumns, m.ID, types.MoneyRequestPaid, t.ID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update request: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	m = withRequestDirection(m, payerID)
+	t.Direction = "sent"
+	return &m, &t, nil
+}
+
+// DeclineMoneyRequest declines a pending request addressed to payerID.
+func (s *Storage) DeclineMoneyRequest(payerID, id string) (*types.MoneyRequest, error) {
+	m, err := scanMoneyRequest(s.db.QueryRow(`UPDATE money_requests SET status = $3, responded_at = NOW()
+	WHERE id = $1 AND payer_id = $2 AND status = $4 AND expires_at > NOW()
+	RETURNING `+moneyRequestColumns, id, payerID, types.MoneyRequestDeclined, types.MoneyRequestPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetMoneyRequest(payerID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidMoneyRequestState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decline request: %w", err)
+	}
+	m = withRequestDirection(m, payerID)
+	return &m, nil
+}
+
+// ExpireMoneyRequests marks pending requests past their expiry as expired
+// and returns them.
+func (s *Storage) ExpireMoneyRequests() ([]types.MoneyRequest, error) {
+	rows, err := s.db.Query(`UPDATE money_requests SET status = $1, responded_at = NOW()
+	WHERE status = $2 AND expires_at <= NOW()
+	RETURNING `+moneyRequestColumns, types.MoneyRequestExpired, types.MoneyRequestPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire requests: %w", err)
+	}
+	defer rows.Close()
+
+	expired := []types.MoneyRequest{}
+	for rows.Next() {
+		m, err := scanMoneyRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, m)
+	}
+	return expired, rows.Err()
+}
+
+// ClaimDueReminders marks every unsent reminder due by today for a pending
+// request as sent and returns them, so each is delivered once even if the
+// job runs twice.
+func (s *Storage) ClaimDueReminders() ([]types.MoneyRequestReminder, error) {
+	rows, err := s.db.Query(`UPDATE money_request_reminders rem SET sent_at = NOW()
+	FROM money_requests m
+	WHERE m.id = rem.request_id AND rem.sent_at IS NULL AND rem.remind_on <= CURRENT_DATE
+		AND m.status = $1 AND m.expires_at > NOW()
+	RETURNING `+moneyRequestColumns+`, COALESCE(m.due_date - CURRENT_DATE, 0)`, types.MoneyRequestPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim reminders: %w", err)
+	}
+	defer rows.Close()
+
+	reminders := []types.MoneyRequestReminder{}
+	for rows.Next() {
+		var reminder types.MoneyRequestReminder
+		reminder.Request, err = scanMoneyRequest(rows, &reminder.DaysLeft)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+func withRequestDirection(m types.MoneyRequest, userID string) types.MoneyRequest {
+	m.Direction = "sent"
+	if m.PayerID == userID {
+		m.Direction = "received"
+	}
+	return m
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const rulesSchema = `
+	ALTER TABLE expenses ADD COLUMN IF NOT EXISTS payee VARCHAR(255) NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS categorization_rules (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		name VARCHAR(100) NOT NULL DEFAULT '',
+		priority INTEGER NOT NULL DEFAULT 0,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		conditions JSONB NOT NULL,
+		actions JSONB NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_categorization_rules_user ON categorization_rules (user_id, priority);
+	`
+
+const ruleColumns = `id, user_id, name, priority, active, conditions, actions, created_at::text`
+
+func scanRule(row interface{ Scan(...any) error }) (types.CategorizationRule, error) {
+	var r types.CategorizationRule
+	var conditions, actions []byte
+	if err := row.Scan(&r.ID, &r.UserID, &r.Name, &r.Priority, &r.Active, &conditions, &actions, &r.CreatedAt); err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(conditions, &r.Conditions); err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(actions, &r.Actions); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func marshalRule(rule *types.CategorizationRule) (string, string, error) {
+	conditions, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		return "", "", err
+	}
+	actions, err := json.Marshal(rule.Actions)
+	if err != nil {
+		return "", "", err
+	}
+	return string(conditions), string(actions), nil
+}
+
+func (s *Storage) CreateRule(rule *types.CategorizationRule) error {
+	conditions, actions, err := marshalRule(rule)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.QueryRow(`INSERT INTO categorization_rules (user_id, name, priority, active, conditions, actions)
+	VALUES ($1, $2, $3, $4, $5::jsonb, $6::jsonb) RETURNING id, created_at::text`,
+		rule.UserID, rule.Name, rule.Priority, rule.Active, conditions, actions,
+	).Scan(&rule.ID, &rule.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create rule: %w", err)
+	}
+	return nil
+}
+
+// GetRules returns the user's rules in the order they are applied.
+func (s *Storage) GetRules(userID string) ([]types.CategorizationRule, error) {
+	rows, err := s.db.Query(`SELECT `+ruleColumns+` FROM categorization_rules
+	WHERE user_id = $1 ORDER BY priority, created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []types.CategorizationRule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *Storage) UpdateRule(rule *types.CategorizationRule) error {
+	conditions, actions, err := marshalRule(rule)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.QueryRow(`UPDATE categorization_rules
+	SET name = $3, priority = $4, active = $5, conditions = $6::jsonb, actions = $7::jsonb
+	WHERE id = $1 AND user_id = $2 RETURNING created_at::text`,
+		rule.ID, rule.UserID, rule.Name, rule.Priority, rule.Active, conditions, actions,
+	).Scan(&rule.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) DeleteRule(userID, id string) error {
+	result, err := s.db.Exec(`DELETE FROM categorization_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+func (s *Storage) GetImportedExpenses(userID, batchID string) ([]types.Expense, error) {
+	query := `SELECT ` + expenseColumns + ` FROM expenses e
+	WHERE e.user_id = $1 AND e.import_batch_id = $2 AND e.deleted_at IS NULL
+	ORDER BY e.expense_date`
+	return s.queryExpenses(query, userID, batchID)
+}
+
+// ApplyClassifications saves the category and payee of each expense and adds
+// any new tags, all or nothing. Each change is recorded in the expense's
+// history.
+func (s *Storage) ApplyClassifications(userID string, expenses []types.Expense) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, len(expenses))
+	for i, e := range expenses {
+		ids[i] = e.ID
+	}
+	before, err := lockExpenses(tx, "e.user_id = $1 AND e.id::text = ANY(string_to_array($2, ','))",
+		userID, strings.Join(ids, ","))
+	if err != nil {
+		return err
+	}
+
+	for _, e := range expenses {
+		_, err := tx.Exec(`UPDATE expenses SET category = $3, payee = $4, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, e.ID, userID, e.Category, e.Payee)
+		if err != nil {
+			return fmt.Errorf("failed to update expense: %w", err)
+		}
+		if err := setExpenseTags(tx, userID, e.ID, e.Tags); err != nil {
+			return err
+		}
+	}
+
+	if err := recordUpdates(tx, types.SourceRules, userID, before); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
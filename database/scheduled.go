@@ -0,0 +1,381 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var ErrInvalidScheduleState = errors.New("scheduled transfer is not in a valid state for this operation")
+
+// scheduledTransferSchema holds transfers the worker sends later, once or
+// on a schedule, and every attempt it made at them. An occurrence succeeds
+// at most once: the run is recorded in the same transaction that moves the
+// money, and the unique index refuses a second success.
+const scheduledTransferSchema = `
+	CREATE TABLE IF NOT EXISTS scheduled_transfers (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		sender_id UUID NOT NULL,
+		recipient_id UUID NOT NULL,
+		recipient VARCHAR(255) NOT NULL,
+		amount BIGINT NOT NULL CHECK (amount > 0),
+		currency VARCHAR(3) NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		frequency VARCHAR(20) NOT NULL DEFAULT '',
+		interval INT NOT NULL DEFAULT 0,
+		day_of_month INT NOT NULL DEFAULT 0,
+		end_date DATE,
+		count INT NOT NULL DEFAULT 0,
+		start_at TIMESTAMPTZ NOT NULL,
+		timezone VARCHAR(64) NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'active',
+		next_run_at TIMESTAMPTZ,
+		retry_at TIMESTAMPTZ,
+		attempts INT NOT NULL DEFAULT 0,
+		run_count INT NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		cancelled_at TIMESTAMPTZ,
+		FOREIGN KEY (sender_id) REFERENCES users (id),
+		FOREIGN KEY (recipient_id) REFERENCES users (id),
+		CHECK (sender_id <> recipient_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_sender ON scheduled_transfers (sender_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due
+		ON scheduled_transfers ((COALESCE(retry_at, next_run_at))) WHERE status = 'active';
+
+	CREATE TABLE IF NOT EXISTS scheduled_transfer_runs (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		schedule_id UUID NOT NULL,
+		occurrence TIMESTAMPTZ NOT NULL,
+		attempt INT NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		transfer_id UUID,
+		error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (schedule_id) REFERENCES scheduled_transfers (id),
+		FOREIGN KEY (transfer_id) REFERENCES transfers (id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_scheduled_transfer_runs_schedule ON scheduled_transfer_runs (schedule_id, created_at);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_scheduled_transfer_runs_success
+		ON scheduled_transfer_runs (schedule_id, occurrence) WHERE status = 'succeeded';
+	`
+
+// utcTime renders a timestamp column as RFC 3339 in UTC so the worker can
+// parse it back exactly.
+func utcTime(column string) string {
+	return `COALESCE(to_char(` + column + ` AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'), '')`
+}
+
+var scheduledTransferColumns = `id, sender_id, recipient_id, recipient, amount, currency, note, frequency,
+	interval, day_of_month, COALESCE(end_date::text, ''), count, ` + utcTime("start_at") + `, timezone, status,
+	` + utcTime("next_run_at") + `, ` + utcTime("retry_at") + `, attempts, run_count, last_error,
+	created_at::text, COALESCE(cancelled_at::text, '')`
+
+func scanScheduledTransfer(row interface{ Scan(...any) error }, extra ...any) (types.ScheduledTransfer, error) {
+	var t types.ScheduledTransfer
+	err := row.Scan(append([]any{
+		&t.ID,
+		&t.SenderID,
+		&t.RecipientID,
+		&t.Recipient,
+		&t.Amount,
+		&t.Currency,
+		&t.Note,
+		&t.Frequency,
+		&t.Interval,
+		&t.DayOfMonth,
+		&t.EndDate,
+		&t.Count,
+		&t.StartAt,
+		&t.Timezone,
+		&t.Status,
+		&t.NextRunAt,
+		&t.RetryAt,
+		&t.Attempts,
+		&t.RunCount,
+		&t.LastError,
+		&t.CreatedAt,
+		&t.CancelledAt,
+	}, extra...)...)
+	return t, err
+}
+
+// CreateScheduledTransfer stores a new active schedule. NextRunAt must be
+// set to its first occurrence.
+func (s *Storage) CreateScheduledTransfer(t *types.ScheduledTransfer) error {
+	created, err := scanScheduledTransfer(s.db.QueryRow(`INSERT INTO scheduled_transfers (sender_id,
+	recipient_id, recipient, amount, currency, note, frequency, interval, day_of_month, end_date, count,
+	start_at, timezone, next_run_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::date, $11, $12, $13, $14)
+	RETURNING `+scheduledTransferColumns,
+		t.SenderID,
+		t.RecipientID,
+		t.Recipient,
+		t.Amount,
+		t.Currency,
+		t.Note,
+		t.Frequency,
+		t.Interval,
+		t.DayOfMonth,
+		t.EndDate,
+		t.Count,
+		t.StartAt,
+		t.Timezone,
+		t.NextRunAt,
+	))
+	if err != nil {
+		return fmt.Errorf("failed to create scheduled transfer: %w", err)
+	}
+	*t = created
+	return nil
+}
+
+// GetScheduledTransfers lists the user's scheduled transfers, newest
+// first, optionally with one status.
+func (s *Storage) GetScheduledTransfers(userID string, status types.ScheduledTransferStatus, limit int) ([]types.ScheduledTransfer, error) {
+	rows, err := s.db.Query(`SELECT `+scheduledTransferColumns+` FROM scheduled_transfers
+	WHERE sender_id = $1 AND ($2 = '' OR status = $2)
+	ORDER BY created_at DESC
+	LIMIT $3`, userID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled transfers: %w", err)
+	}
+	defer rows.Close()
+
+	list := []types.ScheduledTransfer{}
+	for rows.Next() {
+		t, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// GetScheduledTransfer returns one of the user's scheduled transfers with
+// its latest runs.
+func (s *Storage) GetScheduledTransfer(userID, id string) (*types.ScheduledTransfer, error) {
+	t, err := scanScheduledTransfer(s.db.QueryRow(`SELECT `+scheduledTransferColumns+` FROM scheduled_transfers
+	WHERE id = $1 AND sender_id = $2`, id, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT id, `+utcTime("occurrence")+`, attempt, status,
+	COALESCE(transfer_id::text, ''), error, created_at::text
+	FROM scheduled_transfer_runs WHERE schedule_id = $1
+	ORDER BY created_at DESC LIMIT 50`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled transfer runs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run types.ScheduledTransferRun
+		if err := rows.Scan(&run.ID, &run.Occurrence, &run.Attempt, &run.Status, &run.TransferID, &run.Error, &run.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.Runs = append(t.Runs, run)
+	}
+	return &t, rows.Err()
+}
+
+// CancelScheduledTransfer stops an active schedule. It fails with
+// ErrInvalidScheduleState once the schedule has finished.
+func (s *Storage) CancelScheduledTransfer(userID, id string) (*types.ScheduledTransfer, error) {
+	t, err := scanScheduledTransfer(s.db.QueryRow(`UPDATE scheduled_transfers
+	SET status = $3, cancelled_at = NOW(), next_run_at = NULL, retry_at = NULL
+	WHERE id = $1 AND sender_id = $2 AND status = $4
+	RETURNING `+scheduledTransferColumns, id, userID, types.ScheduleCancelled, types.ScheduleActive))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetScheduledTransfer(userID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidScheduleState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel scheduled transfer: %w", err)
+	}
+	return &t, nil
+}
+
+// GetDueScheduledTransfers lists active schedules whose occurrence or
+// retry is due by now, the longest waiting first.
+func (s *Storage) GetDueScheduledTransfers(now time.Time, limit int) ([]types.ScheduledTransfer, error) {
+	rows, err := s.db.Query(`SELECT `+scheduledTransferColumns+` FROM scheduled_transfers
+	WHERE status = $1 AND COALESCE(retry_at, next_run_at) <= $2
+	ORDER BY COALESCE(retry_at, next_run_at)
+	LIMIT $3`, types.ScheduleActive, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due scheduled transfers: %w", err)
+	}
+	defer rows.Close()
+
+	list := []types.ScheduledTransfer{}
+	for rows.Next() {
+		t, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// lockScheduledRun locks an active schedule whose current occurrence is
+// the one given, failing with ErrInvalidScheduleState when another worker
+// has already dealt with it or the schedule was cancelled.
+func lockScheduledRun(tx *sql.Tx, id string, occurrence time.Time) (*types.ScheduledTransfer, error) {
+	var current bool
+	t, err := scanScheduledTransfer(tx.QueryRow(`SELECT `+scheduledTransferColumns+`,
+	next_run_at IS NOT DISTINCT FROM $2
+	FROM scheduled_transfers WHERE id = $1 FOR UPDATE`, id, occurrence), &current)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != types.ScheduleActive || !current {
+		return nil, ErrInvalidScheduleState
+	}
+	return &t, nil
+}
+
+// ExecuteScheduledTransfer sends the occurrence of a schedule due at
+// occurrence and moves the schedule on to next, or completes it when next
+// is zero, all in one transaction. It fails with a *LimitError when it is
+// over the sender's limits, with ledger.ErrInsufficientFunds when the
+// sender's wallet cannot cover it and with ErrInvalidScheduleState when the
+// occurrence was already handled; in each case nothing changes.
+func (s *Storage) ExecuteScheduledTransfer(id string, occurrence, next time.Time) (*types.WalletTransfer, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	sched, err := lockScheduledRun(tx, id, occurrence)
+	if err != nil {
+		return nil, err
+	}
+	err = enforceLimits(tx, types.LimitAttempt{
+		UserID:      sched.SenderID,
+		Action:      types.LimitTransfer,
+		Amount:      sched.Amount,
+		Currency:    sched.Currency,
+		RecipientID: sched.RecipientID,
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var transferID string
+	err = tx.QueryRow(`INSERT INTO transfers (sender_id, recipient_id, recipient, amount, currency, note)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		sched.SenderID, sched.RecipientID, sched.Recipient, sched.Amount, sched.Currency, sched.Note,
+	).Scan(&transferID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transfer: %w", err)
+	}
+
+	reference := fmt.Sprintf("scheduled:%s:%d", sched.ID, occurrence.Unix())
+	entry, err := postTransfer(tx, "transfer", reference, sched.SenderID, sched.RecipientID, sched.Amount, sched.Currency, sched.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := scanTransfer(tx.QueryRow(`UPDATE transfers SET status = $2, entry_id = $3, completed_at = NOW()
+	WHERE id = $1 RETURNING `+transferColumns, transferID, types.TransferCompleted, entry.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete transfer: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO scheduled_transfer_runs (schedule_id, occurrence, attempt, status, transfer_id)
+	VALUES ($1, $2, $3, $4, $5)`, sched.ID, occurrence, sched.Attempts+1, types.RunSucceeded, t.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record scheduled transfer run: %w", err)
+	}
+
+	if err := advanceSchedule(tx, sched.ID, next, "", true); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	t.Direction = "sent"
+	return &t, nil
+}
+
+// FailScheduledRun records a failed attempt at the occurrence due at
+// occurrence. With a retryAt the occurrence is tried again then; without
+// one it is given up and the schedule moves on to next, or fails when next
+// is zero.
+func (s *Storage) FailScheduledRun(id string, occurrence time.Time, reason string, retryAt, next time.Time) (*types.ScheduledTransfer, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	sched, err := lockScheduledRun(tx, id, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO scheduled_transfer_runs (schedule_id, occurrence, attempt, status, error)
+	VALUES ($1, $2, $3, $4, $5)`, sched.ID, occurrence, sched.Attempts+1, types.RunFailed, reason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record scheduled transfer run: %w", err)
+	}
+
+	if !retryAt.IsZero() {
+		_, err = tx.Exec(`UPDATE scheduled_transfers SET attempts = attempts + 1, retry_at = $2, last_error = $3
+		WHERE id = $1`, sched.ID, retryAt, reason)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update scheduled transfer: %w", err)
+		}
+	} else if err := advanceSchedule(tx, sched.ID, next, reason, false); err != nil {
+		return nil, err
+	}
+
+	updated, err := scanScheduledTransfer(tx.QueryRow(`SELECT `+scheduledTransferColumns+`
+	FROM scheduled_transfers WHERE id = $1`, sched.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &updated, nil
+}
+
+// advanceSchedule moves a schedule past its current occurrence. Without a
+// next occurrence it ends: completed after a success, failed otherwise.
+func advanceSchedule(tx *sql.Tx, id string, next time.Time, reason string, succeeded bool) error {
+	final := types.ScheduleFailed
+	runs := 0
+	if succeeded {
+		final = types.ScheduleCompleted
+		runs = 1
+	}
+
+	var nextRun any
+	if !next.IsZero() {
+		nextRun = next
+	}
+	_, err := tx.Exec(`UPDATE scheduled_transfers
+	SET next_run_at = $2, status = CASE WHEN $2::timestamptz IS NULL THEN $3 ELSE status END,
+		retry_at = NULL, attempts = 0, run_count = run_count + $4, last_error = $5
+	WHERE id = $1`, id, nextRun, final, runs, reason)
+	if err != nil {
+		return fmt.Errorf("failed to update scheduled transfer: %w", err)
+	}
+	return nil
+}
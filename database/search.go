@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// searchSchema adds notes and tags to expenses and keeps a generated search
+// vector over the description (weighted higher) and the notes.
+const searchSchema = `
+	ALTER TABLE expenses ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '';
+
+	ALTER TABLE expenses ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', description), 'A') ||
+			setweight(to_tsvector('english', notes), 'B')
+		) STORED;
+
+	CREATE INDEX IF NOT EXISTS idx_expenses_search ON expenses USING GIN (search_vector);
+
+	CREATE TABLE IF NOT EXISTS expense_tags (
+		expense_id UUID NOT NULL,
+		user_id UUID NOT NULL,
+		tag VARCHAR(50) NOT NULL,
+		PRIMARY KEY (expense_id, tag),
+		FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_expense_tags_user_tag ON expense_tags (user_id, tag);
+	`
+
+const maxSearchResults = 100
+
+func setExpenseTags(tx *sql.Tx, userID, expenseID string, tags []string) error {
+	for _, tag := range tags {
+		_, err := tx.Exec(`INSERT INTO expense_tags (expense_id, user_id, tag) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, expenseID, userID, tag)
+		if err != nil {
+			return fmt.Errorf("failed to tag expense: %w", err)
+		}
+	}
+	return nil
+}
+
+// SearchExpenses runs filter.Query as a full-text search, narrowed by the
+// rest of the filter, and returns the best matches first. Snippets are cut
+// from the description and notes with matches wrapped in <mark>.
+func (s *Storage) SearchExpenses(userID string, filter types.ExpenseFilter) ([]types.ExpenseSearchResult, error) {
+	if filter.Query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+
+	text := filter.Query
+	filter.Query = ""
+	where, args := expenseFilterClause(filter, []any{userID, text})
+	query := `SELECT ` + expenseColumns + `,
+	ts_rank_cd(e.search_vector, q.query) AS rank,
+	ts_headline('english', e.description || ' ' || e.notes, q.query,
+		'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')
+	FROM expenses e, websearch_to_tsquery('english', $2) AS q(query)
+	WHERE e.user_id = $1 AND e.deleted_at IS NULL AND e.search_vector @@ q.query` + where + `
+	ORDER BY rank DESC, e.expense_date DESC
+	LIMIT ` + fmt.Sprint(maxSearchResults)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search expenses: %w", err)
+	}
+	defer rows.Close()
+
+	results := []types.ExpenseSearchResult{}
+	for rows.Next() {
+		var result types.ExpenseSearchResult
+		result.Expense, err = scanExpense(rows, &result.Rank, &result.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// GetTags lists the tags a user has applied with how often each is used.
+func (s *Storage) GetTags(userID string) ([]types.TagCount, error) {
+	rows, err := s.db.Query(`SELECT t.tag, COUNT(*) FROM expense_tags t
+	JOIN expenses e ON e.id = t.expense_id
+	WHERE t.user_id = $1 AND e.deleted_at IS NULL
+	GROUP BY t.tag ORDER BY 2 DESC, 1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []types.TagCount{}
+	for rows.Next() {
+		var t types.TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
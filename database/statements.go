@@ -0,0 +1,219 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var ErrStatementExists = errors.New("statement already produced for this period")
+
+// statementSchema keeps produced statements with their rendered files. A
+// wallet has at most one statement per month and statements can never be
+// updated or deleted, so a document handed out once is always the same.
+const statementSchema = `
+	CREATE TABLE IF NOT EXISTS statements (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		user_id UUID NOT NULL,
+		account_id UUID NOT NULL,
+		currency VARCHAR(3) NOT NULL,
+		period CHAR(7) NOT NULL,
+		timezone VARCHAR(64) NOT NULL,
+		period_start DATE NOT NULL,
+		period_end DATE NOT NULL,
+		opening_balance BIGINT NOT NULL,
+		closing_balance BIGINT NOT NULL,
+		total_in BIGINT NOT NULL,
+		total_out BIGINT NOT NULL,
+		line_count INT NOT NULL,
+		pdf BYTEA NOT NULL,
+		csv BYTEA NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		FOREIGN KEY (user_id) REFERENCES users (id),
+		FOREIGN KEY (account_id) REFERENCES ledger_accounts (id),
+		UNIQUE (account_id, period)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_statements_user ON statements (user_id, period);
+
+	CREATE OR REPLACE FUNCTION statements_immutable() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+	BEGIN
+		RAISE EXCEPTION 'statements are immutable';
+	END
+	$$;
+
+	DROP TRIGGER IF EXISTS statements_immutable ON statements;
+	CREATE TRIGGER statements_immutable BEFORE UPDATE OR DELETE ON statements
+		FOR EACH ROW EXECUTE FUNCTION statements_immutable();
+	`
+
+const statementColumns = `id, user_id, account_id, currency, period, timezone, period_start::text,
+	period_end::text, opening_balance, closing_balance, total_in, total_out, line_count, checksum,
+	created_at::text`
+
+func scanStatement(row interface{ Scan(...any) error }) (types.Statement, error) {
+	var st types.Statement
+	err := row.Scan(&st.ID, &st.UserID, &st.AccountID, &st.Currency, &st.Period, &st.Timezone,
+		&st.PeriodStart, &st.PeriodEnd, &st.OpeningBalance, &st.ClosingBalance, &st.TotalIn,
+		&st.TotalOut, &st.LineCount, &st.Checksum, &st.CreatedAt)
+	return st, err
+}
+
+// GetStatementPostings returns an account's balance at from and its
+// postings in [from, to), oldest first, dated in timezone. Entries are
+// stamped when their transaction starts, so one can commit after a later
+// stamped one; the period is therefore cut in posting order, at the first
+// posting stamped at or after each bound, and the opening balance is the
+// one left by the posting just before the start.
+func (s *Storage) GetStatementPostings(accountID string, from, to time.Time, timezone string) (int64, []types.AccountStatementLine, error) {
+	var startID, endID int64
+	err := s.db.QueryRow(`SELECT
+	COALESCE(MIN(p.id) FILTER (WHERE e.created_at >= $2), 9223372036854775807),
+	COALESCE(MIN(p.id) FILTER (WHERE e.created_at >= $3), 9223372036854775807)
+	FROM journal_postings p JOIN journal_entries e ON e.id = p.entry_id
+	WHERE p.account_id = $1`, accountID, from, to).Scan(&startID, &endID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query statement bounds: %w", err)
+	}
+
+	var opening int64
+	err = s.db.QueryRow(`SELECT balance_after FROM journal_postings
+	WHERE account_id = $1 AND id < $2
+	ORDER BY id DESC LIMIT 1`, accountID, startID).Scan(&opening)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, nil, fmt.Errorf("failed to query opening balance: %w", err)
+	}
+
+	rows, err := s.db.Query(`SELECT e.id, e.kind, e.reference, e.description, p.amount, p.balance_after,
+	to_char(e.created_at AT TIME ZONE $4, 'YYYY-MM-DD HH24:MI')
+	FROM journal_postings p JOIN journal_entries e ON e.id = p.entry_id
+	WHERE p.account_id = $1 AND p.id >= $2 AND p.id < $3
+	ORDER BY p.id`, accountID, startID, endID, timezone)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query statement postings: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []types.AccountStatementLine{}
+	for rows.Next() {
+		var l types.AccountStatementLine
+		if err := rows.Scan(&l.EntryID, &l.Kind, &l.Reference, &l.Description, &l.Amount, &l.BalanceAfter, &l.CreatedAt); err != nil {
+			return 0, nil, err
+		}
+		lines = append(lines, l)
+	}
+	return opening, lines, rows.Err()
+}
+
+// CreateStatement stores a produced statement with its files. It fails
+// with ErrStatementExists when the wallet already has one for the period.
+func (s *Storage) CreateStatement(st *types.Statement, pdf, csv []byte) error {
+	err := s.db.QueryRow(`INSERT INTO statements (user_id, account_id, currency, period, timezone,
+	period_start, period_end, opening_balance, closing_balance, total_in, total_out, line_count,
+	pdf, csv, checksum)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	ON CONFLICT (account_id, period) DO NOTHING
+	RETURNING id, created_at::text`,
+		st.UserID, st.AccountID, st.Currency, st.Period, st.Timezone, st.PeriodStart, st.PeriodEnd,
+		st.OpeningBalance, st.ClosingBalance, st.TotalIn, st.TotalOut, st.LineCount, pdf, csv, st.Checksum,
+	).Scan(&st.ID, &st.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrStatementExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create statement: %w", err)
+	}
+	return nil
+}
+
+// GetStatements lists the user's statements, newest period first,
+// optionally for one currency.
+func (s *Storage) GetStatements(userID, currency string, limit int) ([]types.Statement, error) {
+	rows, err := s.db.Query(`SELECT `+statementColumns+` FROM statements
+	WHERE user_id = $1 AND ($2 = '' OR currency = $2)
+	ORDER BY period DESC, currency
+	LIMIT $3`, userID, currency, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query statements: %w", err)
+	}
+	defer rows.Close()
+
+	list := []types.Statement{}
+	for rows.Next() {
+		st, err := scanStatement(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, st)
+	}
+	return list, rows.Err()
+}
+
+func (s *Storage) GetStatement(userID, id string) (*types.Statement, error) {
+	st, err := scanStatement(s.db.QueryRow(`SELECT `+statementColumns+` FROM statements
+	WHERE id = $1 AND user_id = $2`, id, userID))
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (s *Storage) GetStatementByPeriod(userID, currency, period string) (*types.Statement, error) {
+	st, err := scanStatement(s.db.QueryRow(`SELECT `+statementColumns+` FROM statements
+	WHERE user_id = $1 AND currency = $2 AND period = $3`, userID, currency, period))
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// GetStatementFile returns the stored PDF or CSV of one of the user's
+// statements.
+func (s *Storage) GetStatementFile(userID, id, format string) ([]byte, error) {
+	var column string
+	switch format {
+	case "pdf":
+		column = "pdf"
+	case "csv":
+		column = "csv"
+	default:
+		return nil, fmt.Errorf("unknown statement format %q", format)
+	}
+
+	var data []byte
+	err := s.db.QueryRow(`SELECT `+column+` FROM statements WHERE id = $1 AND user_id = $2`, id, userID).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetWalletsDueStatement lists wallets opened before the given time that
+// have no statement for period yet, in id order after the wallet afterID,
+// or from the first when it is empty.
+func (s *Storage) GetWalletsDueStatement(period string, openedBefore time.Time, afterID string, limit int) ([]types.LedgerAccount, error) {
+	rows, err := s.db.Query(`SELECT `+ledgerAccountColumns+` FROM ledger_accounts a
+	WHERE a.kind = $1 AND a.created_at < $3 AND a.id > COALESCE(NULLIF($4, '')::uuid, '00000000-0000-0000-0000-000000000000')
+		AND NOT EXISTS (SELECT 1 FROM statements st WHERE st.account_id = a.id AND st.period = $2)
+	ORDER BY a.id
+	LIMIT $5`, types.AccountWallet, period, openedBefore, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallets due a statement: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []types.LedgerAccount{}
+	for rows.Next() {
+		a, err := scanLedgerAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
@@ -94,9 +94,39 @@ func (s *Storage) CreateTable() error {
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec(query); err != nil {
-		slog.Error("Error executing schema creation", "error", err)
-		return fmt.Errorf("error creating database schema: %w", err)
+	schemas := []string{
+		query,
+		expenseSchema,
+		recurringSchema,
+		importSchema,
+		receiptSchema,
+		budgetSchema,
+		notificationSchema,
+		searchSchema,
+		ledgerSchema,
+		rulesSchema,
+		fxSchema,
+		historySchema,
+		analyticsSchema,
+		incomeSchema,
+		goalSchema,
+		journalSchema,
+		pinSchema,
+		transferSchema,
+		scheduledTransferSchema,
+		moneyRequestSchema,
+		paymentSchema,
+		reconciliationSchema,
+		limitSchema,
+		auditSchema,
+		kycSchema,
+		statementSchema,
+	}
+	for _, schema := range schemas {
+		if _, err := tx.Exec(schema); err != nil {
+			slog.Error("Error executing schema creation", "error", err)
+			return fmt.Errorf("error creating database schema: %w", err)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -140,7 +170,7 @@ func (s *Storage) CreateAccount(account *types.Account) error {
 }
 
 func (s *Storage) Authenticate(password, email string) (types.LoginResponse, error) {
-	query := `SELECT id, firstName, lastName, phoneNumber, email, passwordhash FROM users
+	query := `SELECT id, firstName, lastName, phoneNumber, email, passwordhash, kyc_tier FROM users
 	WHERE email=$1`
 	var user types.User
 	err := s.db.QueryRow(query, email).Scan(
@@ -150,6 +180,7 @@ func (s *Storage) Authenticate(password, email string) (types.LoginResponse, err
 		&user.PhoneNumber,
 		&user.Email,
 		&user.Password,
+		&user.KYCTier,
 	)
 	if err != nil {
 		return types.LoginResponse{}, err
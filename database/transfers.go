@@ -0,0 +1,279 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var ErrInvalidTransferState = errors.New("transfer is not in a valid state for this operation")
+
+// transferSchema tracks peer-to-peer transfers. The money itself moves in
+// the journal; a completed transfer points at the entry that moved it and a
+// reversed one also at the entry that gave it back.
+const transferSchema = `
+	CREATE TABLE IF NOT EXISTS transfers (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+		sender_id UUID NOT NULL,
+		recipient_id UUID NOT NULL,
+		recipient VARCHAR(255) NOT NULL,
+		amount BIGINT NOT NULL CHECK (amount > 0),
+		currency VARCHAR(3) NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		failure_reason TEXT NOT NULL DEFAULT '',
+		entry_id UUID,
+		reversal_entry_id UUID,
+		created_at TIMESTAMPTZ DEFAULT NOW (),
+		completed_at TIMESTAMPTZ,
+		reversed_at TIMESTAMPTZ,
+		FOREIGN KEY (sender_id) REFERENCES users (id),
+		FOREIGN KEY (recipient_id) REFERENCES users (id),
+		FOREIGN KEY (entry_id) REFERENCES journal_entries (id),
+		FOREIGN KEY (reversal_entry_id) REFERENCES journal_entries (id),
+		CHECK (sender_id <> recipient_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_transfers_sender ON transfers (sender_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_transfers_recipient ON transfers (recipient_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_transfers_pending ON transfers (created_at) WHERE status = 'pending';
+	`
+
+const transferColumns = `id, sender_id, recipient_id, recipient, amount, currency, note, status,
+	failure_reason, created_at::text, COALESCE(completed_at::text, ''), COALESCE(reversed_at::text, '')`
+
+func scanTransfer(row interface{ Scan(...any) error }) (types.WalletTransfer, error) {
+	var t types.WalletTransfer
+	err := row.Scan(
+		&t.ID,
+		&t.SenderID,
+		&t.RecipientID,
+		&t.Recipient,
+		&t.Amount,
+		&t.Currency,
+		&t.Note,
+		&t.Status,
+		&t.FailureReason,
+		&t.CreatedAt,
+		&t.CompletedAt,
+		&t.ReversedAt,
+	)
+	return t, err
+}
+
+// CreateTransfer records a transfer as pending before any money moves, so
+// an attempt is on record even if posting it fails.
+func (s *Storage) CreateTransfer(t *types.WalletTransfer) error {
+	err := s.db.QueryRow(`INSERT INTO transfers (sender_id, recipient_id, recipient, amount, currency, note)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, status, created_at::text`,
+		t.SenderID,
+		t.RecipientID,
+		t.Recipient,
+		t.Amount,
+		t.Currency,
+		t.Note,
+	).Scan(&t.ID, &t.Status, &t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create transfer: %w", err)
+	}
+	return nil
+}
+
+// CompleteTransfer moves the money of a pending transfer from the sender's
+// wallet to the recipient's and marks it completed, all in one
+// transaction, once it is within the sender's limits. On error nothing has
+// moved and the transfer is still pending; over the limits that error is a
+// *LimitError.
+func (s *Storage) CompleteTransfer(id string) (*types.WalletTransfer, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	t, err := scanTransfer(tx.QueryRow(`SELECT `+transferColumns+` FROM transfers WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != types.TransferPending {
+		return nil, ErrInvalidTransferState
+	}
+	err = enforceLimits(tx, types.LimitAttempt{
+		UserID:      t.SenderID,
+		Action:      types.LimitTransfer,
+		Amount:      t.Amount,
+		Currency:    t.Currency,
+		RecipientID: t.RecipientID,
+	}, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := postTransfer(tx, "transfer", "transfer:"+t.ID, t.SenderID, t.RecipientID, t.Amount, t.Currency, t.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`UPDATE transfers SET status = $2, entry_id = $3, completed_at = NOW()
+	WHERE id = $1 RETURNING status, completed_at::text`,
+		t.ID, types.TransferCompleted, entry.ID,
+	).Scan(&t.Status, &t.CompletedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &t, nil
+}
+
+// FailTransfer marks a pending transfer as failed with the reason.
+func (s *Storage) FailTransfer(id, reason string) error {
+	result, err := s.db.Exec(`UPDATE transfers SET status = $2, failure_reason = $3
+	WHERE id = $1 AND status = $4`, id, types.TransferFailed, reason, types.TransferPending)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer: %w", err)
+	}
+	return expectOneRow(result)
+}
+
+// ReverseTransfer sends a completed transfer's money back from the
+// recipient to the sender. Only the recipient can reverse a transfer,
+// unless asAdmin is set. For the recipient it is an outgoing transfer held
+// to their limits, failing with a *LimitError over them; an admin's
+// reversal is recorded on the audit trail instead.
+func (s *Storage) ReverseTransfer(userID, id string, asAdmin bool) (*types.WalletTransfer, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	t, err := scanTransfer(tx.QueryRow(`SELECT `+transferColumns+` FROM transfers
+	WHERE id = $1 AND ($3 OR recipient_id = $2) FOR UPDATE`, id, userID, asAdmin))
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != types.TransferCompleted {
+		return nil, ErrInvalidTransferState
+	}
+	if asAdmin {
+		err = recordAuditEvent(tx, &types.AuditEvent{
+			UserID:  t.RecipientID,
+			ActorID: userID,
+			Action:  "transfer_reversed",
+			Outcome: "reversed",
+			Details: map[string]any{"transferId": t.ID, "amount": t.Amount, "currency": t.Currency},
+		})
+	} else {
+		// The money goes back where it came from, so the limit on paying
+		// someone new does not apply.
+		err = enforceLimits(tx, types.LimitAttempt{
+			UserID:   t.RecipientID,
+			Action:   types.LimitTransfer,
+			Amount:   t.Amount,
+			Currency: t.Currency,
+		}, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := postTransfer(tx, "transfer_reversal", "transfer:"+t.ID+":reversal",
+		t.RecipientID, t.SenderID, t.Amount, t.Currency, "Reversal of transfer "+t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`UPDATE transfers SET status = $2, reversal_entry_id = $3, reversed_at = NOW()
+	WHERE id = $1 RETURNING status, reversed_at::text`,
+		t.ID, types.TransferReversed, entry.ID,
+	).Scan(&t.Status, &t.ReversedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reverse transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &t, nil
+}
+
+// postTransfer posts an entry moving amount from one user's wallet to
+// another's, opening the recipient's wallet if needed.
+func postTransfer(tx *sql.Tx, kind, reference, fromUser, toUser string, amount int64, currency, description string) (*types.JournalEntry, error) {
+	from, err := openWallet(tx, fromUser, currency)
+	if err != nil {
+		return nil, err
+	}
+	to, err := openWallet(tx, toUser, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return moveMoney(tx, kind, reference, description, from.ID, to.ID, amount, currency)
+}
+
+// GetTransfers lists transfers the user sent, received or both, newest
+// first.
+func (s *Storage) GetTransfers(userID, direction string, limit int) ([]types.WalletTransfer, error) {
+	rows, err := s.db.Query(`SELECT `+transferColumns+` FROM transfers
+	WHERE ($2 <> 'received' AND sender_id = $1) OR ($2 <> 'sent' AND recipient_id = $1)
+	ORDER BY created_at DESC
+	LIMIT $3`, userID, direction, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := []types.WalletTransfer{}
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, withDirection(t, userID))
+	}
+	return transfers, rows.Err()
+}
+
+// GetTransfer returns a transfer the user sent or received.
+func (s *Storage) GetTransfer(userID, id string) (*types.WalletTransfer, error) {
+	t, err := scanTransfer(s.db.QueryRow(`SELECT `+transferColumns+` FROM transfers
+	WHERE id = $1 AND (sender_id = $2 OR recipient_id = $2)`, id, userID))
+	if err != nil {
+		return nil, err
+	}
+	t = withDirection(t, userID)
+	return &t, nil
+}
+
+// FailStaleTransfers fails transfers left pending for longer than age,
+// which only happens when the server stopped between recording a transfer
+// and posting it. No money moved for them.
+func (s *Storage) FailStaleTransfers(age time.Duration) (int, error) {
+	result, err := s.db.Exec(`UPDATE transfers SET status = $1, failure_reason = 'timed out'
+	WHERE status = $2 AND created_at < NOW() - $3 * INTERVAL '1 second'`,
+		types.TransferFailed, types.TransferPending, age.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail stale transfers: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	return int(n), nil
+}
+
+func withDirection(t types.WalletTransfer, userID string) types.WalletTransfer {
+	t.Direction = "sent"
+	if t.RecipientID == userID {
+		t.Direction = "received"
+	}
+	return t
+}
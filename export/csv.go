@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin() error {
+	return c.w.Write([]string{"id", "date", "amount", "description", "category", "payment_method"})
+}
+
+func (c *csvWriter) Write(e types.Expense) error {
+	err := c.w.Write([]string{
+		e.ID,
+		e.Date,
+		strconv.FormatFloat(e.Amount, 'f', 2, 64),
+		e.Description,
+		e.Category,
+		e.PaymentMethod,
+	})
+	if err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
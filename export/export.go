@@ -0,0 +1,115 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+type Format string
+
+const (
+	FormatCSV       Format = "csv"
+	FormatOFX       Format = "ofx"
+	FormatLedger    Format = "ledger"
+	FormatBeancount Format = "beancount"
+)
+
+// Options control how expenses are written. Accounts maps a category name to
+// the account used in plain-text journals; unmapped categories become
+// Expenses:<Category>.
+type Options struct {
+	Currency      string
+	SourceAccount string
+	Accounts      map[string]string
+	From          string
+	To            string
+}
+
+// Writer emits expenses one at a time so callers can stream straight from a
+// database cursor to the response.
+type Writer interface {
+	Begin() error
+	Write(expense types.Expense) error
+	End() error
+}
+
+func NewWriter(format Format, w io.Writer, opts Options) (Writer, error) {
+	if opts.Currency == "" {
+		opts.Currency = "UGX"
+	}
+	if opts.SourceAccount == "" {
+		opts.SourceAccount = "Assets:Cash"
+	}
+
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatOFX:
+		return newOFXWriter(w, opts), nil
+	case FormatLedger:
+		return newJournalWriter(w, opts, false), nil
+	case FormatBeancount:
+		return newJournalWriter(w, opts, true), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatOFX:
+		return "application/x-ofx"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+func (f Format) Extension() string {
+	switch f {
+	case FormatBeancount:
+		return "beancount"
+	case FormatLedger:
+		return "journal"
+	default:
+		return string(f)
+	}
+}
+
+// AccountFor returns the journal account for a category.
+func (o Options) AccountFor(category string) string {
+	if account, ok := o.Accounts[strings.ToLower(category)]; ok {
+		return account
+	}
+	if category == "" {
+		return "Expenses:Uncategorized"
+	}
+	return "Expenses:" + accountComponent(category)
+}
+
+// accountComponent turns free text into a valid account segment: words are
+// capitalised and joined, and anything but letters, digits and dashes is
+// dropped, which keeps both ledger and beancount happy.
+func accountComponent(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	}) {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	if b.Len() == 0 {
+		return "Uncategorized"
+	}
+	out := b.String()
+	if !unicode.IsLetter([]rune(out)[0]) {
+		out = "X" + out
+	}
+	return out
+}
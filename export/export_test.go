@@ -0,0 +1,64 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sample = []types.Expense{
+	{ID: "e1", Date: "2025-01-02", Amount: 45000, Description: "Shoprite \"weekly\"", Category: "groceries"},
+	{ID: "e2", Date: "2025-01-03", Amount: 12000.5, Description: "Boda <ride>", Category: "eating out", PaymentMethod: "mobile money"},
+}
+
+func render(t *testing.T, format Format, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf, opts)
+	require.NoError(t, err)
+	require.NoError(t, w.Begin())
+	for _, e := range sample {
+		require.NoError(t, w.Write(e))
+	}
+	require.NoError(t, w.End())
+	return buf.String()
+}
+
+func TestCSV(t *testing.T) {
+	out := render(t, FormatCSV, Options{})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "id,date,amount,description,category,payment_method", lines[0])
+	assert.Equal(t, `e1,2025-01-02,45000.00,"Shoprite ""weekly""",groceries,`, lines[1])
+}
+
+func TestOFX(t *testing.T) {
+	out := render(t, FormatOFX, Options{Currency: "KES", From: "2025-01-01", To: "2025-01-31"})
+	assert.Contains(t, out, "<CURDEF>KES</CURDEF>")
+	assert.Contains(t, out, "<DTSTART>20250101</DTSTART>")
+	assert.Contains(t, out, "<TRNAMT>-12000.50</TRNAMT>")
+	assert.Contains(t, out, "<NAME>Boda &lt;ride&gt;</NAME>")
+	assert.True(t, strings.HasSuffix(out, "</OFX>\n"))
+}
+
+func TestLedger(t *testing.T) {
+	out := render(t, FormatLedger, Options{Accounts: map[string]string{"groceries": "Expenses:Food:Groceries"}})
+	assert.Contains(t, out, "2025/01/02 * Shoprite \"weekly\"\n    Expenses:Food:Groceries    45000.00 UGX\n    Assets:Cash\n")
+	assert.Contains(t, out, "Expenses:EatingOut    12000.50 UGX")
+}
+
+func TestBeancount(t *testing.T) {
+	out := render(t, FormatBeancount, Options{SourceAccount: "Assets:MobileMoney"})
+	assert.Equal(t, 1, strings.Count(out, "open Assets:MobileMoney"))
+	assert.Contains(t, out, "2025-01-02 open Expenses:Groceries")
+	assert.Contains(t, out, "2025-01-02 * \"Shoprite 'weekly'\"\n  Expenses:Groceries  45000.00 UGX\n  Assets:MobileMoney\n")
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	_, err := NewWriter("xlsx", &bytes.Buffer{}, Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// journalWriter writes double-entry plain-text journals for ledger-cli or
+// beancount. Every expense debits its category account and credits the
+// source account.
+type journalWriter struct {
+	w         io.Writer
+	opts      Options
+	beancount bool
+	opened    map[string]bool
+}
+
+func newJournalWriter(w io.Writer, opts Options, beancount bool) *journalWriter {
+	return &journalWriter{w: w, opts: opts, beancount: beancount, opened: make(map[string]bool)}
+}
+
+func (j *journalWriter) Begin() error {
+	if !j.beancount {
+		_, err := fmt.Fprintf(j.w, "; Exported from Liora\n\n")
+		return err
+	}
+	_, err := fmt.Fprintf(j.w, "; Exported from Liora\noption \"operating_currency\" \"%s\"\n\n", j.opts.Currency)
+	return err
+}
+
+func (j *journalWriter) Write(e types.Expense) error {
+	account := j.opts.AccountFor(e.Category)
+	source := j.opts.SourceAccount
+
+	if j.beancount {
+		// Beancount refuses postings to accounts that were never opened, and
+		// the accounts are only known once rows arrive.
+		for _, a := range []string{account, source} {
+			if !j.opened[a] {
+				if _, err := fmt.Fprintf(j.w, "%s open %s\n\n", e.Date, a); err != nil {
+					return err
+				}
+				j.opened[a] = true
+			}
+		}
+
+		_, err := fmt.Fprintf(j.w, "%s * %s\n  %s  %.2f %s\n  %s\n\n",
+			e.Date, quote(e.Description), account, e.Amount, j.opts.Currency, source)
+		return err
+	}
+
+	_, err := fmt.Fprintf(j.w, "%s * %s\n    %s    %.2f %s\n    %s\n\n",
+		strings.ReplaceAll(e.Date, "-", "/"), oneLine(e.Description), account, e.Amount, j.opts.Currency, source)
+	return err
+}
+
+func (j *journalWriter) End() error {
+	return nil
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(oneLine(s), `"`, `'`) + `"`
+}
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+type ofxWriter struct {
+	w    io.Writer
+	opts Options
+}
+
+func newOFXWriter(w io.Writer, opts Options) *ofxWriter {
+	return &ofxWriter{w: w, opts: opts}
+}
+
+func ofxDate(date string) string {
+	return strings.ReplaceAll(date, "-", "")
+}
+
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (o *ofxWriter) Begin() error {
+	start, end := ofxDate(o.opts.From), ofxDate(o.opts.To)
+	if start == "" {
+		start = "19700101"
+	}
+	if end == "" {
+		end = time.Now().UTC().Format("20060102")
+	}
+
+	_, err := fmt.Fprintf(o.w, `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<TRNUID>0</TRNUID>
+<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS>
+<CURDEF>%s</CURDEF>
+<BANKACCTFROM><BANKID>LIORA</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>%s</DTSTART>
+<DTEND>%s</DTEND>
+`, ofxEscaper.Replace(o.opts.Currency), ofxEscaper.Replace(o.opts.SourceAccount), start, end)
+	return err
+}
+
+func (o *ofxWriter) Write(e types.Expense) error {
+	memo := e.Category
+	if e.PaymentMethod != "" {
+		memo = strings.TrimSpace(memo + " " + e.PaymentMethod)
+	}
+
+	_, err := fmt.Fprintf(o.w, `<STMTTRN>
+<TRNTYPE>DEBIT</TRNTYPE>
+<DTPOSTED>%s</DTPOSTED>
+<TRNAMT>-%.2f</TRNAMT>
+<FITID>%s</FITID>
+<NAME>%s</NAME>
+<MEMO>%s</MEMO>
+</STMTTRN>
+`, ofxDate(e.Date), e.Amount, ofxEscaper.Replace(e.ID), ofxEscaper.Replace(truncate(e.Description, 32)), ofxEscaper.Replace(memo))
+	return err
+}
+
+func (o *ofxWriter) End() error {
+	_, err := io.WriteString(o.w, `</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`)
+	return err
+}
+
+// truncate shortens s to n runes; OFX limits NAME to 32 characters.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
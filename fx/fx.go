@@ -0,0 +1,105 @@
+package fx
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// DefaultCurrency is the home currency of users who have not chosen one.
+const DefaultCurrency = "UGX"
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// NormalizeCurrency upper-cases an ISO 4217 code and checks its shape.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !currencyCode.MatchString(code) {
+		return "", fmt.Errorf("invalid currency code %q", code)
+	}
+	return code, nil
+}
+
+// Validate normalizes a rate's currencies and checks the rest of it.
+func Validate(rate *types.ExchangeRate) error {
+	var err error
+	if rate.Base, err = NormalizeCurrency(rate.Base); err != nil {
+		return err
+	}
+	if rate.Quote, err = NormalizeCurrency(rate.Quote); err != nil {
+		return err
+	}
+	if rate.Base == rate.Quote {
+		return errors.New("base and quote currencies must differ")
+	}
+	if _, err := time.Parse("2006-01-02", rate.Date); err != nil {
+		return errors.New("date must be in YYYY-MM-DD format")
+	}
+	if rate.Rate <= 0 {
+		return errors.New("rate must be greater than zero")
+	}
+	return nil
+}
+
+// ParseCSV reads rates from a file with a header naming the base, quote,
+// date and rate columns, in any order. Other columns are ignored.
+func ParseCSV(r io.Reader) ([]types.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"base", "quote", "date", "rate"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("csv header must include a %q column", name)
+		}
+	}
+
+	var rates []types.ExchangeRate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[cols["rate"]]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, record[cols["rate"]])
+		}
+		rate := types.ExchangeRate{
+			Base:  record[cols["base"]],
+			Quote: record[cols["quote"]],
+			Date:  strings.TrimSpace(record[cols["date"]]),
+			Rate:  value,
+		}
+		if err := Validate(&rate); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+
+	if len(rates) == 0 {
+		return nil, errors.New("no rates found in file")
+	}
+	return rates, nil
+}
@@ -0,0 +1,46 @@
+package fx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	rates, err := ParseCSV(strings.NewReader("\ufeffDate,Base,Quote,Rate,Note\n2025-01-01,usd,ugx,3700.5,central bank\n2025-01-01,KES,UGX,28.6,\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []types.ExchangeRate{
+		{Base: "USD", Quote: "UGX", Date: "2025-01-01", Rate: 3700.5},
+		{Base: "KES", Quote: "UGX", Date: "2025-01-01", Rate: 28.6},
+	}, rates)
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":          "",
+		"missing column": "base,quote,rate\nUSD,UGX,1\n",
+		"bad rate":       "base,quote,date,rate\nUSD,UGX,2025-01-01,abc\n",
+		"zero rate":      "base,quote,date,rate\nUSD,UGX,2025-01-01,0\n",
+		"same currency":  "base,quote,date,rate\nUSD,USD,2025-01-01,1\n",
+		"bad date":       "base,quote,date,rate\nUSD,UGX,01/01/2025,1\n",
+		"bad code":       "base,quote,date,rate\nDOLLAR,UGX,2025-01-01,1\n",
+		"no rows":        "base,quote,date,rate\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseCSV(strings.NewReader(input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	code, err := NormalizeCurrency(" kes ")
+	require.NoError(t, err)
+	assert.Equal(t, "KES", code)
+
+	_, err = NormalizeCurrency("KE")
+	assert.Error(t, err)
+}
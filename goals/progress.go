@@ -0,0 +1,75 @@
+// Package goals works out how savings goals are progressing.
+package goals
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const dateLayout = "2006-01-02"
+
+// MonthsLeft counts the monthly contributions still possible before
+// deadline, including the current month. It is zero once the deadline has
+// passed.
+func MonthsLeft(today, deadline time.Time) int {
+	if deadline.Before(today) {
+		return 0
+	}
+	months := (deadline.Year()-today.Year())*12 + int(deadline.Month()-today.Month())
+	if deadline.Day() >= today.Day() {
+		months++
+	}
+	return max(months, 1)
+}
+
+// Progress compares what has been saved towards a goal with a steady pace
+// from the day the goal was created to its deadline. A goal is on track
+// while its savings keep up with that pace.
+func Progress(goal types.SavingsGoal, today time.Time) (types.GoalProgress, error) {
+	deadline, err := time.Parse(dateLayout, goal.Deadline)
+	if err != nil {
+		return types.GoalProgress{}, fmt.Errorf("invalid deadline %q", goal.Deadline)
+	}
+	start, err := time.Parse(dateLayout, goal.CreatedAt)
+	if err != nil {
+		return types.GoalProgress{}, fmt.Errorf("invalid start date %q", goal.CreatedAt)
+	}
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+
+	p := types.GoalProgress{
+		SavingsGoal: goal,
+		Remaining:   roundCents(math.Max(goal.TargetAmount-goal.Saved, 0)),
+		MonthsLeft:  MonthsLeft(today, deadline),
+	}
+	if goal.TargetAmount > 0 {
+		p.PercentComplete = roundCents(goal.Saved / goal.TargetAmount * 100)
+	}
+
+	elapsed := 1.0
+	if total := deadline.Sub(start); total > 0 && today.Before(deadline) {
+		elapsed = math.Max(today.Sub(start).Hours()/total.Hours(), 0)
+	}
+	p.Expected = roundCents(goal.TargetAmount * elapsed)
+
+	switch {
+	case p.Remaining == 0:
+		p.Status = types.GoalAchieved
+	case p.MonthsLeft == 0:
+		p.Status = types.GoalOverdue
+		p.RequiredMonthly = p.Remaining
+	default:
+		p.RequiredMonthly = roundCents(p.Remaining / float64(p.MonthsLeft))
+		p.Status = types.GoalOnTrack
+		if goal.Saved < p.Expected {
+			p.Status = types.GoalBehind
+		}
+	}
+	return p, nil
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,64 @@
+package goals
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse(dateLayout, s)
+	return t
+}
+
+func TestMonthsLeft(t *testing.T) {
+	assert.Equal(t, 4, MonthsLeft(day("2025-10-19"), day("2026-01-31")))
+	assert.Equal(t, 3, MonthsLeft(day("2025-10-19"), day("2026-01-01")))
+	assert.Equal(t, 1, MonthsLeft(day("2025-10-19"), day("2025-10-25")))
+	assert.Equal(t, 1, MonthsLeft(day("2025-10-19"), day("2025-11-10")))
+	assert.Equal(t, 0, MonthsLeft(day("2025-10-19"), day("2025-10-18")))
+}
+
+func TestProgress(t *testing.T) {
+	goal := types.SavingsGoal{
+		Name:         "School fees",
+		TargetAmount: 2000000,
+		Deadline:     "2026-01-31",
+		CreatedAt:    "2025-01-31",
+		Saved:        1200000,
+	}
+
+	// Just over 72% of the way through the year.
+	p, err := Progress(goal, day("2025-10-19"))
+	require.NoError(t, err)
+	assert.Equal(t, 800000.0, p.Remaining)
+	assert.Equal(t, 60.0, p.PercentComplete)
+	assert.Equal(t, 4, p.MonthsLeft)
+	assert.Equal(t, 200000.0, p.RequiredMonthly)
+	assert.Equal(t, types.GoalBehind, p.Status)
+
+	goal.Saved = 1500000
+	p, err = Progress(goal, day("2025-10-19"))
+	require.NoError(t, err)
+	assert.Equal(t, types.GoalOnTrack, p.Status)
+	assert.Equal(t, 125000.0, p.RequiredMonthly)
+
+	goal.Saved = 2100000
+	p, err = Progress(goal, day("2025-10-19"))
+	require.NoError(t, err)
+	assert.Equal(t, types.GoalAchieved, p.Status)
+	assert.Zero(t, p.Remaining)
+	assert.Zero(t, p.RequiredMonthly)
+
+	goal.Saved = 1900000
+	p, err = Progress(goal, day("2026-02-01"))
+	require.NoError(t, err)
+	assert.Equal(t, types.GoalOverdue, p.Status)
+	assert.Equal(t, 100000.0, p.RequiredMonthly)
+
+	_, err = Progress(types.SavingsGoal{Deadline: "January", CreatedAt: "2025-01-31"}, day("2025-10-19"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+// Package history works out what changed between two versions of an
+// expense.
+package history
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Fields lists the tracked expense fields by their JSON names, which are
+// also the keys of a version's changes.
+var Fields = []string{"amount", "date", "description", "category", "paymentMethod", "notes", "payee", "currency", "tags"}
+
+func values(e types.Expense) map[string]any {
+	tags := e.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return map[string]any{
+		"amount":        e.Amount,
+		"date":          e.Date,
+		"description":   e.Description,
+		"category":      e.Category,
+		"paymentMethod": e.PaymentMethod,
+		"notes":         e.Notes,
+		"payee":         e.Payee,
+		"currency":      e.Currency,
+		"tags":          tags,
+	}
+}
+
+// Diff returns the tracked fields that differ between before and after. Tag
+// order is ignored.
+func Diff(before, after types.Expense) map[string]types.FieldChange {
+	before.Tags = sorted(before.Tags)
+	after.Tags = sorted(after.Tags)
+
+	old, new := values(before), values(after)
+	changes := map[string]types.FieldChange{}
+	for _, field := range Fields {
+		if !reflect.DeepEqual(old[field], new[field]) {
+			changes[field] = types.FieldChange{Old: old[field], New: new[field]}
+		}
+	}
+	return changes
+}
+
+// Snapshot returns every tracked field as a change with no old value, for
+// the version that creates an expense.
+func Snapshot(e types.Expense) map[string]types.FieldChange {
+	e.Tags = sorted(e.Tags)
+
+	changes := map[string]types.FieldChange{}
+	for field, value := range values(e) {
+		changes[field] = types.FieldChange{New: value}
+	}
+	return changes
+}
+
+func sorted(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return tags
+}
@@ -0,0 +1,53 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := types.Expense{
+		Amount:      12000,
+		Date:        "2025-01-02",
+		Description: "Lunch",
+		Category:    "Food",
+		Currency:    "UGX",
+		Tags:        []string{"work", "client"},
+	}
+
+	t.Run("No Changes", func(t *testing.T) {
+		after := before
+		after.Tags = []string{"client", "work"}
+		assert.Empty(t, Diff(before, after))
+	})
+
+	t.Run("Changed Fields Only", func(t *testing.T) {
+		after := before
+		after.Amount = 15000
+		after.Notes = "with the team"
+		after.Tags = []string{"work"}
+
+		assert.Equal(t, map[string]types.FieldChange{
+			"amount": {Old: 12000.0, New: 15000.0},
+			"notes":  {Old: "", New: "with the team"},
+			"tags":   {Old: []string{"client", "work"}, New: []string{"work"}},
+		}, Diff(before, after))
+	})
+
+	t.Run("Nil And Empty Tags Match", func(t *testing.T) {
+		a, b := before, before
+		a.Tags, b.Tags = nil, []string{}
+		assert.Empty(t, Diff(a, b))
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	changes := Snapshot(types.Expense{Amount: 5000, Date: "2025-01-03", Currency: "KES"})
+
+	assert.Len(t, changes, len(Fields))
+	assert.Equal(t, types.FieldChange{New: 5000.0}, changes["amount"])
+	assert.Equal(t, types.FieldChange{New: []string{}}, changes["tags"])
+	assert.Nil(t, changes["date"].Old)
+}
@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// ParseCSV reads a bank export using a user-supplied column mapping.
+func ParseCSV(r io.Reader, mapping types.ColumnMapping) ([]Transaction, error) {
+	if mapping.Date == "" || mapping.Description == "" {
+		return nil, errors.New("mapping must name the date and description columns")
+	}
+	if mapping.Amount == "" && mapping.Debit == "" && mapping.Credit == "" {
+		return nil, errors.New("mapping must name an amount column or debit/credit columns")
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if mapping.Delimiter != "" {
+		reader.Comma = []rune(mapping.Delimiter)[0]
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("csv file is empty")
+	}
+
+	var header []string
+	firstLine := 1
+	if !mapping.NoHeader {
+		header = records[0]
+		records = records[1:]
+		firstLine = 2
+	}
+
+	resolve := func(column string) (int, error) {
+		if column == "" {
+			return -1, nil
+		}
+		if mapping.NoHeader {
+			idx, err := strconv.Atoi(column)
+			if err != nil || idx < 0 {
+				return -1, fmt.Errorf("column %q must be a zero-based index when the file has no header", column)
+			}
+			return idx, nil
+		}
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")), column) {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("column %q not found in header", column)
+	}
+
+	var cols [5]int
+	for i, column := range []string{mapping.Date, mapping.Amount, mapping.Debit, mapping.Credit, mapping.Description} {
+		if cols[i], err = resolve(column); err != nil {
+			return nil, err
+		}
+	}
+	dateCol, amountCol, debitCol, creditCol, descCol := cols[0], cols[1], cols[2], cols[3], cols[4]
+
+	field := func(record []string, idx int) string {
+		if idx < 0 || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+
+	var transactions []Transaction
+	for i, record := range records {
+		line := firstLine + i
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		date, err := ParseDate(field(record, dateCol), mapping.DateFormat)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var amount float64
+		if amountCol >= 0 {
+			amount, err = ParseAmount(field(record, amountCol))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if mapping.AmountsPositive {
+				amount = -amount
+			}
+		} else {
+			// Split columns hold unsigned values; money out becomes negative.
+			if debit := field(record, debitCol); debit != "" {
+				value, err := ParseAmount(debit)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				amount -= abs(value)
+			}
+			if credit := field(record, creditCol); credit != "" {
+				value, err := ParseAmount(credit)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				amount += abs(value)
+			}
+		}
+
+		transactions = append(transactions, Transaction{
+			Line:        line,
+			Date:        date,
+			Amount:      amount,
+			Description: field(record, descCol),
+		})
+	}
+	return transactions, nil
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
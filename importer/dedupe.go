@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// DefaultWindowDays is how far apart two dates may be and still count as the
+// same transaction; banks often post a card payment a day or two late.
+const DefaultWindowDays = 3
+
+// similarityThreshold is the minimum description similarity for a fuzzy match.
+const similarityThreshold = 0.5
+
+// Rows turns statement transactions into import rows. Only money out becomes
+// an expense; credits such as salary or refunds are dropped.
+func Rows(transactions []Transaction) []types.ImportRow {
+	rows := make([]types.ImportRow, 0, len(transactions))
+	for _, t := range transactions {
+		if t.Amount >= 0 {
+			continue
+		}
+		rows = append(rows, types.ImportRow{
+			Line:        t.Line,
+			Date:        t.Date.Format(dateLayout),
+			Amount:      math.Round(-t.Amount*100) / 100,
+			Description: t.Description,
+		})
+	}
+	return rows
+}
+
+// MarkDuplicates flags rows that fuzzy-match an existing expense: same
+// amount, dates within windowDays of each other and similar descriptions.
+// Each existing expense can only absorb one row, so two identical coffees on
+// the same day are not both discarded because of a single stored one.
+func MarkDuplicates(rows []types.ImportRow, existing []types.Expense, windowDays int) {
+	used := make(map[string]bool)
+	for i := range rows {
+		rowDate, err := time.Parse(dateLayout, rows[i].Date)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range existing {
+			if used[e.ID] || math.Abs(e.Amount-rows[i].Amount) >= 0.005 {
+				continue
+			}
+
+			date, err := time.Parse(dateLayout, e.Date)
+			if err != nil {
+				continue
+			}
+			if math.Abs(date.Sub(rowDate).Hours()/24) > float64(windowDays) {
+				continue
+			}
+
+			if Similarity(e.Description, rows[i].Description) >= similarityThreshold {
+				rows[i].Duplicate = true
+				rows[i].DuplicateOf = e.ID
+				used[e.ID] = true
+				break
+			}
+		}
+	}
+}
+
+// Similarity scores two descriptions between 0 and 1 using the Dice
+// coefficient over their words, ignoring case, punctuation and digits-only
+// tokens such as card references. A description contained in the other
+// counts as a full match.
+func Similarity(a, b string) float64 {
+	na, nb := normalise(a), normalise(b)
+	if len(na) == 0 && len(nb) == 0 {
+		return 1
+	}
+	if len(na) == 0 || len(nb) == 0 {
+		return 0
+	}
+
+	ja, jb := strings.Join(na, " "), strings.Join(nb, " ")
+	if strings.Contains(ja, jb) || strings.Contains(jb, ja) {
+		return 1
+	}
+
+	words := make(map[string]int)
+	for _, w := range na {
+		words[w]++
+	}
+	common := 0
+	for _, w := range nb {
+		if words[w] > 0 {
+			words[w]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(na)+len(nb))
+}
+
+func normalise(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	words := fields[:0]
+	for _, f := range fields {
+		if strings.IndexFunc(f, unicode.IsLetter) >= 0 {
+			words = append(words, f)
+		}
+	}
+	return words
+}
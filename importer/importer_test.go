@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	cases := map[string]float64{
+		"-1,234.50":     -1234.50,
+		"1.234,50":      1234.50,
+		"UGX 5 000":     5000,
+		"(12.00)":       -12,
+		"12.00-":        -12,
+		"$ 7.5":         7.5,
+		"10,5":          10.5,
+		"1,000,000":     1000000,
+		"-KES 250":      -250,
+		"1.000.000":     1000000,
+		"Rs.1,250":      1250,
+		"USh. 5,000":    5000,
+		"-Rs. 99.50":    -99.50,
+		"Kshs.2.500,75": 2500.75,
+		".50":           0.5,
+	}
+	for input, want := range cases {
+		got, err := ParseAmount(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	_, err := ParseAmount("abc")
+	assert.Error(t, err)
+}
+
+func TestParseDate(t *testing.T) {
+	for _, input := range []string{"2025-03-04", "04/03/2025", "4 Mar 2025", "20250304"} {
+		d, err := ParseDate(input, "")
+		require.NoError(t, err, input)
+		assert.Equal(t, "2025-03-04", d.Format(dateLayout), input)
+	}
+
+	d, err := ParseDate("03/04/2025", "01/02/2006")
+	require.NoError(t, err)
+	assert.Equal(t, "2025-03-04", d.Format(dateLayout))
+}
+
+func TestParseCSV(t *testing.T) {
+	t.Run("Header Mapping", func(t *testing.T) {
+		input := "Posted,Details,Value\n2025-01-02,Shoprite Kampala,-45000\n2025-01-03,Salary,900000\n"
+		txs, err := ParseCSV(strings.NewReader(input), types.ColumnMapping{
+			Date: "posted", Description: "Details", Amount: "Value",
+		})
+		require.NoError(t, err)
+		require.Len(t, txs, 2)
+		assert.Equal(t, 2, txs[0].Line)
+		assert.Equal(t, -45000.0, txs[0].Amount)
+
+		rows := Rows(txs)
+		require.Len(t, rows, 1)
+		assert.Equal(t, 45000.0, rows[0].Amount)
+	})
+
+	t.Run("Debit Credit Columns Without Header", func(t *testing.T) {
+		input := "02/01/2025;Airtime;5000;\n03/01/2025;Refund;;2000\n"
+		txs, err := ParseCSV(strings.NewReader(input), types.ColumnMapping{
+			Date: "0", Description: "1", Debit: "2", Credit: "3", Delimiter: ";", NoHeader: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, txs, 2)
+		assert.Equal(t, -5000.0, txs[0].Amount)
+		assert.Equal(t, 2000.0, txs[1].Amount)
+	})
+
+	t.Run("Unknown Column", func(t *testing.T) {
+		_, err := ParseCSV(strings.NewReader("a,b\n"), types.ColumnMapping{Date: "date", Description: "b", Amount: "a"})
+		assert.Error(t, err)
+	})
+}
+
+func TestParseOFX(t *testing.T) {
+	input := `OFXHEADER:100
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250105120000[+3:EAT]
+<TRNAMT>-12500.00
+<NAME>JUMIA &amp; CO
+<MEMO>Order 1234
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250106
+<TRNAMT>300.00
+<NAME>Refund
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+
+	txs, err := ParseOFX(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	assert.Equal(t, "2025-01-05", txs[0].Date.Format(dateLayout))
+	assert.Equal(t, -12500.0, txs[0].Amount)
+	assert.Equal(t, "JUMIA & CO - Order 1234", txs[0].Description)
+	assert.Equal(t, 300.0, txs[1].Amount)
+}
+
+func TestParseQIF(t *testing.T) {
+	input := "!Type:Bank\nD1/15'25\nT-20.00\nPCafe Javas\n^\nD01/16/2025\nT-1,000.00\nMRent\n^\n"
+
+	txs, err := ParseQIF(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	assert.Equal(t, "2025-01-15", txs[0].Date.Format(dateLayout))
+	assert.Equal(t, "Cafe Javas", txs[0].Description)
+	assert.Equal(t, -1000.0, txs[1].Amount)
+	assert.Equal(t, "Rent", txs[1].Description)
+}
+
+func TestMarkDuplicates(t *testing.T) {
+	existing := []types.Expense{
+		{ID: "e1", Amount: 45000, Date: "2025-01-01", Description: "SHOPRITE KAMPALA 0042"},
+	}
+	rows := []types.ImportRow{
+		{Date: "2025-01-02", Amount: 45000, Description: "Shoprite Kampala"},
+		{Date: "2025-01-02", Amount: 45000, Description: "Shoprite Kampala"},
+		{Date: "2025-01-10", Amount: 45000, Description: "Shoprite Kampala"},
+		{Date: "2025-01-02", Amount: 45000, Description: "Uber trip"},
+	}
+
+	MarkDuplicates(rows, existing, DefaultWindowDays)
+
+	assert.True(t, rows[0].Duplicate)
+	assert.Equal(t, "e1", rows[0].DuplicateOf)
+	assert.False(t, rows[1].Duplicate)
+	assert.False(t, rows[2].Duplicate)
+	assert.False(t, rows[3].Duplicate)
+}
@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var ofxTag = regexp.MustCompile(`<([A-Za-z0-9.]+)>([^<\r\n]*)`)
+
+// ParseOFX reads the STMTTRN records from an OFX statement. Both the SGML
+// flavour (OFX 1.x, unclosed tags) and the XML flavour (OFX 2.x) are accepted.
+func ParseOFX(r io.Reader) ([]Transaction, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var (
+		transactions []Transaction
+		current      map[string]string
+		startLine    int
+		line         int
+	)
+
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		defer func() { current = nil }()
+
+		posted := current["DTPOSTED"]
+		if len(posted) >= 8 {
+			posted = posted[:8]
+		}
+		date, err := ParseDate(posted, "20060102")
+		if err != nil {
+			return fmt.Errorf("line %d: %w", startLine, err)
+		}
+
+		amount, err := ParseAmount(current["TRNAMT"])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", startLine, err)
+		}
+
+		description := current["NAME"]
+		if memo := current["MEMO"]; memo != "" {
+			if description == "" {
+				description = memo
+			} else if !strings.EqualFold(memo, description) {
+				description += " - " + memo
+			}
+		}
+
+		transactions = append(transactions, Transaction{
+			Line:        startLine,
+			Date:        date,
+			Amount:      amount,
+			Description: description,
+		})
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+		for _, match := range ofxTag.FindAllStringSubmatch(scanner.Text(), -1) {
+			tag := strings.ToUpper(match[1])
+			value := strings.TrimSpace(match[2])
+
+			switch {
+			case tag == "STMTTRN":
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				current = make(map[string]string)
+				startLine = line
+			case current != nil && value != "":
+				current[tag] = unescapeOFX(value)
+			}
+		}
+
+		if strings.Contains(strings.ToUpper(scanner.Text()), "</STMTTRN>") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if len(transactions) == 0 {
+		return nil, errors.New("no transactions found in ofx file")
+	}
+	return transactions, nil
+}
+
+func unescapeOFX(value string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">").Replace(value)
+}
@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const dateLayout = "2006-01-02"
+
+// Transaction is a statement line as read from the file. Amount keeps the
+// statement's sign: money out is negative.
+type Transaction struct {
+	Line        int
+	Date        time.Time
+	Amount      float64
+	Description string
+}
+
+// Parse reads a statement in the given format. The column mapping is only
+// used for CSV files.
+func Parse(format types.ImportFormat, r io.Reader, mapping types.ColumnMapping) ([]Transaction, error) {
+	switch format {
+	case types.ImportFormatCSV:
+		return ParseCSV(r, mapping)
+	case types.ImportFormatOFX:
+		return ParseOFX(r)
+	case types.ImportFormatQIF:
+		return ParseQIF(r)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"02/01/2006",
+	"02-01-2006",
+	"02.01.2006",
+	"01/02/2006",
+	"2 Jan 2006",
+	"02 Jan 2006",
+	"Jan 2, 2006",
+	"2-Jan-2006",
+	"02-Jan-06",
+	"20060102",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+// ParseDate reads a statement date. An explicit layout is tried first; after
+// that day-first formats win over month-first ones, matching how most banks
+// outside the US print dates.
+func ParseDate(value, layout string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	if layout != "" {
+		if t, err := time.Parse(layout, value); err == nil {
+			return truncateDay(t), nil
+		}
+	}
+
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, value); err == nil {
+			return truncateDay(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ParseAmount reads a money value such as "-1,234.50", "UGX 5 000",
+// "Rs.1,250", "(12.00)", "12.00-" or "1.234,50", ignoring currency symbols
+// and codes.
+func ParseAmount(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = value[1 : len(value)-1]
+	}
+	if strings.HasSuffix(value, "-") {
+		negative = !negative
+		value = strings.TrimSuffix(value, "-")
+	}
+
+	// Currency text before the number can end in a dot, as in "Rs.1,250" or
+	// "USh. 5,000", so it is dropped before looking for separators. A
+	// separator right before the digits, as in ".50", starts the number
+	// unless it follows a letter.
+	if i := strings.IndexFunc(value, unicode.IsDigit); i > 0 {
+		prefix, number := value[:i], value[i:]
+		if last, size := utf8.DecodeLastRuneInString(prefix); last == '.' || last == ',' {
+			before, _ := utf8.DecodeLastRuneInString(prefix[:len(prefix)-size])
+			if !unicode.IsLetter(before) {
+				number = prefix[len(prefix)-size:] + number
+			}
+		}
+		if strings.Count(prefix, "-")%2 == 1 {
+			negative = !negative
+		}
+		value = number
+	}
+
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case unicode.IsDigit(r), r == '.', r == ',':
+			b.WriteRune(r)
+		case r == '-':
+			negative = !negative
+		}
+	}
+	digits := b.String()
+	if digits == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	lastDot := strings.LastIndex(digits, ".")
+	lastComma := strings.LastIndex(digits, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Whichever separator comes last is the decimal point.
+		if lastComma > lastDot {
+			digits = strings.ReplaceAll(digits, ".", "")
+			digits = strings.Replace(digits, ",", ".", 1)
+		} else {
+			digits = strings.ReplaceAll(digits, ",", "")
+		}
+	case lastComma >= 0:
+		// A single comma followed by one or two digits is a decimal comma,
+		// anything else is a thousands separator.
+		if strings.Count(digits, ",") == 1 && len(digits)-lastComma-1 <= 2 {
+			digits = strings.Replace(digits, ",", ".", 1)
+		} else {
+			digits = strings.ReplaceAll(digits, ",", "")
+		}
+	case strings.Count(digits, ".") > 1:
+		digits = strings.ReplaceAll(digits, ".", "")
+	}
+
+	amount, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var qifDateLayouts = []string{"01/02/2006", "01/02/06", "1/2/2006", "1/2/06", "01-02-2006", "2006-01-02"}
+
+// ParseQIF reads a Quicken Interchange Format file. Only the date (D),
+// amount (T or U), payee (P) and memo (M) fields are used.
+func ParseQIF(r io.Reader) ([]Transaction, error) {
+	scanner := bufio.NewScanner(r)
+
+	var (
+		transactions []Transaction
+		current      Transaction
+		hasFields    bool
+		line         int
+		rawDate      string
+		rawAmount    string
+		memo         string
+	)
+
+	flush := func() error {
+		if !hasFields {
+			return nil
+		}
+		defer func() {
+			current, hasFields, rawDate, rawAmount, memo = Transaction{}, false, "", "", ""
+		}()
+
+		date, err := parseQIFDate(rawDate)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", current.Line, err)
+		}
+		amount, err := ParseAmount(rawAmount)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", current.Line, err)
+		}
+
+		current.Date = date
+		current.Amount = amount
+		if current.Description == "" {
+			current.Description = memo
+		}
+		transactions = append(transactions, current)
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" || strings.HasPrefix(text, "!") {
+			continue
+		}
+		if text == "^" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !hasFields {
+			current.Line = line
+			hasFields = true
+		}
+
+		value := strings.TrimSpace(text[1:])
+		switch text[0] {
+		case 'D':
+			rawDate = value
+		case 'T', 'U':
+			rawAmount = value
+		case 'P':
+			current.Description = value
+		case 'M':
+			memo = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if len(transactions) == 0 {
+		return nil, errors.New("no transactions found in qif file")
+	}
+	return transactions, nil
+}
+
+// parseQIFDate handles Quicken's month-first dates, including the
+// apostrophe form used for years after 2000 ("1/15'25").
+func parseQIFDate(value string) (time.Time, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), "'", "/")
+	value = strings.ReplaceAll(value, " ", "")
+	for _, layout := range qifDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return truncateDay(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
@@ -0,0 +1,126 @@
+// Package ledger holds the rules of the double-entry wallet ledger. Money
+// is counted in int64 minor units so balances never suffer rounding.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+var (
+	ErrUnbalanced        = errors.New("journal entry postings do not sum to zero")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrDuplicateEntry    = errors.New("journal entry reference already used")
+	ErrCurrencyMismatch  = errors.New("posting currency does not match the account")
+)
+
+// exponents lists currencies whose minor unit is not a hundredth.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Exponent is the number of decimal places in currency's minor unit.
+func Exponent(currency string) int {
+	if e, ok := exponents[strings.ToUpper(currency)]; ok {
+		return e
+	}
+	return 2
+}
+
+// ToMinor converts a decimal amount to minor units, refusing amounts with
+// more precision than the currency has.
+func ToMinor(amount float64, currency string) (int64, error) {
+	scaled := amount * math.Pow10(Exponent(currency))
+	minor := math.Round(scaled)
+	if math.Abs(scaled-minor) > 1e-6 {
+		return 0, fmt.Errorf("%s amounts have at most %d decimal places", currency, Exponent(currency))
+	}
+	if math.Abs(minor) > math.MaxInt64/2 {
+		return 0, fmt.Errorf("amount is too large")
+	}
+	return int64(minor), nil
+}
+
+// FromMinor converts minor units back to a decimal amount for display.
+func FromMinor(minor int64, currency string) float64 {
+	return float64(minor) / math.Pow10(Exponent(currency))
+}
+
+// Format renders minor units as a decimal amount with its currency, such
+// as "19.99 USD".
+func Format(minor int64, currency string) string {
+	return fmt.Sprintf("%.*f %s", Exponent(currency), FromMinor(minor, currency), currency)
+}
+
+// Validate checks that an entry can be posted: it needs a kind, a
+// reference and at least two non-zero postings summing to zero in each
+// currency.
+func Validate(entry types.JournalEntry) error {
+	if strings.TrimSpace(entry.Kind) == "" {
+		return fmt.Errorf("journal entry kind is required")
+	}
+	if strings.TrimSpace(entry.Reference) == "" {
+		return fmt.Errorf("journal entry reference is required")
+	}
+	if len(entry.Postings) < 2 {
+		return fmt.Errorf("journal entry needs at least two postings")
+	}
+
+	sums := map[string]int64{}
+	for _, p := range entry.Postings {
+		if p.AccountID == "" {
+			return fmt.Errorf("posting account is required")
+		}
+		if p.Amount == 0 {
+			return fmt.Errorf("posting amount must not be zero")
+		}
+		if p.Currency == "" {
+			return fmt.Errorf("posting currency is required")
+		}
+		sum, overflow := add(sums[p.Currency], p.Amount)
+		if overflow {
+			return fmt.Errorf("posting amounts are too large")
+		}
+		sums[p.Currency] = sum
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("%w: %s is off by %d", ErrUnbalanced, currency, sum)
+		}
+	}
+	return nil
+}
+
+// AccountOrder returns the distinct accounts of an entry in the order they
+// must be locked. Locking in one global order keeps concurrent postings to
+// the same accounts from deadlocking.
+func AccountOrder(entry types.JournalEntry) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, p := range entry.Postings {
+		if !seen[p.AccountID] {
+			seen[p.AccountID] = true
+			ids = append(ids, p.AccountID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CanGoNegative reports whether an account of kind may have a balance
+// below zero.
+func CanGoNegative(kind types.LedgerAccountKind) bool {
+	return kind != types.AccountWallet
+}
+
+func add(a, b int64) (int64, bool) {
+	sum := a + b
+	return sum, (b > 0 && sum < a) || (b < 0 && sum > a)
+}
@@ -0,0 +1,80 @@
+package ledger
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToMinor(t *testing.T) {
+	minor, err := ToMinor(2000000, "UGX")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2000000), minor)
+
+	minor, err = ToMinor(19.99, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1999), minor)
+
+	minor, err = ToMinor(1.234, "KWD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1234), minor)
+
+	_, err = ToMinor(10.5, "UGX")
+	assert.Error(t, err)
+	_, err = ToMinor(0.001, "USD")
+	assert.Error(t, err)
+
+	assert.Equal(t, 19.99, FromMinor(1999, "USD"))
+	assert.Equal(t, 500.0, FromMinor(500, "UGX"))
+	assert.Equal(t, "19.99 USD", Format(1999, "USD"))
+	assert.Equal(t, "2000000 UGX", Format(2000000, "UGX"))
+}
+
+func TestValidate(t *testing.T) {
+	entry := types.JournalEntry{
+		Kind:      "topup",
+		Reference: "topup:1",
+		Postings: []types.Posting{
+			{AccountID: "float", Amount: -5000, Currency: "UGX"},
+			{AccountID: "wallet", Amount: 4900, Currency: "UGX"},
+			{AccountID: "fees", Amount: 100, Currency: "UGX"},
+		},
+	}
+	require.NoError(t, Validate(entry))
+
+	unbalanced := entry
+	unbalanced.Postings = []types.Posting{
+		{AccountID: "float", Amount: -5000, Currency: "UGX"},
+		{AccountID: "wallet", Amount: 5000, Currency: "USD"},
+	}
+	assert.True(t, errors.Is(Validate(unbalanced), ErrUnbalanced))
+
+	invalid := []types.JournalEntry{
+		{Reference: "r", Postings: entry.Postings},
+		{Kind: "topup", Postings: entry.Postings},
+		{Kind: "topup", Reference: "r", Postings: entry.Postings[:1]},
+		{Kind: "topup", Reference: "r", Postings: []types.Posting{
+			{AccountID: "a", Amount: 0, Currency: "UGX"},
+			{AccountID: "b", Amount: 0, Currency: "UGX"},
+		}},
+		{Kind: "topup", Reference: "r", Postings: []types.Posting{
+			{AccountID: "a", Amount: math.MaxInt64, Currency: "UGX"},
+			{AccountID: "b", Amount: math.MaxInt64, Currency: "UGX"},
+			{AccountID: "c", Amount: 2, Currency: "UGX"},
+		}},
+	}
+	for _, e := range invalid {
+		assert.Error(t, Validate(e))
+	}
+}
+
+func TestAccountOrder(t *testing.T) {
+	entry := types.JournalEntry{Postings: []types.Posting{
+		{AccountID: "c"}, {AccountID: "a"}, {AccountID: "c"}, {AccountID: "b"},
+	}}
+	assert.Equal(t, []string{"a", "b", "c"}, AccountOrder(entry))
+}
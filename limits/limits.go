@@ -0,0 +1,157 @@
+// Package limits checks outgoing transactions against the limit rules for
+// a user's KYC tier.
+package limits
+
+import (
+	"fmt"
+
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Reason codes reported in a types.LimitViolation.
+const (
+	CodeSingle       = "single_limit_exceeded"
+	CodeDaily        = "daily_limit_exceeded"
+	CodeMonthly      = "monthly_limit_exceeded"
+	CodeHourlyCount  = "hourly_count_exceeded"
+	CodeNewRecipient = "new_recipient_limit_exceeded"
+	CodeCurrency     = "currency_not_allowed"
+)
+
+// Evaluate checks attempt against every rule that applies to it and
+// reports each one it would break. Rules for other tiers, actions or
+// currencies are ignored, so a tier with no rules has no limits. A tier
+// that caps amounts but has no amount rule in the attempt's currency
+// refuses it, as the amount could not be held to any of them.
+func Evaluate(rules []types.LimitRule, attempt types.LimitAttempt, usage types.LimitUsage) types.LimitDecision {
+	decision := types.LimitDecision{Allowed: true, Tier: attempt.Tier}
+
+	capped, covered := false, false
+	for _, rule := range rules {
+		if rule.Tier != attempt.Tier || (rule.Action != "" && rule.Action != attempt.Action) {
+			continue
+		}
+		if rule.Metric != types.LimitHourlyCount {
+			capped = true
+			if rule.Currency != attempt.Currency {
+				continue
+			}
+			covered = true
+		}
+
+		window := usageFor(usage, rule.Action)
+		violation := types.LimitViolation{RuleID: rule.ID, Metric: rule.Metric, Limit: rule.Value}
+		switch rule.Metric {
+		case types.LimitSingle:
+			if attempt.Amount <= rule.Value {
+				continue
+			}
+			violation.Code = CodeSingle
+			violation.Current = attempt.Amount
+			violation.Message = fmt.Sprintf("a single %s can be at most %s", label(rule.Action), ledger.Format(rule.Value, rule.Currency))
+		case types.LimitDaily, types.LimitMonthly:
+			used, period, code := window.Day, "today", CodeDaily
+			if rule.Metric == types.LimitMonthly {
+				used, period, code = window.Month, "this month", CodeMonthly
+			}
+			if used+attempt.Amount <= rule.Value {
+				continue
+			}
+			violation.Code = code
+			violation.Current = used
+			violation.Message = fmt.Sprintf("%s limit of %s %s would be exceeded; %s already sent",
+				label(rule.Action), ledger.Format(rule.Value, rule.Currency), period, ledger.Format(used, rule.Currency))
+		case types.LimitHourlyCount:
+			if int64(window.LastHour) < rule.Value {
+				continue
+			}
+			violation.Code = CodeHourlyCount
+			violation.Current = int64(window.LastHour)
+			violation.Message = fmt.Sprintf("at most %d %ss can be made in an hour", rule.Value, label(rule.Action))
+		case types.LimitNewRecipient:
+			if attempt.Action != types.LimitTransfer || attempt.RecipientID == "" || usage.KnownRecipient || attempt.Amount <= rule.Value {
+				continue
+			}
+			violation.Code = CodeNewRecipient
+			violation.Current = attempt.Amount
+			violation.Message = fmt.Sprintf("the first transfer to a new recipient can be at most %s", ledger.Format(rule.Value, rule.Currency))
+		default:
+			continue
+		}
+
+		decision.Allowed = false
+		decision.Violations = append(decision.Violations, violation)
+	}
+
+	if capped && !covered {
+		decision.Allowed = false
+		decision.Violations = append([]types.LimitViolation{{
+			Code:    CodeCurrency,
+			Message: fmt.Sprintf("%ss in %s are not available at this verification level", label(attempt.Action), attempt.Currency),
+		}}, decision.Violations...)
+	}
+	return decision
+}
+
+// usageFor returns the activity a rule for action counts; a rule for every
+// action counts transfers and withdrawals together.
+func usageFor(usage types.LimitUsage, action types.LimitAction) types.LimitWindow {
+	switch action {
+	case types.LimitTransfer:
+		return usage.Transfers
+	case types.LimitWithdrawal:
+		return usage.Withdrawals
+	}
+	return types.LimitWindow{
+		Day:      usage.Transfers.Day + usage.Withdrawals.Day,
+		Month:    usage.Transfers.Month + usage.Withdrawals.Month,
+		LastHour: usage.Transfers.LastHour + usage.Withdrawals.LastHour,
+	}
+}
+
+func label(action types.LimitAction) string {
+	if action == "" {
+		return "transaction"
+	}
+	return string(action)
+}
+
+// ValidateRule checks a rule entered by an admin and normalises its
+// currency.
+func ValidateRule(rule *types.LimitRule) error {
+	if rule.Tier < types.KYCTierNone || rule.Tier > types.KYCTierFull {
+		return fmt.Errorf("tier must be between %d and %d", types.KYCTierNone, types.KYCTierFull)
+	}
+	switch rule.Action {
+	case "", types.LimitTransfer, types.LimitWithdrawal:
+	default:
+		return fmt.Errorf("action must be transfer, withdrawal or empty for both")
+	}
+	if rule.Value < 0 {
+		return fmt.Errorf("value must not be negative")
+	}
+
+	switch rule.Metric {
+	case types.LimitHourlyCount:
+		if rule.Currency != "" {
+			return fmt.Errorf("%s rules have no currency", rule.Metric)
+		}
+		return nil
+	case types.LimitNewRecipient:
+		if rule.Action == types.LimitWithdrawal {
+			return fmt.Errorf("%s rules only apply to transfers", rule.Metric)
+		}
+	case types.LimitSingle, types.LimitDaily, types.LimitMonthly:
+	default:
+		return fmt.Errorf("unknown metric %q", rule.Metric)
+	}
+
+	currency, err := fx.NormalizeCurrency(rule.Currency)
+	if err != nil {
+		return err
+	}
+	rule.Currency = currency
+	return nil
+}
@@ -0,0 +1,113 @@
+package limits
+
+import (
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rules = []types.LimitRule{
+	{ID: "single", Tier: 0, Action: types.LimitTransfer, Metric: types.LimitSingle, Currency: "UGX", Value: 500000},
+	{ID: "daily", Tier: 0, Metric: types.LimitDaily, Currency: "UGX", Value: 1000000},
+	{ID: "monthly", Tier: 0, Metric: types.LimitMonthly, Currency: "UGX", Value: 3000000},
+	{ID: "hourly", Tier: 0, Metric: types.LimitHourlyCount, Value: 5},
+	{ID: "new", Tier: 0, Action: types.LimitTransfer, Metric: types.LimitNewRecipient, Currency: "UGX", Value: 100000},
+	{ID: "withdraw", Tier: 0, Action: types.LimitWithdrawal, Metric: types.LimitSingle, Currency: "UGX", Value: 200000},
+	{ID: "tier1", Tier: 1, Action: types.LimitTransfer, Metric: types.LimitSingle, Currency: "UGX", Value: 10},
+	{ID: "usd", Tier: 0, Action: types.LimitTransfer, Metric: types.LimitSingle, Currency: "USD", Value: 10000},
+}
+
+func codes(d types.LimitDecision) []string {
+	var out []string
+	for _, v := range d.Violations {
+		out = append(out, v.Code)
+	}
+	return out
+}
+
+func TestEvaluateAllowed(t *testing.T) {
+	attempt := types.LimitAttempt{Action: types.LimitTransfer, Amount: 50000, Currency: "UGX", RecipientID: "u2"}
+	d := Evaluate(rules, attempt, types.LimitUsage{})
+	assert.True(t, d.Allowed)
+	assert.Empty(t, d.Violations)
+
+	// Tier 1 only has its own rule.
+	attempt.Tier = 1
+	attempt.Amount = 11
+	assert.Equal(t, []string{CodeSingle}, codes(Evaluate(rules, attempt, types.LimitUsage{})))
+}
+
+func TestEvaluateViolations(t *testing.T) {
+	attempt := types.LimitAttempt{Action: types.LimitTransfer, Amount: 600000, Currency: "UGX", RecipientID: "u2"}
+	usage := types.LimitUsage{
+		Transfers:   types.LimitWindow{Day: 300000, Month: 2000000, LastHour: 3},
+		Withdrawals: types.LimitWindow{Day: 200000, Month: 500000, LastHour: 2},
+	}
+
+	d := Evaluate(rules, attempt, usage)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, []string{CodeSingle, CodeDaily, CodeMonthly, CodeHourlyCount, CodeNewRecipient}, codes(d))
+
+	daily := d.Violations[1]
+	assert.Equal(t, "daily", daily.RuleID)
+	assert.Equal(t, int64(1000000), daily.Limit)
+	assert.Equal(t, int64(500000), daily.Current)
+	assert.Contains(t, daily.Message, "1000000 UGX")
+
+	usage.KnownRecipient = true
+	usage.Transfers.Day = 700000
+	usage.Withdrawals.LastHour = 1
+	attempt.Amount = 400000
+	assert.Equal(t, []string{CodeDaily}, codes(Evaluate(rules, attempt, usage)))
+}
+
+func TestEvaluateCurrencyAndAction(t *testing.T) {
+	withdrawal := types.LimitAttempt{Action: types.LimitWithdrawal, Amount: 250000, Currency: "UGX"}
+	assert.Equal(t, []string{CodeSingle}, codes(Evaluate(rules, withdrawal, types.LimitUsage{})))
+
+	usd := types.LimitAttempt{Action: types.LimitTransfer, Amount: 20000, Currency: "USD", RecipientID: "u2"}
+	d := Evaluate(rules, usd, types.LimitUsage{})
+	require.Len(t, d.Violations, 1)
+	assert.Equal(t, "usd", d.Violations[0].RuleID)
+
+	// KES has no amount rules at tier 0, so a transfer in it is refused
+	// rather than let through uncapped.
+	kes := types.LimitAttempt{Action: types.LimitTransfer, Amount: 10000000, Currency: "KES", RecipientID: "u2"}
+	d = Evaluate(rules, kes, types.LimitUsage{})
+	assert.False(t, d.Allowed)
+	assert.Equal(t, []string{CodeCurrency}, codes(d))
+	assert.Contains(t, d.Violations[0].Message, "KES")
+
+	// The USD rule only covers transfers.
+	usd.Action = types.LimitWithdrawal
+	usd.RecipientID = ""
+	assert.Equal(t, []string{CodeCurrency}, codes(Evaluate(rules, usd, types.LimitUsage{})))
+
+	// A tier without amount rules has no amount limits in any currency.
+	kes.Tier = 2
+	assert.True(t, Evaluate(rules, kes, types.LimitUsage{}).Allowed)
+}
+
+func TestValidateRule(t *testing.T) {
+	rule := types.LimitRule{Tier: 1, Metric: types.LimitDaily, Currency: "ugx", Value: 100}
+	require.NoError(t, ValidateRule(&rule))
+	assert.Equal(t, "UGX", rule.Currency)
+
+	require.NoError(t, ValidateRule(&types.LimitRule{Metric: types.LimitHourlyCount, Value: 10}))
+
+	invalid := []types.LimitRule{
+		{Tier: 3, Metric: types.LimitDaily, Currency: "UGX"},
+		{Tier: -1, Metric: types.LimitDaily, Currency: "UGX"},
+		{Action: "deposit", Metric: types.LimitDaily, Currency: "UGX"},
+		{Metric: "weekly_amount", Currency: "UGX"},
+		{Metric: types.LimitDaily, Currency: "UGX", Value: -1},
+		{Metric: types.LimitDaily},
+		{Metric: types.LimitHourlyCount, Currency: "UGX"},
+		{Action: types.LimitWithdrawal, Metric: types.LimitNewRecipient, Currency: "UGX"},
+	}
+	for _, r := range invalid {
+		assert.Error(t, ValidateRule(&r), "%+v", r)
+	}
+}
@@ -5,10 +5,16 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/Ayikoandrew/server/api"
+	"github.com/Ayikoandrew/server/blob"
 	"github.com/Ayikoandrew/server/database"
+	"github.com/Ayikoandrew/server/notify"
+	"github.com/Ayikoandrew/server/payments"
 )
 
 func main() {
@@ -34,7 +40,54 @@ func main() {
 		os.Exit(1)
 	}
 
-	server := api.NewServer(":"+port, store)
-	server.Run()
+	// ADMIN_EMAILS is a comma-separated list of accounts allowed to manage
+	// shared data such as exchange rates.
+	if err := store.GrantAdmins(strings.Split(os.Getenv("ADMIN_EMAILS"), ",")); err != nil {
+		slog.Error("error granting admin roles", "err", err)
+		os.Exit(1)
+	}
+
+	blobs, err := blob.NewFromEnv()
+	if err != nil {
+		slog.Error("error configuring blob storage", "err", err)
+		os.Exit(1)
+	}
+
+	opts := []api.Option{
+		api.WithBlobStore(blobs),
+		api.WithNotifier(notify.NewFromEnv(store, store)),
+		api.WithIdempotencyStore(database.NewIdempotencyStore()),
+	}
+	if raw := os.Getenv("BATCH_MAX_SIZE"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size <= 0 {
+			slog.Error("BATCH_MAX_SIZE must be a positive number", "value", raw)
+			os.Exit(1)
+		}
+		opts = append(opts, api.WithMaxBatchSize(size))
+	}
+
+	// PAYMENT_PROVIDER picks how wallets are topped up and withdrawn. Only
+	// the in-process fake exists so far; it calls PAYMENT_FAKE_WEBHOOK_URL,
+	// normally this server's /payments/webhook/fake, when payments settle.
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "":
+	case "fake":
+		secret := []byte(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+		if len(secret) == 0 {
+			slog.Error("PAYMENT_WEBHOOK_SECRET is required when PAYMENT_PROVIDER is set")
+			os.Exit(1)
+		}
+		fake := payments.NewFake(os.Getenv("PAYMENT_FAKE_WEBHOOK_URL"), secret, 5*time.Second)
+		opts = append(opts, api.WithPaymentProvider(fake, secret))
+	default:
+		slog.Error("unknown PAYMENT_PROVIDER", "value", provider)
+		os.Exit(1)
+	}
+
+	server := api.NewServer(":"+port, store, opts...)
 	server.StartTokenCleanup(24 * time.Hour)
+	server.StartScheduledTransfers(time.Minute)
+	server.StartPaymentPolling(time.Minute)
+	server.Run()
 }
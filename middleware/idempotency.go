@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Ayikoandrew/server/security"
+	"github.com/Ayikoandrew/server/types"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on responses served from a stored result.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	IdempotencyTTL     = 24 * time.Hour
+	maxIdempotencyKey  = 255
+	maxIdempotentBody  = 16 << 20
+	idempotencyTimeout = 5 * time.Second
+)
+
+// IdempotencyStore keeps idempotency records until they expire.
+type IdempotencyStore interface {
+	// Reserve claims key for a request with the given fingerprint. If the
+	// key is already taken it returns false with the record held for it.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (bool, *types.IdempotencyRecord, error)
+	// Complete stores the final response for a reserved key.
+	Complete(ctx context.Context, key string, record *types.IdempotencyRecord, ttl time.Duration) error
+	// Release frees a reserved key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency makes POST and PATCH requests carrying an Idempotency-Key
+// header safe to retry. The first request with a key runs normally and its
+// response is stored; a retry with the same key and body gets that response
+// again without running the handler. Reusing a key for a different request
+// is rejected with 422, and a retry while the first request is still
+// running gets 409.
+//
+// Keys are scoped to the authenticated user, so the middleware belongs
+// inside ValidateAccessTokenMiddleware on protected routes. Once the
+// handler has run its response is stored whatever the status, since an
+// error may have been raised after money moved; only a handler that calls
+// AllowRetry frees the key instead. The request goes ahead without
+// protection when the store is unavailable. A nil store disables the
+// middleware.
+func Idempotency(store IdempotencyStore) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if store == nil {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyHeader)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+				next(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKey {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxIdempotentBody {
+				http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scope := "anonymous"
+			if userID, ok := security.UserIDFromContext(r.Context()); ok {
+				scope = userID
+			}
+			storeKey := "idempotency:" + scope + ":" + key
+			fingerprint := requestFingerprint(r, body)
+
+			ctx, cancel := context.WithTimeout(r.Context(), idempotencyTimeout)
+			reserved, record, err := store.Reserve(ctx, storeKey, fingerprint, IdempotencyTTL)
+			cancel()
+			if err != nil {
+				slog.Error("Idempotency store unavailable", "error", err)
+				next(w, r)
+				return
+			}
+
+			if !reserved {
+				switch {
+				case record.Fingerprint != fingerprint:
+					http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+				case !record.Done:
+					http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+				default:
+					replay(w, record)
+				}
+				return
+			}
+
+			state := &idempotencyState{}
+			r = r.WithContext(context.WithValue(r.Context(), idempotencyStateKey{}, state))
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next(rec, r)
+
+			// Detached from the request so a client hanging up does not
+			// leave the key reserved.
+			ctx, cancel = context.WithTimeout(context.Background(), idempotencyTimeout)
+			defer cancel()
+
+			if state.retry {
+				err = store.Release(ctx, storeKey)
+			} else {
+				err = store.Complete(ctx, storeKey, &types.IdempotencyRecord{
+					Fingerprint: fingerprint,
+					Done:        true,
+					Status:      rec.status,
+					Header:      w.Header().Clone(),
+					Body:        rec.body.Bytes(),
+				}, IdempotencyTTL)
+			}
+			if err != nil {
+				slog.Error("Failed to save idempotent response", "error", err, "key", key)
+			}
+		}
+	}
+}
+
+type idempotencyStateKey struct{}
+
+type idempotencyState struct {
+	retry bool
+}
+
+// AllowRetry tells Idempotency that the request was turned down before it
+// changed anything, such as for a wrong transaction PIN, which travels in
+// a header outside the fingerprint. The key is freed instead of the
+// response being stored, so a retry with the same key runs. It does
+// nothing outside the middleware.
+func AllowRetry(r *http.Request) {
+	if state, ok := r.Context().Value(idempotencyStateKey{}).(*idempotencyState); ok {
+		state.retry = true
+	}
+}
+
+// requestFingerprint identifies a request by method, path, query and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, record *types.IdempotencyRecord) {
+	for name, values := range record.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// recorder passes a response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]types.IdempotencyRecord
+	err     error
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]types.IdempotencyRecord{}}
+}
+
+func (m *memoryIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (bool, *types.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return false, nil, m.err
+	}
+	if record, ok := m.records[key]; ok {
+		return false, &record, nil
+	}
+	m.records[key] = types.IdempotencyRecord{Fingerprint: fingerprint}
+	return true, nil, nil
+}
+
+func (m *memoryIdempotencyStore) Complete(ctx context.Context, key string, record *types.IdempotencyRecord, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[key] = *record
+	return nil
+}
+
+func (m *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, key)
+	return nil
+}
+
+func idempotentRequest(method, body, key string) *http.Request {
+	req := httptest.NewRequest(method, "/expense", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyHeader, key)
+	}
+	return req
+}
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	status := http.StatusCreated
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id":"e1"}`))
+	}
+
+	t.Run("replays the stored response", func(t *testing.T) {
+		calls = 0
+		h := Idempotency(newMemoryIdempotencyStore())(handler)
+
+		first := httptest.NewRecorder()
+		h(first, idempotentRequest(http.MethodPost, `{"amount":5}`, "k1"))
+		retry := httptest.NewRecorder()
+		h(retry, idempotentRequest(http.MethodPost, `{"amount":5}`, "k1"))
+
+		if calls != 1 {
+			t.Errorf("Expected handler to run once, ran %d times", calls)
+		}
+		if retry.Code != http.StatusCreated || retry.Body.String() != `{"id":"e1"}` {
+			t.Errorf("Expected replayed 201 response, got %d %q", retry.Code, retry.Body.String())
+		}
+		if retry.Header().Get(ReplayedHeader) != "true" || retry.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected replayed headers, got %v", retry.Header())
+		}
+	})
+
+	t.Run("rejects key reuse with a different body", func(t *testing.T) {
+		calls = 0
+		h := Idempotency(newMemoryIdempotencyStore())(handler)
+
+		h(httptest.NewRecorder(), idempotentRequest(http.MethodPost, `{"amount":5}`, "k1"))
+		reuse := httptest.NewRecorder()
+		h(reuse, idempotentRequest(http.MethodPost, `{"amount":6}`, "k1"))
+
+		if reuse.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, reuse.Code)
+		}
+		if calls != 1 {
+			t.Errorf("Expected handler to run once, ran %d times", calls)
+		}
+	})
+
+	t.Run("conflicts while the first request is running", func(t *testing.T) {
+		store := newMemoryIdempotencyStore()
+		req := idempotentRequest(http.MethodPost, `{}`, "k1")
+		store.Reserve(context.Background(), "idempotency:anonymous:k1", requestFingerprint(req, []byte(`{}`)), time.Hour)
+
+		rec := httptest.NewRecorder()
+		Idempotency(store)(handler)(rec, req)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, rec.Code)
+		}
+	})
+
+	t.Run("errors after the handler ran are replayed", func(t *testing.T) {
+		defer func() { status = http.StatusCreated }()
+		for _, code := range []int{http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError} {
+			calls = 0
+			status = code
+			h := Idempotency(newMemoryIdempotencyStore())(handler)
+
+			h(httptest.NewRecorder(), idempotentRequest(http.MethodPost, `{}`, "k1"))
+			retry := httptest.NewRecorder()
+			h(retry, idempotentRequest(http.MethodPost, `{}`, "k1"))
+			if calls != 1 || retry.Code != code {
+				t.Errorf("Expected a replayed %d after one call, got %d after %d calls", code, retry.Code, calls)
+			}
+		}
+	})
+
+	t.Run("a retry after a rejected PIN runs again", func(t *testing.T) {
+		calls = 0
+		pinHandler := func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.Header.Get("X-Transaction-PIN") != "4826" {
+				AllowRetry(r)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}
+		h := Idempotency(newMemoryIdempotencyStore())(pinHandler)
+
+		wrong := idempotentRequest(http.MethodPost, `{"amount":5}`, "k1")
+		wrong.Header.Set("X-Transaction-PIN", "1111")
+		h(httptest.NewRecorder(), wrong)
+
+		right := idempotentRequest(http.MethodPost, `{"amount":5}`, "k1")
+		right.Header.Set("X-Transaction-PIN", "4826")
+		rec := httptest.NewRecorder()
+		h(rec, right)
+		if calls != 2 || rec.Code != http.StatusCreated {
+			t.Errorf("Expected the retry to run and succeed, got %d after %d calls", rec.Code, calls)
+		}
+	})
+
+	t.Run("ignores requests without a key or with other methods", func(t *testing.T) {
+		calls = 0
+		h := Idempotency(newMemoryIdempotencyStore())(handler)
+
+		h(httptest.NewRecorder(), idempotentRequest(http.MethodPost, `{}`, ""))
+		h(httptest.NewRecorder(), idempotentRequest(http.MethodPost, `{}`, ""))
+		h(httptest.NewRecorder(), idempotentRequest(http.MethodDelete, ``, "k1"))
+		h(httptest.NewRecorder(), idempotentRequest(http.MethodDelete, ``, "k1"))
+		if calls != 4 {
+			t.Errorf("Expected handler to run 4 times, ran %d times", calls)
+		}
+	})
+
+	t.Run("passes through when the store fails", func(t *testing.T) {
+		calls = 0
+		store := newMemoryIdempotencyStore()
+		store.err = errors.New("redis down")
+		h := Idempotency(store)(handler)
+
+		rec := httptest.NewRecorder()
+		h(rec, idempotentRequest(http.MethodPatch, `{}`, "k1"))
+		if calls != 1 || rec.Code != http.StatusCreated {
+			t.Errorf("Expected request to go through, got %d after %d calls", rec.Code, calls)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Mailer sends a plain text email.
+type Mailer interface {
+	SendMail(ctx context.Context, to, subject, body string) error
+}
+
+// UserLookup resolves the address an email is sent to.
+type UserLookup interface {
+	GetUser(userID string) (*types.User, error)
+}
+
+type EmailChannel struct {
+	mailer Mailer
+	users  UserLookup
+}
+
+func NewEmailChannel(mailer Mailer, users UserLookup) *EmailChannel {
+	return &EmailChannel{mailer: mailer, users: users}
+}
+
+func (c *EmailChannel) Name() string { return types.ChannelEmail }
+
+func (c *EmailChannel) Send(ctx context.Context, n types.Notification) error {
+	user, err := c.users.GetUser(n.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to look up recipient: %w", err)
+	}
+	if user.Email == "" {
+		return errors.New("user has no email address")
+	}
+	return c.mailer.SendMail(ctx, user.Email, n.Title, n.Body)
+}
+
+// SMTPMailer sends mail through an SMTP relay, using STARTTLS and PLAIN auth
+// when the server offers them.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	m := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) SendMail(_ context.Context, to, subject, body string) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, buildMessage(m.from, to, subject, body, time.Now()))
+}
+
+func buildMessage(from, to, subject, body string, now time.Time) []byte {
+	// Header values must not be able to inject further headers.
+	clean := strings.NewReplacer("\r", " ", "\n", " ")
+
+	var b strings.Builder
+	b.WriteString("From: " + clean.Replace(from) + "\r\n")
+	b.WriteString("To: " + clean.Replace(to) + "\r\n")
+	b.WriteString("Subject: " + clean.Replace(subject) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
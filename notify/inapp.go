@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Inbox persists notifications for the in-app list.
+type Inbox interface {
+	CreateNotification(n *types.Notification) error
+}
+
+type InAppChannel struct {
+	inbox Inbox
+}
+
+func NewInAppChannel(inbox Inbox) *InAppChannel {
+	return &InAppChannel{inbox: inbox}
+}
+
+func (c *InAppChannel) Name() string { return types.ChannelInApp }
+
+func (c *InAppChannel) Send(_ context.Context, n types.Notification) error {
+	return c.inbox.CreateNotification(&n)
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// The in-memory stand-ins below record what would have been delivered, for
+// tests and local development. Setting Err makes every delivery fail.
+
+type MemoryInbox struct {
+	mu            sync.Mutex
+	Notifications []types.Notification
+	Err           error
+}
+
+func (m *MemoryInbox) CreateNotification(n *types.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Notifications = append(m.Notifications, *n)
+	return nil
+}
+
+type SentMail struct {
+	To, Subject, Body string
+}
+
+type MemoryMailer struct {
+	mu   sync.Mutex
+	Sent []SentMail
+	Err  error
+}
+
+func (m *MemoryMailer) SendMail(_ context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Sent = append(m.Sent, SentMail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+type MemoryPusher struct {
+	mu     sync.Mutex
+	Pushed []types.Notification
+	Err    error
+}
+
+func (m *MemoryPusher) Push(_ context.Context, n types.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.Pushed = append(m.Pushed, n)
+	return nil
+}
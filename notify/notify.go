@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Channel delivers a notification to its user over one medium.
+type Channel interface {
+	// Name is the identifier users pick the channel by, such as "email".
+	Name() string
+	Send(ctx context.Context, n types.Notification) error
+}
+
+// Notifier routes notifications to the channels a user asked for.
+type Notifier struct {
+	channels map[string]Channel
+}
+
+func New(channels ...Channel) *Notifier {
+	n := &Notifier{channels: make(map[string]Channel, len(channels))}
+	for _, c := range channels {
+		n.channels[c.Name()] = c
+	}
+	return n
+}
+
+// Has reports whether a channel with the given name is configured.
+func (n *Notifier) Has(name string) bool {
+	_, ok := n.channels[name]
+	return ok
+}
+
+// Send delivers n over each named channel, falling back to the in-app inbox
+// when none are given. Every channel is attempted even if an earlier one
+// fails; channels that are not configured are skipped.
+func (n *Notifier) Send(ctx context.Context, note types.Notification, channels ...string) error {
+	if len(channels) == 0 {
+		channels = []string{types.ChannelInApp}
+	}
+
+	var errs []error
+	for _, name := range channels {
+		c, ok := n.channels[name]
+		if !ok {
+			slog.Warn("Notification channel is not configured", "channel", name)
+			continue
+		}
+		if err := c.Send(ctx, note); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewFromEnv builds a notifier with the in-app channel backed by inbox, plus
+// email when SMTP_HOST is set and push when PUSH_GATEWAY_URL is set.
+func NewFromEnv(inbox Inbox, users UserLookup) *Notifier {
+	channels := []Channel{NewInAppChannel(inbox)}
+
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		mailer := NewSMTPMailer(host+":"+port, os.Getenv("SMTP_FROM"),
+			os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+		channels = append(channels, NewEmailChannel(mailer, users))
+	}
+
+	if url := os.Getenv("PUSH_GATEWAY_URL"); url != "" {
+		channels = append(channels, NewPushChannel(NewGatewayPusher(url, os.Getenv("PUSH_GATEWAY_TOKEN"))))
+	}
+
+	return New(channels...)
+}
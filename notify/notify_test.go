@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type users map[string]*types.User
+
+func (u users) GetUser(id string) (*types.User, error) {
+	if user, ok := u[id]; ok {
+		return user, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestNotifierRoutesToChannels(t *testing.T) {
+	inbox := &MemoryInbox{}
+	mailer := &MemoryMailer{}
+	pusher := &MemoryPusher{}
+	n := New(
+		NewInAppChannel(inbox),
+		NewEmailChannel(mailer, users{"u1": {ID: "u1", Email: "ada@example.com"}}),
+		NewPushChannel(pusher),
+	)
+
+	note := types.Notification{UserID: "u1", Title: "Food budget at 80%", Body: "Careful"}
+	require.NoError(t, n.Send(context.Background(), note, types.ChannelInApp, types.ChannelEmail, types.ChannelPush))
+
+	require.Len(t, inbox.Notifications, 1)
+	assert.Equal(t, "u1", inbox.Notifications[0].UserID)
+	require.Len(t, mailer.Sent, 1)
+	assert.Equal(t, SentMail{To: "ada@example.com", Subject: "Food budget at 80%", Body: "Careful"}, mailer.Sent[0])
+	require.Len(t, pusher.Pushed, 1)
+}
+
+func TestNotifierDefaultsAndFailures(t *testing.T) {
+	inbox := &MemoryInbox{}
+	pusher := &MemoryPusher{Err: errors.New("gateway down")}
+	n := New(NewInAppChannel(inbox), NewPushChannel(pusher))
+
+	require.NoError(t, n.Send(context.Background(), types.Notification{UserID: "u1"}))
+	assert.Len(t, inbox.Notifications, 1)
+
+	// Unconfigured channels are skipped; a failing one does not stop the rest.
+	err := n.Send(context.Background(), types.Notification{UserID: "u1"}, types.ChannelPush, types.ChannelEmail, types.ChannelInApp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "push: gateway down")
+	assert.Len(t, inbox.Notifications, 2)
+	assert.False(t, n.Has(types.ChannelEmail))
+}
+
+func TestEmailChannelWithoutAddress(t *testing.T) {
+	c := NewEmailChannel(&MemoryMailer{}, users{"u1": {ID: "u1"}})
+	assert.Error(t, c.Send(context.Background(), types.Notification{UserID: "u1"}))
+	assert.Error(t, c.Send(context.Background(), types.Notification{UserID: "missing"}))
+}
+
+func TestBuildMessage(t *testing.T) {
+	msg := string(buildMessage("alerts@example.com", "ada@example.com", "Hi\r\nBcc: evil@example.com", "line one\nline two",
+		time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	assert.Contains(t, msg, "Subject: Hi  Bcc: evil@example.com\r\n")
+	assert.NotContains(t, msg, "\r\nBcc:")
+	assert.True(t, strings.HasSuffix(msg, "\r\n\r\nline one\r\nline two\r\n"))
+}
+
+func TestGatewayPusher(t *testing.T) {
+	var got pushMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got.UserID == "fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewGatewayPusher(srv.URL, "secret")
+	require.NoError(t, p.Push(context.Background(), types.Notification{UserID: "u1", Title: "t", Body: "b"}))
+	assert.Equal(t, pushMessage{UserID: "u1", Title: "t", Body: "b"}, got)
+
+	assert.Error(t, p.Push(context.Background(), types.Notification{UserID: "fail"}))
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Pusher hands a notification to a push delivery service, which knows the
+// user's registered devices.
+type Pusher interface {
+	Push(ctx context.Context, n types.Notification) error
+}
+
+type PushChannel struct {
+	pusher Pusher
+}
+
+func NewPushChannel(pusher Pusher) *PushChannel {
+	return &PushChannel{pusher: pusher}
+}
+
+func (c *PushChannel) Name() string { return types.ChannelPush }
+
+func (c *PushChannel) Send(ctx context.Context, n types.Notification) error {
+	return c.pusher.Push(ctx, n)
+}
+
+// GatewayPusher posts notifications as JSON to an HTTP push gateway.
+type GatewayPusher struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewGatewayPusher(url, token string) *GatewayPusher {
+	return &GatewayPusher{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type pushMessage struct {
+	UserID string            `json:"userId"`
+	Title  string            `json:"title"`
+	Body   string            `json:"body"`
+	Data   map[string]string `json:"data,omitempty"`
+}
+
+func (p *GatewayPusher) Push(ctx context.Context, n types.Notification) error {
+	payload, err := json.Marshal(pushMessage{
+		UserID: n.UserID,
+		Title:  n.Title,
+		Body:   n.Body,
+		Data:   n.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("push gateway returned %s: %s", resp.Status, body)
+	}
+	return nil
+}
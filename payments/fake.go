@@ -0,0 +1,177 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// FailingPhoneSuffix makes the fake provider fail payments to or from any
+// number ending in it, so failures can be tried out.
+const FailingPhoneSuffix = "0000"
+
+type fakePayment struct {
+	result  Result
+	settles time.Time
+}
+
+// Fake is an in-process provider for development and tests. Payments
+// settle after a delay: they succeed unless the phone number ends in
+// FailingPhoneSuffix. When a webhook URL is set the outcome is also
+// delivered there as a signed callback, as a real provider would.
+type Fake struct {
+	webhookURL string
+	secret     []byte
+	delay      time.Duration
+	client     *http.Client
+	now        func() time.Time
+
+	mu          sync.Mutex
+	payments    map[string]*fakePayment
+	byReference map[string]string
+}
+
+func NewFake(webhookURL string, secret []byte, delay time.Duration) *Fake {
+	return &Fake{
+		webhookURL:  webhookURL,
+		secret:      secret,
+		delay:       delay,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+		payments:    make(map[string]*fakePayment),
+		byReference: make(map[string]string),
+	}
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) Collect(ctx context.Context, req Request) (Result, error) {
+	return f.start(req)
+}
+
+func (f *Fake) Payout(ctx context.Context, req Request) (Result, error) {
+	return f.start(req)
+}
+
+// start accepts a payment once per reference; a retry with the same
+// reference gets the payment already started.
+func (f *Fake) start(req Request) (Result, error) {
+	if req.Amount <= 0 {
+		return Result{}, fmt.Errorf("%w: amount must be positive", ErrRejected)
+	}
+	if req.Phone == "" {
+		return Result{}, fmt.Errorf("%w: phone number is required", ErrRejected)
+	}
+
+	f.mu.Lock()
+	if ref, ok := f.byReference[req.Reference]; ok {
+		f.mu.Unlock()
+		return Result{ProviderRef: ref, Reference: req.Reference, Status: types.PaymentProcessing}, nil
+	}
+	f.mu.Unlock()
+
+	ref := make([]byte, 8)
+	if _, err := rand.Read(ref); err != nil {
+		return Result{}, err
+	}
+	outcome := Result{ProviderRef: "fake-" + hex.EncodeToString(ref), Reference: req.Reference, Status: types.PaymentSucceeded}
+	if strings.HasSuffix(req.Phone, FailingPhoneSuffix) {
+		outcome.Status = types.PaymentFailed
+		outcome.FailureReason = "declined by the subscriber"
+	}
+
+	f.mu.Lock()
+	f.payments[outcome.ProviderRef] = &fakePayment{result: outcome, settles: f.now().Add(f.delay)}
+	f.byReference[req.Reference] = outcome.ProviderRef
+	f.mu.Unlock()
+
+	if f.webhookURL != "" {
+		time.AfterFunc(f.delay, func() { f.deliver(outcome) })
+	}
+	return Result{ProviderRef: outcome.ProviderRef, Reference: req.Reference, Status: types.PaymentProcessing}, nil
+}
+
+func (f *Fake) Status(ctx context.Context, providerRef string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status(providerRef)
+}
+
+func (f *Fake) StatusByReference(ctx context.Context, reference string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ref, ok := f.byReference[reference]
+	if !ok {
+		return Result{}, fmt.Errorf("%w: %q", ErrUnknownPayment, reference)
+	}
+	return f.status(ref)
+}
+
+func (f *Fake) status(providerRef string) (Result, error) {
+	p, ok := f.payments[providerRef]
+	if !ok {
+		return Result{}, fmt.Errorf("%w: %q", ErrUnknownPayment, providerRef)
+	}
+	if f.now().Before(p.settles) {
+		return Result{ProviderRef: providerRef, Reference: p.result.Reference, Status: types.PaymentProcessing}, nil
+	}
+	return p.result, nil
+}
+
+type fakeCallback struct {
+	Reference         string              `json:"reference"`
+	MerchantReference string              `json:"merchantReference,omitempty"`
+	Status            types.PaymentStatus `json:"status"`
+	Reason            string              `json:"reason,omitempty"`
+}
+
+func (f *Fake) ParseCallback(body []byte) (Result, error) {
+	var cb fakeCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		return Result{}, fmt.Errorf("invalid callback: %w", err)
+	}
+	if cb.Reference == "" || !Final(cb.Status) {
+		return Result{}, fmt.Errorf("invalid callback")
+	}
+	return Result{ProviderRef: cb.Reference, Reference: cb.MerchantReference, Status: cb.Status, FailureReason: cb.Reason}, nil
+}
+
+func (f *Fake) deliver(result Result) {
+	body, err := json.Marshal(fakeCallback{
+		Reference:         result.ProviderRef,
+		MerchantReference: result.Reference,
+		Status:            result.Status,
+		Reason:            result.FailureReason,
+	})
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, f.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		slog.Error("Fake provider callback failed", "error", err)
+		return
+	}
+	timestamp := strconv.FormatInt(f.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(f.secret, timestamp, body))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		slog.Error("Fake provider callback failed", "error", err)
+		return
+	}
+	resp.Body.Close()
+}
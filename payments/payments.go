@@ -0,0 +1,125 @@
+// Package payments connects wallets to external payment providers such as
+// mobile money operators.
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const (
+	SignatureHeader = "X-Liora-Signature"
+	TimestampHeader = "X-Liora-Timestamp"
+
+	// MaxSignatureAge bounds how old a signed callback may be, so a
+	// captured callback cannot be replayed later.
+	MaxSignatureAge = 5 * time.Minute
+)
+
+var (
+	ErrBadSignature   = errors.New("invalid callback signature")
+	ErrStaleSignature = errors.New("callback signature has expired")
+
+	// ErrRejected is wrapped by Collect and Payout when the provider
+	// definitely refused a payment. Any other error leaves the outcome
+	// unknown: the provider may have accepted it.
+	ErrRejected = errors.New("payment rejected by the provider")
+
+	// ErrUnknownPayment is returned by StatusByReference when the provider
+	// has no payment with our reference, so it never accepted it.
+	ErrUnknownPayment = errors.New("provider has no record of the payment")
+)
+
+// Request asks a provider to move Amount minor units. Reference is our
+// payment ID and lets the provider recognise retries.
+type Request struct {
+	Reference   string
+	Amount      int64
+	Currency    string
+	Phone       string
+	Description string
+}
+
+// Result is what a provider reports about a payment. ProviderRef is the
+// provider's own ID for it and Reference our payment ID, when the provider
+// reports it.
+type Result struct {
+	ProviderRef   string
+	Reference     string
+	Status        types.PaymentStatus
+	FailureReason string
+}
+
+// Provider is an external payment provider. Collect pulls money from a
+// customer into our float and Payout pushes it out; both only start the
+// payment, whose outcome arrives later in a callback or from Status. When
+// starting a payment fails without ErrRejected, StatusByReference finds
+// out whether the provider accepted it after all.
+type Provider interface {
+	Name() string
+	Collect(ctx context.Context, req Request) (Result, error)
+	Payout(ctx context.Context, req Request) (Result, error)
+	Status(ctx context.Context, providerRef string) (Result, error)
+	StatusByReference(ctx context.Context, reference string) (Result, error)
+	ParseCallback(body []byte) (Result, error)
+}
+
+// CanTransition reports whether a payment may move from one status to
+// another. Succeeded and failed are final.
+func CanTransition(from, to types.PaymentStatus) bool {
+	switch from {
+	case types.PaymentPending:
+		return to == types.PaymentProcessing || to == types.PaymentSucceeded || to == types.PaymentFailed
+	case types.PaymentProcessing:
+		return to == types.PaymentSucceeded || to == types.PaymentFailed
+	}
+	return false
+}
+
+// Final reports whether status is an outcome rather than a step on the
+// way to one.
+func Final(status types.PaymentStatus) bool {
+	return status == types.PaymentSucceeded || status == types.PaymentFailed
+}
+
+// Sign computes the callback signature: a hex HMAC-SHA256 over the Unix
+// timestamp, a dot and the raw body.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a callback's signature in constant time and
+// rejects callbacks signed more than MaxSignatureAge from now.
+func VerifySignature(secret []byte, timestamp, signature string, body []byte, now time.Time) error {
+	if len(secret) == 0 || signature == "" {
+		return ErrBadSignature
+	}
+	expected, err := hex.DecodeString(Sign(secret, timestamp, body))
+	if err != nil {
+		return ErrBadSignature
+	}
+	given, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, given) {
+		return ErrBadSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return ErrStaleSignature
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package payments
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"reference":"fake-1","status":"succeeded"}`)
+	now := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign(secret, ts, body)
+
+	assert.NoError(t, VerifySignature(secret, ts, sig, body, now.Add(time.Minute)))
+	assert.ErrorIs(t, VerifySignature(secret, ts, sig, []byte(`{}`), now), ErrBadSignature)
+	assert.ErrorIs(t, VerifySignature([]byte("other"), ts, sig, body, now), ErrBadSignature)
+	assert.ErrorIs(t, VerifySignature(secret, ts, "zz", body, now), ErrBadSignature)
+	assert.ErrorIs(t, VerifySignature(nil, ts, sig, body, now), ErrBadSignature)
+	assert.ErrorIs(t, VerifySignature(secret, ts, sig, body, now.Add(MaxSignatureAge+time.Second)), ErrStaleSignature)
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, CanTransition(types.PaymentPending, types.PaymentProcessing))
+	assert.True(t, CanTransition(types.PaymentPending, types.PaymentFailed))
+	assert.True(t, CanTransition(types.PaymentProcessing, types.PaymentSucceeded))
+	assert.True(t, CanTransition(types.PaymentProcessing, types.PaymentFailed))
+	assert.False(t, CanTransition(types.PaymentProcessing, types.PaymentPending))
+	assert.False(t, CanTransition(types.PaymentSucceeded, types.PaymentFailed))
+	assert.False(t, CanTransition(types.PaymentFailed, types.PaymentSucceeded))
+}
+
+func TestFakeStatus(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fake := NewFake("", nil, time.Minute)
+	fake.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	ok, err := fake.Collect(ctx, Request{Reference: "p1", Amount: 5000, Currency: "UGX", Phone: "256772123456"})
+	require.NoError(t, err)
+	assert.Equal(t, types.PaymentProcessing, ok.Status)
+
+	declined, err := fake.Payout(ctx, Request{Reference: "p2", Amount: 5000, Currency: "UGX", Phone: "256772120000"})
+	require.NoError(t, err)
+
+	status, err := fake.Status(ctx, ok.ProviderRef)
+	require.NoError(t, err)
+	assert.Equal(t, types.PaymentProcessing, status.Status)
+
+	now = now.Add(time.Minute)
+	status, err = fake.Status(ctx, ok.ProviderRef)
+	require.NoError(t, err)
+	assert.Equal(t, types.PaymentSucceeded, status.Status)
+
+	status, err = fake.Status(ctx, declined.ProviderRef)
+	require.NoError(t, err)
+	assert.Equal(t, types.PaymentFailed, status.Status)
+	assert.NotEmpty(t, status.FailureReason)
+
+	byRef, err := fake.StatusByReference(ctx, "p2")
+	require.NoError(t, err)
+	assert.Equal(t, declined.ProviderRef, byRef.ProviderRef)
+	assert.Equal(t, types.PaymentFailed, byRef.Status)
+
+	retried, err := fake.Collect(ctx, Request{Reference: "p1", Amount: 5000, Currency: "UGX", Phone: "256772123456"})
+	require.NoError(t, err)
+	assert.Equal(t, ok.ProviderRef, retried.ProviderRef)
+
+	_, err = fake.Status(ctx, "fake-unknown")
+	assert.ErrorIs(t, err, ErrUnknownPayment)
+	_, err = fake.StatusByReference(ctx, "p9")
+	assert.ErrorIs(t, err, ErrUnknownPayment)
+	_, err = fake.Collect(ctx, Request{Reference: "p3", Amount: 0, Phone: "256772123456"})
+	assert.ErrorIs(t, err, ErrRejected)
+}
+
+func TestFakeWebhook(t *testing.T) {
+	secret := []byte("s3cret")
+	received := make(chan Result, 1)
+	fake := NewFake("", secret, 0)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := VerifySignature(secret, r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), body, time.Now())
+		assert.NoError(t, err)
+		result, err := fake.ParseCallback(body)
+		assert.NoError(t, err)
+		received <- result
+	}))
+	defer srv.Close()
+	fake.webhookURL = srv.URL
+
+	started, err := fake.Collect(context.Background(), Request{Reference: "p1", Amount: 100, Phone: "256772123456"})
+	require.NoError(t, err)
+
+	select {
+	case result := <-received:
+		assert.Equal(t, started.ProviderRef, result.ProviderRef)
+		assert.Equal(t, "p1", result.Reference)
+		assert.Equal(t, types.PaymentSucceeded, result.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+
+	_, err = fake.ParseCallback([]byte(`{"reference":"fake-1","status":"processing"}`))
+	assert.Error(t, err)
+}
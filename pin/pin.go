@@ -0,0 +1,65 @@
+// Package pin holds the rules for transaction PINs, the second secret
+// users enter to authorise moving money.
+package pin
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MinLength = 4
+	MaxLength = 6
+
+	// MaxAttempts is how many wrong PINs in a row lock the PIN for
+	// LockoutDuration. The count resets after a correct PIN.
+	MaxAttempts     = 5
+	LockoutDuration = 30 * time.Minute
+
+	cost = 12
+)
+
+var (
+	ErrInvalid   = errors.New("PIN must be 4 to 6 digits")
+	ErrTooSimple = errors.New("PIN is too easy to guess")
+)
+
+// Validate checks that a PIN is 4 to 6 digits and not a repeated digit or
+// a straight run such as 1234 or 9876.
+func Validate(p string) error {
+	if len(p) < MinLength || len(p) > MaxLength {
+		return ErrInvalid
+	}
+	for _, c := range p {
+		if c < '0' || c > '9' {
+			return ErrInvalid
+		}
+	}
+
+	same, up, down := true, true, true
+	for i := 1; i < len(p); i++ {
+		step := int(p[i]) - int(p[i-1])
+		same = same && step == 0
+		up = up && step == 1
+		down = down && step == -1
+	}
+	if same || up || down {
+		return ErrTooSimple
+	}
+	return nil
+}
+
+// Hash validates a PIN and hashes it for storage.
+func Hash(p string) ([]byte, error) {
+	if err := Validate(p); err != nil {
+		return nil, err
+	}
+	return bcrypt.GenerateFromPassword([]byte(p), cost)
+}
+
+// Matches reports whether p is the PIN behind hash.
+func Matches(hash []byte, p string) bool {
+	return bcrypt.CompareHashAndPassword(hash, []byte(p)) == nil
+}
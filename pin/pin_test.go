@@ -0,0 +1,31 @@
+package pin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	for _, p := range []string{"2580", "13579", "902114", "1243"} {
+		assert.NoError(t, Validate(p), p)
+	}
+
+	for _, p := range []string{"", "123", "1234567", "12a4", " 2580", "２５８０"} {
+		assert.ErrorIs(t, Validate(p), ErrInvalid, p)
+	}
+	for _, p := range []string{"0000", "1234", "98765", "456789", "777777"} {
+		assert.ErrorIs(t, Validate(p), ErrTooSimple, p)
+	}
+}
+
+func TestHash(t *testing.T) {
+	hash, err := Hash("2580")
+	require.NoError(t, err)
+	assert.True(t, Matches(hash, "2580"))
+	assert.False(t, Matches(hash, "2581"))
+
+	_, err = Hash("1111")
+	assert.ErrorIs(t, err, ErrTooSimple)
+}
@@ -0,0 +1,195 @@
+// Package reconcile checks provider settlement reports against our own
+// payment records.
+package reconcile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Ayikoandrew/server/fx"
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/types"
+)
+
+// statuses maps the words providers use in settlement reports onto ours.
+var statuses = map[string]types.PaymentStatus{
+	"success":    types.PaymentSucceeded,
+	"successful": types.PaymentSucceeded,
+	"succeeded":  types.PaymentSucceeded,
+	"completed":  types.PaymentSucceeded,
+	"settled":    types.PaymentSucceeded,
+	"failed":     types.PaymentFailed,
+	"failure":    types.PaymentFailed,
+	"declined":   types.PaymentFailed,
+	"rejected":   types.PaymentFailed,
+	"cancelled":  types.PaymentFailed,
+	"pending":    types.PaymentProcessing,
+	"processing": types.PaymentProcessing,
+}
+
+// ParseCSV reads a settlement report with a header naming the reference,
+// amount, currency and status columns in any order. An our_reference
+// column with our payment IDs is used when present. Amounts are decimal.
+func ParseCSV(r io.Reader) ([]types.SettlementRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"reference", "amount", "currency", "status"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("csv header must include a %q column", name)
+		}
+	}
+	ours, hasOurs := cols["our_reference"]
+
+	var rows []types.SettlementRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		row := types.SettlementRow{Line: line, Reference: strings.TrimSpace(record[cols["reference"]])}
+		if row.Reference == "" {
+			return nil, fmt.Errorf("line %d: reference is required", line)
+		}
+		if hasOurs {
+			row.OurReference = strings.TrimSpace(record[ours])
+		}
+		if row.Currency, err = fx.NormalizeCurrency(record[cols["currency"]]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		raw := strings.ReplaceAll(strings.TrimSpace(record[cols["amount"]]), ",", "")
+		amount, err := strconv.ParseFloat(raw, 64)
+		if err != nil || amount <= 0 {
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, record[cols["amount"]])
+		}
+		if row.Amount, err = ledger.ToMinor(amount, row.Currency); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		status, ok := statuses[strings.ToLower(strings.TrimSpace(record[cols["status"]]))]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown status %q", line, record[cols["status"]])
+		}
+		row.Status = status
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("no payments found in file")
+	}
+	return rows, nil
+}
+
+// Match compares a settlement report with our payments and returns what
+// does not agree, plus how many rows matched cleanly. Payments should
+// cover the report's period and any older payment the report mentions.
+//
+// A payment we still have open that the report shows settled for the same
+// amount is reported as stuck, which can be resolved automatically.
+// Payments we have open that are missing from the report are left alone,
+// since the provider may settle them in a later report.
+func Match(rows []types.SettlementRow, payments []types.Payment) ([]types.ReconciliationItem, int) {
+	byRef := map[string]*types.Payment{}
+	byID := map[string]*types.Payment{}
+	for i := range payments {
+		p := &payments[i]
+		byID[p.ID] = p
+		if p.ProviderRef != "" {
+			byRef[p.ProviderRef] = p
+		}
+	}
+
+	var items []types.ReconciliationItem
+	matched := 0
+	seen := map[string]bool{}
+	reported := map[string]bool{}
+	for _, row := range rows {
+		item := types.ReconciliationItem{
+			ProviderRef:    row.Reference,
+			Line:           row.Line,
+			Currency:       row.Currency,
+			ReportedAmount: row.Amount,
+			ReportedStatus: row.Status,
+		}
+
+		if seen[row.Reference] {
+			item.Kind = types.DiscrepancyDuplicate
+			items = append(items, item)
+			continue
+		}
+		seen[row.Reference] = true
+
+		p := byRef[row.Reference]
+		if p == nil && row.OurReference != "" {
+			p = byID[row.OurReference]
+		}
+		if p == nil {
+			item.Kind = types.DiscrepancyMissingHere
+			items = append(items, item)
+			continue
+		}
+		reported[p.ID] = true
+
+		item.PaymentID = p.ID
+		item.ExpectedAmount = p.Amount
+		item.OurStatus = p.Status
+		switch {
+		case p.Amount != row.Amount || p.Currency != row.Currency:
+			item.Kind = types.DiscrepancyAmount
+		case p.Status == row.Status:
+			matched++
+			continue
+		case row.Status == types.PaymentProcessing:
+			// The provider has not finished with it either.
+			matched++
+			continue
+		case p.Status == types.PaymentPending || p.Status == types.PaymentProcessing:
+			item.Kind = types.DiscrepancyStuck
+		default:
+			item.Kind = types.DiscrepancyStatus
+		}
+		items = append(items, item)
+	}
+
+	for _, p := range payments {
+		if reported[p.ID] || p.Status != types.PaymentSucceeded {
+			continue
+		}
+		items = append(items, types.ReconciliationItem{
+			Kind:           types.DiscrepancyMissingThere,
+			PaymentID:      p.ID,
+			ProviderRef:    p.ProviderRef,
+			Currency:       p.Currency,
+			ExpectedAmount: p.Amount,
+			OurStatus:      p.Status,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Kind < items[j].Kind })
+	for i := range items {
+		items[i].Resolution = types.ResolutionOpen
+	}
+	return items, matched
+}
@@ -0,0 +1,94 @@
+package reconcile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	rows, err := ParseCSV(strings.NewReader("\ufeffStatus,Reference,Amount,Currency,Our_Reference\n" +
+		"SUCCESS,ref-1,\"50,000\",ugx,\n" +
+		"declined,ref-2,19.99,USD,p-2\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, types.SettlementRow{Line: 2, Reference: "ref-1", Amount: 50000, Currency: "UGX", Status: types.PaymentSucceeded}, rows[0])
+	assert.Equal(t, types.SettlementRow{Line: 3, Reference: "ref-2", OurReference: "p-2", Amount: 1999, Currency: "USD", Status: types.PaymentFailed}, rows[1])
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"reference,amount,currency\nref-1,10,UGX\n",
+		"reference,amount,currency,status\n",
+		"reference,amount,currency,status\n,10,UGX,success\n",
+		"reference,amount,currency,status\nref-1,-10,UGX,success\n",
+		"reference,amount,currency,status\nref-1,ten,UGX,success\n",
+		"reference,amount,currency,status\nref-1,10,UGX,lost\n",
+	} {
+		_, err := ParseCSV(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	ours := []types.Payment{
+		{ID: "p-1", ProviderRef: "ref-1", Amount: 5000, Currency: "UGX", Status: types.PaymentSucceeded},
+		{ID: "p-2", ProviderRef: "ref-2", Amount: 7000, Currency: "UGX", Status: types.PaymentProcessing},
+		{ID: "p-3", ProviderRef: "ref-3", Amount: 9000, Currency: "UGX", Status: types.PaymentSucceeded},
+		{ID: "p-4", ProviderRef: "ref-4", Amount: 1000, Currency: "UGX", Status: types.PaymentFailed},
+		{ID: "p-5", ProviderRef: "ref-5", Amount: 2000, Currency: "UGX", Status: types.PaymentSucceeded},
+		{ID: "p-6", ProviderRef: "ref-6", Amount: 3000, Currency: "UGX", Status: types.PaymentProcessing},
+		{ID: "p-7", Amount: 4000, Currency: "UGX", Status: types.PaymentPending},
+	}
+	rows := []types.SettlementRow{
+		{Line: 2, Reference: "ref-1", Amount: 5000, Currency: "UGX", Status: types.PaymentSucceeded},
+		{Line: 3, Reference: "ref-2", Amount: 7000, Currency: "UGX", Status: types.PaymentSucceeded},
+		{Line: 4, Reference: "ref-3", Amount: 8000, Currency: "UGX", Status: types.PaymentSucceeded},
+		{Line: 5, Reference: "ref-4", Amount: 1000, Currency: "UGX", Status: types.PaymentSucceeded},
+		{Line: 6, Reference: "ref-1", Amount: 5000, Currency: "UGX", Status: types.PaymentSucceeded},
+		{Line: 7, Reference: "ref-x", Amount: 100, Currency: "UGX", Status: types.PaymentSucceeded},
+		{Line: 8, Reference: "ref-6", Amount: 3000, Currency: "UGX", Status: types.PaymentProcessing},
+		{Line: 9, Reference: "ref-y", OurReference: "p-7", Amount: 4000, Currency: "UGX", Status: types.PaymentFailed},
+	}
+
+	items, matched := Match(rows, ours)
+	assert.Equal(t, 2, matched)
+
+	kinds := map[string]types.DiscrepancyKind{}
+	for _, item := range items {
+		assert.Equal(t, types.ResolutionOpen, item.Resolution)
+		key := item.PaymentID
+		if key == "" {
+			key = item.ProviderRef
+		}
+		if item.Kind == types.DiscrepancyDuplicate {
+			key = "dup:" + item.ProviderRef
+		}
+		kinds[key] = item.Kind
+	}
+	assert.Equal(t, map[string]types.DiscrepancyKind{
+		"p-2":       types.DiscrepancyStuck,
+		"p-3":       types.DiscrepancyAmount,
+		"p-4":       types.DiscrepancyStatus,
+		"dup:ref-1": types.DiscrepancyDuplicate,
+		"ref-x":     types.DiscrepancyMissingHere,
+		"p-5":       types.DiscrepancyMissingThere,
+		"p-7":       types.DiscrepancyStuck,
+	}, kinds)
+}
+
+func TestMatchCurrencyMismatch(t *testing.T) {
+	ours := []types.Payment{{ID: "p-1", ProviderRef: "ref-1", Amount: 5000, Currency: "UGX", Status: types.PaymentPending}}
+	rows := []types.SettlementRow{{Line: 2, Reference: "ref-1", Amount: 5000, Currency: "KES", Status: types.PaymentSucceeded}}
+
+	items, matched := Match(rows, ours)
+	assert.Equal(t, 0, matched)
+	require.Len(t, items, 1)
+	assert.Equal(t, types.DiscrepancyAmount, items[0].Kind)
+	assert.Equal(t, int64(5000), items[0].ExpectedAmount)
+}
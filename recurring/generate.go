@@ -0,0 +1,64 @@
+package recurring
+
+import (
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Expand returns the concrete expenses a template owes up to and including
+// today that have not been generated yet, with per-occurrence exceptions
+// applied. A long backlog is expanded in parts: the second result is the
+// date generation got through, today unless the backlog was cut short. The
+// third reports whether the schedule has no occurrences left after it.
+func Expand(r types.RecurringExpense, exceptions map[string]types.RecurringException, today time.Time) ([]types.Expense, time.Time, bool, error) {
+	schedule, err := FromExpense(r)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+
+	var last time.Time
+	if r.LastGenerated != "" {
+		last, err = time.Parse(DateLayout, r.LastGenerated)
+		if err != nil {
+			return nil, time.Time{}, false, err
+		}
+	}
+
+	dates, truncated := schedule.Between(last, today)
+	through := today
+	if truncated && len(dates) > 0 {
+		through = dates[len(dates)-1]
+	}
+
+	var expenses []types.Expense
+	for _, date := range dates {
+
+		key := date.Format(DateLayout)
+		expense := types.Expense{
+			UserID:        r.UserID,
+			Amount:        r.Amount,
+			Date:          key,
+			Description:   r.Description,
+			Category:      r.Category,
+			PaymentMethod: r.PaymentMethod,
+			RecurringID:   r.ID,
+		}
+
+		if ex, ok := exceptions[key]; ok {
+			if ex.Skip {
+				continue
+			}
+			if ex.Amount != nil {
+				expense.Amount = *ex.Amount
+			}
+			if ex.Description != nil {
+				expense.Description = *ex.Description
+			}
+		}
+		expenses = append(expenses, expense)
+	}
+
+	_, more := schedule.Next(through)
+	return expenses, through, !more, nil
+}
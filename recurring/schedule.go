@@ -0,0 +1,199 @@
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const DateLayout = "2006-01-02"
+
+// maxOccurrences bounds a single walk over the schedule so a daily schedule
+// with years to catch up on cannot stall the generator.
+const maxOccurrences = 5000
+
+// Schedule describes when a recurring item falls due.
+type Schedule struct {
+	Frequency  types.Frequency
+	Interval   int
+	DayOfMonth int
+	Start      time.Time
+	End        time.Time
+	Count      int
+}
+
+func FromExpense(r types.RecurringExpense) (Schedule, error) {
+	start, err := time.Parse(DateLayout, r.StartDate)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("invalid start date: %w", err)
+	}
+
+	var end time.Time
+	if r.EndDate != "" {
+		end, err = time.Parse(DateLayout, r.EndDate)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("invalid end date: %w", err)
+		}
+	}
+
+	s := Schedule{
+		Frequency:  r.Frequency,
+		Interval:   r.Interval,
+		DayOfMonth: r.DayOfMonth,
+		Start:      start,
+		End:        end,
+		Count:      r.Count,
+	}
+	return s, s.Validate()
+}
+
+func (s Schedule) Validate() error {
+	switch s.Frequency {
+	case types.FrequencyDaily, types.FrequencyWeekly, types.FrequencyMonthly, types.FrequencyYearly:
+	default:
+		return fmt.Errorf("unsupported frequency %q", s.Frequency)
+	}
+	if s.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if s.DayOfMonth < 0 || s.DayOfMonth > 31 {
+		return errors.New("day of month must be between 1 and 31")
+	}
+	if s.Count < 0 {
+		return errors.New("count must not be negative")
+	}
+	if !s.End.IsZero() && s.End.Before(s.Start) {
+		return errors.New("end date is before start date")
+	}
+	return nil
+}
+
+// Occurrences returns every due date from the start of the schedule up to and
+// including until, honouring the end date and occurrence count.
+func (s Schedule) Occurrences(until time.Time) []time.Time {
+	dates, _ := s.Between(time.Time{}, until)
+	return dates
+}
+
+// Between returns the due dates strictly after after, up to and including
+// until. At most maxOccurrences dates are returned; the second result reports
+// whether the list was cut short there, so later dates up to until are still
+// owed.
+func (s Schedule) Between(after, until time.Time) ([]time.Time, bool) {
+	var dates []time.Time
+	truncated := s.each(after, func(date time.Time) bool {
+		if date.After(until) {
+			return false
+		}
+		if date.After(after) {
+			dates = append(dates, date)
+		}
+		return true
+	})
+	return dates, truncated
+}
+
+// Next returns the first due date strictly after the given date, or false when
+// the schedule has ended.
+func (s Schedule) Next(after time.Time) (time.Time, bool) {
+	var next time.Time
+	s.each(after, func(date time.Time) bool {
+		if date.After(after) {
+			next = date
+			return false
+		}
+		return true
+	})
+	return next, !next.IsZero()
+}
+
+// each walks the due dates in order until fn returns false or the schedule
+// runs out. It starts just before from rather than at the start of the
+// schedule, so a schedule that has run for years costs no more than a new
+// one. It reports true when it stopped after maxOccurrences dates with the
+// schedule still going.
+func (s Schedule) each(from time.Time, fn func(time.Time) bool) bool {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 1
+	}
+
+	// Only the first step can fall before the start, when a monthly day of
+	// month comes earlier than the start date's day.
+	skipped := 0
+	if s.nth(0).Before(s.Start) {
+		skipped = 1
+	}
+
+	first := s.stepsBefore(from)/interval - 1
+	if first < 0 {
+		first = 0
+	}
+	for i := first; i < first+maxOccurrences; i++ {
+		if emitted := i - skipped; s.Count > 0 && emitted >= s.Count {
+			return false
+		}
+
+		date := s.nth(i * interval)
+		if date.Before(s.Start) {
+			continue
+		}
+		if !s.End.IsZero() && date.After(s.End) {
+			return false
+		}
+
+		if !fn(date) {
+			return false
+		}
+	}
+	return true
+}
+
+// stepsBefore estimates how many single steps of the frequency fit between
+// the start and t. It may be one off either way; each starts a step earlier
+// to make up for it.
+func (s Schedule) stepsBefore(t time.Time) int {
+	if !t.After(s.Start) {
+		return 0
+	}
+	switch s.Frequency {
+	case types.FrequencyWeekly:
+		return int(t.Sub(s.Start).Hours() / 24 / 7)
+	case types.FrequencyMonthly:
+		return (t.Year()-s.Start.Year())*12 + int(t.Month()-s.Start.Month())
+	case types.FrequencyYearly:
+		return t.Year() - s.Start.Year()
+	default:
+		return int(t.Sub(s.Start).Hours() / 24)
+	}
+}
+
+func (s Schedule) nth(steps int) time.Time {
+	switch s.Frequency {
+	case types.FrequencyWeekly:
+		return s.Start.AddDate(0, 0, 7*steps)
+	case types.FrequencyMonthly:
+		day := s.DayOfMonth
+		if day == 0 {
+			day = s.Start.Day()
+		}
+		return clampDay(s.Start.Year(), s.Start.Month()+time.Month(steps), day, s.Start.Location())
+	case types.FrequencyYearly:
+		return clampDay(s.Start.Year()+steps, s.Start.Month(), s.Start.Day(), s.Start.Location())
+	default:
+		return s.Start.AddDate(0, 0, steps)
+	}
+}
+
+// clampDay builds a date, moving days past the end of the month back to the
+// month's last day so "monthly on the 31st" still fires in February.
+func clampDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,150 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse(DateLayout, s)
+	require.NoError(t, err)
+	return d
+}
+
+func format(dates []time.Time) []string {
+	out := make([]string, len(dates))
+	for i, d := range dates {
+		out[i] = d.Format(DateLayout)
+	}
+	return out
+}
+
+func TestOccurrences(t *testing.T) {
+	t.Run("Daily With Interval", func(t *testing.T) {
+		s := Schedule{Frequency: types.FrequencyDaily, Interval: 2, Start: date(t, "2025-01-01")}
+		assert.Equal(t, []string{"2025-01-01", "2025-01-03", "2025-01-05"}, format(s.Occurrences(date(t, "2025-01-06"))))
+	})
+
+	t.Run("Weekly Count", func(t *testing.T) {
+		s := Schedule{Frequency: types.FrequencyWeekly, Start: date(t, "2025-01-06"), Count: 2}
+		assert.Equal(t, []string{"2025-01-06", "2025-01-13"}, format(s.Occurrences(date(t, "2025-12-31"))))
+	})
+
+	t.Run("Monthly Clamps Short Months", func(t *testing.T) {
+		s := Schedule{Frequency: types.FrequencyMonthly, DayOfMonth: 31, Start: date(t, "2025-01-01")}
+		assert.Equal(t, []string{"2025-01-31", "2025-02-28", "2025-03-31", "2025-04-30"}, format(s.Occurrences(date(t, "2025-05-01"))))
+	})
+
+	t.Run("Monthly Skips Days Before Start", func(t *testing.T) {
+		s := Schedule{Frequency: types.FrequencyMonthly, DayOfMonth: 5, Start: date(t, "2025-01-20"), Count: 2}
+		assert.Equal(t, []string{"2025-02-05", "2025-03-05"}, format(s.Occurrences(date(t, "2025-12-31"))))
+	})
+
+	t.Run("Yearly Leap Day", func(t *testing.T) {
+		s := Schedule{Frequency: types.FrequencyYearly, Start: date(t, "2024-02-29")}
+		assert.Equal(t, []string{"2024-02-29", "2025-02-28"}, format(s.Occurrences(date(t, "2025-12-31"))))
+	})
+
+	t.Run("End Date", func(t *testing.T) {
+		s := Schedule{Frequency: types.FrequencyDaily, Start: date(t, "2025-01-01"), End: date(t, "2025-01-02")}
+		assert.Equal(t, []string{"2025-01-01", "2025-01-02"}, format(s.Occurrences(date(t, "2025-02-01"))))
+	})
+}
+
+func TestNext(t *testing.T) {
+	s := Schedule{Frequency: types.FrequencyMonthly, Start: date(t, "2025-01-15"), Count: 3}
+
+	next, ok := s.Next(date(t, "2025-01-15"))
+	assert.True(t, ok)
+	assert.Equal(t, "2025-02-15", next.Format(DateLayout))
+
+	_, ok = s.Next(date(t, "2025-03-15"))
+	assert.False(t, ok)
+}
+
+func TestFromExpenseValidation(t *testing.T) {
+	_, err := FromExpense(types.RecurringExpense{Frequency: "hourly", StartDate: "2025-01-01"})
+	assert.Error(t, err)
+
+	_, err = FromExpense(types.RecurringExpense{Frequency: types.FrequencyDaily, StartDate: "2025-01-02", EndDate: "2025-01-01"})
+	assert.Error(t, err)
+}
+
+func TestExpand(t *testing.T) {
+	amount := 15.5
+	template := types.RecurringExpense{
+		ID:            "rec-1",
+		UserID:        "user-1",
+		Amount:        10,
+		Description:   "Data bundle",
+		Frequency:     types.FrequencyDaily,
+		StartDate:     "2025-01-01",
+		Count:         5,
+		LastGenerated: "2025-01-01",
+	}
+	exceptions := map[string]types.RecurringException{
+		"2025-01-02": {OccurrenceDate: "2025-01-02", Skip: true},
+		"2025-01-03": {OccurrenceDate: "2025-01-03", Amount: &amount},
+	}
+
+	expenses, through, finished, err := Expand(template, exceptions, date(t, "2025-01-04"))
+	require.NoError(t, err)
+	assert.False(t, finished)
+	assert.Equal(t, "2025-01-04", through.Format(DateLayout))
+	require.Len(t, expenses, 2)
+	assert.Equal(t, "2025-01-03", expenses[0].Date)
+	assert.Equal(t, 15.5, expenses[0].Amount)
+	assert.Equal(t, "2025-01-04", expenses[1].Date)
+	assert.Equal(t, "rec-1", expenses[1].RecurringID)
+
+	_, _, finished, err = Expand(template, nil, date(t, "2025-01-05"))
+	require.NoError(t, err)
+	assert.True(t, finished)
+}
+
+func TestExpandLongRunning(t *testing.T) {
+	template := types.RecurringExpense{
+		ID:            "rec-1",
+		Amount:        10,
+		Frequency:     types.FrequencyDaily,
+		StartDate:     "2005-01-01",
+		LastGenerated: "2025-01-01",
+	}
+
+	// Twenty years in, the schedule still runs and only the new day is owed.
+	expenses, through, finished, err := Expand(template, nil, date(t, "2025-01-02"))
+	require.NoError(t, err)
+	assert.False(t, finished)
+	assert.Equal(t, "2025-01-02", through.Format(DateLayout))
+	require.Len(t, expenses, 1)
+	assert.Equal(t, "2025-01-02", expenses[0].Date)
+
+	// A backlog longer than one walk is generated in parts.
+	template.LastGenerated = ""
+	expenses, through, finished, err = Expand(template, nil, date(t, "2025-01-02"))
+	require.NoError(t, err)
+	assert.False(t, finished)
+	require.Len(t, expenses, maxOccurrences)
+	assert.Equal(t, expenses[len(expenses)-1].Date, through.Format(DateLayout))
+
+	template.LastGenerated = through.Format(DateLayout)
+	expenses, _, _, err = Expand(template, nil, date(t, "2025-01-02"))
+	require.NoError(t, err)
+	assert.Equal(t, date(t, template.LastGenerated).AddDate(0, 0, 1).Format(DateLayout), expenses[0].Date)
+}
+
+func TestCountFromLaterStart(t *testing.T) {
+	s := Schedule{Frequency: types.FrequencyMonthly, DayOfMonth: 5, Start: date(t, "2020-01-20"), Count: 24}
+
+	// The 24th occurrence is two years on, counted from February 2020.
+	next, ok := s.Next(date(t, "2021-12-31"))
+	assert.True(t, ok)
+	assert.Equal(t, "2022-01-05", next.Format(DateLayout))
+	_, ok = s.Next(date(t, "2022-01-05"))
+	assert.False(t, ok)
+}
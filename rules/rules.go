@@ -0,0 +1,141 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+const maxRegexLength = 200
+
+type compiled struct {
+	rule  types.CategorizationRule
+	regex *regexp.Regexp
+}
+
+// Engine applies a user's active rules to expenses.
+type Engine struct {
+	rules []compiled
+}
+
+// Validate checks that a rule has at least one condition and one action and
+// that its regex compiles.
+func Validate(rule types.CategorizationRule) error {
+	c := rule.Conditions
+	if c.DescriptionContains == "" && c.DescriptionRegex == "" && c.MinAmount == nil &&
+		c.MaxAmount == nil && c.PaymentMethod == "" {
+		return errors.New("a rule needs at least one condition")
+	}
+	a := rule.Actions
+	if a.Category == "" && a.Payee == "" && len(a.Tags) == 0 {
+		return errors.New("a rule needs at least one action")
+	}
+	if c.MinAmount != nil && c.MaxAmount != nil && *c.MinAmount > *c.MaxAmount {
+		return errors.New("minAmount must not be greater than maxAmount")
+	}
+	if len(c.DescriptionRegex) > maxRegexLength {
+		return fmt.Errorf("descriptionRegex must be at most %d characters", maxRegexLength)
+	}
+	if c.DescriptionRegex != "" {
+		if _, err := regexp.Compile(c.DescriptionRegex); err != nil {
+			return fmt.Errorf("invalid descriptionRegex: %w", err)
+		}
+	}
+	return nil
+}
+
+// New builds an engine from the active rules, ordered by priority and then
+// by the order given.
+func New(rules []types.CategorizationRule) (*Engine, error) {
+	e := &Engine{}
+	for _, rule := range rules {
+		if !rule.Active {
+			continue
+		}
+		c := compiled{rule: rule}
+		if pattern := rule.Conditions.DescriptionRegex; pattern != "" {
+			// Go's RE2 engine runs in linear time, so user patterns cannot
+			// blow up on long descriptions.
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: invalid regex: %w", rule.Name, err)
+			}
+			c.regex = re
+		}
+		e.rules = append(e.rules, c)
+	}
+	sort.SliceStable(e.rules, func(i, j int) bool {
+		return e.rules[i].rule.Priority < e.rules[j].rule.Priority
+	})
+	return e, nil
+}
+
+// Apply runs the rules against the expense and returns the IDs of the rules
+// that changed it. The first matching rule to set the category or payee
+// wins; tags from every matching rule are added. Unless overwrite is set, a
+// category or payee the expense already has is left alone.
+func (e *Engine) Apply(expense *types.Expense, overwrite bool) []string {
+	categorySet := expense.Category != "" && !overwrite
+	payeeSet := expense.Payee != "" && !overwrite
+
+	var applied []string
+	for _, c := range e.rules {
+		if !c.matches(expense) {
+			continue
+		}
+
+		changed := false
+		a := c.rule.Actions
+		if a.Category != "" && !categorySet {
+			categorySet = true
+			if expense.Category != a.Category {
+				expense.Category = a.Category
+				changed = true
+			}
+		}
+		if a.Payee != "" && !payeeSet {
+			payeeSet = true
+			if expense.Payee != a.Payee {
+				expense.Payee = a.Payee
+				changed = true
+			}
+		}
+		for _, tag := range a.Tags {
+			if !slices.Contains(expense.Tags, tag) {
+				expense.Tags = append(expense.Tags, tag)
+				changed = true
+			}
+		}
+
+		if changed {
+			applied = append(applied, c.rule.ID)
+		}
+	}
+	return applied
+}
+
+func (c compiled) matches(expense *types.Expense) bool {
+	cond := c.rule.Conditions
+	if cond.DescriptionContains != "" &&
+		!strings.Contains(strings.ToLower(expense.Description), strings.ToLower(cond.DescriptionContains)) {
+		return false
+	}
+	if c.regex != nil && !c.regex.MatchString(expense.Description) {
+		return false
+	}
+	if cond.MinAmount != nil && expense.Amount < *cond.MinAmount {
+		return false
+	}
+	if cond.MaxAmount != nil && expense.Amount > *cond.MaxAmount {
+		return false
+	}
+	if cond.PaymentMethod != "" && !strings.EqualFold(expense.PaymentMethod, cond.PaymentMethod) {
+		return false
+	}
+	return true
+}
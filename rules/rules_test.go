@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func amount(v float64) *float64 { return &v }
+
+func TestValidate(t *testing.T) {
+	valid := types.CategorizationRule{
+		Conditions: types.RuleConditions{DescriptionContains: "uber"},
+		Actions:    types.RuleActions{Category: "Transport"},
+	}
+	assert.NoError(t, Validate(valid))
+
+	noCondition := valid
+	noCondition.Conditions = types.RuleConditions{}
+	assert.Error(t, Validate(noCondition))
+
+	noAction := valid
+	noAction.Actions = types.RuleActions{}
+	assert.Error(t, Validate(noAction))
+
+	badRegex := valid
+	badRegex.Conditions.DescriptionRegex = "(unclosed"
+	assert.Error(t, Validate(badRegex))
+
+	badRange := valid
+	badRange.Conditions.MinAmount, badRange.Conditions.MaxAmount = amount(10), amount(5)
+	assert.Error(t, Validate(badRange))
+}
+
+func TestApply(t *testing.T) {
+	engine, err := New([]types.CategorizationRule{
+		{
+			ID: "fuel", Priority: 2, Active: true,
+			Conditions: types.RuleConditions{DescriptionRegex: `^(shell|total)\b`},
+			Actions:    types.RuleActions{Category: "Fuel", Tags: []string{"car"}},
+		},
+		{
+			ID: "big", Priority: 1, Active: true,
+			Conditions: types.RuleConditions{MinAmount: amount(100), PaymentMethod: "card"},
+			Actions:    types.RuleActions{Category: "Large", Tags: []string{"review"}, Payee: "Various"},
+		},
+		{
+			ID: "off", Active: false,
+			Conditions: types.RuleConditions{DescriptionContains: "shell"},
+			Actions:    types.RuleActions{Category: "Never"},
+		},
+	})
+	require.NoError(t, err)
+
+	e := types.Expense{Description: "SHELL Kampala Rd", Amount: 50, PaymentMethod: "Cash"}
+	assert.Equal(t, []string{"fuel"}, engine.Apply(&e, false))
+	assert.Equal(t, "Fuel", e.Category)
+	assert.Equal(t, []string{"car"}, e.Tags)
+
+	// Higher priority wins the category; tags accumulate.
+	e = types.Expense{Description: "Shell Entebbe", Amount: 150, PaymentMethod: "CARD"}
+	assert.Equal(t, []string{"big", "fuel"}, engine.Apply(&e, false))
+	assert.Equal(t, "Large", e.Category)
+	assert.Equal(t, "Various", e.Payee)
+	assert.Equal(t, []string{"review", "car"}, e.Tags)
+
+	// Existing values survive unless overwriting.
+	e = types.Expense{Description: "Total Jinja", Category: "Travel", Tags: []string{"car"}}
+	assert.Empty(t, engine.Apply(&e, false))
+	assert.Equal(t, "Travel", e.Category)
+
+	assert.Equal(t, []string{"fuel"}, engine.Apply(&e, true))
+	assert.Equal(t, "Fuel", e.Category)
+
+	e = types.Expense{Description: "Totally unrelated"}
+	assert.Empty(t, engine.Apply(&e, false))
+}
@@ -6,48 +6,67 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/Ayikoandrew/server/database"
 	"github.com/Ayikoandrew/server/types"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+type contextKey string
+
+const userIDKey contextKey = "user_id"
+
+// UserIDFromContext returns the user ID stored by ValidateAccessTokenMiddleware.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey).(string)
+	return userID, ok && userID != ""
+}
+
 func ValidateAccessTokenMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		access := os.Getenv("ACCESS_TOKEN")
-		access_token, err := r.Cookie("access-token")
 
-		if err != nil {
-			slog.Error("Invalid access token")
+		var tokenString string
+		if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+		} else if accessToken, err := r.Cookie("access_token"); err == nil {
+			tokenString = accessToken.Value
 		}
 
-		token, err := jwt.ParseWithClaims(access_token.Value, &types.CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if tokenString == "" {
+			slog.Error("Missing access token")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		token, err := jwt.ParseWithClaims(tokenString, &types.CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
 			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method %+v", t.Header["alg"])
 			}
 			return []byte(access), nil
 		})
-
 		if err != nil {
-			slog.Error("Invalid access token")
+			slog.Error("Invalid access token", "error", err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 
-		claim, ok := token.Claims.(types.CustomClaims)
-
-		if !ok {
-			slog.Error("Invalid access token")
+		claim, ok := token.Claims.(*types.CustomClaims)
+		if !ok || !token.Valid {
+			slog.Error("Invalid access token claims")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 
 		userID := claim.Subject
-		Token := database.Get(userID, context.Background())
-		redisToken, err := Token.Result()
-		if err != nil {
-			slog.Error("Invalid access token")
+		redisToken, err := database.Get(userID, r.Context()).Result()
+		if err != nil || redisToken != tokenString {
+			slog.Error("Access token revoked or unknown", "userId", userID)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
 
-		if access != redisToken {
-			slog.Error("Invalid access token")
-		}
-		next(w, r)
+		next(w, r.WithContext(context.WithValue(r.Context(), userIDKey, userID)))
 	}
 }
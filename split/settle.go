@@ -0,0 +1,145 @@
+package split
+
+import (
+	"sort"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// exactLimit is the most members with a non-zero balance for which the
+// optimal plan is searched for; larger groups fall back to the greedy plan,
+// which needs at most one transfer more per extra subgroup it misses.
+const exactLimit = 16
+
+// SettleUp returns a set of transfers that brings every balance to zero
+// using as few transfers as possible.
+//
+// Any group of n people whose balances cancel out can be settled with n-1
+// transfers, so the plan is shortest when the members are partitioned into
+// as many zero-sum groups as possible. That partition is found by dynamic
+// programming over subsets, and each group is then settled greedily.
+func SettleUp(balances []types.LedgerBalance) []types.Transfer {
+	var users []string
+	var nets []int64
+	for _, b := range balances {
+		if cents := toCents(b.Net); cents != 0 {
+			users = append(users, b.UserID)
+			nets = append(nets, cents)
+		}
+	}
+
+	transfers := []types.Transfer{}
+	for _, group := range zeroSumGroups(nets) {
+		transfers = append(transfers, settleGroup(users, nets, group)...)
+	}
+	return transfers
+}
+
+// zeroSumGroups partitions the indexes of nets into the largest number of
+// groups that each sum to zero. Rounding can leave the overall sum a few
+// cents off zero, in which case the last group absorbs the difference.
+func zeroSumGroups(nets []int64) [][]int {
+	n := len(nets)
+	if n == 0 {
+		return nil
+	}
+	if n > exactLimit {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return [][]int{all}
+	}
+
+	full := 1<<n - 1
+	sums := make([]int64, full+1)
+	best := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := mask & -mask
+		i := bitIndex(low)
+		sums[mask] = sums[mask^low] + nets[i]
+
+		for j := 0; j < n; j++ {
+			if mask&(1<<j) != 0 && best[mask^(1<<j)] > best[mask] {
+				best[mask] = best[mask^(1<<j)]
+			}
+		}
+		if sums[mask] == 0 {
+			best[mask]++
+		}
+	}
+
+	// Walk back from the full set, peeling off one member at a time in an
+	// order that keeps the best count; a group closes whenever the remaining
+	// set sums to zero.
+	var groups [][]int
+	var current []int
+	mask := full
+	for mask != 0 {
+		target := best[mask]
+		if sums[mask] == 0 {
+			target--
+			if len(current) > 0 {
+				groups = append(groups, current)
+				current = nil
+			}
+		}
+		for j := 0; j < n; j++ {
+			if mask&(1<<j) != 0 && best[mask^(1<<j)] == target {
+				current = append(current, j)
+				mask ^= 1 << j
+				break
+			}
+		}
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+// settleGroup pays off the largest debt to the largest credit until the
+// group is square, which takes at most len(group)-1 transfers.
+func settleGroup(users []string, nets []int64, group []int) []types.Transfer {
+	type party struct {
+		user   string
+		amount int64
+	}
+	var debtors, creditors []party
+	for _, i := range group {
+		if nets[i] < 0 {
+			debtors = append(debtors, party{users[i], -nets[i]})
+		} else {
+			creditors = append(creditors, party{users[i], nets[i]})
+		}
+	}
+
+	var transfers []types.Transfer
+	for len(debtors) > 0 && len(creditors) > 0 {
+		sort.Slice(debtors, func(a, b int) bool { return debtors[a].amount > debtors[b].amount })
+		sort.Slice(creditors, func(a, b int) bool { return creditors[a].amount > creditors[b].amount })
+
+		d, c := &debtors[0], &creditors[0]
+		amount := min(d.amount, c.amount)
+		transfers = append(transfers, types.Transfer{From: d.user, To: c.user, Amount: fromCents(amount)})
+		d.amount -= amount
+		c.amount -= amount
+
+		if d.amount == 0 {
+			debtors = debtors[1:]
+		}
+		if c.amount == 0 {
+			creditors = creditors[1:]
+		}
+	}
+	return transfers
+}
+
+func bitIndex(bit int) int {
+	i := 0
+	for bit > 1 {
+		bit >>= 1
+		i++
+	}
+	return i
+}
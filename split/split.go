@@ -0,0 +1,122 @@
+package split
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Ayikoandrew/server/types"
+)
+
+// Allocate works out what each participant owes of amount. Amounts are
+// divided in whole cents; cents left over by rounding go to the participants
+// with the largest fractional remainders, earlier participants first on ties,
+// so the parts always add up to the total exactly.
+func Allocate(amount float64, method types.SplitMethod, parts []types.Split) ([]types.Split, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("a split needs at least one participant")
+	}
+	total := toCents(amount)
+	if total <= 0 {
+		return nil, errors.New("amount must be greater than zero")
+	}
+
+	seen := make(map[string]bool, len(parts))
+	for _, p := range parts {
+		if p.UserID == "" {
+			return nil, errors.New("every split needs a userId")
+		}
+		if seen[p.UserID] {
+			return nil, fmt.Errorf("user %s appears in the split more than once", p.UserID)
+		}
+		seen[p.UserID] = true
+		if p.Value < 0 {
+			return nil, errors.New("split values must not be negative")
+		}
+	}
+
+	weights := make([]float64, len(parts))
+	switch method {
+	case types.SplitEqual:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case types.SplitShares:
+		for i, p := range parts {
+			if p.Value <= 0 {
+				return nil, errors.New("every participant needs a positive number of shares")
+			}
+			weights[i] = p.Value
+		}
+	case types.SplitPercentage:
+		var sum float64
+		for i, p := range parts {
+			weights[i] = p.Value
+			sum += p.Value
+		}
+		if math.Abs(sum-100) > 0.001 {
+			return nil, fmt.Errorf("percentages must add up to 100, got %g", sum)
+		}
+	case types.SplitExact:
+		var sum int64
+		result := make([]types.Split, len(parts))
+		for i, p := range parts {
+			cents := toCents(p.Value)
+			sum += cents
+			result[i] = types.Split{UserID: p.UserID, Value: p.Value, Amount: fromCents(cents)}
+		}
+		if sum != total {
+			return nil, fmt.Errorf("exact amounts add up to %.2f, expected %.2f", fromCents(sum), fromCents(total))
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported split method %q", method)
+	}
+
+	return distribute(total, parts, weights), nil
+}
+
+func distribute(total int64, parts []types.Split, weights []float64) []types.Split {
+	var weightSum float64
+	for _, w := range weights {
+		weightSum += w
+	}
+
+	cents := make([]int64, len(parts))
+	remainders := make([]float64, len(parts))
+	var allocated int64
+	for i, w := range weights {
+		exact := float64(total) * w / weightSum
+		// The epsilon keeps values like 332.99999999 from losing a cent.
+		cents[i] = int64(math.Floor(exact + 1e-9))
+		remainders[i] = exact - float64(cents[i])
+		allocated += cents[i]
+	}
+
+	order := make([]int, len(parts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; allocated < total; i++ {
+		cents[order[i%len(order)]]++
+		allocated++
+	}
+
+	result := make([]types.Split, len(parts))
+	for i, p := range parts {
+		result[i] = types.Split{UserID: p.UserID, Value: p.Value, Amount: fromCents(cents[i])}
+	}
+	return result
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,117 @@
+package split
+
+import (
+	"testing"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func amounts(splits []types.Split) []float64 {
+	var out []float64
+	for _, s := range splits {
+		out = append(out, s.Amount)
+	}
+	return out
+}
+
+func people(values ...float64) []types.Split {
+	var parts []types.Split
+	for i, v := range values {
+		parts = append(parts, types.Split{UserID: string(rune('a' + i)), Value: v})
+	}
+	return parts
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount float64
+		method types.SplitMethod
+		parts  []types.Split
+		want   []float64
+	}{
+		{"equal with remainder", 100, types.SplitEqual, people(0, 0, 0), []float64{33.34, 33.33, 33.33}},
+		{"percentage", 250, types.SplitPercentage, people(50, 30, 20), []float64{125, 75, 50}},
+		{"shares", 90, types.SplitShares, people(2, 1), []float64{60, 30}},
+		{"shares with remainder", 10, types.SplitShares, people(1, 1, 1), []float64{3.34, 3.33, 3.33}},
+		{"exact", 70.5, types.SplitExact, people(50, 20.5), []float64{50, 20.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splits, err := Allocate(tt.amount, tt.method, tt.parts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, amounts(splits))
+		})
+	}
+}
+
+func TestAllocateErrors(t *testing.T) {
+	_, err := Allocate(100, types.SplitPercentage, people(50, 40))
+	assert.ErrorContains(t, err, "add up to 100")
+
+	_, err = Allocate(100, types.SplitExact, people(50, 40))
+	assert.ErrorContains(t, err, "expected 100.00")
+
+	_, err = Allocate(100, types.SplitShares, people(1, 0))
+	assert.Error(t, err)
+
+	_, err = Allocate(100, types.SplitEqual, nil)
+	assert.Error(t, err)
+
+	_, err = Allocate(100, types.SplitEqual, []types.Split{{UserID: "a"}, {UserID: "a"}})
+	assert.Error(t, err)
+
+	_, err = Allocate(100, "thirds", people(1))
+	assert.Error(t, err)
+}
+
+func balances(nets map[string]float64) []types.LedgerBalance {
+	var out []types.LedgerBalance
+	for _, user := range []string{"a", "b", "c", "d", "e", "f"} {
+		if net, ok := nets[user]; ok {
+			out = append(out, types.LedgerBalance{UserID: user, Net: net})
+		}
+	}
+	return out
+}
+
+// apply checks that the transfers clear every balance.
+func apply(t *testing.T, nets map[string]float64, transfers []types.Transfer) {
+	t.Helper()
+	left := map[string]int64{}
+	for user, net := range nets {
+		left[user] = toCents(net)
+	}
+	for _, tr := range transfers {
+		assert.Positive(t, tr.Amount)
+		left[tr.From] += toCents(tr.Amount)
+		left[tr.To] -= toCents(tr.Amount)
+	}
+	for user, cents := range left {
+		assert.Zero(t, cents, "balance of %s", user)
+	}
+}
+
+func TestSettleUp(t *testing.T) {
+	t.Run("simple", func(t *testing.T) {
+		nets := map[string]float64{"a": 60, "b": -30, "c": -30}
+		transfers := SettleUp(balances(nets))
+		assert.Len(t, transfers, 2)
+		apply(t, nets, transfers)
+	})
+
+	t.Run("finds independent pairs", func(t *testing.T) {
+		// Matching largest debt to largest credit needs four transfers here;
+		// settling {b, e} and {a, c, d} separately needs three.
+		nets := map[string]float64{"a": 6, "b": 4, "c": -3, "d": -3, "e": -4}
+		transfers := SettleUp(balances(nets))
+		assert.Len(t, transfers, 3)
+		apply(t, nets, transfers)
+	})
+
+	t.Run("settled ledger", func(t *testing.T) {
+		assert.Empty(t, SettleUp(balances(map[string]float64{"a": 0, "b": 0})))
+	})
+}
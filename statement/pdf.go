@@ -0,0 +1,252 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The PDF is A4 in points, using the standard Helvetica and Courier fonts
+// every reader has, so no fonts are embedded. Amounts are set in Courier
+// so they can be right-aligned without font metrics.
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 40
+	rowHeight    = 14
+	bodySize     = 9
+	footerY      = 30
+	lastRowY     = 60
+	firstTableY  = 680
+	nextTableY   = 790
+	maxDescRunes = 48
+)
+
+var columnRight = struct{ in, out, balance float64 }{390, 470, pageWidth - margin}
+
+type row struct {
+	date, description, in, out, balance string
+	bold                                bool
+}
+
+// WritePDF renders the statement as a PDF document.
+func WritePDF(w io.Writer, doc Document) error {
+	pages := paginate(rows(doc))
+
+	var p pdfWriter
+	p.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-5 are fixed; each page then takes a page object and its
+	// content stream.
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	p.object("<< /Type /Catalog /Pages 2 0 R >>")
+	p.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	p.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	p.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	p.object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pages {
+		content := renderPage(doc, page, i, len(pages))
+		p.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 7+2*i))
+		p.object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	_, err := w.Write(p.finish())
+	return err
+}
+
+// rows lays the statement out as table rows between the opening and
+// closing balances.
+func rows(doc Document) []row {
+	st := doc.Statement
+	out := []row{{date: st.PeriodStart, description: "Opening balance", balance: amount(st.OpeningBalance, st.Currency), bold: true}}
+	for _, l := range doc.Lines {
+		r := row{date: l.CreatedAt, description: l.Description, balance: amount(l.BalanceAfter, st.Currency)}
+		if r.description == "" {
+			r.description = strings.ReplaceAll(l.Kind, "_", " ")
+		}
+		if l.Amount > 0 {
+			r.in = amount(l.Amount, st.Currency)
+		} else {
+			r.out = amount(-l.Amount, st.Currency)
+		}
+		out = append(out, r)
+	}
+	return append(out, row{
+		date:        st.PeriodEnd,
+		description: "Closing balance",
+		in:          amount(st.TotalIn, st.Currency),
+		out:         amount(st.TotalOut, st.Currency),
+		balance:     amount(st.ClosingBalance, st.Currency),
+		bold:        true,
+	})
+}
+
+// paginate splits rows into pages. The first page holds fewer rows since
+// it also carries the summary.
+func paginate(all []row) [][]row {
+	perPage := func(page int) int {
+		top := nextTableY
+		if page == 0 {
+			top = firstTableY
+		}
+		return (top-rowHeight-lastRowY)/rowHeight + 1
+	}
+
+	var pages [][]row
+	for len(all) > 0 {
+		n := min(perPage(len(pages)), len(all))
+		pages = append(pages, all[:n])
+		all = all[n:]
+	}
+	return pages
+}
+
+func renderPage(doc Document, page []row, index, total int) string {
+	st := doc.Statement
+	month := st.Period
+	if t, err := time.Parse(PeriodLayout, st.Period); err == nil {
+		month = t.Format("January 2006")
+	}
+
+	var c content
+	tableY := nextTableY
+	if index == 0 {
+		c.text("F2", 18, margin, 790, "Account statement")
+		c.text("F1", 10, margin, 770, doc.Holder)
+		c.text("F1", 10, margin, 756, doc.Email)
+		c.text("F1", 10, 360, 770, "Period: "+month)
+		c.text("F1", 10, 360, 756, "Wallet: "+st.Currency)
+		c.text("F1", 10, 360, 742, "Timezone: "+st.Timezone)
+
+		summary := []struct{ label, value string }{
+			{"Opening balance", amount(st.OpeningBalance, st.Currency)},
+			{"Money in", amount(st.TotalIn, st.Currency)},
+			{"Money out", amount(st.TotalOut, st.Currency)},
+			{"Closing balance", amount(st.ClosingBalance, st.Currency)},
+		}
+		for i, s := range summary {
+			x := float64(margin + i*130)
+			c.text("F1", 8, x, 722, s.label)
+			c.text("F3", 11, x, 708, s.value+" "+st.Currency)
+		}
+		tableY = firstTableY
+	} else {
+		c.text("F2", 11, margin, 810, fmt.Sprintf("Account statement, %s %s (continued)", month, st.Currency))
+	}
+
+	c.text("F2", bodySize, margin, float64(tableY), "Date")
+	c.text("F2", bodySize, 130, float64(tableY), "Description")
+	c.textRight("F2", bodySize, columnRight.in, float64(tableY), "Money in")
+	c.textRight("F2", bodySize, columnRight.out, float64(tableY), "Money out")
+	c.textRight("F2", bodySize, columnRight.balance, float64(tableY), "Balance")
+	c.line(margin, float64(tableY-4), pageWidth-margin, float64(tableY-4))
+
+	y := float64(tableY - rowHeight)
+	for _, r := range page {
+		font := "F1"
+		if r.bold {
+			font = "F2"
+		}
+		c.text(font, bodySize, margin, y, r.date)
+		c.text(font, bodySize, 130, y, truncate(r.description, maxDescRunes))
+		c.amount(columnRight.in, y, r.in)
+		c.amount(columnRight.out, y, r.out)
+		c.amount(columnRight.balance, y, r.balance)
+		y -= rowHeight
+	}
+
+	c.line(margin, footerY+12, pageWidth-margin, footerY+12)
+	c.text("F1", 8, margin, footerY, fmt.Sprintf("Generated %s", doc.Generated.UTC().Format("2006-01-02 15:04 UTC")))
+	c.textRight("F1", 8, pageWidth-margin, footerY, fmt.Sprintf("Page %d of %d", index+1, total))
+	return c.String()
+}
+
+// content builds a page's content stream.
+type content struct {
+	strings.Builder
+}
+
+func (c *content) text(font string, size, x, y float64, s string) {
+	if s == "" {
+		return
+	}
+	fmt.Fprintf(c, "BT /%s %g Tf %g %g Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// textRight sets s ending at x. Only Courier widths are exact; Helvetica
+// is estimated, which is close enough for short headings.
+func (c *content) textRight(font string, size, x, y float64, s string) {
+	width := 0.6 * size * float64(utf8.RuneCountInString(s))
+	if font != "F3" {
+		width = 0.5 * size * float64(utf8.RuneCountInString(s))
+	}
+	c.text(font, size, x-width, y, s)
+}
+
+func (c *content) amount(right, y float64, s string) {
+	c.textRight("F3", bodySize, right, y, s)
+}
+
+func (c *content) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(c, "0.5 w %g %g m %g %g l S\n", x1, y1, x2, y2)
+}
+
+// escape encodes s as a PDF string literal in WinAnsi. Characters outside
+// Latin-1 become question marks.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
+
+// pdfWriter numbers objects in the order they are written and records
+// their offsets for the cross-reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (p *pdfWriter) object(body string) {
+	p.offsets = append(p.offsets, p.buf.Len())
+	fmt.Fprintf(&p.buf, "%d 0 obj\n%s\nendobj\n", len(p.offsets), body)
+}
+
+func (p *pdfWriter) finish() []byte {
+	xref := p.buf.Len()
+	fmt.Fprintf(&p.buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, off := range p.offsets {
+		fmt.Fprintf(&p.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&p.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref)
+	return p.buf.Bytes()
+}
@@ -0,0 +1,111 @@
+// Package statement builds monthly wallet statements and renders them as
+// PDF and CSV documents.
+package statement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ayikoandrew/server/ledger"
+	"github.com/Ayikoandrew/server/types"
+)
+
+// PeriodLayout is how a statement period is written, such as "2026-09".
+const PeriodLayout = "2006-01"
+
+var ErrUnreconciled = errors.New("statement postings do not reconcile with the opening balance")
+
+// Document is everything printed on a statement. Line dates are already
+// in the holder's timezone.
+type Document struct {
+	Statement types.Statement
+	Holder    string
+	Email     string
+	Lines     []types.AccountStatementLine
+	Generated time.Time
+}
+
+// Month returns the start of period in loc and the start of the month
+// after it.
+func Month(period string, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(PeriodLayout, period, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("period must be a month written as YYYY-MM")
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// PreviousMonth is the last month to have ended by now, in now's location.
+func PreviousMonth(now time.Time) string {
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return first.AddDate(0, -1, 0).Format(PeriodLayout)
+}
+
+// Summarize fills in the totals, line count and closing balance of st from
+// its opening balance and the month's postings, oldest first. Each
+// posting's balance must follow from the one before it.
+func Summarize(st *types.Statement, lines []types.AccountStatementLine) error {
+	st.TotalIn, st.TotalOut = 0, 0
+	balance := st.OpeningBalance
+	for _, l := range lines {
+		if balance+l.Amount != l.BalanceAfter {
+			return fmt.Errorf("%w: entry %s", ErrUnreconciled, l.EntryID)
+		}
+		if l.Amount > 0 {
+			st.TotalIn += l.Amount
+		} else {
+			st.TotalOut -= l.Amount
+		}
+		balance = l.BalanceAfter
+	}
+	st.ClosingBalance = balance
+	st.LineCount = len(lines)
+	return nil
+}
+
+// WriteCSV writes the statement as one table, opening with the opening
+// balance and ending with the closing balance.
+func WriteCSV(w io.Writer, doc Document) error {
+	st := doc.Statement
+	cw := csv.NewWriter(w)
+	records := [][]string{
+		{"date", "type", "reference", "description", "money_in", "money_out", "balance"},
+		{st.PeriodStart, "", "", "Opening balance", "", "", amount(st.OpeningBalance, st.Currency)},
+	}
+	for _, l := range doc.Lines {
+		in, out := "", ""
+		if l.Amount > 0 {
+			in = amount(l.Amount, st.Currency)
+		} else {
+			out = amount(-l.Amount, st.Currency)
+		}
+		records = append(records, []string{
+			l.CreatedAt, csvText(l.Kind), csvText(l.Reference), csvText(l.Description), in, out, amount(l.BalanceAfter, st.Currency),
+		})
+	}
+	records = append(records, []string{
+		st.PeriodEnd, "", "", "Closing balance",
+		amount(st.TotalIn, st.Currency), amount(st.TotalOut, st.Currency), amount(st.ClosingBalance, st.Currency),
+	})
+	return cw.WriteAll(records)
+}
+
+// csvText keeps a text cell from being read as a formula by spreadsheet
+// software: one starting with =, +, -, @, a tab or a carriage return gets a
+// leading quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// amount renders minor units as a plain decimal without the currency.
+func amount(minor int64, currency string) string {
+	return strconv.FormatFloat(ledger.FromMinor(minor, currency), 'f', ledger.Exponent(currency), 64)
+}
@@ -0,0 +1,136 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Ayikoandrew/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleDocument(t *testing.T, n int) Document {
+	t.Helper()
+	st := types.Statement{
+		Currency:       "USD",
+		Period:         "2026-09",
+		Timezone:       "Africa/Kampala",
+		PeriodStart:    "2026-09-01",
+		PeriodEnd:      "2026-09-30",
+		OpeningBalance: 10000,
+	}
+	balance := st.OpeningBalance
+	var lines []types.AccountStatementLine
+	for i := range n {
+		amount := int64(2500)
+		if i%2 == 1 {
+			amount = -1999
+		}
+		balance += amount
+		lines = append(lines, types.AccountStatementLine{
+			EntryID:      fmt.Sprintf("e%d", i),
+			Kind:         "wallet_transfer",
+			Reference:    fmt.Sprintf("transfer:%d", i),
+			Description:  "Rent (September) für Café",
+			Amount:       amount,
+			BalanceAfter: balance,
+			CreatedAt:    "2026-09-02 10:15",
+		})
+	}
+	require.NoError(t, Summarize(&st, lines))
+	return Document{Statement: st, Holder: "Jane Doe", Email: "jane@example.com", Lines: lines,
+		Generated: time.Date(2026, 10, 1, 1, 0, 0, 0, time.UTC)}
+}
+
+func TestMonth(t *testing.T) {
+	loc, err := time.LoadLocation("Africa/Kampala")
+	require.NoError(t, err)
+
+	from, to, err := Month("2026-02", loc)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-01-31T21:00:00Z", from.UTC().Format(time.RFC3339))
+	assert.Equal(t, "2026-02-28T21:00:00Z", to.UTC().Format(time.RFC3339))
+
+	_, _, err = Month("2026-13", loc)
+	assert.Error(t, err)
+	assert.Equal(t, "2026-09", PreviousMonth(time.Date(2026, 10, 1, 0, 0, 0, 0, loc)))
+	assert.Equal(t, "2025-12", PreviousMonth(time.Date(2026, 1, 31, 23, 0, 0, 0, loc)))
+}
+
+func TestSummarize(t *testing.T) {
+	doc := sampleDocument(t, 3)
+	st := doc.Statement
+	assert.Equal(t, int64(5000), st.TotalIn)
+	assert.Equal(t, int64(1999), st.TotalOut)
+	assert.Equal(t, int64(13001), st.ClosingBalance)
+	assert.Equal(t, 3, st.LineCount)
+
+	doc.Lines[1].BalanceAfter++
+	assert.ErrorIs(t, Summarize(&st, doc.Lines), ErrUnreconciled)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, sampleDocument(t, 2)))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	assert.Equal(t, []string{"2026-09-01", "", "", "Opening balance", "", "", "100.00"}, records[1])
+	assert.Equal(t, []string{"2026-09-02 10:15", "wallet_transfer", "transfer:0", "Rent (September) für Café", "25.00", "", "125.00"}, records[2])
+	assert.Equal(t, "19.99", records[3][5])
+	assert.Equal(t, []string{"2026-09-30", "", "", "Closing balance", "25.00", "19.99", "105.01"}, records[4])
+}
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	doc := sampleDocument(t, 6)
+	for i, d := range []string{`=HYPERLINK("http://evil")`, "+1", "-2+3", "@SUM(A1)", "\tx", "\r=1"} {
+		doc.Lines[i].Description = d
+	}
+	doc.Lines[0].Reference = "=cmd"
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, doc))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+
+	assert.Equal(t, `'=HYPERLINK("http://evil")`, records[2][3])
+	assert.Equal(t, "'=cmd", records[2][2])
+	assert.Equal(t, "'+1", records[3][3])
+	assert.Equal(t, "'-2+3", records[4][3])
+	assert.Equal(t, "'@SUM(A1)", records[5][3])
+	assert.Equal(t, "'\tx", records[6][3])
+	assert.Equal(t, "'\r=1", records[7][3])
+	assert.Equal(t, "Closing balance", records[8][3])
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WritePDF(&buf, sampleDocument(t, 120)))
+	out := buf.String()
+
+	assert.Regexp(t, `^%PDF-1\.4\n`, out)
+	assert.Regexp(t, `%%EOF\n$`, out)
+	assert.Contains(t, out, "/Count 3")
+	assert.Contains(t, out, `(Rent \(September\) f\374r Caf\351)`)
+	assert.Contains(t, out, "(Opening balance)")
+	assert.Contains(t, out, "(Page 3 of 3)")
+
+	// Every cross-reference entry must point at the object it names.
+	xref := regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(out)
+	require.Len(t, xref, 2)
+	start, err := strconv.Atoi(xref[1])
+	require.NoError(t, err)
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(out[start:], -1)
+	require.NotEmpty(t, offsets)
+	for i, m := range offsets {
+		off, err := strconv.Atoi(m[1])
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(buf.Bytes()[off:], fmt.Appendf(nil, "%d 0 obj", i+1)), "object %d", i+1)
+	}
+}
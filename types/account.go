@@ -15,12 +15,25 @@ type Account struct {
 }
 
 type User struct {
-	ID          string `json:"id"`
-	FirstName   string `json:"firstName"`
-	LastName    string `json:"lastName"`
-	PhoneNumber string `json:"phoneNumber"`
-	Email       string `json:"email"`
-	Password    string `json:"-"`
+	ID           string  `json:"id"`
+	FirstName    string  `json:"firstName"`
+	LastName     string  `json:"lastName"`
+	PhoneNumber  string  `json:"phoneNumber"`
+	Email        string  `json:"email"`
+	Password     string  `json:"-"`
+	Timezone     string  `json:"timezone,omitempty"`
+	HomeCurrency string  `json:"homeCurrency,omitempty"`
+	Role         string  `json:"role,omitempty"`
+	KYCTier      KYCTier `json:"kycTier"`
+}
+
+const RoleAdmin = "admin"
+
+// ProfileUpdate carries the user settings that may be changed after signup.
+// Nil fields are left untouched.
+type ProfileUpdate struct {
+	Timezone     *string `json:"timezone,omitempty"`
+	HomeCurrency *string `json:"homeCurrency,omitempty"`
 }
 
 type LoginRequest struct {
@@ -0,0 +1,51 @@
+package types
+
+// Report totals are converted to Currency, the user's home currency, at the
+// rate on each expense's date. Original holds the unconverted totals per
+// currency, and Unconverted counts expenses left out of Total because no
+// rate was known for them.
+
+type CategorySpend struct {
+	Month       string             `json:"month"`
+	Category    string             `json:"category"`
+	Currency    string             `json:"currency"`
+	Total       float64            `json:"total"`
+	Count       int                `json:"count"`
+	Original    map[string]float64 `json:"original"`
+	Unconverted int                `json:"unconverted,omitempty"`
+}
+
+type PaymentMethodSpend struct {
+	PaymentMethod string             `json:"paymentMethod"`
+	Currency      string             `json:"currency"`
+	Total         float64            `json:"total"`
+	Count         int                `json:"count"`
+	Original      map[string]float64 `json:"original"`
+	Unconverted   int                `json:"unconverted,omitempty"`
+}
+
+type TrendPoint struct {
+	Period      string  `json:"period"`
+	Currency    string  `json:"currency"`
+	Total       float64 `json:"total"`
+	Count       int     `json:"count"`
+	Unconverted int     `json:"unconverted,omitempty"`
+}
+
+type TopDescription struct {
+	Description string             `json:"description"`
+	Currency    string             `json:"currency"`
+	Total       float64            `json:"total"`
+	Count       int                `json:"count"`
+	Original    map[string]float64 `json:"original"`
+	Unconverted int                `json:"unconverted,omitempty"`
+}
+
+type MonthChange struct {
+	Month         string   `json:"month"`
+	Currency      string   `json:"currency"`
+	Total         float64  `json:"total"`
+	Previous      float64  `json:"previous"`
+	Change        float64  `json:"change"`
+	ChangePercent *float64 `json:"changePercent"`
+}
@@ -0,0 +1,13 @@
+package types
+
+// AuditEvent records a security or compliance decision about a user.
+// ActorID is whoever made it, empty for the system.
+type AuditEvent struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"userId"`
+	ActorID   string         `json:"actorId,omitempty"`
+	Action    string         `json:"action"`
+	Outcome   string         `json:"outcome"`
+	Details   map[string]any `json:"details,omitempty"`
+	CreatedAt string         `json:"createdAt"`
+}
@@ -0,0 +1,55 @@
+package types
+
+type BatchMode string
+
+const (
+	// BatchAtomic applies every operation or none of them.
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort applies each operation that succeeds and reports the
+	// rest.
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchOperation is one queued change. Expense is required to create, ID
+// to update or delete, and Update to update. ClientID is echoed back so the
+// caller can match results to its queue.
+type BatchOperation struct {
+	Op       BatchOp        `json:"op"`
+	ClientID string         `json:"clientId,omitempty"`
+	ID       string         `json:"id,omitempty"`
+	Expense  *Expense       `json:"expense,omitempty"`
+	Update   *ExpenseUpdate `json:"update,omitempty"`
+}
+
+type BatchRequest struct {
+	Mode       BatchMode        `json:"mode"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchResult reports one operation with the HTTP status it would have got
+// as a single request. In an atomic batch that failed, the operations that
+// were rolled back have status 424.
+type BatchResult struct {
+	Index    int      `json:"index"`
+	Op       BatchOp  `json:"op"`
+	ClientID string   `json:"clientId,omitempty"`
+	ID       string   `json:"id,omitempty"`
+	Status   int      `json:"status"`
+	Error    string   `json:"error,omitempty"`
+	Expense  *Expense `json:"expense,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode      BatchMode     `json:"mode"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
@@ -0,0 +1,22 @@
+package types
+
+// BudgetAlertRule warns the user once spending in a category reaches
+// ThresholdPercent of its monthly budget.
+type BudgetAlertRule struct {
+	ID               string   `json:"id,omitempty"`
+	Category         string   `json:"category"`
+	ThresholdPercent float64  `json:"thresholdPercent"`
+	Channels         []string `json:"channels"`
+}
+
+// BudgetStatus is how much of a category's monthly budget has been used.
+// Budget and Spent are in Currency, the user's home currency; Original holds
+// the spending per currency before conversion.
+type BudgetStatus struct {
+	Category    string             `json:"category"`
+	Currency    string             `json:"currency"`
+	Budget      float64            `json:"budget"`
+	Spent       float64            `json:"spent"`
+	Original    map[string]float64 `json:"original"`
+	Unconverted int                `json:"unconverted,omitempty"`
+}
@@ -1,9 +1,18 @@
 package types
 
 type Expense struct {
-	Amount      float64 `json:"amount"`
-	Date        string  `json:"date"`
-	Description string  `json:"description"`
+	ID            string   `json:"id,omitempty"`
+	UserID        string   `json:"userId,omitempty"`
+	Amount        float64  `json:"amount"`
+	Date          string   `json:"date"`
+	Description   string   `json:"description"`
+	Category      string   `json:"category,omitempty"`
+	PaymentMethod string   `json:"paymentMethod,omitempty"`
+	RecurringID   string   `json:"recurringId,omitempty"`
+	Notes         string   `json:"notes,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Payee         string   `json:"payee,omitempty"`
+	Currency      string   `json:"currency,omitempty"`
 }
 
 type PaymentMethods struct {
@@ -14,3 +23,46 @@ type Category struct {
 	Name   string  `json:"name"`
 	Budget float64 `json:"budget"`
 }
+
+// ExpenseFilter narrows an expense listing. Empty fields are ignored; dates
+// are inclusive and formatted YYYY-MM-DD.
+type ExpenseFilter struct {
+	From          string  `json:"from,omitempty"`
+	To            string  `json:"to,omitempty"`
+	Category      string  `json:"category,omitempty"`
+	PaymentMethod string  `json:"paymentMethod,omitempty"`
+	MinAmount     float64 `json:"minAmount,omitempty"`
+	MaxAmount     float64 `json:"maxAmount,omitempty"`
+	// Tags keeps expenses carrying every listed tag.
+	Tags []string `json:"tags,omitempty"`
+	// Query is a full-text search over descriptions and notes, in web search
+	// syntax: quoted phrases, "or" and -excluded words.
+	Query string `json:"q,omitempty"`
+}
+
+// ExpenseSearchResult is an expense matched by a full-text query, with its
+// relevance and the matching text highlighted in <mark> tags.
+type ExpenseSearchResult struct {
+	Expense
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// ExpenseUpdate is a partial edit of an expense; nil fields are unchanged.
+type ExpenseUpdate struct {
+	Amount        *float64 `json:"amount,omitempty"`
+	Date          *string  `json:"date,omitempty"`
+	Description   *string  `json:"description,omitempty"`
+	Category      *string  `json:"category,omitempty"`
+	PaymentMethod *string  `json:"paymentMethod,omitempty"`
+	Notes         *string  `json:"notes,omitempty"`
+	Payee         *string  `json:"payee,omitempty"`
+	Currency      *string  `json:"currency,omitempty"`
+	// Tags replaces the expense's tags when present; an empty list clears them.
+	Tags *[]string `json:"tags,omitempty"`
+}
@@ -0,0 +1,20 @@
+package types
+
+// ExchangeRate says one unit of Base was worth Rate units of Quote from Date
+// until the next rate for the pair takes effect.
+type ExchangeRate struct {
+	Base   string  `json:"base"`
+	Quote  string  `json:"quote"`
+	Date   string  `json:"date"`
+	Rate   float64 `json:"rate"`
+	Source string  `json:"source,omitempty"`
+}
+
+type Conversion struct {
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	Date      string  `json:"date"`
+	Rate      float64 `json:"rate"`
+	Amount    float64 `json:"amount"`
+	Converted float64 `json:"converted"`
+}
@@ -0,0 +1,77 @@
+package types
+
+type GoalStatus string
+
+const (
+	GoalOnTrack  GoalStatus = "on_track"
+	GoalBehind   GoalStatus = "behind"
+	GoalAchieved GoalStatus = "achieved"
+	GoalOverdue  GoalStatus = "overdue"
+)
+
+type ContributionSource string
+
+const (
+	ContributionManual ContributionSource = "manual"
+	ContributionRule   ContributionSource = "rule"
+)
+
+// GoalRule sets aside Percent of every income entry for a goal, or only of
+// income of IncomeKind when it is set.
+type GoalRule struct {
+	Percent    float64    `json:"percent"`
+	IncomeKind IncomeKind `json:"incomeKind,omitempty"`
+}
+
+// SavingsGoal is an amount to save in Currency by Deadline. Saved is the
+// total contributed so far.
+type SavingsGoal struct {
+	ID           string    `json:"id,omitempty"`
+	UserID       string    `json:"userId,omitempty"`
+	Name         string    `json:"name"`
+	TargetAmount float64   `json:"targetAmount"`
+	Currency     string    `json:"currency,omitempty"`
+	Deadline     string    `json:"deadline"`
+	Rule         *GoalRule `json:"rule,omitempty"`
+	Saved        float64   `json:"saved"`
+	CreatedAt    string    `json:"createdAt,omitempty"`
+}
+
+// GoalUpdate is a partial edit of a goal; nil fields are unchanged. A rule
+// with a zero percent turns automatic contributions off.
+type GoalUpdate struct {
+	Name         *string   `json:"name,omitempty"`
+	TargetAmount *float64  `json:"targetAmount,omitempty"`
+	Deadline     *string   `json:"deadline,omitempty"`
+	Rule         *GoalRule `json:"rule,omitempty"`
+}
+
+// GoalContribution is money put towards a goal, in the goal's currency.
+// Rule contributions record the income entry they were taken from.
+type GoalContribution struct {
+	ID       string             `json:"id,omitempty"`
+	GoalID   string             `json:"goalId,omitempty"`
+	Amount   float64            `json:"amount"`
+	Date     string             `json:"date"`
+	Note     string             `json:"note,omitempty"`
+	Source   ContributionSource `json:"source"`
+	IncomeID string             `json:"incomeId,omitempty"`
+}
+
+// GoalProgress reports how far a goal has come. Expected is what a steady
+// saver would have put aside by now, and RequiredMonthly is what must be
+// contributed each remaining month to reach the target in time.
+type GoalProgress struct {
+	SavingsGoal
+	Remaining       float64    `json:"remaining"`
+	PercentComplete float64    `json:"percentComplete"`
+	Expected        float64    `json:"expected"`
+	MonthsLeft      int        `json:"monthsLeft"`
+	RequiredMonthly float64    `json:"requiredMonthly"`
+	Status          GoalStatus `json:"status"`
+}
+
+type GoalDetail struct {
+	GoalProgress
+	Contributions []GoalContribution `json:"contributions"`
+}
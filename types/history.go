@@ -0,0 +1,45 @@
+package types
+
+type ExpenseAction string
+
+const (
+	ExpenseCreated  ExpenseAction = "created"
+	ExpenseUpdated  ExpenseAction = "updated"
+	ExpenseDeleted  ExpenseAction = "deleted"
+	ExpenseRestored ExpenseAction = "restored"
+)
+
+// Where a change to an expense came from. ChangedBy on the version says
+// which user, if any, set it off.
+const (
+	SourceUser      = "user"
+	SourceImport    = "import"
+	SourceRecurring = "recurring"
+	SourceRules     = "rules"
+)
+
+// FieldChange is one field's value before and after a change. Old is absent
+// on the version that created the expense.
+type FieldChange struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new"`
+}
+
+// ExpenseVersion is one entry in an expense's history, numbered from 1.
+// Changes is keyed by the expense's JSON field names.
+type ExpenseVersion struct {
+	Version   int                    `json:"version"`
+	Action    ExpenseAction          `json:"action"`
+	Source    string                 `json:"source"`
+	ChangedBy string                 `json:"changedBy,omitempty"`
+	Changes   map[string]FieldChange `json:"changes"`
+	CreatedAt string                 `json:"createdAt"`
+}
+
+// TrashedExpense is a soft-deleted expense that can still be restored until
+// PurgeAt.
+type TrashedExpense struct {
+	Expense
+	DeletedAt string `json:"deletedAt"`
+	PurgeAt   string `json:"purgeAt"`
+}
@@ -0,0 +1,12 @@
+package types
+
+// IdempotencyRecord is kept for each Idempotency-Key: the fingerprint of the
+// request that claimed it and, once that request finished, the response to
+// replay to retries.
+type IdempotencyRecord struct {
+	Fingerprint string              `json:"fingerprint"`
+	Done        bool                `json:"done"`
+	Status      int                 `json:"status,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
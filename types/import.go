@@ -0,0 +1,55 @@
+package types
+
+type ImportFormat string
+
+const (
+	ImportFormatCSV ImportFormat = "csv"
+	ImportFormatOFX ImportFormat = "ofx"
+	ImportFormatQIF ImportFormat = "qif"
+)
+
+type ImportStatus string
+
+const (
+	ImportStatusPending    ImportStatus = "pending"
+	ImportStatusCommitted  ImportStatus = "committed"
+	ImportStatusRolledBack ImportStatus = "rolled_back"
+)
+
+// ColumnMapping tells the CSV importer which columns hold which fields.
+// Columns are matched by header name, or by zero-based index when the file
+// has no header row. Debit and Credit may be used instead of Amount for
+// statements that split money in and out into separate columns.
+type ColumnMapping struct {
+	Date            string `json:"date"`
+	Amount          string `json:"amount,omitempty"`
+	Debit           string `json:"debit,omitempty"`
+	Credit          string `json:"credit,omitempty"`
+	Description     string `json:"description"`
+	DateFormat      string `json:"dateFormat,omitempty"`
+	Delimiter       string `json:"delimiter,omitempty"`
+	NoHeader        bool   `json:"noHeader,omitempty"`
+	AmountsPositive bool   `json:"amountsPositive,omitempty"`
+}
+
+type ImportBatch struct {
+	ID            string       `json:"id"`
+	UserID        string       `json:"userId"`
+	Format        ImportFormat `json:"format"`
+	Filename      string       `json:"filename"`
+	Status        ImportStatus `json:"status"`
+	RowCount      int          `json:"rowCount"`
+	ImportedCount int          `json:"importedCount"`
+	SkippedCount  int          `json:"skippedCount"`
+	CreatedAt     string       `json:"createdAt"`
+	Rows          []ImportRow  `json:"rows,omitempty"`
+}
+
+type ImportRow struct {
+	Line        int     `json:"line"`
+	Date        string  `json:"date"`
+	Amount      float64 `json:"amount"`
+	Description string  `json:"description"`
+	Duplicate   bool    `json:"duplicate"`
+	DuplicateOf string  `json:"duplicateOf,omitempty"`
+}
@@ -0,0 +1,59 @@
+package types
+
+type IncomeKind string
+
+const (
+	IncomeSalary     IncomeKind = "salary"
+	IncomeTransferIn IncomeKind = "transfer_in"
+	IncomeOther      IncomeKind = "other"
+)
+
+// Income is money received. Source names where it came from, such as an
+// employer or the person who sent a transfer.
+type Income struct {
+	ID          string     `json:"id,omitempty"`
+	UserID      string     `json:"userId,omitempty"`
+	Amount      float64    `json:"amount"`
+	Date        string     `json:"date"`
+	Kind        IncomeKind `json:"kind"`
+	Source      string     `json:"source,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Currency    string     `json:"currency,omitempty"`
+	RecurringID string     `json:"recurringId,omitempty"`
+}
+
+// IncomeUpdate is a partial edit of an income entry; nil fields are
+// unchanged.
+type IncomeUpdate struct {
+	Amount      *float64    `json:"amount,omitempty"`
+	Date        *string     `json:"date,omitempty"`
+	Kind        *IncomeKind `json:"kind,omitempty"`
+	Source      *string     `json:"source,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	Currency    *string     `json:"currency,omitempty"`
+}
+
+// CashFlowPoint is income against spending for one period, in the user's
+// home currency. SavingsRate is Net as a percentage of Income and is null
+// for periods without income. Balance is the running total of Net,
+// starting from everything recorded before the report.
+type CashFlowPoint struct {
+	Period      string   `json:"period"`
+	Income      float64  `json:"income"`
+	Expenses    float64  `json:"expenses"`
+	Net         float64  `json:"net"`
+	SavingsRate *float64 `json:"savingsRate"`
+	Balance     float64  `json:"balance"`
+	Unconverted int      `json:"unconverted,omitempty"`
+}
+
+type CashFlowReport struct {
+	Currency       string          `json:"currency"`
+	Interval       string          `json:"interval"`
+	OpeningBalance float64         `json:"openingBalance"`
+	Income         float64         `json:"income"`
+	Expenses       float64         `json:"expenses"`
+	Net            float64         `json:"net"`
+	SavingsRate    *float64        `json:"savingsRate"`
+	Periods        []CashFlowPoint `json:"periods"`
+}
@@ -0,0 +1,68 @@
+package types
+
+// LedgerAccountKind says what a double-entry ledger account holds. Wallets
+// belong to a user; the other kinds are system accounts, one per currency.
+type LedgerAccountKind string
+
+const (
+	AccountWallet        LedgerAccountKind = "wallet"
+	AccountFees          LedgerAccountKind = "fees"
+	AccountSuspense      LedgerAccountKind = "suspense"
+	AccountProviderFloat LedgerAccountKind = "provider_float"
+)
+
+// LedgerAccount is one account of the double-entry ledger. Balance is in
+// minor units of Currency, such as cents. Wallets can never go below zero;
+// system accounts can, since the provider float mirrors money held
+// elsewhere.
+type LedgerAccount struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"userId,omitempty"`
+	Kind      LedgerAccountKind `json:"kind"`
+	Currency  string            `json:"currency"`
+	Balance   int64             `json:"balance"`
+	CreatedAt string            `json:"createdAt,omitempty"`
+}
+
+// Posting moves Amount minor units into an account, or out of it when
+// negative. BalanceAfter is the account's balance once the posting was
+// applied.
+type Posting struct {
+	AccountID    string `json:"accountId"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	BalanceAfter int64  `json:"balanceAfter"`
+}
+
+// JournalEntry is an immutable set of postings that sum to zero in each
+// currency. Reference is unique and makes posting the same entry twice
+// fail rather than move money twice.
+type JournalEntry struct {
+	ID          string    `json:"id,omitempty"`
+	Kind        string    `json:"kind"`
+	Reference   string    `json:"reference"`
+	Description string    `json:"description,omitempty"`
+	Postings    []Posting `json:"postings"`
+	CreatedAt   string    `json:"createdAt,omitempty"`
+}
+
+// AccountStatementLine is one posting to an account with the entry it
+// belongs to.
+type AccountStatementLine struct {
+	EntryID      string `json:"entryId"`
+	Kind         string `json:"kind"`
+	Reference    string `json:"reference"`
+	Description  string `json:"description,omitempty"`
+	Amount       int64  `json:"amount"`
+	BalanceAfter int64  `json:"balanceAfter"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// LedgerViolation is a broken ledger invariant found by the checker.
+type LedgerViolation struct {
+	Check     string `json:"check"`
+	AccountID string `json:"accountId,omitempty"`
+	EntryID   string `json:"entryId,omitempty"`
+	Currency  string `json:"currency,omitempty"`
+	Detail    string `json:"detail"`
+}
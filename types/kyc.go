@@ -0,0 +1,69 @@
+package types
+
+// KYCTier is how well we know a user. Higher tiers get higher limits.
+type KYCTier int
+
+const (
+	// KYCTierNone is every new account: only the signup details are known.
+	KYCTierNone KYCTier = 0
+	// KYCTierBasic has a verified identity document.
+	KYCTierBasic KYCTier = 1
+	// KYCTierFull has a verified identity document and proof of address.
+	KYCTierFull KYCTier = 2
+)
+
+type KYCDocumentType string
+
+const (
+	DocumentNationalID     KYCDocumentType = "national_id"
+	DocumentPassport       KYCDocumentType = "passport"
+	DocumentProofOfAddress KYCDocumentType = "proof_of_address"
+)
+
+type KYCStatus string
+
+const (
+	KYCPending  KYCStatus = "pending"
+	KYCApproved KYCStatus = "approved"
+	KYCRejected KYCStatus = "rejected"
+)
+
+// KYCDocument is one uploaded image of a document, such as the front of
+// an ID card.
+type KYCDocument struct {
+	ID          string `json:"id"`
+	Side        string `json:"side"`
+	BlobKey     string `json:"-"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url,omitempty"`
+}
+
+// KYCSubmission asks for a user to be raised to Tier on the strength of
+// the attached documents. Reason explains a rejection.
+type KYCSubmission struct {
+	ID             string          `json:"id"`
+	UserID         string          `json:"userId"`
+	Tier           KYCTier         `json:"tier"`
+	DocumentType   KYCDocumentType `json:"documentType"`
+	DocumentNumber string          `json:"documentNumber,omitempty"`
+	Status         KYCStatus       `json:"status"`
+	Reason         string          `json:"reason,omitempty"`
+	ReviewedBy     string          `json:"reviewedBy,omitempty"`
+	ReviewedAt     string          `json:"reviewedAt,omitempty"`
+	CreatedAt      string          `json:"createdAt"`
+	Documents      []KYCDocument   `json:"documents,omitempty"`
+}
+
+// KYCStatusResponse is a user's current tier and their submissions.
+type KYCStatusResponse struct {
+	Tier        KYCTier         `json:"tier"`
+	Submissions []KYCSubmission `json:"submissions"`
+}
+
+// KYCReview is an admin's decision on a submission. Reason is required to
+// reject one.
+type KYCReview struct {
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,111 @@
+package types
+
+type LedgerRole string
+
+const (
+	LedgerRoleOwner  LedgerRole = "owner"
+	LedgerRoleEditor LedgerRole = "editor"
+	LedgerRoleViewer LedgerRole = "viewer"
+)
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationDeclined InvitationStatus = "declined"
+)
+
+type SplitMethod string
+
+const (
+	SplitEqual      SplitMethod = "equal"
+	SplitPercentage SplitMethod = "percentage"
+	SplitShares     SplitMethod = "shares"
+	SplitExact      SplitMethod = "exact"
+)
+
+// Ledger is a shared book of expenses for a household or group.
+type Ledger struct {
+	ID        string     `json:"id,omitempty"`
+	Name      string     `json:"name"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	CreatedAt string     `json:"createdAt,omitempty"`
+	Role      LedgerRole `json:"role,omitempty"`
+}
+
+type LedgerMember struct {
+	UserID    string     `json:"userId"`
+	FirstName string     `json:"firstName"`
+	LastName  string     `json:"lastName"`
+	Email     string     `json:"email"`
+	Role      LedgerRole `json:"role"`
+	JoinedAt  string     `json:"joinedAt"`
+}
+
+type LedgerInvitation struct {
+	ID         string           `json:"id,omitempty"`
+	LedgerID   string           `json:"ledgerId"`
+	LedgerName string           `json:"ledgerName,omitempty"`
+	Email      string           `json:"email"`
+	Role       LedgerRole       `json:"role"`
+	InvitedBy  string           `json:"invitedBy,omitempty"`
+	Status     InvitationStatus `json:"status,omitempty"`
+	CreatedAt  string           `json:"createdAt,omitempty"`
+}
+
+// Split is one member's part of a shared expense. Value is read according to
+// the expense's split method: a percentage, a number of shares or an exact
+// amount, and is ignored for equal splits. Amount is what the member owes.
+type Split struct {
+	UserID string  `json:"userId"`
+	Value  float64 `json:"value,omitempty"`
+	Amount float64 `json:"amount"`
+}
+
+type LedgerExpense struct {
+	ID          string      `json:"id,omitempty"`
+	LedgerID    string      `json:"ledgerId"`
+	PaidBy      string      `json:"paidBy"`
+	Amount      float64     `json:"amount"`
+	Date        string      `json:"date"`
+	Description string      `json:"description"`
+	Category    string      `json:"category,omitempty"`
+	SplitMethod SplitMethod `json:"splitMethod"`
+	Splits      []Split     `json:"splits"`
+	CreatedBy   string      `json:"createdBy,omitempty"`
+}
+
+// Settlement records money paid directly from one member to another to
+// square up.
+type Settlement struct {
+	ID        string  `json:"id,omitempty"`
+	LedgerID  string  `json:"ledgerId"`
+	FromUser  string  `json:"fromUser"`
+	ToUser    string  `json:"toUser"`
+	Amount    float64 `json:"amount"`
+	Date      string  `json:"date"`
+	CreatedBy string  `json:"createdBy,omitempty"`
+}
+
+// LedgerBalance is a member's position in a ledger. A positive Net means the
+// member is owed money; a negative one means they owe it.
+type LedgerBalance struct {
+	UserID    string  `json:"userId"`
+	FirstName string  `json:"firstName"`
+	LastName  string  `json:"lastName"`
+	Paid      float64 `json:"paid"`
+	Owed      float64 `json:"owed"`
+	Net       float64 `json:"net"`
+}
+
+type Transfer struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+type LedgerBalances struct {
+	Balances []LedgerBalance `json:"balances"`
+	SettleUp []Transfer      `json:"settleUp"`
+}
@@ -0,0 +1,85 @@
+package types
+
+// LimitAction is the kind of outgoing money movement a limit applies to.
+type LimitAction string
+
+const (
+	LimitTransfer   LimitAction = "transfer"
+	LimitWithdrawal LimitAction = "withdrawal"
+)
+
+// LimitMetric is what a limit rule measures.
+type LimitMetric string
+
+const (
+	// LimitSingle caps the amount of one transaction.
+	LimitSingle LimitMetric = "single_amount"
+	// LimitDaily caps the total sent since the start of the user's day.
+	LimitDaily LimitMetric = "daily_amount"
+	// LimitMonthly caps the total sent since the start of the user's month.
+	LimitMonthly LimitMetric = "monthly_amount"
+	// LimitHourlyCount caps how many transactions are made in an hour.
+	LimitHourlyCount LimitMetric = "hourly_count"
+	// LimitNewRecipient caps the amount of a transfer to someone the user
+	// has never paid before.
+	LimitNewRecipient LimitMetric = "new_recipient_amount"
+)
+
+// LimitRule is one configurable limit for users of a KYC tier. An empty
+// Action applies to transfers and withdrawals together. Amount rules are in
+// minor units of Currency and only apply to transactions in it; count
+// rules have no currency. A tier with amount rules refuses transactions in
+// a currency none of them is in.
+type LimitRule struct {
+	ID        string      `json:"id,omitempty"`
+	Tier      KYCTier     `json:"tier"`
+	Action    LimitAction `json:"action,omitempty"`
+	Metric    LimitMetric `json:"metric"`
+	Currency  string      `json:"currency,omitempty"`
+	Value     int64       `json:"value"`
+	UpdatedAt string      `json:"updatedAt,omitempty"`
+}
+
+// LimitAttempt is an outgoing transaction about to be checked against the
+// limits. RecipientID is empty for withdrawals.
+type LimitAttempt struct {
+	UserID      string      `json:"userId"`
+	Tier        KYCTier     `json:"tier"`
+	Action      LimitAction `json:"action"`
+	Amount      int64       `json:"amount"`
+	Currency    string      `json:"currency"`
+	RecipientID string      `json:"recipientId,omitempty"`
+}
+
+// LimitWindow is what a user already sent of one kind and currency in the
+// current day and month, and how many transactions of that kind they made
+// in the last hour in any currency.
+type LimitWindow struct {
+	Day      int64 `json:"day"`
+	Month    int64 `json:"month"`
+	LastHour int   `json:"lastHour"`
+}
+
+// LimitUsage is the activity a LimitAttempt is checked against.
+type LimitUsage struct {
+	Transfers      LimitWindow `json:"transfers"`
+	Withdrawals    LimitWindow `json:"withdrawals"`
+	KnownRecipient bool        `json:"knownRecipient"`
+}
+
+// LimitViolation explains one rule a transaction broke. Code is stable
+// for clients to act on.
+type LimitViolation struct {
+	Code    string      `json:"code"`
+	RuleID  string      `json:"ruleId,omitempty"`
+	Metric  LimitMetric `json:"metric"`
+	Limit   int64       `json:"limit"`
+	Current int64       `json:"current"`
+	Message string      `json:"message"`
+}
+
+type LimitDecision struct {
+	Allowed    bool             `json:"allowed"`
+	Tier       KYCTier          `json:"tier"`
+	Violations []LimitViolation `json:"violations,omitempty"`
+}
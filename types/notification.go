@@ -0,0 +1,18 @@
+package types
+
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+type Notification struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"-"`
+	Kind      string            `json:"kind"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Data      map[string]string `json:"data,omitempty"`
+	Read      bool              `json:"read"`
+	CreatedAt string            `json:"createdAt"`
+}
@@ -0,0 +1,46 @@
+package types
+
+type PaymentKind string
+
+const (
+	PaymentTopUp      PaymentKind = "topup"
+	PaymentWithdrawal PaymentKind = "withdrawal"
+)
+
+// PaymentStatus is where a payment is in its lifecycle: pending until the
+// provider accepts it, processing while the provider works on it, then
+// succeeded or failed.
+type PaymentStatus string
+
+const (
+	PaymentPending    PaymentStatus = "pending"
+	PaymentProcessing PaymentStatus = "processing"
+	PaymentSucceeded  PaymentStatus = "succeeded"
+	PaymentFailed     PaymentStatus = "failed"
+)
+
+// PaymentRequest asks to top up a wallet from, or withdraw it to, a mobile
+// money number. Amount is in minor units; Phone defaults to the user's own
+// number.
+type PaymentRequest struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+}
+
+// Payment is money moving between a wallet and an external payment
+// provider. Amount is in minor units of Currency.
+type Payment struct {
+	ID            string        `json:"id"`
+	UserID        string        `json:"userId"`
+	Kind          PaymentKind   `json:"kind"`
+	Amount        int64         `json:"amount"`
+	Currency      string        `json:"currency"`
+	Phone         string        `json:"phone"`
+	Provider      string        `json:"provider"`
+	ProviderRef   string        `json:"providerRef,omitempty"`
+	Status        PaymentStatus `json:"status"`
+	FailureReason string        `json:"failureReason,omitempty"`
+	CreatedAt     string        `json:"createdAt"`
+	UpdatedAt     string        `json:"updatedAt"`
+}
@@ -0,0 +1,17 @@
+package types
+
+// PINRequest sets, changes or resets a transaction PIN. Changing it needs
+// CurrentPIN; resetting a forgotten one needs the login Password instead.
+type PINRequest struct {
+	PIN        string `json:"pin"`
+	CurrentPIN string `json:"currentPin,omitempty"`
+	Password   string `json:"password,omitempty"`
+}
+
+// PINStatus tells a user whether they have a transaction PIN and whether
+// it is locked after too many wrong attempts.
+type PINStatus struct {
+	Set          bool   `json:"set"`
+	AttemptsLeft int    `json:"attemptsLeft"`
+	LockedUntil  string `json:"lockedUntil,omitempty"`
+}
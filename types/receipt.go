@@ -0,0 +1,13 @@
+package types
+
+type Receipt struct {
+	ID          string `json:"id"`
+	ExpenseID   string `json:"expenseId"`
+	UserID      string `json:"-"`
+	BlobKey     string `json:"-"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	CreatedAt   string `json:"createdAt"`
+	URL         string `json:"url,omitempty"`
+}
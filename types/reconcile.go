@@ -0,0 +1,80 @@
+package types
+
+// SettlementRow is one payment in a provider's settlement report. Amount
+// is in minor units. Reference is the provider's ID for the payment and
+// OurReference, when the provider echoes it, our payment ID.
+type SettlementRow struct {
+	Line         int           `json:"line"`
+	Reference    string        `json:"reference"`
+	OurReference string        `json:"ourReference,omitempty"`
+	Amount       int64         `json:"amount"`
+	Currency     string        `json:"currency"`
+	Status       PaymentStatus `json:"status"`
+}
+
+// DiscrepancyKind names what reconciliation found wrong with a payment.
+type DiscrepancyKind string
+
+const (
+	// DiscrepancyMissingHere is in the report but not in our records.
+	DiscrepancyMissingHere DiscrepancyKind = "missing_in_records"
+	// DiscrepancyMissingThere is in our records but not in the report.
+	DiscrepancyMissingThere DiscrepancyKind = "missing_in_report"
+	DiscrepancyDuplicate    DiscrepancyKind = "duplicate"
+	DiscrepancyAmount       DiscrepancyKind = "amount_mismatch"
+	DiscrepancyStatus       DiscrepancyKind = "status_mismatch"
+	// DiscrepancyStuck is a payment we still have open that the provider
+	// has settled, typically because its callback was lost.
+	DiscrepancyStuck DiscrepancyKind = "stuck"
+)
+
+type ReconciliationResolution string
+
+const (
+	ResolutionOpen         ReconciliationResolution = "open"
+	ResolutionAutoResolved ReconciliationResolution = "auto_resolved"
+	ResolutionResolved     ReconciliationResolution = "resolved"
+	ResolutionDismissed    ReconciliationResolution = "dismissed"
+)
+
+// ReconciliationItem is one discrepancy found in a run. Open items make up
+// the manual review queue.
+type ReconciliationItem struct {
+	ID             string                   `json:"id,omitempty"`
+	RunID          string                   `json:"runId,omitempty"`
+	Kind           DiscrepancyKind          `json:"kind"`
+	PaymentID      string                   `json:"paymentId,omitempty"`
+	ProviderRef    string                   `json:"providerRef,omitempty"`
+	Line           int                      `json:"line,omitempty"`
+	Currency       string                   `json:"currency,omitempty"`
+	ExpectedAmount int64                    `json:"expectedAmount"`
+	ReportedAmount int64                    `json:"reportedAmount"`
+	OurStatus      PaymentStatus            `json:"ourStatus,omitempty"`
+	ReportedStatus PaymentStatus            `json:"reportedStatus,omitempty"`
+	Resolution     ReconciliationResolution `json:"resolution"`
+	Note           string                   `json:"note,omitempty"`
+	ResolvedBy     string                   `json:"resolvedBy,omitempty"`
+	ResolvedAt     string                   `json:"resolvedAt,omitempty"`
+}
+
+// ReconciliationRun is one settlement report checked against our payments
+// made through Provider between From and To.
+type ReconciliationRun struct {
+	ID        string               `json:"id"`
+	Provider  string               `json:"provider"`
+	Filename  string               `json:"filename"`
+	From      string               `json:"from"`
+	To        string               `json:"to"`
+	RowCount  int                  `json:"rowCount"`
+	Matched   int                  `json:"matched"`
+	CreatedBy string               `json:"createdBy"`
+	CreatedAt string               `json:"createdAt"`
+	Items     []ReconciliationItem `json:"items,omitempty"`
+}
+
+// ReconciliationAction is how an admin closes a review item: settle the
+// payment as succeeded or failed, or dismiss the item as needing nothing.
+type ReconciliationAction struct {
+	Action string `json:"action"`
+	Note   string `json:"note,omitempty"`
+}
@@ -0,0 +1,57 @@
+package types
+
+type Frequency string
+
+// RecurringType says whether a recurring template generates expenses or
+// income.
+type RecurringType string
+
+const (
+	RecurringExpenseType RecurringType = "expense"
+	RecurringIncomeType  RecurringType = "income"
+)
+
+const (
+	FrequencyDaily   Frequency = "daily"
+	FrequencyWeekly  Frequency = "weekly"
+	FrequencyMonthly Frequency = "monthly"
+	FrequencyYearly  Frequency = "yearly"
+)
+
+// RecurringExpense is a template from which concrete expenses are generated.
+// Weekly schedules repeat on the weekday of StartDate, monthly schedules on
+// DayOfMonth (or the start day when zero) and yearly schedules on the start
+// month and day. A schedule ends at EndDate or after Count occurrences,
+// whichever comes first.
+//
+// Templates of type income generate income entries of IncomeKind from
+// Source instead; Category and PaymentMethod are unused for them.
+type RecurringExpense struct {
+	ID            string        `json:"id,omitempty"`
+	UserID        string        `json:"userId,omitempty"`
+	Type          RecurringType `json:"type"`
+	IncomeKind    IncomeKind    `json:"incomeKind,omitempty"`
+	Source        string        `json:"source,omitempty"`
+	Amount        float64       `json:"amount"`
+	Description   string        `json:"description"`
+	Category      string        `json:"category,omitempty"`
+	PaymentMethod string        `json:"paymentMethod,omitempty"`
+	Frequency     Frequency     `json:"frequency"`
+	Interval      int           `json:"interval"`
+	DayOfMonth    int           `json:"dayOfMonth,omitempty"`
+	StartDate     string        `json:"startDate"`
+	EndDate       string        `json:"endDate,omitempty"`
+	Count         int           `json:"count,omitempty"`
+	LastGenerated string        `json:"lastGenerated,omitempty"`
+	Active        bool          `json:"active"`
+}
+
+// RecurringException overrides a single occurrence of a recurring expense,
+// either skipping it or replacing its amount or description.
+type RecurringException struct {
+	RecurringID    string   `json:"recurringId"`
+	OccurrenceDate string   `json:"occurrenceDate"`
+	Skip           bool     `json:"skip"`
+	Amount         *float64 `json:"amount,omitempty"`
+	Description    *string  `json:"description,omitempty"`
+}
@@ -0,0 +1,66 @@
+package types
+
+type MoneyRequestKind string
+
+const (
+	MoneyRequestPlain   MoneyRequestKind = "request"
+	MoneyRequestInvoice MoneyRequestKind = "invoice"
+)
+
+type MoneyRequestStatus string
+
+const (
+	MoneyRequestPending  MoneyRequestStatus = "pending"
+	MoneyRequestPaid     MoneyRequestStatus = "paid"
+	MoneyRequestDeclined MoneyRequestStatus = "declined"
+	MoneyRequestExpired  MoneyRequestStatus = "expired"
+)
+
+// InvoiceItem is one line of an invoice. UnitAmount is in minor units of
+// the invoice's currency.
+type InvoiceItem struct {
+	Description string `json:"description"`
+	Quantity    int64  `json:"quantity"`
+	UnitAmount  int64  `json:"unitAmount"`
+}
+
+// MoneyRequestInput asks the user with the phone number or email in To for
+// money. A plain request gives Amount; an invoice lists Items, whose total
+// becomes the amount, and must have a DueDate.
+type MoneyRequestInput struct {
+	Kind     MoneyRequestKind `json:"kind,omitempty"`
+	To       string           `json:"to"`
+	Amount   int64            `json:"amount,omitempty"`
+	Currency string           `json:"currency,omitempty"`
+	Note     string           `json:"note,omitempty"`
+	Items    []InvoiceItem    `json:"items,omitempty"`
+	DueDate  string           `json:"dueDate,omitempty"`
+}
+
+// MoneyRequest asks PayerID to pay RequesterID. Approving it creates the
+// transfer in TransferID. Direction is "sent" for the requester and
+// "received" for the payer. A pending request expires at ExpiresAt.
+type MoneyRequest struct {
+	ID          string             `json:"id"`
+	Kind        MoneyRequestKind   `json:"kind"`
+	RequesterID string             `json:"requesterId"`
+	PayerID     string             `json:"payerId"`
+	Payer       string             `json:"payer"`
+	Amount      int64              `json:"amount"`
+	Currency    string             `json:"currency"`
+	Note        string             `json:"note,omitempty"`
+	Items       []InvoiceItem      `json:"items,omitempty"`
+	DueDate     string             `json:"dueDate,omitempty"`
+	ExpiresAt   string             `json:"expiresAt"`
+	Status      MoneyRequestStatus `json:"status"`
+	TransferID  string             `json:"transferId,omitempty"`
+	Direction   string             `json:"direction,omitempty"`
+	CreatedAt   string             `json:"createdAt"`
+	RespondedAt string             `json:"respondedAt,omitempty"`
+}
+
+// MoneyRequestReminder is a reminder due to be sent to a request's payer.
+type MoneyRequestReminder struct {
+	Request  MoneyRequest `json:"request"`
+	DaysLeft int          `json:"daysLeft"`
+}
@@ -0,0 +1,54 @@
+package types
+
+// RuleConditions must all hold for a rule to match. Empty conditions are
+// ignored; text comparisons ignore case.
+type RuleConditions struct {
+	DescriptionContains string   `json:"descriptionContains,omitempty"`
+	DescriptionRegex    string   `json:"descriptionRegex,omitempty"`
+	MinAmount           *float64 `json:"minAmount,omitempty"`
+	MaxAmount           *float64 `json:"maxAmount,omitempty"`
+	PaymentMethod       string   `json:"paymentMethod,omitempty"`
+}
+
+type RuleActions struct {
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Payee    string   `json:"payee,omitempty"`
+}
+
+// CategorizationRule fills in an expense's category, tags or payee when its
+// conditions match. Rules run in ascending priority order.
+type CategorizationRule struct {
+	ID         string         `json:"id,omitempty"`
+	UserID     string         `json:"-"`
+	Name       string         `json:"name"`
+	Priority   int            `json:"priority"`
+	Active     bool           `json:"active"`
+	Conditions RuleConditions `json:"conditions"`
+	Actions    RuleActions    `json:"actions"`
+	CreatedAt  string         `json:"createdAt,omitempty"`
+}
+
+type Classification struct {
+	Category string   `json:"category"`
+	Payee    string   `json:"payee"`
+	Tags     []string `json:"tags"`
+}
+
+// RuleChange describes how re-applying rules changes one expense.
+type RuleChange struct {
+	ExpenseID   string         `json:"expenseId"`
+	Date        string         `json:"date"`
+	Description string         `json:"description"`
+	Amount      float64        `json:"amount"`
+	Before      Classification `json:"before"`
+	After       Classification `json:"after"`
+	RuleIDs     []string       `json:"ruleIds"`
+}
+
+type RuleRun struct {
+	DryRun  bool         `json:"dryRun"`
+	Scanned int          `json:"scanned"`
+	Changed int          `json:"changed"`
+	Changes []RuleChange `json:"changes"`
+}
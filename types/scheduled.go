@@ -0,0 +1,77 @@
+package types
+
+type ScheduledTransferStatus string
+
+const (
+	ScheduleActive    ScheduledTransferStatus = "active"
+	ScheduleCompleted ScheduledTransferStatus = "completed"
+	ScheduleCancelled ScheduledTransferStatus = "cancelled"
+	ScheduleFailed    ScheduledTransferStatus = "failed"
+)
+
+type ScheduledRunStatus string
+
+const (
+	RunSucceeded ScheduledRunStatus = "succeeded"
+	RunFailed    ScheduledRunStatus = "failed"
+)
+
+// ScheduledTransferInput asks to send Amount minor units of Currency to
+// the user with the phone number or email in To, first at StartAt (RFC
+// 3339) and then on the schedule given by Frequency, Interval, DayOfMonth,
+// EndDate and Count, as for recurring expenses. Without a Frequency the
+// transfer runs once.
+type ScheduledTransferInput struct {
+	To         string    `json:"to"`
+	Amount     int64     `json:"amount"`
+	Currency   string    `json:"currency,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	StartAt    string    `json:"startAt"`
+	Frequency  Frequency `json:"frequency,omitempty"`
+	Interval   int       `json:"interval,omitempty"`
+	DayOfMonth int       `json:"dayOfMonth,omitempty"`
+	EndDate    string    `json:"endDate,omitempty"`
+	Count      int       `json:"count,omitempty"`
+}
+
+// ScheduledTransfer is a transfer the worker sends on the sender's behalf.
+// NextRunAt is when the current occurrence fell due; after a failed run it
+// stays put while RetryAt says when the occurrence is tried again.
+// Occurrences fall on dates in Timezone, the sender's at creation.
+type ScheduledTransfer struct {
+	ID          string                  `json:"id"`
+	SenderID    string                  `json:"senderId"`
+	RecipientID string                  `json:"recipientId"`
+	Recipient   string                  `json:"recipient"`
+	Amount      int64                   `json:"amount"`
+	Currency    string                  `json:"currency"`
+	Note        string                  `json:"note,omitempty"`
+	Frequency   Frequency               `json:"frequency,omitempty"`
+	Interval    int                     `json:"interval,omitempty"`
+	DayOfMonth  int                     `json:"dayOfMonth,omitempty"`
+	EndDate     string                  `json:"endDate,omitempty"`
+	Count       int                     `json:"count,omitempty"`
+	StartAt     string                  `json:"startAt"`
+	Timezone    string                  `json:"timezone"`
+	Status      ScheduledTransferStatus `json:"status"`
+	NextRunAt   string                  `json:"nextRunAt,omitempty"`
+	RetryAt     string                  `json:"retryAt,omitempty"`
+	Attempts    int                     `json:"attempts"`
+	RunCount    int                     `json:"runCount"`
+	LastError   string                  `json:"lastError,omitempty"`
+	CreatedAt   string                  `json:"createdAt"`
+	CancelledAt string                  `json:"cancelledAt,omitempty"`
+	Runs        []ScheduledTransferRun  `json:"runs,omitempty"`
+}
+
+// ScheduledTransferRun is one attempt at one occurrence of a scheduled
+// transfer. A successful run points at the transfer it made.
+type ScheduledTransferRun struct {
+	ID         string             `json:"id"`
+	Occurrence string             `json:"occurrence"`
+	Attempt    int                `json:"attempt"`
+	Status     ScheduledRunStatus `json:"status"`
+	TransferID string             `json:"transferId,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	CreatedAt  string             `json:"createdAt"`
+}
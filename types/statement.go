@@ -0,0 +1,30 @@
+package types
+
+// Statement is the formal record of one wallet over one calendar month in
+// the holder's timezone. Amounts are in minor units of Currency. Once
+// produced, a statement and its PDF and CSV files never change; Checksum
+// is the SHA-256 of the PDF.
+type Statement struct {
+	ID             string `json:"id"`
+	UserID         string `json:"userId"`
+	AccountID      string `json:"accountId"`
+	Currency       string `json:"currency"`
+	Period         string `json:"period"`
+	Timezone       string `json:"timezone"`
+	PeriodStart    string `json:"periodStart"`
+	PeriodEnd      string `json:"periodEnd"`
+	OpeningBalance int64  `json:"openingBalance"`
+	ClosingBalance int64  `json:"closingBalance"`
+	TotalIn        int64  `json:"totalIn"`
+	TotalOut       int64  `json:"totalOut"`
+	LineCount      int    `json:"lineCount"`
+	Checksum       string `json:"checksum"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+// StatementRequest asks for the statement of the wallet in Currency for
+// Period, a closed month written as YYYY-MM.
+type StatementRequest struct {
+	Currency string `json:"currency"`
+	Period   string `json:"period"`
+}
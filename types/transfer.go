@@ -0,0 +1,38 @@
+package types
+
+type TransferStatus string
+
+const (
+	TransferPending   TransferStatus = "pending"
+	TransferCompleted TransferStatus = "completed"
+	TransferFailed    TransferStatus = "failed"
+	TransferReversed  TransferStatus = "reversed"
+)
+
+// WalletTransferRequest asks to send Amount minor units of Currency to the user
+// with the phone number or email in To.
+type WalletTransferRequest struct {
+	To       string `json:"to"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// WalletTransfer moves money from one user's wallet to another's. Amount is in
+// minor units of Currency. Direction is "sent" or "received" from the
+// point of view of the user looking at it.
+type WalletTransfer struct {
+	ID            string         `json:"id"`
+	SenderID      string         `json:"senderId"`
+	RecipientID   string         `json:"recipientId"`
+	Recipient     string         `json:"recipient"`
+	Amount        int64          `json:"amount"`
+	Currency      string         `json:"currency"`
+	Note          string         `json:"note,omitempty"`
+	Status        TransferStatus `json:"status"`
+	FailureReason string         `json:"failureReason,omitempty"`
+	Direction     string         `json:"direction,omitempty"`
+	CreatedAt     string         `json:"createdAt"`
+	CompletedAt   string         `json:"completedAt,omitempty"`
+	ReversedAt    string         `json:"reversedAt,omitempty"`
+}